@@ -15,12 +15,14 @@
 //
 // HTTP signatures are used to authenticate requests between servers. The package
 // handles signing outgoing requests and verifying incoming requests using RSA keys
-// configured in the application.
+// configured in the application. Verifier checks incoming signatures and caches
+// the public keys of remote actors in a KeyCache.
 //
 // Key types:
 //   - Actor: Represents a user or service on the federation
 //   - InboxRequest: Incoming activity from another server
 //   - OutboxItem: Outgoing activity to send to followers
+//   - Annotation: W3C Web Annotation of a highlighted passage
 //
 // Example usage:
 //
@@ -34,16 +36,12 @@ package activitypub
 
 import (
 	"bytes"
-	"crypto"
 	"crypto/rsa"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/asciimoo/omnom/storage"
@@ -56,6 +54,7 @@ const (
 	apRequestTimeout = 10 * time.Second
 	jsonNull         = "null"
 	imageType        = "Image"
+	maxResponseSize  = 1 << 20
 )
 
 // Outbox represents an ActivityPub outbox containing published activities.
@@ -68,6 +67,15 @@ type Outbox struct {
 	OrderedItems []*OutboxItem `json:"orderedItems"`
 }
 
+// Followers represents the followers collection of an actor.
+type Followers struct {
+	Context      string   `json:"@context"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	TotalItems   int64    `json:"totalItems"`
+	OrderedItems []string `json:"orderedItems"`
+}
+
 // OutboxItem represents a single activity in an outbox.
 type OutboxItem struct {
 	Context   any          `json:"@context"`
@@ -233,31 +241,16 @@ type Attachment struct {
 // SendSignedPostRequest sends an HTTP POST request with HTTP signature authentication.
 // The request is signed using the provided RSA private key.
 func SendSignedPostRequest(us, keyID string, data []byte, key *rsa.PrivateKey) error {
-	u, err := url.Parse(us)
-	if err != nil {
-		return err
-	}
-	d := time.Now().UTC().Format(http.TimeFormat)
-	hash := sha256.Sum256(data)
-	digest := fmt.Sprintf("SHA-256=%s", base64.StdEncoding.EncodeToString(hash[:]))
-	sigData := fmt.Appendf(nil, "(request-target): post %s\nhost: %s\ndate: %s\ndigest: %s", u.Path, u.Host, d, digest)
-	sigHash := sha256.Sum256(sigData)
-	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, sigHash[:])
-	if err != nil {
-		return err
-	}
-	sigHeader := fmt.Sprintf(`keyId="%s",headers="(request-target) host date digest",signature="%s",algorithm="rsa-sha256"`, keyID, base64.StdEncoding.EncodeToString(sig))
 	cli := &http.Client{Timeout: apRequestTimeout}
 	req, err := http.NewRequest("POST", us, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Host", u.Host)
-	req.Header.Set("Date", d)
-	req.Header.Set("Digest", digest)
-	req.Header.Set("Signature", sigHeader)
 	req.Header.Set("Content-Type", "application/activity+json; charset=utf-8")
 	req.Header.Set("Accept", "application/activity+json")
+	if err := SignRequest(req, keyID, key, data); err != nil {
+		return err
+	}
 	r, err := cli.Do(req)
 	if err != nil {
 		return err
@@ -335,42 +328,41 @@ func FetchObject(u string) (*InboxRequestObject, error) {
 
 // FetchActor fetches an actor's profile information with HTTP signature authentication.
 func FetchActor(us string, keyID string, key *rsa.PrivateKey) (*Identity, error) {
-	u, err := url.Parse(us)
+	body, err := signedGet(us, keyID, key)
 	if err != nil {
 		return nil, err
 	}
-	c := &http.Client{Timeout: apRequestTimeout}
-	req, err := http.NewRequest("GET", us, nil)
+	i := &Identity{}
+	err = json.Unmarshal(body, i)
 	if err != nil {
 		return nil, err
 	}
-	d := time.Now().UTC().Format(http.TimeFormat)
-	sigData := fmt.Appendf(nil, "(request-target): get %s\nhost: %s\ndate: %s", u.Path, u.Host, d)
-	sigHash := sha256.Sum256(sigData)
-	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, sigHash[:])
+	if i.ID == "" || i.Inbox == "" || i.Outbox == "" {
+		return nil, errors.New("mandatory actor data is missing")
+	}
+	return i, nil
+}
+
+func signedGet(us string, keyID string, key *rsa.PrivateKey) ([]byte, error) {
+	c := &http.Client{Timeout: apRequestTimeout}
+	req, err := http.NewRequest("GET", us, nil)
 	if err != nil {
-		return nil, errors.New("failed to sign data")
+		return nil, err
 	}
-	sigHeader := fmt.Sprintf(`keyId="%s",headers="(request-target) host date",signature="%s",algorithm="rsa-sha256"`, keyID, base64.StdEncoding.EncodeToString(sig))
-	req.Header.Set("Host", u.Host)
-	req.Header.Set("Date", d)
-	req.Header.Set("Signature", sigHeader)
 	req.Header.Set("Content-Type", "application/activity+json; charset=utf-8")
 	req.Header.Set("Accept", "application/activity+json")
-	r, err := c.Do(req)
-	if err != nil {
+	if err := SignRequest(req, keyID, key, nil); err != nil {
 		return nil, err
 	}
-	defer r.Body.Close()
-	i := &Identity{}
-	err = json.NewDecoder(r.Body).Decode(i)
+	r, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	if i.ID == "" || i.Inbox == "" || i.Outbox == "" {
-		return nil, errors.New("mandatory actor data is missing")
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid response status code: %d", r.StatusCode)
 	}
-	return i, nil
+	return io.ReadAll(io.LimitReader(r.Body, maxResponseSize))
 }
 
 // SaveFavicon downloads and saves user favicon as a storage resource.
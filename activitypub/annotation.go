@@ -0,0 +1,59 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package activitypub
+
+// AnnotationContext is the JSON-LD context of the W3C Web Annotation data model.
+const AnnotationContext = "http://www.w3.org/ns/anno.jsonld"
+
+// AnnotationMediaType is the content type of Web Annotation documents.
+const AnnotationMediaType = `application/ld+json; profile="http://www.w3.org/ns/anno.jsonld"`
+
+// Annotation is a W3C Web Annotation of a passage of a web page.
+type Annotation struct {
+	Context    any               `json:"@context,omitempty"`
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Motivation string            `json:"motivation"`
+	Creator    string            `json:"creator"`
+	Created    string            `json:"created"`
+	Body       []*AnnotationBody `json:"body,omitempty"`
+	Target     *AnnotationTarget `json:"target"`
+}
+
+// AnnotationBody is the comment of an annotation.
+type AnnotationBody struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Format  string `json:"format"`
+	Purpose string `json:"purpose"`
+}
+
+// AnnotationTarget identifies the annotated passage of a web page.
+type AnnotationTarget struct {
+	Source   string                `json:"source"`
+	Selector []*AnnotationSelector `json:"selector"`
+}
+
+// AnnotationSelector is a TextQuoteSelector or a TextPositionSelector.
+type AnnotationSelector struct {
+	Type   string `json:"type"`
+	Exact  string `json:"exact,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Start  *int   `json:"start,omitempty"`
+	End    *int   `json:"end,omitempty"`
+}
+
+// AnnotationActivity is a Create activity of an annotation.
+type AnnotationActivity struct {
+	Context   any         `json:"@context"`
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     string      `json:"actor"`
+	To        []string    `json:"to"`
+	Cc        []string    `json:"cc"`
+	Published string      `json:"published"`
+	Object    *Annotation `json:"object"`
+}
@@ -0,0 +1,130 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package activitypub
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const createType = "Create"
+
+// ErrInvalidHandle is returned if a string is not a valid @user@instance handle.
+var ErrInvalidHandle = errors.New("invalid handle")
+
+// ParseHandle splits a @user@instance handle to username and host.
+// The leading @ and the acct: prefix are optional.
+func ParseHandle(h string) (string, string, error) {
+	h = strings.TrimPrefix(strings.TrimSpace(h), "acct:")
+	h = strings.TrimPrefix(h, "@")
+	user, host, ok := strings.Cut(h, "@")
+	if !ok || user == "" || host == "" || strings.ContainsAny(user, "/?#") || strings.ContainsAny(host, "/?#@") {
+		return "", "", ErrInvalidHandle
+	}
+	return user, host, nil
+}
+
+// IsHandle reports whether s looks like a @user@instance handle.
+func IsHandle(s string) bool {
+	if !strings.HasPrefix(s, "@") && !strings.HasPrefix(s, "acct:") {
+		return false
+	}
+	_, _, err := ParseHandle(s)
+	return err == nil
+}
+
+// ResolveHandle discovers the actor URL of a @user@instance handle using WebFinger.
+func ResolveHandle(h string) (string, error) {
+	return resolveHandle(h, "https")
+}
+
+func resolveHandle(h, scheme string) (string, error) {
+	user, host, err := ParseHandle(h)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("resource", fmt.Sprintf("acct:%s@%s", user, host))
+	u := fmt.Sprintf("%s://%s/.well-known/webfinger?%s", scheme, host, q.Encode())
+	c := &http.Client{Timeout: apRequestTimeout}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/jrd+json, application/json")
+	r, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid WebFinger response status code: %d", r.StatusCode)
+	}
+	wf := &Webfinger{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxResponseSize)).Decode(wf); err != nil {
+		return "", err
+	}
+	for _, l := range wf.Links {
+		if l.Rel == "self" && isActivityPubType(l.Type) && l.Href != "" {
+			return l.Href, nil
+		}
+	}
+	return "", errors.New("no ActivityPub actor found")
+}
+
+// FetchOutboxItems fetches the latest Create activities of an outbox.
+// Paginated outboxes are resolved by fetching their first page.
+func FetchOutboxItems(us, keyID string, key *rsa.PrivateKey, limit int) ([]*InboxRequest, error) {
+	body, err := signedGet(us, keyID, key)
+	if err != nil {
+		return nil, err
+	}
+	var o struct {
+		First        json.RawMessage   `json:"first"`
+		OrderedItems []json.RawMessage `json:"orderedItems"`
+	}
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, err
+	}
+	if len(o.OrderedItems) == 0 && len(o.First) > 0 {
+		var first string
+		if json.Unmarshal(o.First, &first) == nil {
+			body, err = signedGet(first, keyID, key)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			body = o.First
+		}
+		if err := json.Unmarshal(body, &o); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]*InboxRequest, 0, limit)
+	for _, raw := range o.OrderedItems {
+		if len(items) >= limit {
+			break
+		}
+		i := &InboxRequest{}
+		if err := json.Unmarshal(raw, i); err != nil {
+			continue
+		}
+		// skip other activities and non-embedded objects (e.g. boosts)
+		if i.Type == createType && i.Object != nil && i.Object.Content != "" {
+			items = append(items, i)
+		}
+	}
+	return items, nil
+}
+
+func isActivityPubType(t string) bool {
+	return t == "application/activity+json" || strings.HasPrefix(t, "application/ld+json")
+}
@@ -0,0 +1,99 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInstance(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/.well-known/webfinger", func(w http.ResponseWriter, r *http.Request) {
+		host := strings.TrimPrefix(srv.URL, "http://")
+		if r.URL.Query().Get("resource") != "acct:alice@"+host {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&Webfinger{
+			Subject: "acct:alice@" + host,
+			Links: []Link{
+				{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: srv.URL + "/@alice"},
+				{Rel: "self", Type: "application/activity+json", Href: srv.URL + "/users/alice"},
+			},
+		})
+	})
+	mux.HandleFunc("/users/alice/outbox", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Signature") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			_, _ = w.Write([]byte(`{"type":"OrderedCollection","first":"` + srv.URL + `/users/alice/outbox?page=true"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"OrderedCollectionPage","orderedItems":[
+			{"type":"Announce","actor":"` + srv.URL + `/users/alice","object":"https://other.example/notes/1"},
+			{"type":"Create","actor":"` + srv.URL + `/users/alice","published":"2025-01-01T00:00:00Z","object":{"id":"` + srv.URL + `/notes/2","type":"Note","content":"<p>hello</p>"}},
+			{"type":"Create","actor":"` + srv.URL + `/users/alice","object":{"id":"` + srv.URL + `/notes/3","type":"Note","content":"<p>world</p>"}}
+		]}`))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseHandle(t *testing.T) {
+	for _, h := range []string{"@alice@example.com", "alice@example.com", "acct:alice@example.com"} {
+		user, host, err := ParseHandle(h)
+		assert.Nil(t, err)
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "example.com", host)
+	}
+	for _, h := range []string{"", "@alice", "https://example.com/@alice", "@alice@example.com/x"} {
+		_, _, err := ParseHandle(h)
+		assert.ErrorIs(t, err, ErrInvalidHandle)
+	}
+	assert.True(t, IsHandle("@alice@example.com"))
+	assert.False(t, IsHandle("alice@example.com"))
+}
+
+func TestResolveHandle(t *testing.T) {
+	srv := newTestInstance(t)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	u, err := resolveHandle("@alice@"+host, "http")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, srv.URL+"/users/alice", u)
+
+	_, err = resolveHandle("@bob@"+host, "http")
+	assert.NotNil(t, err)
+}
+
+func TestFetchOutboxItems(t *testing.T) {
+	srv := newTestInstance(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(t, err) {
+		return
+	}
+	items, err := FetchOutboxItems(srv.URL+"/users/alice/outbox", "https://test.com/users/test#key", key, 5)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Len(t, items, 2) {
+		return
+	}
+	assert.Equal(t, "<p>hello</p>", items[0].Object.Content)
+	assert.Equal(t, "2025-01-01T00:00:00Z", items[0].Published)
+
+	items, err = FetchOutboxItems(srv.URL+"/users/alice/outbox", "https://test.com/users/test#key", key, 1)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+}
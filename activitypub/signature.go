@@ -0,0 +1,500 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AlgorithmHS2019 is the algorithm identifier of the current draft-cavage
+	// HTTP Signatures specification. The actual algorithm is derived from the key.
+	AlgorithmHS2019 = "hs2019"
+	// AlgorithmRSASHA256 is the legacy RSASSA-PKCS1-v1_5 with SHA-256 algorithm identifier.
+	AlgorithmRSASHA256 = "rsa-sha256"
+
+	// DefaultMaxClockSkew is the default maximum difference between the
+	// signature creation time and the local clock.
+	DefaultMaxClockSkew = time.Hour
+	// DefaultKeyCacheTTL is the default lifetime of cached actor keys.
+	DefaultKeyCacheTTL = 24 * time.Hour
+	// DefaultKeyCacheSize is the default maximum number of cached actor keys.
+	DefaultKeyCacheSize = 10000
+	// DefaultKeyRefetchInterval is the default minimum time between two
+	// fetches of the same key.
+	DefaultKeyRefetchInterval = time.Minute
+
+	headerRequestTarget = "(request-target)"
+	headerCreated       = "(created)"
+	headerExpires       = "(expires)"
+)
+
+var (
+	// ErrMissingSignature is returned if the request has no signature.
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrInvalidSignature is returned if the signature does not match.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Signature represents a parsed draft-cavage HTTP Signature.
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+	Created   int64
+	Expires   int64
+}
+
+// ParseSignatureHeader parses the value of a Signature header.
+// The value of an "Authorization: Signature ..." header is accepted as well.
+// Parameters can appear in any order.
+func ParseSignatureHeader(h string) (*Signature, error) {
+	h = strings.TrimSpace(h)
+	if p, ok := strings.CutPrefix(h, "Signature "); ok {
+		h = p
+	}
+	params := make(map[string]string, 6)
+	for h != "" {
+		k, rest, ok := strings.Cut(h, "=")
+		if !ok {
+			return nil, errors.New("invalid signature parameter: " + h)
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		rest = strings.TrimSpace(rest)
+		var v string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated signature parameter: " + k)
+			}
+			v = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			v, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		if _, found := params[k]; found {
+			return nil, errors.New("duplicated signature parameter: " + k)
+		}
+		params[k] = v
+		rest = strings.TrimSpace(rest)
+		if rest != "" && rest[0] != ',' {
+			return nil, errors.New("invalid signature parameter separator")
+		}
+		h = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+	s := &Signature{
+		KeyID:     params["keyid"],
+		Algorithm: strings.ToLower(params["algorithm"]),
+		Headers:   strings.Fields(strings.ToLower(params["headers"])),
+	}
+	if s.KeyID == "" {
+		return nil, errors.New("missing keyId")
+	}
+	if params["signature"] == "" {
+		return nil, errors.New("missing signature")
+	}
+	var err error
+	s.Signature, err = base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(s.Headers) == 0 {
+		s.Headers = []string{"date"}
+	}
+	for _, n := range []string{"created", "expires"} {
+		if params[n] == "" {
+			continue
+		}
+		t, err := strconv.ParseInt(params[n], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter: %w", n, err)
+		}
+		if n == "created" {
+			s.Created = t
+		} else {
+			s.Expires = t
+		}
+	}
+	return s, nil
+}
+
+// SigningString constructs the string covered by the signature.
+// The value of the host header is taken from host if it is not empty,
+// because the request can be modified by reverse proxies.
+func (s *Signature) SigningString(r *http.Request, host string) (string, error) {
+	lines := make([]string, len(s.Headers))
+	for i, h := range s.Headers {
+		var v string
+		switch h {
+		case headerRequestTarget:
+			v = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case headerCreated:
+			if s.Created == 0 || s.Algorithm == AlgorithmRSASHA256 {
+				return "", errors.New("invalid (created) header")
+			}
+			v = strconv.FormatInt(s.Created, 10)
+		case headerExpires:
+			if s.Expires == 0 || s.Algorithm == AlgorithmRSASHA256 {
+				return "", errors.New("invalid (expires) header")
+			}
+			v = strconv.FormatInt(s.Expires, 10)
+		case "host":
+			v = host
+			if v == "" {
+				v = r.Host
+			}
+		default:
+			v = strings.Join(r.Header.Values(h), ", ")
+		}
+		lines[i] = h + ": " + v
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Verify checks the signature of the signing string against the public key.
+func (s *Signature) Verify(pk *rsa.PublicKey, signingString string) error {
+	switch s.Algorithm {
+	case AlgorithmRSASHA256:
+		h := sha256.Sum256([]byte(signingString))
+		if rsa.VerifyPKCS1v15(pk, crypto.SHA256, h[:], s.Signature) != nil {
+			return ErrInvalidSignature
+		}
+	case AlgorithmHS2019, "":
+		// hs2019 doesn't define the digest algorithm, RSA keys are used
+		// with SHA-256 by the major implementations and with SHA-512 (PSS)
+		// by the specification's examples.
+		h := sha256.Sum256([]byte(signingString))
+		if rsa.VerifyPKCS1v15(pk, crypto.SHA256, h[:], s.Signature) == nil {
+			return nil
+		}
+		h512 := sha512.Sum512([]byte(signingString))
+		if rsa.VerifyPSS(pk, crypto.SHA512, h512[:], s.Signature, nil) != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return errors.New("unsupported signature algorithm: " + s.Algorithm)
+	}
+	return nil
+}
+
+// SignRequest adds Date, Host, Digest (if body is not nil) and Signature
+// headers to an outgoing request.
+func SignRequest(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("Host", r.URL.Host)
+	s := &Signature{
+		KeyID:     keyID,
+		Algorithm: AlgorithmRSASHA256,
+		Headers:   []string{headerRequestTarget, "host", "date"},
+	}
+	if body != nil {
+		r.Header.Set("Digest", Digest(body))
+		s.Headers = append(s.Headers, "digest")
+	}
+	ss, err := s.SigningString(r, r.URL.Host)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256([]byte(ss))
+	s.Signature, err = rsa.SignPKCS1v15(nil, key, crypto.SHA256, h[:])
+	if err != nil {
+		return errors.New("failed to sign data")
+	}
+	r.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",headers="%s",signature="%s",algorithm="%s"`,
+		s.KeyID,
+		strings.Join(s.Headers, " "),
+		base64.StdEncoding.EncodeToString(s.Signature),
+		s.Algorithm,
+	))
+	return nil
+}
+
+// Digest returns the value of the Digest header of a payload.
+func Digest(body []byte) string {
+	h := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(h[:])
+}
+
+// KeyFetcher retrieves the public key identified by keyID.
+type KeyFetcher func(keyID string) (*PubKey, error)
+
+type cachedKey struct {
+	owner   string
+	key     *rsa.PublicKey
+	fetched time.Time
+	used    time.Time
+	expires time.Time
+}
+
+// KeyCache caches the public keys of remote actors.
+// The least recently used keys are evicted if the cache is full.
+type KeyCache struct {
+	TTL time.Duration
+	// Size is the maximum number of cached keys.
+	Size int
+	// RefetchInterval is the minimum time between two fetches of a key,
+	// refresh requests within the interval return the cached key.
+	RefetchInterval time.Duration
+	mu              sync.Mutex
+	keys            map[string]*cachedKey
+}
+
+// NewKeyCache creates an empty key cache.
+func NewKeyCache() *KeyCache {
+	return &KeyCache{
+		TTL:             DefaultKeyCacheTTL,
+		Size:            DefaultKeyCacheSize,
+		RefetchInterval: DefaultKeyRefetchInterval,
+		keys:            make(map[string]*cachedKey),
+	}
+}
+
+// Get returns the public key and its owner identified by keyID.
+// The key is retrieved using fetch if refresh is true or the cached key
+// is missing or expired.
+func (kc *KeyCache) Get(keyID string, fetch KeyFetcher, refresh bool) (*rsa.PublicKey, string, error) {
+	now := time.Now()
+	kc.mu.Lock()
+	k, ok := kc.keys[keyID]
+	if ok && now.Before(k.expires) && (!refresh || now.Sub(k.fetched) < kc.RefetchInterval) {
+		k.used = now
+		kc.mu.Unlock()
+		return k.key, k.owner, nil
+	}
+	kc.mu.Unlock()
+	pk, err := fetch(keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	if pk.ID != keyID {
+		return nil, "", errors.New("key ID mismatch")
+	}
+	if !sameOrigin(keyID, pk.Owner) {
+		return nil, "", errors.New("key owner has different origin")
+	}
+	key, err := ParsePublicKey(pk.PublicKeyPem)
+	if err != nil {
+		return nil, "", err
+	}
+	kc.mu.Lock()
+	if _, ok := kc.keys[keyID]; !ok && kc.Size > 0 && len(kc.keys) >= kc.Size {
+		kc.evict(now)
+	}
+	kc.keys[keyID] = &cachedKey{
+		owner:   pk.Owner,
+		key:     key,
+		fetched: now,
+		used:    now,
+		expires: now.Add(kc.TTL),
+	}
+	kc.mu.Unlock()
+	return key, pk.Owner, nil
+}
+
+// evict removes the expired keys or the least recently used key if none of
+// them is expired. The caller must hold the lock.
+func (kc *KeyCache) evict(now time.Time) {
+	var lru string
+	var lruTime time.Time
+	for id, k := range kc.keys {
+		if !now.Before(k.expires) {
+			delete(kc.keys, id)
+			continue
+		}
+		if lru == "" || k.used.Before(lruTime) {
+			lru, lruTime = id, k.used
+		}
+	}
+	if len(kc.keys) >= kc.Size {
+		delete(kc.keys, lru)
+	}
+}
+
+// ParsePublicKey parses a PEM encoded RSA public key.
+func ParsePublicKey(p string) (*rsa.PublicKey, error) {
+	pb, _ := pem.Decode([]byte(p))
+	if pb == nil || pb.Type != "PUBLIC KEY" {
+		return nil, errors.New("failed to decode PEM block containing public key")
+	}
+	pk, err := x509.ParsePKIXPublicKey(pb.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	rpk, ok := pk.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("invalid key type")
+	}
+	return rpk, nil
+}
+
+// ActorKeyFetcher returns a KeyFetcher which fetches keys from actor or key
+// documents. The fetch requests are signed to support remote servers
+// running in authorized fetch mode.
+func ActorKeyFetcher(signerKeyID string, key *rsa.PrivateKey) KeyFetcher {
+	return func(keyID string) (*PubKey, error) {
+		u, _, _ := strings.Cut(keyID, "#")
+		body, err := signedGet(u, signerKeyID, key)
+		if err != nil {
+			return nil, err
+		}
+		var doc struct {
+			ID           string  `json:"id"`
+			Owner        string  `json:"owner"`
+			PublicKeyPem string  `json:"publicKeyPem"`
+			PublicKey    *PubKey `json:"publicKey"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		// actor document
+		if doc.PublicKey != nil {
+			if doc.PublicKey.Owner != doc.ID {
+				return nil, errors.New("key is not owned by the actor")
+			}
+			return doc.PublicKey, nil
+		}
+		// key document
+		if doc.PublicKeyPem != "" {
+			return &PubKey{
+				ID:           doc.ID,
+				Owner:        doc.Owner,
+				PublicKeyPem: doc.PublicKeyPem,
+			}, nil
+		}
+		return nil, errors.New("no public key found")
+	}
+}
+
+// Verifier verifies HTTP signatures of incoming requests.
+type Verifier struct {
+	Keys  *KeyCache
+	Fetch KeyFetcher
+	// Host is the expected value of the host header.
+	Host         string
+	MaxClockSkew time.Duration
+}
+
+// Verify checks the HTTP signature of a request and returns the owner of the
+// signing key. Body must contain the payload of requests with body, its
+// digest is verified as well.
+// The key is fetched again in case of verification failure to handle key rotation.
+func (v *Verifier) Verify(r *http.Request, body []byte) (string, error) {
+	h := r.Header.Get("Signature")
+	if h == "" {
+		h = r.Header.Get("Authorization")
+	}
+	if h == "" {
+		return "", ErrMissingSignature
+	}
+	s, err := ParseSignatureHeader(h)
+	if err != nil {
+		return "", err
+	}
+	required := []string{headerRequestTarget, "host"}
+	if body != nil {
+		required = append(required, "digest")
+	}
+	for _, rh := range required {
+		if !slices.Contains(s.Headers, rh) {
+			return "", fmt.Errorf("%s header is not signed", rh)
+		}
+	}
+	if err := v.checkTime(r, s); err != nil {
+		return "", err
+	}
+	if body != nil && !digestMatches(r.Header.Values("Digest"), body) {
+		return "", errors.New("digest hash mismatch")
+	}
+	ss, err := s.SigningString(r, v.Host)
+	if err != nil {
+		return "", err
+	}
+	key, owner, err := v.Keys.Get(s.KeyID, v.Fetch, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch key: %w", err)
+	}
+	if s.Verify(key, ss) == nil {
+		return owner, nil
+	}
+	key, owner, err = v.Keys.Get(s.KeyID, v.Fetch, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to refetch key: %w", err)
+	}
+	if err := s.Verify(key, ss); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+func (v *Verifier) checkTime(r *http.Request, s *Signature) error {
+	now := time.Now()
+	skew := v.MaxClockSkew
+	if skew == 0 {
+		skew = DefaultMaxClockSkew
+	}
+	var created time.Time
+	switch {
+	case slices.Contains(s.Headers, headerCreated):
+		created = time.Unix(s.Created, 0)
+	case slices.Contains(s.Headers, "date"):
+		var err error
+		created, err = http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			return fmt.Errorf("invalid date header: %w", err)
+		}
+	default:
+		return errors.New("neither date nor (created) header is signed")
+	}
+	if created.After(now.Add(skew)) || created.Before(now.Add(-skew)) {
+		return errors.New("signature creation time is out of the allowed range")
+	}
+	if slices.Contains(s.Headers, headerExpires) && time.Unix(s.Expires, 0).Before(now) {
+		return errors.New("signature has expired")
+	}
+	return nil
+}
+
+func digestMatches(headers []string, body []byte) bool {
+	d := Digest(body)
+	for _, h := range headers {
+		for p := range strings.SplitSeq(h, ",") {
+			if alg, val, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.EqualFold(alg, "SHA-256") && "SHA-256="+val == d {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(a, b string) bool {
+	au, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	bu, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return au.Scheme == bu.Scheme && strings.EqualFold(au.Host, bu.Host)
+}
@@ -0,0 +1,48 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCache(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	keyPem := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	fetches := 0
+	fetch := func(keyID string) (*PubKey, error) {
+		fetches++
+		return &PubKey{ID: keyID, Owner: "https://example.com/a", PublicKeyPem: keyPem}, nil
+	}
+	kc := NewKeyCache()
+	kc.Size = 2
+	_, owner, err := kc.Get("https://example.com/a#key", fetch, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", owner)
+	_, _, _ = kc.Get("https://example.com/a#key", fetch, false)
+	assert.Equal(t, 1, fetches)
+
+	// refetches are rate limited
+	_, _, _ = kc.Get("https://example.com/a#key", fetch, true)
+	assert.Equal(t, 1, fetches)
+	kc.RefetchInterval = 0
+	_, _, _ = kc.Get("https://example.com/a#key", fetch, true)
+	assert.Equal(t, 2, fetches)
+
+	// the least recently used key is evicted
+	_, _, _ = kc.Get("https://example.com/b#key", fetch, false)
+	kc.keys["https://example.com/a#key"].used = time.Now()
+	_, _, _ = kc.Get("https://example.com/c#key", fetch, false)
+	assert.Len(t, kc.keys, 2)
+	assert.Contains(t, kc.keys, "https://example.com/a#key")
+	assert.NotContains(t, kc.keys, "https://example.com/b#key")
+}
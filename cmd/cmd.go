@@ -15,7 +15,12 @@
 //   - create-bookmark: Add a bookmark from the command line
 //   - create-config: Generate a default configuration file
 //   - update-feeds: Manually update all RSS/Atom feeds
+//   - purge-trash: Permanently remove old items from the trash
+//   - check-links: Check bookmark URLs for dead and redirected links
+//   - block-domain, unblock-domain: Manage ActivityPub domain blocks
+//   - import-blocklist, export-blocklist: Mastodon compatible domain blocklists
 //   - generate-api-docs-md: Generate Markdown API documentation
+//   - generate-openapi: Generate OpenAPI 3 specification in JSON format
 //
 // The package handles configuration loading, database initialization, and
 // command-line argument parsing. It also sets up logging with configurable
@@ -43,15 +48,18 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asciimoo/omnom/config"
 	"github.com/asciimoo/omnom/contentdiff"
 	"github.com/asciimoo/omnom/feed"
+	"github.com/asciimoo/omnom/linkcheck"
 	"github.com/asciimoo/omnom/mail"
 	"github.com/asciimoo/omnom/model"
 	"github.com/asciimoo/omnom/storage"
 	"github.com/asciimoo/omnom/validator"
 	"github.com/asciimoo/omnom/webapp"
+	"github.com/asciimoo/omnom/webhook"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -65,6 +73,8 @@ const (
 	addonCmd = "addon"
 )
 
+const trashPurgeInterval = 24 * time.Hour
+
 var cfgFile string
 var cfg *config.Config
 
@@ -119,7 +129,7 @@ var rootCmd = &cobra.Command{
 	Use:     "omnom",
 	Short:   "webpage bookmarking and snapshotting service.",
 	Long:    `A webpage bookmarking and snapshotting service.`,
-	Version: "v0.2.0",
+	Version: config.Version,
 }
 
 func setStrArg(cmd *cobra.Command, arg string, dest *string) {
@@ -158,6 +168,8 @@ var listenCmd = &cobra.Command{
 		setUintArg(cmd, "results-per-page", &cfg.App.ResultsPerPage)
 		setIntArg(cmd, "webapp-snapshotter-timeout", &cfg.App.WebappSnapshotterTimeout)
 		setBoolArg(cmd, "create-snapshot-from-webapp", &cfg.App.CreateSnapshotFromWebapp)
+		setBoolArg(cmd, "snapshot-screenshot", &cfg.App.SnapshotScreenshot)
+		setBoolArg(cmd, "snapshot-pdf", &cfg.App.SnapshotPDF)
 		setBoolArg(cmd, "secure-cookie", &cfg.Server.SecureCookie)
 		setStrArg(cmd, "db-type", &cfg.DB.Type)
 		setStrArg(cmd, "db-connection", &cfg.DB.Connection)
@@ -171,6 +183,7 @@ var listenCmd = &cobra.Command{
 		setIntArg(cmd, "smtp-send-timeout", &cfg.SMTP.SendTimeout)
 		setIntArg(cmd, "smtp-connection-timeout", &cfg.SMTP.ConnectionTimeout)
 		setUintArg(cmd, "feed-items-per-page", &cfg.Feed.ItemsPerPage)
+		setUintArg(cmd, "trash-retention-days", &cfg.App.TrashRetentionDays)
 		if v, err := cmd.Flags().GetString("data-directory"); err == nil && cmd.Flags().Changed("data-directory") {
 			if cfg.Storage.Filesystem == nil {
 				cfg.Storage.Filesystem = &config.StorageFilesystem{}
@@ -187,6 +200,12 @@ var listenCmd = &cobra.Command{
 			exit(1, "Failed to initialize ActivityPub keys: "+err.Error())
 		}
 		go feed.UpdateLoop()
+		webhook.Configure(cfg.Webhooks)
+		go webhook.DeliveryLoop()
+		go purgeTrashLoop(cfg.App.TrashRetentionDays)
+		if cfg.LinkCheck.Enabled {
+			go linkcheck.New(cfg.LinkCheck).Loop()
+		}
 		webapp.Run(cfg)
 	},
 }
@@ -303,6 +322,95 @@ var updateFeedsCmd = &cobra.Command{
 	},
 }
 
+var purgeTrashCmd = &cobra.Command{
+	Use:    "purge-trash",
+	Short:  "permanently remove old items from the trash",
+	Long:   `purge-trash`,
+	Args:   cobra.ExactArgs(0),
+	PreRun: initDB,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setUintArg(cmd, "days", &cfg.App.TrashRetentionDays)
+		initStorage()
+		n, err := model.PurgeTrash(0, trashPurgeTime(cfg.App.TrashRetentionDays))
+		if err != nil {
+			exit(1, "Failed to purge trash: "+err.Error())
+		}
+		fmt.Println(n, "items purged")
+	},
+}
+
+var checkLinksCmd = &cobra.Command{
+	Use:    "check-links",
+	Short:  "check bookmark URLs for dead and redirected links",
+	Long:   `check-links`,
+	Args:   cobra.ExactArgs(0),
+	PreRun: initDB,
+	Run: func(cmd *cobra.Command, _ []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		n, err := linkcheck.New(cfg.LinkCheck).Check(all)
+		if err != nil {
+			exit(1, "Failed to check links: "+err.Error())
+		}
+		fmt.Println(n, "links checked")
+	},
+}
+
+var blockDomainCmd = &cobra.Command{
+	Use:    "block-domain DOMAIN",
+	Short:  "block an ActivityPub domain",
+	Long:   `block-domain DOMAIN`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: initDB,
+	Run:    blockDomain,
+}
+
+var unblockDomainCmd = &cobra.Command{
+	Use:    "unblock-domain DOMAIN",
+	Short:  "remove an ActivityPub domain block",
+	Long:   `unblock-domain DOMAIN`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: initDB,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := model.UnblockDomain(args[0]); err != nil {
+			exit(1, "Failed to unblock domain: "+err.Error())
+		}
+		fmt.Println("Domain", args[0], "unblocked")
+	},
+}
+
+var importBlocklistCmd = &cobra.Command{
+	Use:    "import-blocklist FILENAME",
+	Short:  "import ActivityPub domain blocks from a Mastodon compatible CSV file",
+	Long:   `import-blocklist FILENAME`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: initDB,
+	Run: func(_ *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			exit(1, err.Error())
+		}
+		defer f.Close()
+		n, err := model.ImportDomainBlocks(f)
+		if err != nil {
+			exit(1, fmt.Sprintf("Failed to import blocklist after %d domains: %s", n, err.Error()))
+		}
+		fmt.Printf("%d domains imported\n", n)
+	},
+}
+
+var exportBlocklistCmd = &cobra.Command{
+	Use:    "export-blocklist",
+	Short:  "export ActivityPub domain blocks in Mastodon compatible CSV format",
+	Long:   `export-blocklist`,
+	Args:   cobra.ExactArgs(0),
+	PreRun: initDB,
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := model.ExportDomainBlocks(os.Stdout); err != nil {
+			exit(1, "Failed to export blocklist: "+err.Error())
+		}
+	},
+}
+
 var generateAPIDocsMDCmd = &cobra.Command{
 	Use:   "generate-api-docs-md",
 	Short: "Generate Markdown API documentation",
@@ -343,6 +451,22 @@ var generateAPIDocsMDCmd = &cobra.Command{
 	},
 }
 
+var generateOpenAPICmd = &cobra.Command{
+	Use:   "generate-openapi",
+	Short: "Generate OpenAPI 3 specification in JSON format",
+	Long:  `generate-openapi`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, _ []string) {
+		u := cfg.Server.BaseURL
+		setStrArg(cmd, "server-url", &u)
+		spec, err := webapp.GenerateOpenAPI(u)
+		if err != nil {
+			exit(1, "Failed to generate OpenAPI specification: "+err.Error())
+		}
+		fmt.Println(string(spec))
+	},
+}
+
 var diffHTML = &cobra.Command{
 	Use:   "diff-html FILE1 FILE2",
 	Short: "diff-html FILE1 FILE2",
@@ -354,20 +478,48 @@ var diffHTML = &cobra.Command{
 var validateHTML = &cobra.Command{
 	Use:   "validate-html FILE",
 	Short: "validate-html FILE",
-	Long:  `validate-html FILE`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(_ *cobra.Command, args []string) {
-		b, err := os.ReadFile(args[0])
+	Long: `validate-html FILE
+
+Reports every security issue of an HTML file with its line and column.
+Use --sanitize to print the cleaned HTML which is stored as snapshot.`,
+	Args: cobra.ExactArgs(1),
+	Run:  handleValidateHTML,
+}
+
+func handleValidateHTML(cmd *cobra.Command, args []string) {
+	b, err := os.ReadFile(args[0])
+	if err != nil {
+		exit(1, err.Error())
+	}
+	sanitize, err := cmd.Flags().GetBool("sanitize")
+	if err != nil {
+		exit(1, err.Error())
+	}
+	out := cmd.OutOrStdout()
+	if sanitize {
+		clean, issues, err := validator.SanitizeHTML(b)
 		if err != nil {
 			exit(1, err.Error())
 		}
-		res := validator.ValidateHTML(b)
-		if res.Error != nil {
-			fmt.Printf("Error found: %v\n", res.Error)
-			return
+		for _, i := range issues {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Removed: %s\n", i)
 		}
-		fmt.Println("No errors found")
-	},
+		_, _ = out.Write(clean)
+		return
+	}
+	issues, err := validator.CheckHTML(b)
+	if err != nil {
+		fmt.Fprintf(out, "Error found: %v\n", err)
+		return
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(out, "No errors found")
+		return
+	}
+	for _, i := range issues {
+		fmt.Fprintf(out, "%s: %s\n", args[0], i)
+	}
+	fmt.Fprintf(out, "%d errors found\n", len(issues))
 }
 
 func createToken(_ *cobra.Command, args []string) {
@@ -394,11 +546,7 @@ func changeToken(args []string, tok string) {
 			exit(1, "Failed to set token: "+err.Error())
 		}
 	} else {
-		t := &model.Token{
-			UserID: u.ID,
-			Text:   tok,
-		}
-		err := model.DB.Save(t).Error
+		_, err := model.SetToken(u.ID, "Addon", tok, model.AddonTokenScopes, nil)
 		if err != nil {
 			exit(1, "Failed to set token: "+err.Error())
 		}
@@ -413,6 +561,48 @@ func changeToken(args []string, tok string) {
 	}
 }
 
+func blockDomain(cmd *cobra.Command, args []string) {
+	b := &model.DomainBlock{
+		Domain: args[0],
+	}
+	if v, err := cmd.Flags().GetString("severity"); err == nil {
+		b.Severity = v
+	}
+	if v, err := cmd.Flags().GetString("comment"); err == nil {
+		b.PublicComment = v
+	}
+	switch b.Severity {
+	case model.SeveritySuspend, model.SeveritySilence, model.SeverityNoop:
+	default:
+		exit(1, fmt.Sprintf("Unknown severity: %s", b.Severity))
+	}
+	if err := model.BlockDomain(b); err != nil {
+		exit(1, "Failed to block domain: "+err.Error())
+	}
+	fmt.Println("Domain", b.Domain, "blocked")
+}
+
+func trashPurgeTime(days uint) time.Time {
+	return time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+}
+
+// purgeTrashLoop periodically removes the items from the trash which were
+// deleted more than days ago. Zero days disables purging.
+func purgeTrashLoop(days uint) {
+	if days == 0 {
+		return
+	}
+	for {
+		n, err := model.PurgeTrash(0, trashPurgeTime(days))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to purge trash")
+		} else if n > 0 {
+			log.Info().Int64("count", n).Msg("Trash purged")
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
 func createConfig(_ *cobra.Command, args []string) {
 	fname := args[0]
 	if _, err := os.Stat(fname); err == nil {
@@ -495,14 +685,23 @@ func init() {
 	rootCmd.AddCommand(setTokenCmd)
 	rootCmd.AddCommand(showUserCmd)
 	rootCmd.AddCommand(generateAPIDocsMDCmd)
+	rootCmd.AddCommand(generateOpenAPICmd)
 	rootCmd.AddCommand(createConfigCmd)
 	rootCmd.AddCommand(createBookmarkCmd)
 	rootCmd.AddCommand(updateFeedsCmd)
 	rootCmd.AddCommand(showUnreadCmd)
 	rootCmd.AddCommand(diffHTML)
 	rootCmd.AddCommand(validateHTML)
+	rootCmd.AddCommand(blockDomainCmd)
+	rootCmd.AddCommand(unblockDomainCmd)
+	rootCmd.AddCommand(importBlocklistCmd)
+	rootCmd.AddCommand(exportBlocklistCmd)
+	rootCmd.AddCommand(purgeTrashCmd)
+	rootCmd.AddCommand(checkLinksCmd)
 
 	dcfg := config.CreateDefaultConfig()
+	generateOpenAPICmd.Flags().String("server-url", "", "Server URL of the specification (default: base URL from the config)")
+
 	listenCmd.Flags().StringP("address", "a", dcfg.Server.Address, "Listen address")
 	listenCmd.Flags().StringP("base-url", "b", dcfg.Server.BaseURL, "Base URL")
 	listenCmd.Flags().String("data-directory", "./static/data", "Data directory location to store snapshots and resources using file system storage")
@@ -510,6 +709,8 @@ func init() {
 	//nolint: gosec // conversion is safe. TODO use uint by default
 	listenCmd.Flags().Uint("webapp-snapshotter-timeout", uint(dcfg.App.WebappSnapshotterTimeout), "Timeout duration for webapp snapshotter (seconds)")
 	listenCmd.Flags().Bool("create-bookmark-from-webapp", dcfg.App.CreateSnapshotFromWebapp, "Allow creating snapshots from webapp (requires chromium)")
+	listenCmd.Flags().Bool("snapshot-screenshot", dcfg.App.SnapshotScreenshot, "Save a full-page PNG screenshot of webapp snapshots")
+	listenCmd.Flags().Bool("snapshot-pdf", dcfg.App.SnapshotPDF, "Save a PDF print of webapp snapshots")
 	listenCmd.Flags().Bool("secure-cookie", dcfg.Server.SecureCookie, "Use secure cookies")
 	listenCmd.Flags().String("db-type", dcfg.DB.Type, "Database type")
 	listenCmd.Flags().String("db-connection", dcfg.DB.Connection, "Database connection string (path for sqlite)")
@@ -526,6 +727,7 @@ func init() {
 	//nolint: gosec // conversion is safe. TODO use uint by default
 	listenCmd.Flags().Uint("smtp-connection-timeout", uint(dcfg.SMTP.ConnectionTimeout), "SMTP connection timeout (seconds)")
 	listenCmd.Flags().Uint("feed-items-per-page", dcfg.Feed.ItemsPerPage, "Number of feed items per page")
+	listenCmd.Flags().Uint("trash-retention-days", dcfg.App.TrashRetentionDays, "Purge items from the trash after this many days (0 disables purging)")
 
 	createBookmarkCmd.Flags().Bool("public", true, "Set bookmark to public or private")
 	createBookmarkCmd.Flags().Bool("unread", false, "Mark bookmark as unread")
@@ -533,7 +735,20 @@ func init() {
 	createBookmarkCmd.Flags().String("notes", "", "Bookmark notes")
 	createBookmarkCmd.Flags().String("collection", "", "Collection name")
 
-	diffHTML.Flags().StringP("type", "t", "all", `Specify types to diff. Possible values are "all", "text", "link", "media"`)
+	blockDomainCmd.Flags().String("severity", model.SeveritySuspend, `Block severity. Possible values are "suspend", "silence" and "noop"`)
+	blockDomainCmd.Flags().String("comment", "", "Public comment")
+
+	purgeTrashCmd.Flags().Uint("days", dcfg.App.TrashRetentionDays, "Purge items deleted more than this many days ago")
+
+	checkLinksCmd.Flags().Bool("all", false, "Check every bookmark, not only the ones due by the configured interval")
+
+	diffHTML.Flags().StringP("type", "t", "all", `Specify types to diff. Possible values are "all", "text", "link", "media", "block"`)
+	diffHTML.Flags().StringArray("include", nil, "CSS selector of the compared elements (can be repeated)")
+	diffHTML.Flags().StringArray("exclude", nil, "CSS selector of the ignored elements (can be repeated)")
+	diffHTML.Flags().StringArray("ignore", nil, "Regular expression of the ignored text (can be repeated)")
+	diffHTML.Flags().Bool("main-content", false, "Compare only the automatically detected main content")
+
+	validateHTML.Flags().Bool("sanitize", false, "Print the sanitized HTML instead of the issues")
 
 	cobra.OnInitialize(initialize)
 
@@ -632,17 +847,31 @@ func handleDiffHTML(cmd *cobra.Command, args []string) {
 	}
 	defer f2.Close()
 
-	diff, err := contentdiff.DiffHTML(f1, f2)
+	o := &contentdiff.Options{}
+	if o.Include, err = cmd.Flags().GetStringArray("include"); err != nil {
+		exit(1, err.Error())
+	}
+	if o.Exclude, err = cmd.Flags().GetStringArray("exclude"); err != nil {
+		exit(1, err.Error())
+	}
+	if o.Ignore, err = cmd.Flags().GetStringArray("ignore"); err != nil {
+		exit(1, err.Error())
+	}
+	if o.MainContent, err = cmd.Flags().GetBool("main-content"); err != nil {
+		exit(1, err.Error())
+	}
+	diff, err := contentdiff.DiffHTML(f1, f2, o)
 	if err != nil {
 		exit(1, err.Error())
 	}
+	out := cmd.OutOrStdout()
 	switch t {
 	case "all":
 		if len(diff.Link) > 0 {
-			fmt.Println("=== Link diffs ===")
-			fmt.Println(diff.Link)
+			fmt.Fprintln(out, "=== Link diffs ===")
+			fmt.Fprintln(out, diff.Link)
 		} else {
-			fmt.Println("=== No link diffs found ===")
+			fmt.Fprintln(out, "=== No link diffs found ===")
 		}
 		tdlen := 0
 		for _, t := range diff.Text {
@@ -651,28 +880,38 @@ func handleDiffHTML(cmd *cobra.Command, args []string) {
 			}
 		}
 		if tdlen > 0 {
-			fmt.Println("=== Text diffs ===")
-			fmt.Println(diff.Text)
+			fmt.Fprintln(out, "=== Text diffs ===")
+			fmt.Fprintln(out, diff.Text)
 		} else {
-			fmt.Println("=== No text diffs found ===")
+			fmt.Fprintln(out, "=== No text diffs found ===")
 		}
 		if len(diff.Multimedia) > 0 {
-			fmt.Println("=== Media diffs ===")
-			fmt.Println(diff.Multimedia)
+			fmt.Fprintln(out, "=== Media diffs ===")
+			fmt.Fprintln(out, diff.Multimedia)
 		} else {
-			fmt.Println("=== No media diffs found ===")
+			fmt.Fprintln(out, "=== No media diffs found ===")
+		}
+		if d := diff.Blocks.String(); d != "" {
+			fmt.Fprintln(out, "=== Block diffs ===")
+			fmt.Fprintln(out, d)
+		} else {
+			fmt.Fprintln(out, "=== No block diffs found ===")
 		}
 	case "text":
 		if d := diff.Text.String(); d != "" {
-			fmt.Println(d)
+			fmt.Fprintln(out, d)
 		}
 	case "media":
 		if d := diff.Multimedia.String(); d != "" {
-			fmt.Println(d)
+			fmt.Fprintln(out, d)
 		}
 	case "link":
 		if d := diff.Link.String(); d != "" {
-			fmt.Println(d)
+			fmt.Fprintln(out, d)
+		}
+	case "block":
+		if d := diff.Blocks.String(); d != "" {
+			fmt.Fprintln(out, d)
 		}
 	default:
 		exit(1, fmt.Sprintf("Unknown diff type: %s", t))
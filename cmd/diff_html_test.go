@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestDiffHTMLGolden(t *testing.T) {
+	cases, err := os.ReadDir(filepath.Join("testdata", "diff-html"))
+	assert.NoError(t, err)
+	for _, c := range cases {
+		dir := filepath.Join("testdata", "diff-html", c.Name())
+		for _, typ := range []string{"block", "all"} {
+			t.Run(c.Name()+"/"+typ, func(t *testing.T) {
+				out := bytes.NewBuffer(nil)
+				resetFlags(diffHTML.Flags())
+				if args, err := os.ReadFile(filepath.Join(dir, "args")); err == nil {
+					assert.NoError(t, diffHTML.Flags().Parse(strings.Split(strings.TrimSpace(string(args)), "\n")))
+				}
+				assert.NoError(t, diffHTML.Flags().Set("type", typ))
+				diffHTML.SetOut(out)
+				defer diffHTML.SetOut(nil)
+				handleDiffHTML(diffHTML, []string{
+					filepath.Join(dir, "old.html"),
+					filepath.Join(dir, "new.html"),
+				})
+				golden := filepath.Join(dir, typ+".golden")
+				if *updateGolden {
+					assert.NoError(t, os.WriteFile(golden, out.Bytes(), 0o600))
+				}
+				expected, err := os.ReadFile(golden)
+				assert.NoError(t, err)
+				assert.Equal(t, string(expected), out.String())
+			})
+		}
+	}
+}
+
+func resetFlags(fs *pflag.FlagSet) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sv.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
@@ -47,6 +47,9 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Version is the version of the Omnom application.
+const Version = "v0.2.0"
+
 // Config holds the application configuration.
 type Config struct {
 	fname       string
@@ -54,6 +57,9 @@ type Config struct {
 	Server      Server       `yaml:"server"`
 	DB          DB           `yaml:"db"`
 	Feed        Feed         `yaml:"feed"`
+	URLs        URLs         `yaml:"urls"`
+	LinkCheck   LinkCheck    `yaml:"link_check"`
+	Webhooks    Webhooks     `yaml:"webhooks"`
 	Storage     Storage      `yaml:"storage"`
 	SMTP        SMTP         `yaml:"smtp"`
 	ActivityPub *ActivityPub `yaml:"activitypub"`
@@ -68,8 +74,11 @@ type App struct {
 	StaticDir                string `yaml:"static_dir"` // Deprecated: use Storage.Filesystem.RootDir instead
 	CreateSnapshotFromWebapp bool   `yaml:"create_snapshot_from_webapp"`
 	WebappSnapshotterTimeout int    `yaml:"webapp_snapshotter_timeout"`
+	SnapshotScreenshot       bool   `yaml:"snapshot_screenshot"`
+	SnapshotPDF              bool   `yaml:"snapshot_pdf"`
 	DebugSQL                 bool   `yaml:"debug_sql"`
 	DisableTagSuggestions    bool   `yaml:"disable_tag_suggestions"`
+	TrashRetentionDays       uint   `yaml:"trash_retention_days"`
 }
 
 // Server holds server configuration.
@@ -78,6 +87,12 @@ type Server struct {
 	BaseURL          string `yaml:"base_url"`
 	SecureCookie     bool   `yaml:"secure_cookie"`
 	RemoteUserHeader string `yaml:"remote_user_header"`
+	// SnapshotBaseURL is the base URL of a separate origin serving the
+	// archived snapshots. Snapshots are served from BaseURL if it is empty.
+	SnapshotBaseURL string `yaml:"snapshot_base_url"`
+	// SnapshotURLSecret is the key of the signed snapshot URLs.
+	// It is required if SnapshotBaseURL is set.
+	SnapshotURLSecret string `yaml:"snapshot_url_secret"`
 }
 
 // DB holds database configuration.
@@ -91,6 +106,37 @@ type Feed struct {
 	ItemsPerPage uint `yaml:"items_per_page"`
 }
 
+// URLs holds the settings of bookmark URL normalization.
+// The normalized URLs are used to detect duplicate bookmarks.
+type URLs struct {
+	// RemoveParams lists the removed query parameters. A trailing "*"
+	// matches any suffix. The built-in list of tracking parameters is
+	// used if it is empty.
+	RemoveParams      []string `yaml:"remove_params"`
+	KeepScheme        bool     `yaml:"keep_scheme"`
+	KeepWWW           bool     `yaml:"keep_www"`
+	KeepTrailingSlash bool     `yaml:"keep_trailing_slash"`
+	KeepFragment      bool     `yaml:"keep_fragment"`
+}
+
+// LinkCheck holds the settings of the bookmark link checker.
+type LinkCheck struct {
+	Enabled     bool `yaml:"enabled"`
+	Interval    uint `yaml:"interval"`     // hours between two checks of a bookmark
+	DomainDelay uint `yaml:"domain_delay"` // seconds between two requests to the same domain, at least 1
+	Timeout     uint `yaml:"timeout"`      // seconds
+	// AllowPrivateNetworks permits checking bookmarks pointing to loopback
+	// and private network addresses.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
+// Webhooks holds the settings of webhook deliveries.
+type Webhooks struct {
+	// AllowPrivateNetworks permits deliveries to loopback and private
+	// network addresses.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
 // Storage holds storage backend configuration.
 type Storage struct {
 	Filesystem *StorageFilesystem `yaml:"fs"`
@@ -116,10 +162,12 @@ type SMTP struct {
 
 // ActivityPub holds ActivityPub configuration including key paths.
 type ActivityPub struct {
-	PubKeyPath  string `yaml:"pubkey"`
-	PrivKeyPath string `yaml:"privkey"`
-	PubK        *rsa.PublicKey
-	PrivK       *rsa.PrivateKey
+	PubKeyPath      string `yaml:"pubkey"`
+	PrivKeyPath     string `yaml:"privkey"`
+	AuthorizedFetch bool   `yaml:"authorized_fetch"`
+	MaxClockSkew    int    `yaml:"max_clock_skew"`
+	PubK            *rsa.PublicKey
+	PrivK           *rsa.PrivateKey
 }
 
 // OAuth maps provider names to their OAuth configurations.
@@ -199,9 +247,15 @@ func CreateDefaultConfig() *Config {
 		Feed: Feed{
 			ItemsPerPage: 20,
 		},
+		LinkCheck: LinkCheck{
+			Interval:    168,
+			DomainDelay: 5,
+			Timeout:     15,
+		},
 		ActivityPub: &ActivityPub{
-			PubKeyPath:  "./public.pem",
-			PrivKeyPath: "./private.pem",
+			PubKeyPath:   "./public.pem",
+			PrivKeyPath:  "./private.pem",
+			MaxClockSkew: 3600,
 		},
 		SMTP: SMTP{
 			Host:              "",
@@ -246,6 +300,19 @@ func parseConfig(rawConfig []byte) (*Config, error) {
 	if strings.HasSuffix(c.Server.BaseURL, "/") {
 		c.Server.BaseURL = c.Server.BaseURL[:len(c.Server.BaseURL)-1]
 	}
+	if c.Server.SnapshotBaseURL != "" {
+		su, err := url.Parse(c.Server.SnapshotBaseURL)
+		if err != nil || su.Scheme == "" || su.Host == "" {
+			return nil, errors.New("invalid Server.SnapshotBaseURL - use 'https://snapshots.domain.tld/' format")
+		}
+		if su.Scheme == pu.Scheme && su.Host == pu.Host {
+			return nil, errors.New("invalid Server.SnapshotBaseURL - it must be a different origin than Server.BaseURL")
+		}
+		if c.Server.SnapshotURLSecret == "" {
+			return nil, errors.New("missing Server.SnapshotURLSecret - it is required to sign the URLs of Server.SnapshotBaseURL")
+		}
+		c.Server.SnapshotBaseURL = strings.TrimSuffix(c.Server.SnapshotBaseURL, "/")
+	}
 	if c.App.StaticDir != "" {
 		if c.Storage.Filesystem != nil {
 			return nil, errors.New("remove app.static_dir from config, storage.fs is already configured")
@@ -0,0 +1,483 @@
+package contentdiff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// minChangeSimilarity is the minimum similarity of a removed and an added
+// block to display them as a changed block.
+const minChangeSimilarity = 0.5
+
+var blockTags = map[string]bool{
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"p":          true,
+	"li":         true,
+	"dt":         true,
+	"dd":         true,
+	"tr":         true,
+	"pre":        true,
+	"blockquote": true,
+	"caption":    true,
+	"figcaption": true,
+}
+
+var skippedTags = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+}
+
+// Block is a structural unit of an HTML document, like a heading,
+// a paragraph, a list item or a table row.
+type Block struct {
+	Tag     string `json:"tag"`
+	Text    string `json:"text"`
+	Section string `json:"section"`
+}
+
+// BlockDiff represents a block level difference.
+// Type is "0" for unchanged, "+" for added, "-" for removed, "~" for
+// changed and "m" for moved blocks. Changes contains the text differences
+// of changed blocks.
+type BlockDiff struct {
+	Block
+	Type    string    `json:"type"`
+	Changes TextDiffs `json:"changes,omitempty"`
+}
+
+// BlockDiffs is a list of BlockDiff items
+type BlockDiffs []BlockDiff
+
+type blockOp struct {
+	typ     string
+	block   Block
+	moved   bool
+	paired  bool
+	changes TextDiffs
+}
+
+// ExtractBlocks returns the blocks of an HTML document in document order.
+// Text outside of blocks is collected into blocks with "text" tag.
+func ExtractBlocks(r io.Reader) ([]Block, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	e := &blockExtractor{}
+	e.walk(doc, nil)
+	res := make([]Block, 0, len(e.blocks))
+	for _, b := range e.blocks {
+		if b.Text = strings.Join(strings.Fields(b.Text), " "); b.Text != "" {
+			res = append(res, *b)
+		}
+	}
+	return res, nil
+}
+
+type blockExtractor struct {
+	blocks  []*Block
+	loose   *Block
+	section string
+}
+
+func (e *blockExtractor) walk(n *html.Node, cur *Block) {
+	switch n.Type {
+	case html.TextNode:
+		if cur == nil {
+			if e.loose == nil {
+				e.loose = &Block{Tag: "text", Section: e.section}
+				e.blocks = append(e.blocks, e.loose)
+			}
+			cur = e.loose
+		}
+		cur.Text += n.Data
+		return
+	case html.ElementNode:
+		if skippedTags[n.Data] {
+			return
+		}
+		switch n.Data {
+		case "br":
+			if cur != nil {
+				cur.Text += " "
+			}
+		case "td", "th":
+			if cur != nil && strings.TrimSpace(cur.Text) != "" {
+				cur.Text += " | "
+			}
+		}
+		if blockTags[n.Data] {
+			b := &Block{Tag: n.Data, Section: e.section}
+			e.blocks = append(e.blocks, b)
+			e.loose = nil
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				e.walk(c, b)
+			}
+			e.loose = nil
+			if isHeading(n.Data) {
+				e.section = strings.Join(strings.Fields(b.Text), " ")
+				b.Section = e.section
+			}
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.walk(c, cur)
+	}
+}
+
+// DiffBlocks compares two block lists and returns their differences.
+//
+// Sections are aligned by their headings first, then the blocks of the
+// matching sections are aligned by their tag and text. Removed blocks which
+// appear elsewhere in the second list are reported as moved, similar removed
+// and added blocks of the same type are reported as changed.
+func DiffBlocks(b1, b2 []Block) BlockDiffs {
+	ops := alignSections(splitSections(b1), splitSections(b2))
+	markMoves(ops)
+	start := 0
+	for i := 0; i <= len(ops); i++ {
+		if i == len(ops) || ops[i].typ == "0" {
+			markChanges(ops[start:i])
+			start = i + 1
+		}
+	}
+	r := make(BlockDiffs, 0, len(ops))
+	for _, o := range ops {
+		switch {
+		case o.typ == "-" && (o.moved || o.paired):
+			continue
+		case o.moved:
+			r = append(r, BlockDiff{Block: o.block, Type: "m"})
+		case o.paired:
+			r = append(r, BlockDiff{Block: o.block, Type: "~", Changes: o.changes})
+		default:
+			r = append(r, BlockDiff{Block: o.block, Type: o.typ})
+		}
+	}
+	return r
+}
+
+type section struct {
+	heading *Block
+	blocks  []Block
+}
+
+// splitSections groups blocks by the preceding heading. The first section
+// has no heading if the document does not start with a heading.
+func splitSections(bs []Block) []section {
+	var res []section
+	for i, b := range bs {
+		if isHeading(b.Tag) {
+			res = append(res, section{heading: &bs[i]})
+			continue
+		}
+		if len(res) == 0 {
+			res = append(res, section{})
+		}
+		res[len(res)-1].blocks = append(res[len(res)-1].blocks, b)
+	}
+	return res
+}
+
+func alignSections(s1, s2 []section) []*blockOp {
+	keys := func(ss []section) []string {
+		res := make([]string, len(ss))
+		for i, s := range ss {
+			if s.heading != nil {
+				res[i] = blockKey(*s.heading)
+			}
+		}
+		return res
+	}
+	var ops []*blockOp
+	var removed, added []section
+	// flush emits the unmatched sections between two matching ones,
+	// sections with the same heading type are compared to each other
+	flush := func() {
+		used := make([]bool, len(removed))
+		pairs := make([]int, len(added))
+		for j, a := range added {
+			pairs[j] = -1
+			for i, r := range removed {
+				if !used[i] && sameHeadingTag(r, a) {
+					used[i] = true
+					pairs[j] = i
+					break
+				}
+			}
+		}
+		for i, r := range removed {
+			if !used[i] {
+				ops = append(ops, sectionOps("-", r)...)
+			}
+		}
+		for j, a := range added {
+			if pairs[j] < 0 {
+				ops = append(ops, sectionOps("+", a)...)
+				continue
+			}
+			r := removed[pairs[j]]
+			if r.heading != nil {
+				ops = append(ops, &blockOp{typ: "-", block: *r.heading})
+				ops = append(ops, &blockOp{typ: "+", block: *a.heading})
+			}
+			ops = append(ops, alignBlocks(r.blocks, a.blocks, a.heading)...)
+		}
+		removed = removed[:0]
+		added = added[:0]
+	}
+	for _, o := range alignKeys(keys(s1), keys(s2)) {
+		switch o.typ {
+		case "-":
+			removed = append(removed, s1[o.i1])
+		case "+":
+			added = append(added, s2[o.i2])
+		default:
+			flush()
+			if h := s2[o.i2].heading; h != nil {
+				ops = append(ops, &blockOp{typ: "0", block: *h})
+			}
+			ops = append(ops, alignBlocks(s1[o.i1].blocks, s2[o.i2].blocks, nil)...)
+		}
+	}
+	flush()
+	return ops
+}
+
+func sectionOps(typ string, s section) []*blockOp {
+	ops := make([]*blockOp, 0, len(s.blocks)+1)
+	if s.heading != nil {
+		ops = append(ops, &blockOp{typ: typ, block: *s.heading})
+	}
+	for _, b := range s.blocks {
+		ops = append(ops, &blockOp{typ: typ, block: b})
+	}
+	return ops
+}
+
+func sameHeadingTag(s1, s2 section) bool {
+	if s1.heading == nil || s2.heading == nil {
+		return s1.heading == s2.heading
+	}
+	return s1.heading.Tag == s2.heading.Tag
+}
+
+// alignBlocks compares the blocks of two matching sections. Removed blocks
+// get the section of the renamed heading h to display them in context.
+func alignBlocks(b1, b2 []Block, h *Block) []*blockOp {
+	k1 := make([]string, len(b1))
+	for i, b := range b1 {
+		k1[i] = blockKey(b)
+	}
+	k2 := make([]string, len(b2))
+	for i, b := range b2 {
+		k2[i] = blockKey(b)
+	}
+	ops := make([]*blockOp, 0, max(len(b1), len(b2)))
+	for _, o := range alignKeys(k1, k2) {
+		switch o.typ {
+		case "-":
+			b := b1[o.i1]
+			if h != nil {
+				b.Section = h.Text
+			}
+			ops = append(ops, &blockOp{typ: "-", block: b})
+		case "+":
+			ops = append(ops, &blockOp{typ: "+", block: b2[o.i2]})
+		default:
+			ops = append(ops, &blockOp{typ: "0", block: b2[o.i2]})
+		}
+	}
+	return ops
+}
+
+type keyOp struct {
+	typ string
+	i1  int
+	i2  int
+}
+
+// alignKeys returns the edit script of two string lists.
+func alignKeys(k1, k2 []string) []keyOp {
+	runes := make(map[string]rune)
+	toRunes := func(ks []string) []rune {
+		rs := make([]rune, len(ks))
+		for i, k := range ks {
+			r, ok := runes[k]
+			if !ok {
+				// skip the surrogate range to keep the runes valid
+				r = rune(len(runes) + 1)
+				if r >= 0xD800 {
+					r += 0x800
+				}
+				runes[k] = r
+			}
+			rs[i] = r
+		}
+		return rs
+	}
+	dmp := diffmatchpatch.New()
+	ds := dmp.DiffMainRunes(toRunes(k1), toRunes(k2), false)
+	res := make([]keyOp, 0, max(len(k1), len(k2)))
+	i1, i2 := 0, 0
+	for _, d := range ds {
+		for range []rune(d.Text) {
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				res = append(res, keyOp{typ: "0", i1: i1, i2: i2})
+				i1++
+				i2++
+			case diffmatchpatch.DiffDelete:
+				res = append(res, keyOp{typ: "-", i1: i1})
+				i1++
+			case diffmatchpatch.DiffInsert:
+				res = append(res, keyOp{typ: "+", i2: i2})
+				i2++
+			}
+		}
+	}
+	return res
+}
+
+func markMoves(ops []*blockOp) {
+	removed := make(map[string][]*blockOp)
+	for _, o := range ops {
+		if o.typ == "-" {
+			k := blockKey(o.block)
+			removed[k] = append(removed[k], o)
+		}
+	}
+	for _, o := range ops {
+		if o.typ != "+" {
+			continue
+		}
+		k := blockKey(o.block)
+		if l := removed[k]; len(l) > 0 {
+			l[0].moved = true
+			o.moved = true
+			removed[k] = l[1:]
+		}
+	}
+}
+
+// markChanges pairs similar removed and added blocks of the same type.
+func markChanges(ops []*blockOp) {
+	for _, d := range ops {
+		if d.typ != "-" || d.moved || d.paired {
+			continue
+		}
+		for _, a := range ops {
+			if a.typ != "+" || a.moved || a.paired || a.block.Tag != d.block.Tag {
+				continue
+			}
+			if similarity(d.block.Text, a.block.Text) >= minChangeSimilarity {
+				a.paired = true
+				d.paired = true
+				a.changes = DiffText(d.block.Text, a.block.Text)
+				break
+			}
+		}
+	}
+}
+
+func blockKey(b Block) string {
+	return b.Tag + "\x00" + b.Text
+}
+
+func similarity(t1, t2 string) float64 {
+	l := max(len([]rune(t1)), len([]rune(t2)))
+	if l == 0 {
+		return 1
+	}
+	dmp := diffmatchpatch.New()
+	return 1 - float64(dmp.DiffLevenshtein(dmp.DiffMain(t1, t2, false)))/float64(l)
+}
+
+func isHeading(tag string) bool {
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6'
+}
+
+func (bds BlockDiffs) String() string {
+	r := make([]string, 0, 8)
+	section := ""
+	for _, d := range bds {
+		if d.Type == "0" {
+			continue
+		}
+		if d.Section != section && !isHeading(d.Tag) {
+			section = d.Section
+			if section != "" {
+				r = append(r, "## "+section)
+			}
+		}
+		r = append(r, fmt.Sprintf("%s %s %#v", d.Type, d.Tag, d.Text))
+		if d.Type == "~" {
+			for _, l := range strings.Split(d.Changes.String(), "\n") {
+				r = append(r, "    "+l)
+			}
+		}
+	}
+	return strings.Join(r, "\n")
+}
+
+// Stats summarizes block differences.
+type Stats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+	Moved   int `json:"moved"`
+	// Magnitude is the ratio of the inserted and deleted text to the text
+	// of both documents in percent.
+	Magnitude float64 `json:"magnitude"`
+}
+
+// Stats returns the summary of the differences.
+func (bds BlockDiffs) Stats() *Stats {
+	s := &Stats{}
+	total, changed := 0, 0
+	for _, d := range bds {
+		l := len([]rune(d.Text))
+		switch d.Type {
+		case "+":
+			s.Added++
+			total += l
+			changed += l
+		case "-":
+			s.Removed++
+			total += l
+			changed += l
+		case "~":
+			s.Changed++
+			total += 2 * l
+			for _, c := range d.Changes {
+				if c.Type != "0" {
+					changed += len([]rune(c.Text))
+				}
+			}
+		case "m":
+			s.Moved++
+			total += 2 * l
+		default:
+			total += 2 * l
+		}
+	}
+	if total > 0 {
+		s.Magnitude = float64(changed) * 100 / float64(total)
+	}
+	return s
+}
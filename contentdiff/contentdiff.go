@@ -5,10 +5,16 @@
 //   - Text content (rendered text from the page)
 //   - Links (href and anchor text)
 //   - Multimedia elements (images, videos)
+//   - Blocks (headings, paragraphs, list items, table rows)
 //
 // The comparison uses the Myers diff algorithm (via sergi/go-diff) to compute
 // differences in text content. For links and multimedia, it performs set-based
-// comparison to identify additions and removals.
+// comparison to identify additions and removals. Blocks are aligned section by
+// section to detect moved and changed blocks.
+//
+// Options can restrict the comparison to parts of the documents selected by
+// CSS selectors or to the automatically detected main content, and can
+// ignore volatile text matching regular expressions.
 //
 // The package is used to show users what has changed on a bookmarked page between
 // snapshots, making it easy to track content updates, new links, or removed sections.
@@ -17,7 +23,7 @@
 //
 //	reader1 := strings.NewReader(oldHTML)
 //	reader2 := strings.NewReader(newHTML)
-//	diffs, err := contentdiff.DiffHTML(reader1, reader2)
+//	diffs, err := contentdiff.DiffHTML(reader1, reader2, nil)
 //	if err != nil {
 //	    return err
 //	}
@@ -60,9 +66,10 @@ type LinkDiffs []LinkDiff
 
 // Diffs contains all types of differences between two HTML documents.
 type Diffs struct {
-	Text       TextDiffs `json:"text"`
-	Multimedia TextDiffs `json:"multimedia"`
-	Link       LinkDiffs `json:"link"`
+	Text       TextDiffs  `json:"text"`
+	Multimedia TextDiffs  `json:"multimedia"`
+	Link       LinkDiffs  `json:"link"`
+	Blocks     BlockDiffs `json:"blocks"`
 }
 
 // HTMLContent represents extracted content from an HTML document.
@@ -79,13 +86,40 @@ type Link struct {
 }
 
 // DiffHTML compares two HTML documents and returns their differences.
-func DiffHTML(r1, r2 io.Reader) (*Diffs, error) {
-	c1 := ExtractHTMLContent(r1)
-	c2 := ExtractHTMLContent(r2)
+// The compared parts of the documents can be restricted by the options,
+// nil options compare the whole documents.
+func DiffHTML(r1, r2 io.Reader, o *Options) (*Diffs, error) {
+	var h1, h2 []byte
+	var err error
+	if o.IsEmpty() {
+		h1, err = io.ReadAll(r1)
+		if err == nil {
+			h2, err = io.ReadAll(r2)
+		}
+	} else {
+		h1, err = FilterHTML(r1, o)
+		if err == nil {
+			h2, err = FilterHTML(r2, o)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c1 := ExtractHTMLContent(bytes.NewReader(h1))
+	c2 := ExtractHTMLContent(bytes.NewReader(h2))
+	b1, err := ExtractBlocks(bytes.NewReader(h1))
+	if err != nil {
+		return nil, err
+	}
+	b2, err := ExtractBlocks(bytes.NewReader(h2))
+	if err != nil {
+		return nil, err
+	}
 	ds := &Diffs{
 		Text:       DiffText(c1.Text, c2.Text),
 		Multimedia: DiffList(c1.Multimedia, c2.Multimedia),
 		Link:       DiffLink(c1.Links, c2.Links),
+		Blocks:     DiffBlocks(b1, b2),
 	}
 	return ds, nil
 }
@@ -93,9 +127,9 @@ func DiffHTML(r1, r2 io.Reader) (*Diffs, error) {
 // DiffText compares two text strings and returns their differences.
 func DiffText(t1, t2 string) TextDiffs {
 	dmp := diffmatchpatch.New()
-	ds := dmp.DiffMain(t1, t2, false)
+	ds := dmp.DiffCleanupSemantic(dmp.DiffMain(t1, t2, false))
 	r := make(TextDiffs, len(ds))
-	for i, d := range dmp.DiffCleanupSemantic(ds) {
+	for i, d := range ds {
 		r[i] = TextDiff{
 			Text: d.Text,
 		}
@@ -0,0 +1,254 @@
+package contentdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// minMainParagraphLen is the minimum length of paragraphs counted by the
+// main content detection.
+const minMainParagraphLen = 25
+
+var (
+	bodySelector        = cascadia.MustCompile("body")
+	mainSelector        = cascadia.MustCompile("main, [role=main]")
+	articleSelector     = cascadia.MustCompile("article")
+	paragraphSelector   = cascadia.MustCompile("p, pre, blockquote")
+	boilerplateSelector = cascadia.MustCompile(
+		"nav, aside, footer, [role=navigation], [role=complementary], [role=contentinfo]",
+	)
+)
+
+// Options controls which parts of the HTML documents are compared.
+// The zero value compares the whole documents.
+type Options struct {
+	// Include contains CSS selectors of the compared elements.
+	// Every element is compared if it is empty.
+	Include []string `json:"include"`
+	// Exclude contains CSS selectors of the ignored elements.
+	Exclude []string `json:"exclude"`
+	// Ignore contains regular expressions of ignored text,
+	// like dates or counters.
+	Ignore []string `json:"ignore"`
+	// MainContent restricts the comparison to the automatically detected
+	// main content of the documents.
+	MainContent bool `json:"main_content"`
+}
+
+type selectors []cascadia.Sel
+
+type filter struct {
+	include selectors
+	exclude selectors
+	ignore  []*regexp.Regexp
+	main    bool
+}
+
+// IsEmpty reports whether the options compare the whole documents.
+func (o *Options) IsEmpty() bool {
+	return o == nil || (len(o.Include) == 0 && len(o.Exclude) == 0 && len(o.Ignore) == 0 && !o.MainContent)
+}
+
+// Validate checks the selectors and the regular expressions of the options.
+func (o *Options) Validate() error {
+	_, err := o.compile()
+	return err
+}
+
+// FilterHTML returns the parts of an HTML document selected by the options.
+func FilterHTML(r io.Reader, o *Options) ([]byte, error) {
+	f, err := o.compile()
+	if err != nil {
+		return nil, err
+	}
+	return f.apply(r)
+}
+
+func (o *Options) compile() (*filter, error) {
+	f := &filter{}
+	if o == nil {
+		return f, nil
+	}
+	f.main = o.MainContent
+	for _, s := range o.Include {
+		sel, err := cascadia.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include selector %q: %w", s, err)
+		}
+		f.include = append(f.include, sel)
+	}
+	for _, s := range o.Exclude {
+		sel, err := cascadia.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude selector %q: %w", s, err)
+		}
+		f.exclude = append(f.exclude, sel)
+	}
+	for _, s := range o.Ignore {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", s, err)
+		}
+		f.ignore = append(f.ignore, re)
+	}
+	return f, nil
+}
+
+func (f *filter) apply(r io.Reader) ([]byte, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	removeNodes(cascadia.QueryAll(doc, f.exclude))
+	roots := []*html.Node{doc}
+	if f.main {
+		if m := findMainContent(doc); m != nil {
+			removeNodes(cascadia.QueryAll(m, boilerplateSelector))
+			roots = []*html.Node{m}
+		}
+	}
+	if len(f.include) > 0 {
+		var matches []*html.Node
+		for _, root := range roots {
+			matches = append(matches, cascadia.QueryAll(root, f.include)...)
+		}
+		roots = outermostNodes(matches)
+	}
+	if len(roots) != 1 || roots[0] != doc {
+		doc, err = html.Parse(strings.NewReader("<html><body></body></html>"))
+		if err != nil {
+			return nil, err
+		}
+		body := cascadia.Query(doc, bodySelector)
+		for _, n := range roots {
+			n.Parent.RemoveChild(n)
+			body.AppendChild(n)
+		}
+	}
+	if len(f.ignore) > 0 {
+		f.removeIgnoredText(doc)
+	}
+	out := bytes.NewBuffer(nil)
+	if err := html.Render(out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (f *filter) removeIgnoredText(n *html.Node) {
+	if n.Type == html.TextNode {
+		for _, re := range f.ignore {
+			n.Data = re.ReplaceAllString(n.Data, "")
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		f.removeIgnoredText(c)
+	}
+}
+
+// Match reports whether any of the selectors matches the node.
+func (s selectors) Match(n *html.Node) bool {
+	for _, sel := range s {
+		if sel.Match(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// findMainContent returns the main element of the document, its longest
+// article or the element containing the most paragraph text.
+// Returns nil if the main content cannot be detected.
+func findMainContent(doc *html.Node) *html.Node {
+	if m := cascadia.Query(doc, mainSelector); m != nil {
+		return m
+	}
+	var best *html.Node
+	bestScore := 0
+	for _, a := range cascadia.QueryAll(doc, articleSelector) {
+		if l := textLen(a); l > bestScore {
+			best = a
+			bestScore = l
+		}
+	}
+	if best != nil {
+		return best
+	}
+	scores := make(map[*html.Node]int)
+	var candidates []*html.Node
+	score := func(n *html.Node, s int) {
+		if _, ok := scores[n]; !ok {
+			candidates = append(candidates, n)
+		}
+		scores[n] += s
+	}
+	for _, p := range cascadia.QueryAll(doc, paragraphSelector) {
+		l := textLen(p)
+		if l < minMainParagraphLen || p.Parent == nil {
+			continue
+		}
+		score(p.Parent, l)
+		if gp := p.Parent.Parent; gp != nil && gp.Type == html.ElementNode {
+			score(gp, l/2)
+		}
+	}
+	for _, n := range candidates {
+		if scores[n] > bestScore {
+			best = n
+			bestScore = scores[n]
+		}
+	}
+	return best
+}
+
+func textLen(n *html.Node) int {
+	if n.Type == html.TextNode {
+		return len(strings.TrimSpace(n.Data))
+	}
+	if n.Type == html.ElementNode && skippedTags[n.Data] {
+		return 0
+	}
+	l := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		l += textLen(c)
+	}
+	return l
+}
+
+// outermostNodes drops the nodes which are descendants of other nodes of
+// the list.
+func outermostNodes(ns []*html.Node) []*html.Node {
+	set := make(map[*html.Node]bool, len(ns))
+	for _, n := range ns {
+		set[n] = true
+	}
+	res := make([]*html.Node, 0, len(ns))
+	for _, n := range ns {
+		nested := false
+		for p := n.Parent; p != nil; p = p.Parent {
+			if set[p] {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func removeNodes(ns []*html.Node) {
+	for _, n := range ns {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
@@ -23,7 +23,7 @@
 // Example usage:
 //
 //	// Subscribe to a feed
-//	err := feed.AddFeed(cfg, "Hacker News", "https://news.ycombinator.com/rss", userID)
+//	uf, err := feed.AddFeed(cfg, "Hacker News", "https://news.ycombinator.com/rss", userID)
 //
 //	// Update all feeds
 //	err := feed.Update()
@@ -66,7 +66,8 @@ var htmlSanitizerPolicy *bluemonday.Policy
 var errUnknownFeedType = errors.New("unknown feed type")
 
 const (
-	srcAttr = "src"
+	srcAttr          = "src"
+	previewItemCount = 5
 )
 
 func init() {
@@ -292,10 +293,14 @@ func AddActivityPubFeedItem(cfg *config.Config, f *model.Feed, u *model.User, d
 	return nil
 }
 
-func createFeed(cfg *config.Config, name, u string, uid uint) (*model.Feed, error) {
-	ftype, fu, err := getFeedInfo(u)
-	if err != nil {
-		return nil, err
+func createFeed(cfg *config.Config, name, u string, uid uint, ftype model.FeedType) (*model.Feed, error) {
+	fu := u
+	if ftype == "" {
+		var err error
+		ftype, fu, err = getFeedInfo(u)
+		if err != nil {
+			return nil, err
+		}
 	}
 	f := &model.Feed{
 		Name: name,
@@ -338,24 +343,34 @@ func createFeed(cfg *config.Config, name, u string, uid uint) (*model.Feed, erro
 	default:
 		return nil, errUnknownFeedType
 	}
-	err = model.DB.Create(f).Error
-	if err != nil {
-		return f, err
-	}
-	err = createUserFeed(name, f, uid)
+	err := model.DB.Create(f).Error
 	return f, err
 }
 
 // AddFeed adds a new feed subscription for a user.
-func AddFeed(cfg *config.Config, name, u string, uid uint) error {
+// u can be a feed URL or a @user@instance fediverse handle.
+func AddFeed(cfg *config.Config, name, u string, uid uint) (*model.UserFeed, error) {
+	var ftype model.FeedType
+	if ap.IsHandle(u) {
+		var err error
+		u, err = ap.ResolveHandle(u)
+		if err != nil {
+			return nil, err
+		}
+		ftype = model.ActivityPubFeed
+	}
 	f, err := model.GetFeedByURL(u)
 	if f == nil || err != nil {
 		var err error
-		f, err = createFeed(cfg, name, u, uid)
+		f, err = createFeed(cfg, name, u, uid, ftype)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	uf, err := createUserFeed(name, f, uid)
+	if err != nil {
+		return nil, err
+	}
 	switch model.FeedType(f.Type) {
 	case model.RSSFeed:
 		updateRSSFeed(f)
@@ -364,7 +379,74 @@ func AddFeed(cfg *config.Config, name, u string, uid uint) error {
 	default:
 		log.Error().Err(errUnknownFeedType).Str("Type", f.Type)
 	}
-	return nil
+	return uf, nil
+}
+
+// ActorPreview contains the public profile and the latest posts of
+// a fediverse account.
+type ActorPreview struct {
+	Handle  string
+	Actor   *ap.Identity
+	Summary string
+	// Favicon is the icon of the actor as a data URL.
+	Favicon string
+	Items   []*ActorPreviewItem
+}
+
+// ActorPreviewItem is a sanitized post of an ActorPreview.
+type ActorPreviewItem struct {
+	URL       string
+	Content   string
+	Published string
+}
+
+// PreviewActivityPubHandle resolves a @user@instance handle and fetches the
+// profile and the latest posts of the account.
+func PreviewActivityPubHandle(cfg *config.Config, handle string, uid uint) (*ActorPreview, error) {
+	au, err := ap.ResolveHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	userURL, err := getUserURL(cfg, uid)
+	if err != nil {
+		return nil, err
+	}
+	userKey := userURL + "#key"
+	pk := cfg.ActivityPub.PrivK
+	actor, err := ap.FetchActor(au, userKey, pk)
+	if err != nil {
+		return nil, err
+	}
+	p := &ActorPreview{
+		Handle: handle,
+		Actor:  actor,
+	}
+	// the icon is inlined, previews must not store resources
+	if actor.Icon != nil {
+		p.Favicon = fetchImageAsInlineURL(actor.Icon.URL)
+	}
+	pu, err := url.Parse(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Summary = sanitizeHTML(pu, actor.Summary)
+	items, err := ap.FetchOutboxItems(actor.Outbox, userKey, pk, previewItemCount)
+	if err != nil {
+		log.Debug().Err(err).Str("actor", actor.ID).Msg("Failed to fetch outbox")
+		return p, nil
+	}
+	for _, i := range items {
+		iu := i.Object.URL
+		if iu == "" {
+			iu = i.Object.ID
+		}
+		p.Items = append(p.Items, &ActorPreviewItem{
+			URL:       iu,
+			Content:   sanitizeHTML(pu, i.Object.Content),
+			Published: i.Published,
+		})
+	}
+	return p, nil
 }
 
 // DeleteFeed removes a user's feed subscription.
@@ -404,17 +486,17 @@ func getUserURL(cfg *config.Config, uid uint) (string, error) {
 	return cfg.BaseURL("/users/" + user.Username), nil
 }
 
-func createUserFeed(name string, f *model.Feed, uid uint) error {
-	var uf *model.UserFeed
+func createUserFeed(name string, f *model.Feed, uid uint) (*model.UserFeed, error) {
+	uf := &model.UserFeed{}
 	if err := model.DB.Where("feed_id = ? and user_id = ?", f.ID, uid).First(uf).Error; err == nil {
-		return nil
+		return uf, nil
 	}
 	uf = &model.UserFeed{
 		Name:   name,
 		FeedID: f.ID,
 		UserID: uid,
 	}
-	return model.DB.Create(uf).Error
+	return uf, model.DB.Create(uf).Error
 }
 
 func getFeedInfo(u string) (model.FeedType, string, error) {
@@ -0,0 +1,271 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package imagediff compares screenshots of web pages pixel by pixel.
+//
+// Content inserted to or removed from the top of a page shifts everything
+// below it, so the images are aligned vertically before the comparison.
+// Pixels whose color channels differ more than a threshold are considered
+// changed, and the changed pixels are grouped into rectangular regions.
+//
+// The result contains the ratio of the changed area, which can be used to
+// decide whether a page has changed significantly, and can render an overlay
+// image highlighting the changes on top of the second image.
+//
+// Example usage:
+//
+//	r := imagediff.Diff(oldScreenshot, newScreenshot, imagediff.DefaultOptions)
+//	fmt.Printf("%.2f%% changed\n", r.ChangedPercent)
+//
+//	// Render the highlighted changes
+//	err := png.Encode(w, r.Overlay())
+package imagediff
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Options controls the comparison of two images.
+type Options struct {
+	// Threshold is the maximum difference of a color channel (0-255)
+	// between two pixels which are considered equal.
+	Threshold uint8
+	// MaxShift is the maximum vertical offset in pixels tried to align
+	// the images.
+	MaxShift int
+	// BlockSize is the size of the squares in pixels used to group
+	// changed pixels into regions.
+	BlockSize int
+}
+
+// DefaultOptions contains the recommended settings to compare screenshots.
+var DefaultOptions = Options{
+	Threshold: 32,
+	MaxShift:  400,
+	BlockSize: 16,
+}
+
+// Result is the difference of two images.
+//
+// Coordinates are relative to the second image. Areas covered by only one
+// of the images count as changed.
+type Result struct {
+	// Width and Height are the size of the compared area.
+	Width  int
+	Height int
+	// Offset is the vertical distance the content of the first image
+	// moved in the second image.
+	Offset int
+	// ChangedPixels is the number of pixels which differ.
+	ChangedPixels int
+	// ChangedPercent is the ratio of the changed pixels in percent.
+	ChangedPercent float64
+	// Regions contains the bounding boxes of the changed areas.
+	Regions []image.Rectangle
+
+	img     *image.RGBA
+	changed []bool
+}
+
+// Diff compares two images and returns their differences.
+func Diff(a, b image.Image, o Options) *Result {
+	ra := toRGBA(a)
+	rb := toRGBA(b)
+	wa, ha := ra.Rect.Dx(), ra.Rect.Dy()
+	wb, hb := rb.Rect.Dx(), rb.Rect.Dy()
+	off := align(ra, rb, o.MaxShift)
+	r := &Result{
+		Width:  max(wa, wb),
+		Height: max(hb, ha+off),
+		Offset: off,
+		img:    rb,
+	}
+	r.changed = make([]bool, r.Width*r.Height)
+	for y := range r.Height {
+		ya := y - off
+		for x := range r.Width {
+			if x >= wb || y >= hb || x >= wa || ya < 0 || ya >= ha {
+				r.changed[y*r.Width+x] = true
+				r.ChangedPixels++
+				continue
+			}
+			ia := ra.PixOffset(x, ya)
+			ib := rb.PixOffset(x, y)
+			if pixelDiff(ra.Pix[ia:ia+4], rb.Pix[ib:ib+4]) > o.Threshold {
+				r.changed[y*r.Width+x] = true
+				r.ChangedPixels++
+			}
+		}
+	}
+	if total := r.Width * r.Height; total > 0 {
+		r.ChangedPercent = float64(r.ChangedPixels) * 100 / float64(total)
+	}
+	r.Regions = r.regions(max(1, o.BlockSize))
+	return r
+}
+
+// Overlay returns the second image faded with the changed pixels and the
+// borders of the changed regions highlighted.
+func (r *Result) Overlay() *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+	b := r.img.Rect
+	for y := range r.Height {
+		for x := range r.Width {
+			c := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			if x < b.Dx() && y < b.Dy() {
+				i := r.img.PixOffset(x, y)
+				c = color.RGBA{R: fade(r.img.Pix[i]), G: fade(r.img.Pix[i+1]), B: fade(r.img.Pix[i+2]), A: 255}
+			}
+			if r.changed[y*r.Width+x] {
+				c = color.RGBA{R: 255, G: c.G / 3, B: c.B / 3, A: 255}
+			}
+			out.SetRGBA(x, y, c)
+		}
+	}
+	border := color.RGBA{R: 222, G: 23, B: 99, A: 255}
+	for _, reg := range r.Regions {
+		for x := reg.Min.X; x < reg.Max.X; x++ {
+			out.SetRGBA(x, reg.Min.Y, border)
+			out.SetRGBA(x, reg.Max.Y-1, border)
+		}
+		for y := reg.Min.Y; y < reg.Max.Y; y++ {
+			out.SetRGBA(reg.Min.X, y, border)
+			out.SetRGBA(reg.Max.X-1, y, border)
+		}
+	}
+	return out
+}
+
+// regions groups the changed pixels into blocks and returns the bounding
+// boxes of the connected changed blocks.
+func (r *Result) regions(bs int) []image.Rectangle {
+	bw := (r.Width + bs - 1) / bs
+	bh := (r.Height + bs - 1) / bs
+	blocks := make([]bool, bw*bh)
+	for y := range r.Height {
+		for x := range r.Width {
+			if r.changed[y*r.Width+x] {
+				blocks[(y/bs)*bw+x/bs] = true
+			}
+		}
+	}
+	var res []image.Rectangle
+	visited := make([]bool, len(blocks))
+	for i, c := range blocks {
+		if !c || visited[i] {
+			continue
+		}
+		rect := image.Rect(i%bw, i/bw, i%bw+1, i/bw+1)
+		queue := []int{i}
+		visited[i] = true
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+			jx, jy := j%bw, j/bw
+			rect = rect.Union(image.Rect(jx, jy, jx+1, jy+1))
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := jx+dx, jy+dy
+					if nx < 0 || ny < 0 || nx >= bw || ny >= bh {
+						continue
+					}
+					n := ny*bw + nx
+					if blocks[n] && !visited[n] {
+						visited[n] = true
+						queue = append(queue, n)
+					}
+				}
+			}
+		}
+		res = append(res, image.Rect(
+			rect.Min.X*bs,
+			rect.Min.Y*bs,
+			min(rect.Max.X*bs, r.Width),
+			min(rect.Max.Y*bs, r.Height),
+		))
+	}
+	return res
+}
+
+// align returns the vertical offset of b relative to a which maximizes the
+// number of identical rows. Uniform rows are ignored, because they match
+// at any offset.
+func align(a, b *image.RGBA, maxShift int) int {
+	sa := rowSignatures(a)
+	sb := rowSignatures(b)
+	best := 0
+	bestScore := alignScore(sa, sb, 0)
+	for d := 1; d <= maxShift; d++ {
+		for _, off := range []int{d, -d} {
+			if s := alignScore(sa, sb, off); s > bestScore {
+				best = off
+				bestScore = s
+			}
+		}
+	}
+	return best
+}
+
+func alignScore(sa, sb []uint64, off int) int {
+	score := 0
+	for y, s := range sb {
+		ya := y - off
+		if s == 0 || ya < 0 || ya >= len(sa) {
+			continue
+		}
+		if sa[ya] == s {
+			score++
+		}
+	}
+	return score
+}
+
+// rowSignatures returns the hash of every row or 0 for uniform rows.
+func rowSignatures(img *image.RGBA) []uint64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	res := make([]uint64, h)
+	for y := range h {
+		row := img.Pix[img.PixOffset(0, y) : img.PixOffset(0, y)+w*4]
+		uniform := true
+		for x := 4; x < len(row); x += 4 {
+			if row[x] != row[0] || row[x+1] != row[1] || row[x+2] != row[2] {
+				uniform = false
+				break
+			}
+		}
+		if uniform {
+			continue
+		}
+		hsh := fnv.New64a()
+		hsh.Write(row)
+		res[y] = hsh.Sum64()
+	}
+	return res
+}
+
+func pixelDiff(p1, p2 []uint8) uint8 {
+	var d uint8
+	for i := range 3 {
+		v := p1[i] - p2[i]
+		if p2[i] > p1[i] {
+			v = p2[i] - p1[i]
+		}
+		d = max(d, v)
+	}
+	return d
+}
+
+func fade(c uint8) uint8 {
+	return c/2 + 127
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	res := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(res, res.Rect, img, b.Min, draw.Src)
+	return res
+}
@@ -0,0 +1,58 @@
+package imagediff
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(w, h, shift int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			if y >= shift {
+				c = color.RGBA{R: uint8((x * 7) % 256), G: uint8(((y - shift) * 13) % 256), B: 100, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDiffIdentical(t *testing.T) {
+	r := Diff(testImage(100, 100, 0), testImage(100, 100, 0), DefaultOptions)
+	assert.Equal(t, 0, r.ChangedPixels)
+	assert.Equal(t, 0.0, r.ChangedPercent)
+	assert.Empty(t, r.Regions)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), r.Overlay().Rect)
+}
+
+func TestDiffChangedRegion(t *testing.T) {
+	b := testImage(100, 100, 0)
+	for y := 20; y < 30; y++ {
+		for x := 40; x < 60; x++ {
+			b.SetRGBA(x, y, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+		}
+	}
+	// small color changes below the threshold are ignored
+	b.Pix[b.PixOffset(90, 90)] += 5
+	o := DefaultOptions
+	o.BlockSize = 10
+	r := Diff(testImage(100, 100, 0), b, o)
+	assert.Equal(t, 0, r.Offset)
+	assert.InDelta(t, 2.0, r.ChangedPercent, 0.1)
+	assert.Equal(t, []image.Rectangle{image.Rect(40, 20, 60, 30)}, r.Regions)
+}
+
+func TestDiffAlign(t *testing.T) {
+	r := Diff(testImage(100, 100, 0), testImage(100, 120, 20), DefaultOptions)
+	assert.Equal(t, 20, r.Offset)
+	assert.Equal(t, 120, r.Height)
+	assert.Equal(t, 100*20, r.ChangedPixels)
+	if assert.Len(t, r.Regions, 1) {
+		assert.Equal(t, image.Rect(0, 0, 100, 32), r.Regions[0])
+	}
+}
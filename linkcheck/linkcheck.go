@@ -0,0 +1,262 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package linkcheck detects dead and redirected bookmark URLs.
+//
+// The checker periodically requests the URLs of the bookmarks and stores the
+// results on the bookmarks. A HEAD request is sent first, servers which do
+// not support HEAD requests are retried with GET. Requests to the same
+// domain are rate limited, different domains are checked concurrently.
+//
+// Results:
+//   - ok: the page responded with a successful status code
+//   - redirected: the page redirects to a URL with a different canonical URL
+//   - dead: the page responded with 404 or 410, or its domain does not exist
+//   - error: any other failure, e.g. server error or timeout
+//
+// Example usage:
+//
+//	c := linkcheck.New(cfg.LinkCheck)
+//
+//	// Check the bookmarks which are due
+//	n, err := c.Check(false)
+//
+//	// Run the periodic checker
+//	go c.Loop()
+package linkcheck
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	batchSize       = 100
+	maxWorkers      = 8
+	maxRedirects    = 10
+	maxResponseSize = 64 * 1024
+	minDomainDelay  = time.Second
+	pollInterval    = time.Hour
+	userAgent       = "Mozilla/5.0 (compatible; Omnom link checker; +https://github.com/asciimoo/omnom)"
+)
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// Result is the outcome of checking a URL.
+type Result struct {
+	Status      model.LinkStatus
+	Code        int
+	RedirectURL string
+}
+
+// Checker checks bookmark URLs.
+type Checker struct {
+	cfg         config.LinkCheck
+	client      *http.Client
+	delay       time.Duration
+	mu          sync.Mutex
+	nextRequest map[string]time.Time
+}
+
+// New creates a Checker from the given configuration.
+// Requests to loopback and private network addresses are refused unless
+// AllowPrivateNetworks is set.
+func New(c config.LinkCheck) *Checker {
+	timeout := time.Duration(c.Timeout) * time.Second
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if !c.AllowPrivateNetworks {
+		t.DialContext = utils.PublicDialer(timeout).DialContext
+	}
+	return &Checker{
+		cfg:   c,
+		delay: max(time.Duration(c.DomainDelay)*time.Second, minDomainDelay),
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: t,
+			CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errTooManyRedirects
+				}
+				return nil
+			},
+		},
+		nextRequest: make(map[string]time.Time),
+	}
+}
+
+// Loop checks the due bookmarks periodically.
+func (c *Checker) Loop() {
+	ticker := time.NewTicker(pollInterval)
+	for {
+		n, err := c.Check(false)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to check links")
+		} else if n > 0 {
+			log.Info().Int("count", n).Msg("Links checked")
+		}
+		<-ticker.C
+	}
+}
+
+// Check checks the bookmarks which were not checked in the configured
+// interval, or every bookmark if all is true.
+// Returns the number of checked bookmarks.
+func (c *Checker) Check(all bool) (int, error) {
+	start := time.Now()
+	since := start.Add(-time.Duration(c.cfg.Interval) * time.Hour)
+	if all {
+		since = start
+	}
+	c.mu.Lock()
+	for d, t := range c.nextRequest {
+		if t.Before(start) {
+			delete(c.nextRequest, d)
+		}
+	}
+	c.mu.Unlock()
+	count := 0
+	for {
+		bs, err := model.GetBookmarksToCheck(since, batchSize)
+		if err != nil {
+			return count, err
+		}
+		err = c.checkBookmarks(bs)
+		count += len(bs)
+		if err != nil {
+			// unsaved results would be returned again by the next query
+			return count, err
+		}
+		if len(bs) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+// checkBookmarks checks the bookmarks grouped by their domains.
+// Each domain is processed by a single worker to respect the rate limit.
+// Returns the first error of saving the results.
+func (c *Checker) checkBookmarks(bs []*model.Bookmark) error {
+	domains := make(map[string][]*model.Bookmark)
+	for _, b := range bs {
+		domains[b.Domain] = append(domains[b.Domain], b)
+	}
+	ch := make(chan []*model.Bookmark)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for range min(maxWorkers, len(domains)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range ch {
+				if err := c.checkDomain(l); err != nil {
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	for _, l := range domains {
+		ch <- l
+	}
+	close(ch)
+	wg.Wait()
+	return firstErr
+}
+
+func (c *Checker) checkDomain(bs []*model.Bookmark) error {
+	results := make(map[string]*Result)
+	for _, b := range bs {
+		r, ok := results[b.URL]
+		if !ok {
+			r = c.checkBookmark(b)
+			results[b.URL] = r
+		}
+		if err := model.SetLinkStatus(b.ID, r.Status, r.Code, r.RedirectURL); err != nil {
+			log.Error().Err(err).Uint("bookmark", b.ID).Msg("Failed to save link status")
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Checker) checkBookmark(b *model.Bookmark) *Result {
+	r := c.CheckURL(b.Domain, b.URL)
+	if r.Status != model.LinkRedirected {
+		return r
+	}
+	cu := model.CanonicalURL(r.RedirectURL)
+	if cu == model.CanonicalURL(b.URL) || cu == b.CanonicalURL {
+		r.Status = model.LinkOK
+		r.RedirectURL = ""
+	}
+	return r
+}
+
+// CheckURL requests a URL and classifies the response.
+// Requests with the same domain are rate limited.
+// Redirected results contain the final URL of the redirect chain.
+func (c *Checker) CheckURL(domain, u string) *Result {
+	resp, err := c.request(http.MethodHead, domain, u)
+	if err != nil || resp.StatusCode >= 400 {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = c.request(http.MethodGet, domain, u)
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return &Result{Status: model.LinkDead}
+		}
+		return &Result{Status: model.LinkError}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	r := &Result{Code: resp.StatusCode}
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		r.Status = model.LinkDead
+	case resp.StatusCode >= 400:
+		r.Status = model.LinkError
+	case resp.Request.URL.String() != u:
+		r.Status = model.LinkRedirected
+		r.RedirectURL = resp.Request.URL.String()
+	default:
+		r.Status = model.LinkOK
+	}
+	return r
+}
+
+func (c *Checker) request(method, domain, u string) (*http.Response, error) {
+	c.wait(domain)
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return c.client.Do(req)
+}
+
+// wait blocks until the next request to the domain is allowed.
+func (c *Checker) wait(domain string) {
+	c.mu.Lock()
+	now := time.Now()
+	next := c.nextRequest[domain]
+	if next.Before(now) {
+		next = now
+	}
+	c.nextRequest[domain] = next.Add(c.delay)
+	c.mu.Unlock()
+	time.Sleep(time.Until(next))
+}
@@ -0,0 +1,104 @@
+package linkcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	err := model.Init(&config.Config{
+		DB: config.DB{
+			Type:       "sqlite",
+			Connection: ":memory:",
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Nil(t, model.CreateUser("linkcheck", "linkcheck@test.com")) {
+		return
+	}
+	u := model.GetUser("linkcheck")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slash/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	expected := map[string]model.LinkStatus{
+		"/ok":     model.LinkOK,
+		"/gone":   model.LinkDead,
+		"/error":  model.LinkError,
+		"/nohead": model.LinkOK,
+		"/moved":  model.LinkRedirected,
+		"/slash":  model.LinkOK,
+	}
+	ids := make(map[string]uint)
+	for p := range expected {
+		b, _, err := model.GetOrCreateBookmark(u, srv.URL+p, p, "", "", "", "", "", "")
+		if !assert.Nil(t, err) {
+			return
+		}
+		ids[p] = b.ID
+	}
+
+	c := New(config.LinkCheck{Interval: 1, Timeout: 5, AllowPrivateNetworks: true})
+	c.delay = 0
+	n, err := c.Check(false)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expected), n)
+	for p, s := range expected {
+		var b model.Bookmark
+		assert.Nil(t, model.DB.First(&b, ids[p]).Error)
+		assert.Equal(t, s, b.LinkStatus, p)
+		assert.NotNil(t, b.LinkCheckedAt, p)
+		if s == model.LinkRedirected {
+			assert.Equal(t, srv.URL+"/ok", b.LinkRedirectURL)
+		}
+	}
+	assert.Equal(t, http.StatusGone, mustBookmark(t, ids["/gone"]).LinkStatusCode)
+
+	n, err = c.Check(false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = c.Check(true)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expected), n)
+
+	// private network addresses are refused by default
+	r := New(config.LinkCheck{Timeout: 5}).CheckURL("127.0.0.1", srv.URL+"/ok")
+	assert.Equal(t, model.LinkError, r.Status)
+}
+
+func mustBookmark(t *testing.T, id uint) *model.Bookmark {
+	var b model.Bookmark
+	assert.Nil(t, model.DB.First(&b, id).Error)
+	return &b
+}
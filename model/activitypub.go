@@ -5,17 +5,41 @@
 package model
 
 // APFollower represents an ActivityPub follower.
+// CollectionID is set if the follower follows a public collection
+// of the user instead of the user itself.
 type APFollower struct {
 	CommonFields
-	UserID   uint   `gorm:"uniqueIndex:apuidx" json:"uid"`
-	Follower string `gorm:"uniqueIndex:apuidx" json:"follower"`
+	UserID       uint   `gorm:"uniqueIndex:apuidx" json:"uid"`
+	CollectionID uint   `gorm:"uniqueIndex:apuidx;default:0" json:"collection_id"`
+	Follower     string `gorm:"uniqueIndex:apuidx" json:"follower"`
 }
 
 // CreateAPFollower creates a new ActivityPub follower record.
 func CreateAPFollower(uid uint, follower string) error {
+	return CreateAPCollectionFollower(uid, 0, follower)
+}
+
+// CreateAPCollectionFollower creates a new ActivityPub follower record for a collection.
+func CreateAPCollectionFollower(uid, cid uint, follower string) error {
 	f := APFollower{
-		UserID:   uid,
-		Follower: follower,
+		UserID:       uid,
+		CollectionID: cid,
+		Follower:     follower,
 	}
 	return DB.Create(&f).Error
 }
+
+// GetAPFollowers retrieves the followers of a user or a collection.
+// Followers of the user are returned if cid is 0.
+func GetAPFollowers(uid, cid uint) ([]*APFollower, error) {
+	var res []*APFollower
+	err := DB.Model(&APFollower{}).Where("user_id = ? and collection_id = ?", uid, cid).Find(&res).Error
+	return res, err
+}
+
+// GetAPFollowerCount returns the number of followers of a user or a collection.
+func GetAPFollowerCount(uid, cid uint) int64 {
+	var res int64
+	DB.Model(&APFollower{}).Where("user_id = ? and collection_id = ?", uid, cid).Count(&res)
+	return res
+}
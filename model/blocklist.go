@@ -0,0 +1,358 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SeveritySuspend rejects every interaction with the blocked domain.
+	SeveritySuspend = "suspend"
+	// SeveritySilence is accepted for compatibility and handled as suspend.
+	SeveritySilence = "silence"
+	// SeverityNoop records a domain without blocking it.
+	SeverityNoop = "noop"
+)
+
+// mastodonBlocklistHeader is the header of Mastodon's domain blocklist CSV export.
+var mastodonBlocklistHeader = []string{
+	"#domain",
+	"#severity",
+	"#reject_media",
+	"#reject_reports",
+	"#public_comment",
+	"#obfuscate",
+}
+
+// DomainBlock represents an instance level ActivityPub domain block.
+type DomainBlock struct {
+	CommonFields
+	Domain        string `gorm:"unique" json:"domain"`
+	Severity      string `json:"severity"`
+	RejectMedia   bool   `json:"reject_media"`
+	RejectReports bool   `json:"reject_reports"`
+	PublicComment string `json:"public_comment"`
+	Obfuscate     bool   `json:"obfuscate"`
+}
+
+// PublicDomain returns the domain of the block as it can be displayed to
+// the users. Obfuscated domains have their middle characters replaced by
+// asterisks like in Mastodon.
+func (b *DomainBlock) PublicDomain() string {
+	if !b.Obfuscate {
+		return b.Domain
+	}
+	d := []rune(b.Domain)
+	visible := len(d) / 4
+	for i, r := range d {
+		if i > visible && i < len(d)-visible-1 && r != '.' {
+			d[i] = '*'
+		}
+	}
+	return string(d)
+}
+
+// ActorBlock represents a user level ActivityPub actor block.
+type ActorBlock struct {
+	CommonFields
+	UserID uint   `gorm:"uniqueIndex:actorblockuidx" json:"user_id"`
+	Actor  string `gorm:"uniqueIndex:actorblockuidx" json:"actor"`
+}
+
+// NormalizeDomain lowercases a domain and strips the surrounding noise
+// (scheme, port, trailing dot) that is often present in shared blocklists.
+func NormalizeDomain(d string) string {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if strings.Contains(d, "://") {
+		if u, err := url.Parse(d); err == nil {
+			d = u.Hostname()
+		}
+	}
+	if h, _, found := strings.Cut(d, ":"); found {
+		d = h
+	}
+	return strings.Trim(d, ".")
+}
+
+// GetDomainBlocks retrieves all instance level domain blocks.
+func GetDomainBlocks() ([]*DomainBlock, error) {
+	var res []*DomainBlock
+	err := DB.Model(&DomainBlock{}).Order("domain asc").Find(&res).Error
+	return res, err
+}
+
+// BlockDomain creates or updates a domain block and removes the existing
+// followers of the blocked domain.
+func BlockDomain(b *DomainBlock) error {
+	if err := saveDomainBlock(b); err != nil {
+		return err
+	}
+	if b.Severity == SeverityNoop {
+		return nil
+	}
+	_, err := RemoveBlockedAPFollowers()
+	return err
+}
+
+func saveDomainBlock(b *DomainBlock) error {
+	b.Domain = NormalizeDomain(b.Domain)
+	if b.Domain == "" {
+		return errors.New("invalid domain")
+	}
+	if b.Severity == "" {
+		b.Severity = SeveritySuspend
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"severity", "reject_media", "reject_reports", "public_comment", "obfuscate", "updated_at"}),
+	}).Create(b).Error
+}
+
+// UnblockDomain deletes a domain block.
+func UnblockDomain(d string) error {
+	res := DB.Where("domain = ?", NormalizeDomain(d)).Delete(&DomainBlock{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("domain block not found")
+	}
+	return nil
+}
+
+// GetActorBlocks retrieves the actors blocked by a user.
+func GetActorBlocks(uid uint) ([]*ActorBlock, error) {
+	var res []*ActorBlock
+	err := DB.Model(&ActorBlock{}).Where("user_id = ?", uid).Order("actor asc").Find(&res).Error
+	return res, err
+}
+
+// BlockActor creates a user level actor block and removes the actor from
+// the user's followers.
+func BlockActor(uid uint, actor string) error {
+	actor = strings.TrimSpace(actor)
+	u, err := url.Parse(actor)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return errors.New("invalid actor URL")
+	}
+	b := &ActorBlock{
+		UserID: uid,
+		Actor:  actor,
+	}
+	err = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(b).Error
+	if err != nil {
+		return err
+	}
+	return DB.Delete(&APFollower{}, "user_id = ? and follower = ?", uid, actor).Error
+}
+
+// UnblockActor deletes a user level actor block.
+func UnblockActor(uid uint, id string) error {
+	return DB.Where("user_id = ? and id = ?", uid, id).Delete(&ActorBlock{}).Error
+}
+
+// IsAPActorBlocked reports whether an actor is blocked either by an
+// instance level domain block or by a block of the given user.
+// Subdomains of blocked domains are blocked as well.
+func IsAPActorBlocked(uid uint, actor string) bool {
+	u, err := url.Parse(actor)
+	if err != nil || u.Hostname() == "" {
+		return true
+	}
+	if IsDomainBlocked(u.Hostname()) {
+		return true
+	}
+	if uid == 0 {
+		return false
+	}
+	var c int64
+	DB.Model(&ActorBlock{}).Where("user_id = ? and actor = ?", uid, actor).Count(&c)
+	return c > 0
+}
+
+// IsDomainBlocked reports whether a host is covered by a domain block.
+func IsDomainBlocked(host string) bool {
+	host = NormalizeDomain(host)
+	var ds []string
+	err := DB.Model(&DomainBlock{}).Where("severity != ?", SeverityNoop).Pluck("domain", &ds).Error
+	if err != nil {
+		return false
+	}
+	for _, d := range ds {
+		if domainMatches(host, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveBlockedAPFollowers deletes the followers from blocked domains.
+// Returns the number of deleted followers.
+func RemoveBlockedAPFollowers() (int64, error) {
+	var fs []*APFollower
+	if err := DB.Model(&APFollower{}).Find(&fs).Error; err != nil {
+		return 0, err
+	}
+	var ds []string
+	if err := DB.Model(&DomainBlock{}).Where("severity != ?", SeverityNoop).Pluck("domain", &ds).Error; err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, 8)
+	for _, f := range fs {
+		u, err := url.Parse(f.Follower)
+		if err != nil {
+			continue
+		}
+		for _, d := range ds {
+			if domainMatches(NormalizeDomain(u.Hostname()), d) {
+				ids = append(ids, f.ID)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := DB.Delete(&APFollower{}, "id IN ?", ids)
+	return res.RowsAffected, res.Error
+}
+
+// ImportDomainBlocks reads domain blocks from a Mastodon compatible CSV
+// blocklist. Both the headered export format and plain one-domain-per-line
+// lists are accepted. Returns the number of imported blocks.
+func ImportDomainBlocks(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cols := map[string]int{"domain": 0}
+	n := 0
+	for line := 0; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if len(rec) == 0 || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		if line == 0 && (strings.HasPrefix(rec[0], "#") || strings.EqualFold(rec[0], "domain")) {
+			cols = make(map[string]int, len(rec))
+			for i, c := range rec {
+				cols[strings.TrimPrefix(strings.ToLower(strings.TrimSpace(c)), "#")] = i
+			}
+			if _, ok := cols["domain"]; !ok {
+				return n, errors.New("missing domain column")
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		flag := func(name string) bool {
+			v, _ := strconv.ParseBool(field(name))
+			return v
+		}
+		b := &DomainBlock{
+			Domain:        field("domain"),
+			Severity:      strings.ToLower(field("severity")),
+			RejectMedia:   flag("reject_media"),
+			RejectReports: flag("reject_reports"),
+			PublicComment: field("public_comment"),
+			Obfuscate:     flag("obfuscate"),
+		}
+		if err := saveDomainBlock(b); err != nil {
+			return n, err
+		}
+		n++
+	}
+	_, err := RemoveBlockedAPFollowers()
+	return n, err
+}
+
+// ExportDomainBlocks writes the domain blocks in Mastodon's CSV blocklist format.
+func ExportDomainBlocks(w io.Writer) error {
+	bs, err := GetDomainBlocks()
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(mastodonBlocklistHeader); err != nil {
+		return err
+	}
+	for _, b := range bs {
+		err := cw.Write([]string{
+			b.Domain,
+			b.Severity,
+			strconv.FormatBool(b.RejectMedia),
+			strconv.FormatBool(b.RejectReports),
+			b.PublicComment,
+			strconv.FormatBool(b.Obfuscate),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportActorBlocks reads a list of actor URLs (one per line) and blocks
+// them for the given user. Returns the number of imported blocks.
+func ImportActorBlocks(uid uint, r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	n := 0
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if len(rec) == 0 || strings.HasPrefix(rec[0], "#") || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		if err := BlockActor(uid, rec[0]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ExportActorBlocks writes the actors blocked by a user one per line.
+func ExportActorBlocks(uid uint, w io.Writer) error {
+	bs, err := GetActorBlocks(uid)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	for _, b := range bs {
+		if err := cw.Write([]string{b.Actor}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func domainMatches(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -8,15 +8,19 @@ import (
 	"database/sql"
 	"errors"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // Bookmark represents a saved webpage bookmark.
 type Bookmark struct {
-	CommonFields
+	TrashableFields
 	URL          string      `json:"url"`
+	CanonicalURL string      `gorm:"index" json:"canonical_url"`
 	Title        string      `json:"title"`
 	Notes        string      `json:"notes"`
 	Domain       string      `json:"domain"`
@@ -29,9 +33,15 @@ type Bookmark struct {
 	Unread       bool        `json:"unread"`
 	UserID       uint        `json:"user_id"`
 	User         User        `json:"-"`
+	// Results of the last link check
+	LinkStatus      LinkStatus `gorm:"index" json:"link_status"`
+	LinkStatusCode  int        `json:"link_status_code"`
+	LinkRedirectURL string     `json:"link_redirect_url"`
+	LinkCheckedAt   *time.Time `gorm:"index" json:"link_checked_at"`
 }
 
 // GetOrCreateBookmark retrieves an existing bookmark or creates a new one.
+// Existing bookmarks are matched by their canonical URL or by their URL.
 // TODO use Bookmark as parameter instead of strings
 func GetOrCreateBookmark(u *User, urlString, title, tags, notes, public, favicon, collection, unread string) (*Bookmark, bool, error) {
 	url, err := url.Parse(urlString)
@@ -40,14 +50,38 @@ func GetOrCreateBookmark(u *User, urlString, title, tags, notes, public, favicon
 		return nil, isNew, errors.New("invalid URL")
 	}
 	var b *Bookmark
-	r := DB.
-		Model(&Bookmark{}).
-		Preload("Snapshots").
-		Preload("Tags").
-		Preload("User").
-		Where("url = ? and user_id = ?", url.String(), u.ID).
-		First(&b)
-	if r.RowsAffected >= 1 {
+	cu := CanonicalURL(url.String())
+	// bookmarks in the trash are restored instead of creating a new one
+	var existing []*Bookmark
+	err = DB.Unscoped().
+		Where("(canonical_url = ? or url = ?) and user_id = ?", cu, url.String(), u.ID).
+		Order("deleted_at IS NOT NULL, id asc").
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return nil, isNew, err
+	}
+	if len(existing) > 0 {
+		b = existing[0]
+		if b.DeletedAt.Valid {
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				return restoreBookmarks(tx, []uint{b.ID})
+			})
+			if err != nil {
+				return nil, isNew, err
+			}
+		}
+		err := DB.
+			Preload("Snapshots").
+			Preload("Tags").
+			Preload("User").
+			First(&b, b.ID).Error
+		if err != nil {
+			return nil, isNew, err
+		}
+		if existing[0].DeletedAt.Valid {
+			TriggerBookmarkWebhooks(EventBookmarkCreated, b)
+		}
 		return b, isNew, nil
 	}
 	isNew = true
@@ -55,14 +89,15 @@ func GetOrCreateBookmark(u *User, urlString, title, tags, notes, public, favicon
 		return nil, isNew, errors.New("missing title")
 	}
 	b = &Bookmark{
-		Title:     title,
-		URL:       url.String(),
-		Domain:    url.Hostname(),
-		Notes:     notes,
-		Favicon:   favicon,
-		UserID:    u.ID,
-		User:      *u,
-		Snapshots: make([]Snapshot, 0, 8),
+		Title:        title,
+		URL:          url.String(),
+		CanonicalURL: cu,
+		Domain:       url.Hostname(),
+		Notes:        notes,
+		Favicon:      favicon,
+		UserID:       u.ID,
+		User:         *u,
+		Snapshots:    make([]Snapshot, 0, 8),
 	}
 	if !strings.HasPrefix(b.Favicon, "data:image") {
 		b.Favicon = ""
@@ -87,9 +122,41 @@ func GetOrCreateBookmark(u *User, urlString, title, tags, notes, public, favicon
 	if err := DB.Save(b).Error; err != nil {
 		return nil, isNew, err
 	}
+	TriggerBookmarkWebhooks(EventBookmarkCreated, b)
 	return b, isNew, nil
 }
 
+// Thumbnail returns the thumbnail of the most recent snapshot which has one
+// or nil if there is no thumbnail. Snapshots and their resources must be preloaded.
+func (b *Bookmark) Thumbnail() *Resource {
+	var res *Resource
+	var t time.Time
+	for i := range b.Snapshots {
+		s := &b.Snapshots[i]
+		if r := s.Thumbnail(); r != nil && (res == nil || s.CreatedAt.After(t)) {
+			res = r
+			t = s.CreatedAt
+		}
+	}
+	return res
+}
+
+// DeleteBookmark moves a bookmark of a user with its snapshots to the trash.
+func DeleteBookmark(uid uint, bid string) error {
+	var b *Bookmark
+	if err := DB.Where("id = ? and user_id = ?", bid, uid).Preload("Tags").First(&b).Error; err != nil {
+		return err
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return trashBookmarks(tx, []uint{b.ID})
+	})
+	if err != nil {
+		return err
+	}
+	TriggerBookmarkWebhooks(EventBookmarkDeleted, b)
+	return nil
+}
+
 // GetUnreadBookmarkItems retrieves unread bookmarks for a user.
 func GetUnreadBookmarkItems(uid, limit uint) []*Bookmark {
 	var res []*Bookmark
@@ -111,6 +178,18 @@ func GetUnreadBookmarkCount(uid uint) int64 {
 		Table("bookmarks").
 		Where("bookmarks.user_id = ?", uid).
 		Where("bookmarks.unread = ?", true).
+		Where("bookmarks.deleted_at IS NULL").
+		Count(&res)
+	return res
+}
+
+// GetPublicBookmarkCount returns the number of public bookmarks of all users.
+func GetPublicBookmarkCount() int64 {
+	var res int64
+	DB.
+		Table("bookmarks").
+		Where("bookmarks.public = ?", true).
+		Where("bookmarks.deleted_at IS NULL").
 		Count(&res)
 	return res
 }
@@ -119,14 +198,14 @@ func GetUnreadBookmarkCount(uid uint) int64 {
 func SearchBookmarks(uid, limit uint, query string) ([]*Bookmark, int64, error) {
 	var res []*Bookmark
 	var resCount int64
-	q := DB.Select("*").Table("bookmarks")
+	q := DB.Select("*").Table("bookmarks").Where("bookmarks.deleted_at IS NULL")
 	if uid == 0 {
 		q = q.Where("bookmarks.public = 1")
 	} else {
 		q = q.Where("bookmarks.public = 1 or bookmarks.user_id = ?", uid)
 	}
 	if query != "" {
-		q = q.Where("bookmarks.title LIKE LOWER(@query) OR bookmarks.notes LIKE LOWER(@query)", sql.Named("query", CreateGlob(query)))
+		q = q.Where("bookmarks.title LIKE LOWER(@query) OR bookmarks.notes LIKE LOWER(@query) OR "+HighlightSearchCondition, sql.Named("query", CreateGlob(query)), sql.Named("uid", uid))
 	}
 	q = q.Session(&gorm.Session{})
 	err := q.Preload("Snapshots").Preload("Tags").Preload("User").Preload("Collection").Order("bookmarks.id asc").Limit(int(limit)).Find(&res).Error //nolint:gosec // TODO
@@ -139,3 +218,166 @@ func SearchBookmarks(uid, limit uint, query string) ([]*Bookmark, int64, error)
 	}
 	return res, resCount, nil
 }
+
+// BulkOperation is an operation applied to multiple bookmarks at once.
+type BulkOperation string
+
+const (
+	// BulkAddTags adds tags to the bookmarks.
+	BulkAddTags BulkOperation = "add_tags"
+	// BulkRemoveTags removes tags from the bookmarks.
+	BulkRemoveTags BulkOperation = "remove_tags"
+	// BulkSetCollection moves the bookmarks to a collection.
+	BulkSetCollection BulkOperation = "set_collection"
+	// BulkSetPublic makes the bookmarks public.
+	BulkSetPublic BulkOperation = "set_public"
+	// BulkSetPrivate makes the bookmarks private.
+	BulkSetPrivate BulkOperation = "set_private"
+	// BulkSetUnread marks the bookmarks as unread.
+	BulkSetUnread BulkOperation = "set_unread"
+	// BulkSetRead marks the bookmarks as read.
+	BulkSetRead BulkOperation = "set_read"
+	// BulkDelete moves the bookmarks with their snapshots to the trash.
+	BulkDelete BulkOperation = "delete"
+	// BulkRestore restores the bookmarks with their snapshots from the trash.
+	BulkRestore BulkOperation = "restore"
+)
+
+// BulkOperations contains all the supported bulk operations.
+var BulkOperations = []BulkOperation{
+	BulkAddTags,
+	BulkRemoveTags,
+	BulkSetCollection,
+	BulkSetPublic,
+	BulkSetPrivate,
+	BulkSetUnread,
+	BulkSetRead,
+	BulkDelete,
+	BulkRestore,
+}
+
+// ErrInvalidBulkOperation is returned if a bulk operation is unknown or its arguments are invalid.
+var ErrInvalidBulkOperation = errors.New("invalid bulk operation")
+
+// BulkAction is a bulk operation with its arguments.
+type BulkAction struct {
+	Operation BulkOperation
+	// Tags are used by BulkAddTags and BulkRemoveTags.
+	Tags []string
+	// CollectionID is used by BulkSetCollection, 0 removes the bookmarks from their collection.
+	CollectionID uint
+}
+
+// BulkUpdateBookmarks applies an action to the bookmarks of a user selected by
+// sel, which is a query returning bookmark IDs. Bookmarks of other users are
+// ignored. BulkRestore affects only the bookmarks in the trash, the other
+// operations only the ones not in the trash.
+// All the changes are made in a single transaction.
+// Returns the number of affected bookmarks.
+func BulkUpdateBookmarks(uid uint, sel *gorm.DB, a *BulkAction) (int64, error) {
+	if !slices.Contains(BulkOperations, a.Operation) {
+		return 0, ErrInvalidBulkOperation
+	}
+	var tags []Tag
+	switch a.Operation {
+	case BulkAddTags, BulkRemoveTags:
+		for _, t := range a.Tags {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			if a.Operation == BulkAddTags {
+				tags = append(tags, GetOrCreateTag(t))
+			} else {
+				tags = append(tags, Tag{Text: t})
+			}
+		}
+		if len(tags) == 0 {
+			return 0, ErrInvalidBulkOperation
+		}
+	case BulkSetCollection:
+		if a.CollectionID > 0 && GetCollection(uid, strconv.FormatUint(uint64(a.CollectionID), 10)) == nil {
+			return 0, ErrInvalidBulkOperation
+		}
+	}
+	e := EventBookmarkUpdated
+	switch a.Operation {
+	case BulkDelete:
+		e = EventBookmarkDeleted
+	case BulkRestore:
+		e = EventBookmarkCreated
+	}
+	notify := len(subscribedWebhooks(uid, e)) > 0
+	var bs []*Bookmark
+	var ids []uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		q := tx.Model(&Bookmark{})
+		if a.Operation == BulkRestore {
+			q = tx.Unscoped().Model(&Bookmark{}).Where("deleted_at IS NOT NULL")
+		}
+		err := q.Where("user_id = ? AND id IN (?)", uid, sel).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if notify && a.Operation == BulkDelete {
+			if err := tx.Preload("Tags").Where("id IN ?", ids).Find(&bs).Error; err != nil {
+				return err
+			}
+		}
+		if err := bulkUpdate(tx, ids, a, tags); err != nil {
+			return err
+		}
+		if notify && a.Operation != BulkDelete {
+			return tx.Preload("Tags").Where("id IN ?", ids).Find(&bs).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range bs {
+		TriggerBookmarkWebhooks(e, b)
+	}
+	return int64(len(ids)), nil
+}
+
+func bulkUpdate(tx *gorm.DB, ids []uint, a *BulkAction, tags []Tag) error {
+	bq := tx.Model(&Bookmark{}).Where("id IN ?", ids)
+	switch a.Operation {
+	case BulkAddTags:
+		for _, t := range tags {
+			err := tx.Exec(
+				"INSERT INTO bookmark_tags (bookmark_id, tag_id) SELECT id, ? FROM bookmarks WHERE id IN ? AND id NOT IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = ?)",
+				t.ID, ids, t.ID,
+			).Error
+			if err != nil {
+				return err
+			}
+		}
+		return bq.Update("updated_at", time.Now()).Error
+	case BulkRemoveTags:
+		texts := make([]string, 0, len(tags))
+		for _, t := range tags {
+			texts = append(texts, t.Text)
+		}
+		err := tx.Exec(
+			"DELETE FROM bookmark_tags WHERE bookmark_id IN ? AND tag_id IN (SELECT id FROM tags WHERE text IN ?)",
+			ids, texts,
+		).Error
+		if err != nil {
+			return err
+		}
+		return bq.Update("updated_at", time.Now()).Error
+	case BulkSetCollection:
+		return bq.Update("collection_id", a.CollectionID).Error
+	case BulkSetPublic, BulkSetPrivate:
+		return bq.Update("public", a.Operation == BulkSetPublic).Error
+	case BulkSetUnread, BulkSetRead:
+		return bq.Update("unread", a.Operation == BulkSetUnread).Error
+	case BulkDelete:
+		return trashBookmarks(tx, ids)
+	case BulkRestore:
+		return restoreBookmarks(tx, ids)
+	}
+	return ErrInvalidBulkOperation
+}
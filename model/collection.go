@@ -6,10 +6,11 @@ package model
 
 // Collection represents a bookmark collection.
 type Collection struct {
-	CommonFields
-	Name      string `gorm:"uniqueIndex:cuid" json:"name"`
+	TrashableFields
+	Name      string `gorm:"uniqueIndex:cuid,where:deleted_at IS NULL" json:"name"`
 	UserID    uint   `gorm:"uniqueIndex:cuid" json:"user_id"`
 	ParentID  uint
+	Public    bool          `json:"public"`
 	Children  []*Collection `gorm:"foreignKey:parent_id"`
 	User      User          `json:"-"`
 	Bookmarks []Bookmark    `json:"bookmarks"`
@@ -21,7 +22,9 @@ func GetCollectionByName(uid uint, cname string) *Collection {
 		return nil
 	}
 	var c *Collection
-	DB.Where("user_id = ?", uid).Where("name = ?", cname).First(&c)
+	if err := DB.Where("user_id = ?", uid).Where("name = ?", cname).First(&c).Error; err != nil {
+		return nil
+	}
 	return c
 }
 
@@ -31,7 +34,22 @@ func GetCollection(uid uint, cid string) *Collection {
 		return nil
 	}
 	var c *Collection
-	DB.Where("user_id = ?", uid).Where("id = ?", cid).First(&c)
+	if err := DB.Where("user_id = ?", uid).Where("id = ?", cid).First(&c).Error; err != nil {
+		return nil
+	}
+	return c
+}
+
+// GetPublicCollection retrieves a public collection by its ID.
+func GetPublicCollection(cid string) *Collection {
+	if cid == "" {
+		return nil
+	}
+	var c *Collection
+	err := DB.Where("id = ? and public = ?", cid, true).Preload("User").First(&c).Error
+	if err != nil {
+		return nil
+	}
 	return c
 }
 
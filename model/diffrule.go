@@ -0,0 +1,110 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"strings"
+
+	"github.com/asciimoo/omnom/contentdiff"
+)
+
+const (
+	// DiffRuleBookmark rules apply to the snapshots of a single bookmark.
+	DiffRuleBookmark = "bookmark"
+	// DiffRuleDomain rules apply to the bookmarks of a domain.
+	DiffRuleDomain = "domain"
+)
+
+// DiffRule stores the snapshot diff options of a bookmark or of a domain.
+// Selectors and patterns are newline separated lists.
+type DiffRule struct {
+	CommonFields
+	UserID      uint   `gorm:"index" json:"user_id"`
+	BookmarkID  uint   `gorm:"index" json:"bookmark_id"`
+	Domain      string `gorm:"index" json:"domain"`
+	Include     string `json:"include"`
+	Exclude     string `json:"exclude"`
+	Ignore      string `json:"ignore"`
+	MainContent bool   `json:"main_content"`
+}
+
+// GetDiffRule returns the diff rule of a bookmark or the diff rule of its
+// domain if the bookmark has no rule. Returns nil if neither exist.
+func GetDiffRule(b *Bookmark) *DiffRule {
+	var r *DiffRule
+	err := DB.Where("bookmark_id = ?", b.ID).First(&r).Error
+	if err == nil {
+		return r
+	}
+	err = DB.Where("user_id = ? AND bookmark_id = 0 AND domain = ?", b.UserID, b.Domain).First(&r).Error
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// SaveDiffRule stores the options as the rule of the bookmark or as the rule
+// of its domain depending on the scope. Empty options delete the rule.
+func SaveDiffRule(b *Bookmark, scope string, o *contentdiff.Options) error {
+	q := DB.Where("user_id = ?", b.UserID)
+	if scope == DiffRuleDomain {
+		q = q.Where("bookmark_id = 0 AND domain = ?", b.Domain)
+	} else {
+		q = q.Where("bookmark_id = ?", b.ID)
+	}
+	var r *DiffRule
+	if err := q.First(&r).Error; err != nil {
+		r = &DiffRule{UserID: b.UserID}
+		if scope == DiffRuleDomain {
+			r.Domain = b.Domain
+		} else {
+			r.BookmarkID = b.ID
+		}
+	}
+	if o.IsEmpty() {
+		if r.ID == 0 {
+			return nil
+		}
+		return DB.Delete(r).Error
+	}
+	r.Include = strings.Join(o.Include, "\n")
+	r.Exclude = strings.Join(o.Exclude, "\n")
+	r.Ignore = strings.Join(o.Ignore, "\n")
+	r.MainContent = o.MainContent
+	return DB.Save(r).Error
+}
+
+// Scope returns the scope of the rule.
+func (r *DiffRule) Scope() string {
+	if r.BookmarkID == 0 {
+		return DiffRuleDomain
+	}
+	return DiffRuleBookmark
+}
+
+// Options returns the diff options of the rule.
+// Nil rules return nil options, which compare the whole documents.
+func (r *DiffRule) Options() *contentdiff.Options {
+	if r == nil {
+		return nil
+	}
+	return &contentdiff.Options{
+		Include:     splitLines(r.Include),
+		Exclude:     splitLines(r.Exclude),
+		Ignore:      splitLines(r.Ignore),
+		MainContent: r.MainContent,
+	}
+}
+
+// splitLines returns the non-empty trimmed lines of s.
+func splitLines(s string) []string {
+	var res []string
+	for l := range strings.SplitSeq(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			res = append(res, l)
+		}
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/asciimoo/omnom/contentdiff"
+)
+
+// MinVisualChange is the changed area of the screenshots in percent from
+// which two snapshots are considered different.
+const MinVisualChange = 1.0
+
+// DiffStat is the cached summary of the differences of two snapshots.
+// Snapshot keys identify the content of the snapshots, so the summaries
+// are valid until the diff options change.
+type DiffStat struct {
+	ID          uint      `gorm:"primary_key" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	Key1        string    `gorm:"uniqueIndex:idx_diff_stat" json:"key1"`
+	Key2        string    `gorm:"uniqueIndex:idx_diff_stat" json:"key2"`
+	OptionsHash string    `gorm:"uniqueIndex:idx_diff_stat" json:"-"`
+	Added       int       `json:"added"`
+	Removed     int       `json:"removed"`
+	Changed     int       `json:"changed"`
+	Moved       int       `json:"moved"`
+	Magnitude   float64   `json:"magnitude"`
+	// VisualChange is the changed area of the screenshots in percent.
+	VisualChange float64 `json:"visual_change"`
+}
+
+// GetDiffStat returns the cached summary of the differences of two
+// snapshots compared with the given options or nil if it is not cached.
+func GetDiffStat(key1, key2 string, o *contentdiff.Options) *DiffStat {
+	var s *DiffStat
+	err := DB.Where("key1 = ? AND key2 = ? AND options_hash = ?", key1, key2, optionsHash(o)).First(&s).Error
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// NewDiffStat creates the summary of the differences of two snapshots.
+// The screenshots of the snapshots are compared as well if both snapshots
+// have screenshots. Resources must be preloaded.
+func NewDiffStat(s1, s2 *Snapshot, o *contentdiff.Options, st *contentdiff.Stats) *DiffStat {
+	return &DiffStat{
+		Key1:         s1.Key,
+		Key2:         s2.Key,
+		OptionsHash:  optionsHash(o),
+		Added:        st.Added,
+		Removed:      st.Removed,
+		Changed:      st.Changed,
+		Moved:        st.Moved,
+		Magnitude:    st.Magnitude,
+		VisualChange: VisualChange(s1, s2),
+	}
+}
+
+// SaveDiffStat caches the summary of the differences of two snapshots.
+func SaveDiffStat(s1, s2 *Snapshot, o *contentdiff.Options, st *contentdiff.Stats) (*DiffStat, error) {
+	s := NewDiffStat(s1, s2, o, st)
+	return s, DB.Create(s).Error
+}
+
+// HasChanges reports whether the snapshots differ.
+func (s *DiffStat) HasChanges() bool {
+	return s.Added+s.Removed+s.Changed+s.Moved > 0 || s.VisualChange >= MinVisualChange
+}
+
+func deleteDiffStats(key string) error {
+	return DB.Where("key1 = ? OR key2 = ?", key, key).Delete(&DiffStat{}).Error
+}
+
+func optionsHash(o *contentdiff.Options) string {
+	if o.IsEmpty() {
+		return ""
+	}
+	j, err := json.Marshal(o)
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(j)
+	return hex.EncodeToString(h[:])
+}
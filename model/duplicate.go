@@ -0,0 +1,161 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/omnom/urlnorm"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidMerge is returned if the bookmarks cannot be merged.
+var ErrInvalidMerge = errors.New("invalid bookmark merge")
+
+// DuplicateGroup contains the bookmarks of a user with the same canonical URL.
+type DuplicateGroup struct {
+	CanonicalURL string
+	Bookmarks    []*Bookmark
+}
+
+// CanonicalURL returns the normalized form of a bookmark URL which is used
+// to detect duplicates. Invalid URLs are returned unchanged.
+func CanonicalURL(u string) string {
+	cu, err := urlNormalizer.Normalize(u)
+	if err != nil {
+		return u
+	}
+	return cu
+}
+
+// SetCanonicalLink updates the canonical URL of a bookmark from the
+// <link rel="canonical"> URL of its snapshot. Empty links, links pointing
+// to other hosts and links pointing to the root path of the site from other
+// pages are ignored, because they are often misconfigured.
+func SetCanonicalLink(b *Bookmark, link string) error {
+	lu, err := url.Parse(link)
+	if link == "" || err != nil {
+		return nil
+	}
+	bu, err := url.Parse(b.URL)
+	if err != nil || !urlnorm.SameHost(bu.Hostname(), lu.Hostname()) {
+		return nil
+	}
+	if strings.Trim(lu.Path, "/") == "" && strings.Trim(bu.Path, "/") != "" {
+		return nil
+	}
+	cu := CanonicalURL(link)
+	if cu == b.CanonicalURL {
+		return nil
+	}
+	b.CanonicalURL = cu
+	return DB.Model(&Bookmark{}).Where("id = ?", b.ID).Update("canonical_url", cu).Error
+}
+
+// GetDuplicateBookmarks returns the bookmarks of a user grouped by their
+// canonical URL. Only the groups with more than one bookmark are returned.
+func GetDuplicateBookmarks(uid uint) ([]*DuplicateGroup, error) {
+	var cus []string
+	err := DB.Model(&Bookmark{}).
+		Where("user_id = ? AND canonical_url != ''", uid).
+		Group("canonical_url").
+		Having("count(*) > 1").
+		Order("canonical_url").
+		Pluck("canonical_url", &cus).Error
+	if err != nil || len(cus) == 0 {
+		return nil, err
+	}
+	var bs []*Bookmark
+	err = DB.Where("user_id = ? AND canonical_url IN ?", uid, cus).
+		Preload("Snapshots").
+		Preload("Tags").
+		Preload("Collection").
+		Order("canonical_url, id").
+		Find(&bs).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*DuplicateGroup, 0, len(cus))
+	for _, b := range bs {
+		if len(res) == 0 || res[len(res)-1].CanonicalURL != b.CanonicalURL {
+			res = append(res, &DuplicateGroup{CanonicalURL: b.CanonicalURL})
+		}
+		g := res[len(res)-1]
+		g.Bookmarks = append(g.Bookmarks, b)
+	}
+	return res, nil
+}
+
+// MergeBookmarks merges bookmarks of a user into the target bookmark.
+// Snapshots and tags of the merged bookmarks are moved to the target, their
+// notes are appended to the notes of the target and the target gets the
+// collection of the first merged bookmark if it has none.
+// The merged bookmarks are moved to the trash.
+func MergeBookmarks(uid, target uint, ids []uint) (*Bookmark, error) {
+	var t *Bookmark
+	if err := DB.Where("id = ? AND user_id = ?", target, uid).First(&t).Error; err != nil {
+		return nil, err
+	}
+	var bs []*Bookmark
+	err := DB.Where("user_id = ? AND id IN ? AND id != ?", uid, ids, target).
+		Preload("Tags").
+		Order("id").
+		Find(&bs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) == 0 {
+		return nil, ErrInvalidMerge
+	}
+	bids := make([]uint, 0, len(bs))
+	notes := []string{t.Notes}
+	cid := t.CollectionID
+	for _, b := range bs {
+		bids = append(bids, b.ID)
+		if n := strings.TrimSpace(b.Notes); n != "" && !slices.Contains(notes, n) {
+			notes = append(notes, n)
+		}
+		if cid == 0 {
+			cid = b.CollectionID
+		}
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&Snapshot{}).Where("bookmark_id IN ?", bids).Update("bookmark_id", t.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Exec(
+			"INSERT INTO bookmark_tags (bookmark_id, tag_id) SELECT DISTINCT ?, tag_id FROM bookmark_tags WHERE bookmark_id IN ? AND tag_id NOT IN (SELECT tag_id FROM bookmark_tags WHERE bookmark_id = ?)",
+			t.ID, bids, t.ID,
+		).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Bookmark{}).Where("id = ?", t.ID).Updates(map[string]any{
+			"notes":         strings.TrimSpace(strings.Join(notes, "\n\n")),
+			"collection_id": cid,
+			"updated_at":    time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return trashBookmarks(tx, bids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bs {
+		TriggerBookmarkWebhooks(EventBookmarkDeleted, b)
+	}
+	if err := DB.Preload("Snapshots").Preload("Tags").First(&t, t.ID).Error; err != nil {
+		return nil, err
+	}
+	TriggerBookmarkWebhooks(EventBookmarkUpdated, t)
+	return t, nil
+}
@@ -210,6 +210,7 @@ func AddFeedItem(i *FeedItem) int64 {
 		return 0
 	}
 	err = DB.Create(i).Error
+	isNew := err == nil
 	// TODO accept only UNIQUE constraint failed
 	// According to docs it is type of  gorm.ErrDuplicatedKey, but it does not work
 	if err != nil {
@@ -238,7 +239,23 @@ func AddFeedItem(i *FeedItem) int64 {
 			Unread:     !slices.Contains(uidsWithSameURLItems, u.ID),
 		}
 	}
-	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&uis).RowsAffected
+	added := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&uis).RowsAffected
+	if isNew {
+		data := &WebhookData{
+			FeedItem: &WebhookFeedItem{
+				ID:        i.ID,
+				FeedID:    f.ID,
+				FeedName:  f.Name,
+				URL:       i.URL,
+				Title:     i.Title,
+				CreatedAt: i.CreatedAt,
+			},
+		}
+		for _, u := range f.Users {
+			TriggerWebhooks(u.ID, EventFeedItemCreated, data)
+		}
+	}
+	return added
 }
 
 // GetUnreadFeedItems retrieves unread feed items for a user.
@@ -0,0 +1,45 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+// HighlightSearchCondition matches the bookmarks which have highlights
+// with the @query glob in their quote or comment. Private highlights match
+// only if they belong to the user with the @uid ID.
+const HighlightSearchCondition = "EXISTS (SELECT 1 FROM highlights WHERE highlights.bookmark_id = bookmarks.id AND highlights.deleted_at IS NULL" +
+	" AND (highlights.public = 1 OR highlights.user_id = @uid)" +
+	" AND (LOWER(highlights.exact) LIKE LOWER(@query) OR LOWER(highlights.comment) LIKE LOWER(@query)))"
+
+// Highlight is a quoted passage of a bookmarked page with an optional comment.
+// The passage is anchored with the quote, its context and its position in
+// the reader view of the snapshot, so it can be found in other snapshots of
+// the bookmark too.
+type Highlight struct {
+	CommonFields
+	UserID     uint     `gorm:"index" json:"user_id"`
+	BookmarkID uint     `gorm:"index" json:"bookmark_id"`
+	Bookmark   Bookmark `json:"-"`
+	SnapshotID uint     `json:"snapshot_id"`
+	Exact      string   `json:"exact"`
+	Prefix     string   `json:"prefix"`
+	Suffix     string   `json:"suffix"`
+	Start      int      `json:"start"`
+	End        int      `json:"end"`
+	Comment    string   `json:"comment"`
+	// Public highlights of public bookmarks are visible to everyone and
+	// are delivered to the ActivityPub followers of the user.
+	Public bool `json:"public"`
+}
+
+// GetHighlights returns the highlights of a bookmark in the order of their
+// position. Only public highlights are returned if onlyPublic is true.
+func GetHighlights(bid uint, onlyPublic bool) []*Highlight {
+	var hs []*Highlight
+	q := DB.Where("bookmark_id = ?", bid)
+	if onlyPublic {
+		q = q.Where("public = 1")
+	}
+	q.Order("start asc, id asc").Find(&hs)
+	return hs
+}
@@ -0,0 +1,71 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// LinkStatus is the result of checking the URL of a bookmark.
+type LinkStatus string
+
+const (
+	// LinkOK means that the page is available.
+	LinkOK LinkStatus = "ok"
+	// LinkRedirected means that the page redirects to a different URL.
+	LinkRedirected LinkStatus = "redirected"
+	// LinkDead means that the page or its domain does not exist anymore.
+	LinkDead LinkStatus = "dead"
+	// LinkError means that the check failed for another reason,
+	// e.g. timeout or server error.
+	LinkError LinkStatus = "error"
+)
+
+// LinkStatuses contains every link status.
+var LinkStatuses = []LinkStatus{LinkOK, LinkRedirected, LinkDead, LinkError}
+
+// ParseLinkStatus returns the link status named s or an empty status if s is unknown.
+func ParseLinkStatus(s string) LinkStatus {
+	if slices.Contains(LinkStatuses, LinkStatus(s)) {
+		return LinkStatus(s)
+	}
+	return ""
+}
+
+// LatestSnapshot returns the most recent snapshot of the bookmark or nil if
+// it has no snapshots. Snapshots must be preloaded.
+func (b *Bookmark) LatestSnapshot() *Snapshot {
+	var res *Snapshot
+	for i := range b.Snapshots {
+		if res == nil || b.Snapshots[i].CreatedAt.After(res.CreatedAt) {
+			res = &b.Snapshots[i]
+		}
+	}
+	return res
+}
+
+// GetBookmarksToCheck returns the bookmarks with http(s) URLs which were not
+// checked since t. Bookmarks never checked come first.
+func GetBookmarksToCheck(t time.Time, limit int) ([]*Bookmark, error) {
+	var bs []*Bookmark
+	err := DB.
+		Where("(link_checked_at IS NULL OR link_checked_at < ?)", t).
+		Where("(url LIKE 'http://%' OR url LIKE 'https://%')").
+		Order("link_checked_at IS NOT NULL, link_checked_at, id").
+		Limit(limit).
+		Find(&bs).Error
+	return bs, err
+}
+
+// SetLinkStatus stores the result of a link check of a bookmark.
+func SetLinkStatus(bid uint, s LinkStatus, code int, redirectURL string) error {
+	return DB.Model(&Bookmark{}).Where("id = ?", bid).UpdateColumns(map[string]any{
+		"link_status":       s,
+		"link_status_code":  code,
+		"link_redirect_url": redirectURL,
+		"link_checked_at":   time.Now(),
+	}).Error
+}
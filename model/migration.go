@@ -5,6 +5,8 @@
 package model
 
 import (
+	"strings"
+
 	"github.com/asciimoo/omnom/storage"
 
 	"github.com/rs/zerolog/log"
@@ -13,6 +15,10 @@ import (
 var migrationFunctions = []func() error{
 	addSnapshotSizes,             // db version 1
 	removeUnusedAPFollowerFields, // db version 2
+	dropAPFollowerUniqueIndex,    // db version 3
+	hashTokens,                   // db version 4
+	dropCollectionUniqueIndex,    // db version 5
+	addCanonicalURLs,             // db version 6
 }
 
 func migrate() error {
@@ -72,3 +78,80 @@ func removeUnusedAPFollowerFields() error {
 	}
 	return nil
 }
+
+// dropAPFollowerUniqueIndex drops the old follower index, auto migration
+// recreates it including the collection_id column.
+func dropAPFollowerUniqueIndex() error {
+	log.Debug().Msg("Dropping ActivityPub follower unique index")
+	if DB.Migrator().HasIndex(&APFollower{}, "apuidx") {
+		return DB.Migrator().DropIndex(&APFollower{}, "apuidx")
+	}
+	return nil
+}
+
+// dropCollectionUniqueIndex drops the old collection name index, auto migration
+// recreates it ignoring the collections in the trash.
+func dropCollectionUniqueIndex() error {
+	log.Debug().Msg("Dropping collection unique index")
+	if DB.Migrator().HasIndex(&Collection{}, "cuid") {
+		return DB.Migrator().DropIndex(&Collection{}, "cuid")
+	}
+	return nil
+}
+
+// hashTokens replaces the plain text tokens with their hashes.
+// Existing tokens keep working with the scopes of the browser addon.
+func hashTokens() error {
+	log.Debug().Msg("Hashing tokens")
+	if !DB.Migrator().HasColumn(&Token{}, "text") {
+		return nil
+	}
+	if err := DB.AutoMigrate(&Token{}); err != nil {
+		return err
+	}
+	var ts []struct {
+		ID   uint
+		Text string
+	}
+	if err := DB.Table("tokens").Select("id, text").Where("text IS NOT NULL AND text != ''").Find(&ts).Error; err != nil {
+		return err
+	}
+	scopes := make([]string, len(AddonTokenScopes))
+	for i, s := range AddonTokenScopes {
+		scopes[i] = string(s)
+	}
+	for _, t := range ts {
+		err := DB.Table("tokens").Where("id = ?", t.ID).Updates(map[string]any{
+			"name":   "Addon",
+			"hash":   HashToken(t.Text),
+			"prefix": tokenPrefix(t.Text),
+			"scopes": strings.Join(scopes, ","),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return DB.Migrator().DropColumn(&Token{}, "text")
+}
+
+// addCanonicalURLs fills the canonical URLs of the existing bookmarks.
+func addCanonicalURLs() error {
+	log.Debug().Msg("Adding canonical URLs to bookmarks")
+	if err := DB.AutoMigrate(&Bookmark{}); err != nil {
+		return err
+	}
+	var bs []struct {
+		ID  uint
+		URL string
+	}
+	err := DB.Table("bookmarks").Select("id, url").Where("canonical_url IS NULL OR canonical_url = ''").Find(&bs).Error
+	if err != nil {
+		return err
+	}
+	for _, b := range bs {
+		if err := DB.Table("bookmarks").Where("id = ?", b.ID).Update("canonical_url", CanonicalURL(b.URL)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
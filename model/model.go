@@ -14,10 +14,12 @@
 //   - FeedItems: Individual posts from subscribed feeds
 //   - Resources: Embedded media and assets from web pages
 //   - ActivityPub: Federation followers and interactions
+//   - Blocklists: Instance level domain blocks and user level actor blocks
 //
 // The package uses GORM as the ORM layer and supports both SQLite and PostgreSQL
-// databases. All models embed CommonFields which provide ID, timestamps, and
-// soft-delete functionality.
+// databases. All models embed CommonFields which provide ID and timestamps.
+// Bookmarks, snapshots and collections embed TrashableFields instead, which
+// add soft-delete functionality to move them to the trash.
 //
 // Database migrations are handled automatically through the Init function which
 // sets up the database connection and runs necessary schema updates.
@@ -37,6 +39,7 @@ import (
 	"time"
 
 	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/urlnorm"
 	"github.com/asciimoo/omnom/utils"
 
 	"gorm.io/gorm"
@@ -65,8 +68,11 @@ var DB *gorm.DB
 // DBType holds the type of the database being used.
 var DBType = Sqlite
 
+var urlNormalizer = urlnorm.New(config.URLs{})
+
 // Init initializes the database connection and runs migrations.
 func Init(c *config.Config) error {
+	urlNormalizer = urlnorm.New(c.URLs)
 	dbCfg := &gorm.Config{}
 	if c.App.DebugSQL {
 		dbCfg.Logger = logger.Default.LogMode(logger.Info)
@@ -124,6 +130,13 @@ func automigrate() error {
 		&FeedItem{},
 		&UserFeed{},
 		&UserFeedItem{},
+		&DomainBlock{},
+		&ActorBlock{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&DiffRule{},
+		&DiffStat{},
+		&Highlight{},
 	)
 }
 
@@ -140,3 +153,13 @@ type CommonFields struct {
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
 }
+
+// TrashableFields contains the common fields of the models which are moved
+// to the trash on deletion. Queries ignore the items in the trash unless
+// they are unscoped.
+type TrashableFields struct {
+	ID        uint           `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
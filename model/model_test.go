@@ -25,3 +25,14 @@ func TestSQLiteInit(t *testing.T) {
 		t.Errorf("Failed to initialize SQLite DB: %s", err)
 	}
 }
+
+func TestDomainBlockPublicDomain(t *testing.T) {
+	b := &DomainBlock{Domain: "bad.example.com"}
+	if d := b.PublicDomain(); d != "bad.example.com" {
+		t.Errorf("Unexpected domain: %s", d)
+	}
+	b.Obfuscate = true
+	if d := b.PublicDomain(); d != "bad.*******.com" {
+		t.Errorf("Unexpected obfuscated domain: %s", d)
+	}
+}
@@ -4,6 +4,14 @@
 
 package model
 
+// Kinds of resources which are captured by the server side snapshotter.
+// Regular page resources have an empty kind.
+const (
+	ResourceScreenshot = "screenshot"
+	ResourcePDF        = "pdf"
+	ResourceThumbnail  = "thumbnail"
+)
+
 // Resource represents a webpage resource like images or stylesheets.
 type Resource struct {
 	CommonFields
@@ -11,11 +19,18 @@ type Resource struct {
 	MimeType         string     `json:"mimeType"`
 	OriginalFilename string     `json:"originalFilename"`
 	Size             uint       `json:"size"`
+	Kind             string     `gorm:"index" json:"kind"`
 	Snapshots        []Snapshot `gorm:"many2many:snapshot_resources;" json:"snapshots"`
 }
 
 // GetOrCreateResource retrieves an existing resource or creates a new one.
 func GetOrCreateResource(key string, mimeType string, fname string, size uint) *Resource {
+	return GetOrCreateCapture("", key, mimeType, fname, size)
+}
+
+// GetOrCreateCapture retrieves an existing resource or creates a new one
+// with the given kind.
+func GetOrCreateCapture(kind string, key string, mimeType string, fname string, size uint) *Resource {
 	var r *Resource
 	if err := DB.Where("key = ?", key).First(&r).Error; err != nil {
 		r = &Resource{
@@ -23,6 +38,7 @@ func GetOrCreateResource(key string, mimeType string, fname string, size uint) *
 			MimeType:         mimeType,
 			OriginalFilename: fname,
 			Size:             size,
+			Kind:             kind,
 		}
 		DB.Create(&r)
 	}
@@ -4,9 +4,24 @@
 
 package model
 
+import (
+	"compress/gzip"
+	"errors"
+	"image"
+	"image/png"
+
+	"github.com/asciimoo/omnom/imagediff"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoScreenshot is returned when a snapshot has no screenshot capture.
+var ErrNoScreenshot = errors.New("snapshot has no screenshot")
+
 // Snapshot represents a saved webpage snapshot.
 type Snapshot struct {
-	CommonFields
+	TrashableFields
 	Title      string      `json:"title"`
 	Key        string      `json:"key"`
 	Text       string      `json:"text"`
@@ -16,6 +31,69 @@ type Snapshot struct {
 	Resources  []*Resource `gorm:"many2many:snapshot_resources;" json:"resources"`
 }
 
+// Capture returns the resource of the given kind or nil if the snapshot has
+// no such capture. Resources must be preloaded.
+func (s *Snapshot) Capture(kind string) *Resource {
+	for _, r := range s.Resources {
+		if r.Kind == kind {
+			return r
+		}
+	}
+	return nil
+}
+
+// Thumbnail returns the thumbnail of the snapshot or nil if it has none.
+func (s *Snapshot) Thumbnail() *Resource {
+	return s.Capture(ResourceThumbnail)
+}
+
+// DiffScreenshots compares the screenshots of two snapshots.
+// Resources must be preloaded.
+func DiffScreenshots(s1, s2 *Snapshot) (*imagediff.Result, error) {
+	i1, err := s1.screenshot()
+	if err != nil {
+		return nil, err
+	}
+	i2, err := s2.screenshot()
+	if err != nil {
+		return nil, err
+	}
+	return imagediff.Diff(i1, i2, imagediff.DefaultOptions), nil
+}
+
+// VisualChange returns the changed area of the screenshots of two snapshots
+// in percent or 0 if any of the snapshots has no screenshot.
+// Resources must be preloaded.
+func VisualChange(s1, s2 *Snapshot) float64 {
+	if s1.Capture(ResourceScreenshot) == nil || s2.Capture(ResourceScreenshot) == nil {
+		return 0
+	}
+	d, err := DiffScreenshots(s1, s2)
+	if err != nil {
+		log.Error().Err(err).Str("key1", s1.Key).Str("key2", s2.Key).Msg("Failed to compare screenshots")
+		return 0
+	}
+	return d.ChangedPercent
+}
+
+func (s *Snapshot) screenshot() (image.Image, error) {
+	r := s.Capture(ResourceScreenshot)
+	if r == nil {
+		return nil, ErrNoScreenshot
+	}
+	f, err := storage.GetResource(r.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	return png.Decode(gr)
+}
+
 // GetSnapshotWithResources retrieves a snapshot with its associated resources.
 func GetSnapshotWithResources(key string) (*Snapshot, error) {
 	var s *Snapshot
@@ -4,6 +4,13 @@
 
 package model
 
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
 // Tag represents a bookmark tag.
 type Tag struct {
 	CommonFields
@@ -13,6 +20,7 @@ type Tag struct {
 
 // TagCount represents a tag with its usage count.
 type TagCount struct {
+	ID    uint
 	Tag   string
 	Count int64
 }
@@ -20,10 +28,65 @@ type TagCount struct {
 // GetFrequentPublicTags retrieves the most frequently used public tags.
 func GetFrequentPublicTags(count int) []*TagCount {
 	var tags []*TagCount
-	DB.Limit(20).Table("tags").Select("tags.text as tag, count(tags.text) as `count`").Joins("join bookmark_tags on bookmark_tags.tag_id == tags.id").Joins("join bookmarks on bookmarks.id == bookmark_tags.bookmark_id").Where("bookmarks.public = true").Group("tags.text").Order("`count` desc, tag asc").Limit(count).Find(&tags)
+	DB.Limit(20).Table("tags").Select("tags.text as tag, count(tags.text) as `count`").Joins("join bookmark_tags on bookmark_tags.tag_id == tags.id").Joins("join bookmarks on bookmarks.id == bookmark_tags.bookmark_id").Where("bookmarks.public = true AND bookmarks.deleted_at IS NULL").Group("tags.text").Order("`count` desc, tag asc").Limit(count).Find(&tags)
 	return tags
 }
 
+// GetUserTags retrieves the tags of a user's bookmarks with usage counts.
+func GetUserTags(uid uint) ([]*TagCount, error) {
+	var tags []*TagCount
+	err := DB.Table("tags").
+		Select("tags.id as id, tags.text as tag, count(bookmarks.id) as `count`").
+		Joins("join bookmark_tags on bookmark_tags.tag_id == tags.id").
+		Joins("join bookmarks on bookmarks.id == bookmark_tags.bookmark_id").
+		Where("bookmarks.user_id = ?", uid).
+		Where("bookmarks.deleted_at IS NULL").
+		Group("tags.id").
+		Order("`count` desc, tag asc").
+		Find(&tags).Error
+	return tags, err
+}
+
+// RenameUserTag replaces a tag with the tag named text on every bookmark of a user.
+func RenameUserTag(uid, tid uint, text string) (*Tag, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("empty tag")
+	}
+	nt := GetOrCreateTag(text)
+	if nt.ID == tid {
+		return &nt, nil
+	}
+	userBookmarks := DB.Table("bookmarks").Select("id").Where("user_id = ?", uid)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// remove the old tag where the new one is already present to avoid duplicates
+		err := tx.Exec(
+			"DELETE FROM bookmark_tags WHERE tag_id = ? AND bookmark_id IN (?) AND bookmark_id IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = ?)",
+			tid, userBookmarks, nt.ID,
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(
+			"UPDATE bookmark_tags SET tag_id = ? WHERE tag_id = ? AND bookmark_id IN (?)",
+			nt.ID, tid, userBookmarks,
+		).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &nt, nil
+}
+
+// DeleteUserTag removes a tag from every bookmark of a user.
+func DeleteUserTag(uid, tid uint) (int64, error) {
+	res := DB.Exec(
+		"DELETE FROM bookmark_tags WHERE tag_id = ? AND bookmark_id IN (?)",
+		tid, DB.Table("bookmarks").Select("id").Where("user_id = ?", uid),
+	)
+	return res.RowsAffected, res.Error
+}
+
 // GetOrCreateTag retrieves an existing tag or creates a new one.
 func GetOrCreateTag(tag string) Tag {
 	var t Tag
@@ -47,7 +110,7 @@ WITH cte AS (SELECT ? AS namevar)
 SELECT tags.* FROM cte, tags
 JOIN bookmark_tags ON bookmark_tags.tag_id == tags.id
 JOIN bookmarks ON bookmarks.id == bookmark_tags.bookmark_id
-WHERE bookmarks.user_id = ? AND instr(lower(cte.namevar), lower(tags.text)) > 0
+WHERE bookmarks.user_id = ? AND bookmarks.deleted_at IS NULL AND instr(lower(cte.namevar), lower(tags.text)) > 0
 GROUP BY tags.id;
 `, s, uid).Scan(&res).Error
 	case Psql:
@@ -56,7 +119,7 @@ WITH cte AS (SELECT ? AS namevar)
 SELECT tags.* FROM cte, tags
 JOIN bookmark_tags ON bookmark_tags.tag_id == tags.id
 JOIN bookmarks ON bookmarks.id == bookmark_tags.bookmark_id
-WHERE bookmarks.user_id = ? AND position(lower(tags.text) IN lower(cte.namevar)) > 0
+WHERE bookmarks.user_id = ? AND bookmarks.deleted_at IS NULL AND position(lower(tags.text) IN lower(cte.namevar)) > 0
 GROUP BY tags.id;
 `, s, uid).Scan(&res).Error
 	default:
@@ -6,14 +6,57 @@ package model
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TokenScope defines what a token can access.
+type TokenScope string
+
+const (
+	// ScopeRead grants read-only access to bookmarks, snapshots, collections and tags.
+	ScopeRead TokenScope = "read"
+	// ScopeBookmarks grants write access to bookmarks, snapshots, collections and tags.
+	ScopeBookmarks TokenScope = "bookmarks"
+	// ScopeFeeds grants read and write access to feeds.
+	ScopeFeeds TokenScope = "feeds"
+	// ScopeAdmin grants full access, including the endpoints of the web interface.
+	ScopeAdmin TokenScope = "admin"
+)
+
+// TokenScopes contains all the valid token scopes.
+var TokenScopes = []TokenScope{ScopeRead, ScopeBookmarks, ScopeFeeds, ScopeAdmin}
+
+// AddonTokenScopes are the scopes required by the browser addon.
+var AddonTokenScopes = []TokenScope{ScopeRead, ScopeBookmarks}
+
+// ErrInvalidScope is returned if a token scope is unknown.
+var ErrInvalidScope = errors.New("invalid token scope")
+
+const (
+	tokenPrefixLength = 8
+	// tokenUsageResolution limits the database writes of frequently used tokens.
+	tokenUsageResolution = time.Minute
 )
 
 // Token represents an API or submission token.
+// Only the hash of the token is stored, the token itself is displayed once after creation.
 type Token struct {
 	CommonFields
-	UserID uint   `json:"user_id"`
-	Text   string `json:"text"`
+	UserID     uint       `json:"user_id"`
+	User       User       `json:"-"`
+	Name       string     `json:"name"`
+	Hash       string     `gorm:"uniqueIndex" json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
 }
 
 // GenerateToken generates a new random token string.
@@ -24,11 +67,134 @@ func GenerateToken() string {
 	return tok
 }
 
+// HashToken returns the hash of the token which is stored in the database.
+func HashToken(tok string) string {
+	h := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(h[:])
+}
+
+// ParseTokenScopes validates scope names.
+func ParseTokenScopes(scopes []string) ([]TokenScope, error) {
+	res := make([]TokenScope, 0, len(scopes))
+	for _, s := range scopes {
+		ts := TokenScope(strings.TrimSpace(s))
+		if !slices.Contains(TokenScopes, ts) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+		if !slices.Contains(res, ts) {
+			res = append(res, ts)
+		}
+	}
+	return res, nil
+}
+
+// CreateToken creates a new token for a user.
+// The returned string is the token itself, only its hash is stored.
+func CreateToken(uid uint, name string, scopes []TokenScope, expiresAt *time.Time) (*Token, string, error) {
+	tok := GenerateToken()
+	t, err := SetToken(uid, name, tok, scopes, expiresAt)
+	return t, tok, err
+}
+
+// SetToken stores the specified token for a user.
+func SetToken(uid uint, name, tok string, scopes []TokenScope, expiresAt *time.Time) (*Token, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scope specified", ErrInvalidScope)
+	}
+	ss := make([]string, len(scopes))
+	for i, s := range scopes {
+		if !slices.Contains(TokenScopes, s) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+		ss[i] = string(s)
+	}
+	t := &Token{
+		UserID:    uid,
+		Name:      name,
+		Hash:      HashToken(tok),
+		Prefix:    tokenPrefix(tok),
+		Scopes:    strings.Join(ss, ","),
+		ExpiresAt: expiresAt,
+	}
+	return t, DB.Create(t).Error
+}
+
 // CreateAddonToken creates a new addon token for a user.
-func CreateAddonToken(uid uint) (*Token, error) {
-	tok := &Token{
-		Text:   GenerateToken(),
-		UserID: uid,
+func CreateAddonToken(uid uint) (*Token, string, error) {
+	return CreateToken(uid, "Addon", AddonTokenScopes, nil)
+}
+
+// GetToken retrieves a valid, non-expired token with its user.
+func GetToken(tok string) *Token {
+	if tok == "" {
+		return nil
+	}
+	var t Token
+	err := DB.
+		Preload("User").
+		Where("hash = ? AND (expires_at IS NULL OR expires_at > ?)", HashToken(tok), time.Now()).
+		First(&t).Error
+	if err != nil || t.User.ID == 0 {
+		return nil
+	}
+	return &t
+}
+
+// GetUserTokens returns the tokens of a user.
+func GetUserTokens(uid uint) ([]*Token, error) {
+	var ts []*Token
+	err := DB.Where("user_id = ?", uid).Order("id desc").Find(&ts).Error
+	return ts, err
+}
+
+// DeleteToken revokes a token of a user.
+func DeleteToken(uid uint, id string) error {
+	return DB.Where("user_id = ? AND id = ?", uid, id).Delete(&Token{}).Error
+}
+
+// ScopeList returns the scopes of the token.
+func (t *Token) ScopeList() []TokenScope {
+	var res []TokenScope
+	for s := range strings.SplitSeq(t.Scopes, ",") {
+		if s != "" {
+			res = append(res, TokenScope(s))
+		}
+	}
+	return res
+}
+
+// HasScope reports whether the token grants access to s.
+// Admin tokens have every scope, an empty scope requires admin access.
+func (t *Token) HasScope(s TokenScope) bool {
+	scopes := t.ScopeList()
+	if slices.Contains(scopes, ScopeAdmin) {
+		return true
+	}
+	return s != "" && slices.Contains(scopes, s)
+}
+
+// Expired reports whether the token has expired.
+func (t *Token) Expired() bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())
+}
+
+// UpdateUsage records the time and the client IP of the last token usage.
+func (t *Token) UpdateUsage(ip string) error {
+	now := time.Now()
+	if t.LastUsedAt != nil && now.Sub(*t.LastUsedAt) < tokenUsageResolution && t.LastUsedIP == ip {
+		return nil
+	}
+	t.LastUsedAt = &now
+	t.LastUsedIP = ip
+	return DB.Model(t).UpdateColumns(map[string]any{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	}).Error
+}
+
+func tokenPrefix(tok string) string {
+	if len(tok) <= tokenPrefixLength {
+		return ""
 	}
-	return tok, DB.Create(tok).Error
+	return tok[:tokenPrefixLength]
 }
@@ -0,0 +1,345 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ErrNotInTrash is returned if a restored item is not in the trash.
+var ErrNotInTrash = errors.New("item is not in the trash")
+
+// ErrCollectionExists is returned if a collection cannot be restored,
+// because another collection has the same name.
+var ErrCollectionExists = errors.New("a collection with the same name already exists")
+
+// Trash contains the deleted items of a user.
+// Snapshots deleted together with their bookmarks are not listed separately.
+type Trash struct {
+	Bookmarks   []*Bookmark
+	Snapshots   []*Snapshot
+	Collections []*Collection
+}
+
+// IsEmpty reports whether the trash has no items.
+func (t *Trash) IsEmpty() bool {
+	return len(t.Bookmarks) == 0 && len(t.Snapshots) == 0 && len(t.Collections) == 0
+}
+
+// GetTrash retrieves the deleted bookmarks, snapshots and collections of a user.
+func GetTrash(uid uint) (*Trash, error) {
+	t := &Trash{}
+	err := DB.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", uid).
+		Preload("Snapshots", unscoped).
+		Preload("Tags").
+		Order("deleted_at desc").
+		Find(&t.Bookmarks).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Unscoped().
+		Model(&Snapshot{}).
+		Joins("join bookmarks on bookmarks.id = snapshots.bookmark_id").
+		Where("bookmarks.user_id = ? AND snapshots.deleted_at IS NOT NULL", uid).
+		Where("(bookmarks.deleted_at IS NULL OR bookmarks.deleted_at != snapshots.deleted_at)").
+		Preload("Bookmark", unscoped).
+		Order("snapshots.deleted_at desc").
+		Find(&t.Snapshots).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", uid).
+		Order("deleted_at desc").
+		Find(&t.Collections).Error
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RestoreBookmark restores a deleted bookmark of a user with the snapshots
+// deleted together with it. Tags and collection are kept during deletion,
+// so they are restored as well.
+func RestoreBookmark(uid uint, id string) (*Bookmark, error) {
+	var b *Bookmark
+	err := DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, uid).First(&b).Error
+	if err != nil {
+		return nil, ErrNotInTrash
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		return restoreBookmarks(tx, []uint{b.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.DeletedAt = gorm.DeletedAt{}
+	TriggerBookmarkWebhooks(EventBookmarkCreated, b)
+	return b, nil
+}
+
+// RestoreSnapshot restores a deleted snapshot of a user.
+// The bookmark of the snapshot is restored too if it is in the trash.
+func RestoreSnapshot(uid uint, id string) (*Snapshot, error) {
+	var s *Snapshot
+	err := DB.Unscoped().
+		Model(&Snapshot{}).
+		Joins("join bookmarks on bookmarks.id = snapshots.bookmark_id").
+		Where("snapshots.id = ? AND bookmarks.user_id = ? AND snapshots.deleted_at IS NOT NULL", id, uid).
+		Preload("Bookmark", unscoped).
+		First(&s).Error
+	if err != nil {
+		return nil, ErrNotInTrash
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if s.Bookmark.DeletedAt.Valid {
+			if err := restoreBookmarks(tx, []uint{s.BookmarkID}); err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Model(&Snapshot{}).Where("id = ?", s.ID).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.Bookmark.DeletedAt.Valid {
+		s.Bookmark.DeletedAt = gorm.DeletedAt{}
+		TriggerBookmarkWebhooks(EventBookmarkCreated, &s.Bookmark)
+	}
+	s.DeletedAt = gorm.DeletedAt{}
+	return s, nil
+}
+
+// TrashCollection moves a collection of a user and its subcollections to the trash.
+// Bookmarks keep their collection, so restoring the collection restores its content.
+func TrashCollection(uid uint, id string) error {
+	col := GetCollection(uid, id)
+	if col == nil {
+		return gorm.ErrRecordNotFound
+	}
+	ids := []uint{col.ID}
+	cols := GetCollections(uid)
+	for i := 0; i < len(ids); i++ {
+		for _, c := range cols {
+			if c.ParentID == ids[i] {
+				ids = append(ids, c.ID)
+			}
+		}
+	}
+	return DB.Model(&Collection{}).Where("id IN ?", ids).Update("deleted_at", time.Now()).Error
+}
+
+// RestoreCollection restores a deleted collection of a user with the
+// subcollections deleted together with it. Collections are moved to the
+// top level if their parent collection is in the trash.
+func RestoreCollection(uid uint, id string) (*Collection, error) {
+	var col *Collection
+	err := DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, uid).First(&col).Error
+	if err != nil {
+		return nil, ErrNotInTrash
+	}
+	var cols []*Collection
+	err = DB.Unscoped().
+		Where("user_id = ? AND deleted_at = (SELECT deleted_at FROM collections WHERE id = ?)", uid, col.ID).
+		Find(&cols).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(cols))
+	for _, c := range cols {
+		if GetCollectionByName(uid, c.Name) != nil {
+			return nil, ErrCollectionExists
+		}
+		ids = append(ids, c.ID)
+	}
+	var parent *Collection
+	if col.ParentID > 0 {
+		parent = GetCollection(uid, strconv.FormatUint(uint64(col.ParentID), 10))
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if parent == nil {
+			col.ParentID = 0
+			if err := tx.Unscoped().Model(&Collection{}).Where("id = ?", col.ID).Update("parent_id", 0).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Model(&Collection{}).Where("id IN ?", ids).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	col.DeletedAt = gorm.DeletedAt{}
+	return col, nil
+}
+
+// PurgeTrash permanently deletes the items moved to the trash before t.
+// Items of all users are purged if uid is 0.
+// Stored snapshot and resource files which are no longer used by any
+// snapshot are removed from the storage.
+// Returns the number of purged bookmarks, snapshots and collections.
+func PurgeTrash(uid uint, t time.Time) (int64, error) {
+	var bids, sids, cids []uint
+	bq := DB.Unscoped().Model(&Bookmark{}).Where("deleted_at < ?", t)
+	cq := DB.Unscoped().Model(&Collection{}).Where("deleted_at < ?", t)
+	if uid > 0 {
+		bq = bq.Where("user_id = ?", uid)
+		cq = cq.Where("user_id = ?", uid)
+	}
+	if err := bq.Pluck("id", &bids).Error; err != nil {
+		return 0, err
+	}
+	if err := cq.Pluck("id", &cids).Error; err != nil {
+		return 0, err
+	}
+	var ss []*Snapshot
+	sq := DB.Unscoped().
+		Model(&Snapshot{}).
+		Select("snapshots.id, snapshots.key").
+		Joins("join bookmarks on bookmarks.id = snapshots.bookmark_id").
+		Where("(snapshots.deleted_at < ? OR snapshots.bookmark_id IN ?)", t, nonEmptyIDs(bids))
+	if uid > 0 {
+		sq = sq.Where("bookmarks.user_id = ?", uid)
+	}
+	if err := sq.Find(&ss).Error; err != nil {
+		return 0, err
+	}
+	keys := make([]string, 0, len(ss))
+	for _, s := range ss {
+		sids = append(sids, s.ID)
+		keys = append(keys, s.Key)
+	}
+	var rids []uint
+	err := DB.Table("snapshot_resources").Distinct("resource_id").Where("snapshot_id IN ?", nonEmptyIDs(sids)).Pluck("resource_id", &rids).Error
+	if err != nil {
+		return 0, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if len(sids) > 0 {
+			if err := tx.Exec("DELETE FROM snapshot_resources WHERE snapshot_id IN ?", sids).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&Snapshot{}, "id IN ?", sids).Error; err != nil {
+				return err
+			}
+		}
+		if len(bids) > 0 {
+			if err := tx.Exec("DELETE FROM bookmark_tags WHERE bookmark_id IN ?", bids).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&DiffRule{}, "bookmark_id IN ?", bids).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&Highlight{}, "bookmark_id IN ?", bids).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&Bookmark{}, "id IN ?", bids).Error; err != nil {
+				return err
+			}
+		}
+		if len(cids) > 0 {
+			if err := tx.Unscoped().Model(&Bookmark{}).Where("collection_id IN ?", cids).Update("collection_id", 0).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&Collection{}).Where("parent_id IN ?", cids).Update("parent_id", 0).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&Collection{}, "id IN ?", cids).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	purgeSnapshotFiles(keys)
+	purgeResources(rids)
+	return int64(len(bids) + len(sids) + len(cids)), nil
+}
+
+// purgeSnapshotFiles removes the snapshot files which are not used by any snapshot.
+// Snapshots in the trash keep their files.
+func purgeSnapshotFiles(keys []string) {
+	for _, k := range keys {
+		var n int64
+		if err := DB.Unscoped().Model(&Snapshot{}).Where("key = ?", k).Count(&n).Error; err != nil || n > 0 {
+			continue
+		}
+		if err := storage.DeleteSnapshot(k); err != nil {
+			log.Warn().Err(err).Str("key", k).Msg("Failed to delete snapshot file")
+		}
+		if err := deleteDiffStats(k); err != nil {
+			log.Warn().Err(err).Str("key", k).Msg("Failed to delete snapshot diff stats")
+		}
+	}
+}
+
+// purgeResources deletes the resources and their files which are not used by any snapshot.
+func purgeResources(rids []uint) {
+	for _, id := range rids {
+		var n int64
+		if err := DB.Table("snapshot_resources").Where("resource_id = ?", id).Count(&n).Error; err != nil || n > 0 {
+			continue
+		}
+		var r *Resource
+		if err := DB.Where("id = ?", id).First(&r).Error; err != nil {
+			continue
+		}
+		if err := DB.Delete(r).Error; err != nil {
+			log.Warn().Err(err).Str("key", r.Key).Msg("Failed to delete resource")
+			continue
+		}
+		for _, del := range []func(string) error{storage.DeleteResource, storage.DeleteStream} {
+			if err := del(r.Key); err != nil {
+				log.Warn().Err(err).Str("key", r.Key).Msg("Failed to delete resource file")
+			}
+		}
+	}
+}
+
+// trashBookmarks moves bookmarks and their snapshots to the trash.
+// Snapshots get the deletion time of their bookmark, which allows
+// restoring them together.
+func trashBookmarks(tx *gorm.DB, bids []uint) error {
+	t := time.Now()
+	if err := tx.Model(&Snapshot{}).Where("bookmark_id IN ?", bids).Update("deleted_at", t).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Bookmark{}).Where("id IN ?", bids).Update("deleted_at", t).Error
+}
+
+// restoreBookmarks restores bookmarks from the trash
+// with the snapshots deleted together with them.
+func restoreBookmarks(tx *gorm.DB, bids []uint) error {
+	err := tx.Unscoped().
+		Model(&Snapshot{}).
+		Where("bookmark_id IN ?", bids).
+		Where("deleted_at = (SELECT bookmarks.deleted_at FROM bookmarks WHERE bookmarks.id = snapshots.bookmark_id)").
+		Update("deleted_at", nil).Error
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&Bookmark{}).Where("id IN ?", bids).Update("deleted_at", nil).Error
+}
+
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// nonEmptyIDs returns a non-empty ID list for IN queries.
+func nonEmptyIDs(l []uint) []uint {
+	if len(l) == 0 {
+		return []uint{0}
+	}
+	return l
+}
@@ -31,6 +31,13 @@ func GetUser(name string) *User {
 	return &u
 }
 
+// GetUserCount returns the number of registered users.
+func GetUserCount() int64 {
+	var res int64
+	DB.Model(&User{}).Count(&res)
+	return res
+}
+
 // GetUserByLoginToken retrieves a user by their login token.
 func GetUserByLoginToken(tok string) *User {
 	var u User
@@ -51,20 +58,6 @@ func GetUserByOAuthID(id string) *User {
 	return &u
 }
 
-// GetUserBySubmissionToken retrieves a user by their submission token.
-func GetUserBySubmissionToken(tok string) *User {
-	if tok == "" {
-		return nil
-	}
-	var u User
-	err := DB.Model(&User{}).Select("users.*").Joins("left join tokens on tokens.user_id = users.id").Where("tokens.text = ?", tok).First(&u).Error
-	if err != nil {
-		return nil
-	}
-
-	return &u
-}
-
 // CreateUser creates a new user with the specified username and email.
 func CreateUser(username, email string) error {
 	if GetUser(username) != nil {
@@ -79,9 +72,6 @@ func CreateUser(username, email string) error {
 		Email:      dbemail,
 		OAuthID:    dbemail,
 		LoginToken: GenerateToken(),
-		SubmissionTokens: []Token{Token{
-			Text: GenerateToken(),
-		}},
 	}
 	return DB.Create(u).Error
 }
@@ -0,0 +1,398 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookEvent is the type of an event delivered to webhooks.
+type WebhookEvent string
+
+const (
+	// EventBookmarkCreated is triggered when a new bookmark is created.
+	EventBookmarkCreated WebhookEvent = "bookmark.created"
+	// EventBookmarkUpdated is triggered when a bookmark or its tags are modified.
+	EventBookmarkUpdated WebhookEvent = "bookmark.updated"
+	// EventBookmarkDeleted is triggered when a bookmark is deleted.
+	EventBookmarkDeleted WebhookEvent = "bookmark.deleted"
+	// EventSnapshotCreated is triggered when a snapshot is added to a bookmark.
+	EventSnapshotCreated WebhookEvent = "snapshot.created"
+	// EventSnapshotUpdated is triggered when resources are added to a snapshot.
+	EventSnapshotUpdated WebhookEvent = "snapshot.updated"
+	// EventPageChanged is triggered when the text of a new snapshot differs
+	// from the text of the previous snapshot of the bookmark.
+	EventPageChanged WebhookEvent = "page.changed"
+	// EventFeedItemCreated is triggered when a new item is received in a subscribed feed.
+	EventFeedItemCreated WebhookEvent = "feed_item.created"
+)
+
+// WebhookEvents contains all the events webhooks can subscribe to.
+var WebhookEvents = []WebhookEvent{
+	EventBookmarkCreated,
+	EventBookmarkUpdated,
+	EventBookmarkDeleted,
+	EventSnapshotCreated,
+	EventSnapshotUpdated,
+	EventPageChanged,
+	EventFeedItemCreated,
+}
+
+// WebhookDeliveryStatus is the state of a webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are waiting for their first or next attempt.
+	DeliveryPending WebhookDeliveryStatus = "pending"
+	// DeliverySucceeded deliveries were accepted by the receiver.
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries ran out of attempts.
+	DeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// ErrInvalidWebhookEvent is returned if a webhook event is unknown.
+var ErrInvalidWebhookEvent = errors.New("invalid webhook event")
+
+// webhookQueue signals the delivery worker about new deliveries.
+var webhookQueue = make(chan struct{}, 1)
+
+// Webhook is a subscription of a user to events, which are
+// delivered to the URL of the webhook signed with its secret.
+type Webhook struct {
+	CommonFields
+	UserID uint   `gorm:"index" json:"user_id"`
+	User   User   `json:"-"`
+	URL    string `json:"url"`
+	Secret string `json:"-"`
+	Events string `json:"events"`
+}
+
+// WebhookDelivery is a queued, delivered or failed event of a webhook.
+type WebhookDelivery struct {
+	CommonFields
+	WebhookID     uint                  `gorm:"index" json:"webhook_id"`
+	Webhook       Webhook               `json:"-"`
+	Event         WebhookEvent          `json:"event"`
+	Payload       string                `json:"payload"`
+	Status        WebhookDeliveryStatus `gorm:"index" json:"status"`
+	Attempts      uint                  `json:"attempts"`
+	NextAttemptAt *time.Time            `json:"next_attempt_at"`
+	ResponseCode  int                   `json:"response_code"`
+	Error         string                `json:"error"`
+}
+
+// WebhookPayload is the JSON document sent to webhooks.
+type WebhookPayload struct {
+	Event     WebhookEvent `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      *WebhookData `json:"data"`
+}
+
+// WebhookData contains the objects related to a webhook event.
+type WebhookData struct {
+	Bookmark         *WebhookBookmark `json:"bookmark,omitempty"`
+	Snapshot         *WebhookSnapshot `json:"snapshot,omitempty"`
+	PreviousSnapshot *WebhookSnapshot `json:"previous_snapshot,omitempty"`
+	FeedItem         *WebhookFeedItem `json:"feed_item,omitempty"`
+	// VisualChange is the changed area of the screenshots of page.changed
+	// events in percent.
+	VisualChange float64 `json:"visual_change,omitempty"`
+}
+
+// WebhookBookmark is the representation of a bookmark in webhook payloads.
+type WebhookBookmark struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Notes     string    `json:"notes"`
+	Domain    string    `json:"domain"`
+	Public    bool      `json:"public"`
+	Unread    bool      `json:"unread"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookSnapshot is the representation of a snapshot in webhook payloads.
+type WebhookSnapshot struct {
+	ID        uint      `json:"id"`
+	Key       string    `json:"key"`
+	Title     string    `json:"title"`
+	Size      uint      `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookFeedItem is the representation of a feed item in webhook payloads.
+type WebhookFeedItem struct {
+	ID        uint      `json:"id"`
+	FeedID    uint      `json:"feed_id"`
+	FeedName  string    `json:"feed_name"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ParseWebhookEvents validates event names.
+func ParseWebhookEvents(events []string) ([]WebhookEvent, error) {
+	res := make([]WebhookEvent, 0, len(events))
+	for _, e := range events {
+		we := WebhookEvent(strings.TrimSpace(e))
+		if !slices.Contains(WebhookEvents, we) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWebhookEvent, e)
+		}
+		if !slices.Contains(res, we) {
+			res = append(res, we)
+		}
+	}
+	return res, nil
+}
+
+// CreateWebhook creates a new webhook with a random secret for a user.
+func CreateWebhook(uid uint, u string, events []WebhookEvent) (*Webhook, error) {
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || pu.Hostname() == "" || (pu.Scheme != "https" && pu.Scheme != "http") {
+		return nil, errors.New("invalid webhook URL")
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: no event specified", ErrInvalidWebhookEvent)
+	}
+	es := make([]string, len(events))
+	for i, e := range events {
+		if !slices.Contains(WebhookEvents, e) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWebhookEvent, e)
+		}
+		es[i] = string(e)
+	}
+	w := &Webhook{
+		UserID: uid,
+		URL:    pu.String(),
+		Secret: GenerateToken(),
+		Events: strings.Join(es, ","),
+	}
+	return w, DB.Create(w).Error
+}
+
+// GetUserWebhooks returns the webhooks of a user.
+func GetUserWebhooks(uid uint) ([]*Webhook, error) {
+	var ws []*Webhook
+	err := DB.Where("user_id = ?", uid).Order("id desc").Find(&ws).Error
+	return ws, err
+}
+
+// DeleteWebhook deletes a webhook of a user with its deliveries.
+func DeleteWebhook(uid uint, id string) error {
+	var w Webhook
+	if err := DB.Where("user_id = ? AND id = ?", uid, id).First(&w).Error; err != nil {
+		return err
+	}
+	if err := DB.Where("webhook_id = ?", w.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return DB.Delete(&w).Error
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of the webhooks of a user.
+func GetWebhookDeliveries(uid uint, limit int) ([]*WebhookDelivery, error) {
+	var ds []*WebhookDelivery
+	err := DB.
+		Preload("Webhook").
+		Joins("join webhooks on webhooks.id = webhook_deliveries.webhook_id").
+		Where("webhooks.user_id = ?", uid).
+		Order("webhook_deliveries.id desc").
+		Limit(limit).
+		Find(&ds).Error
+	return ds, err
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries which can be attempted.
+func GetDueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	var ds []*WebhookDelivery
+	err := DB.
+		Preload("Webhook").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", DeliveryPending, time.Now()).
+		Order("webhook_deliveries.id asc").
+		Limit(limit).
+		Find(&ds).Error
+	return ds, err
+}
+
+// PruneWebhookDeliveries deletes the finished deliveries created before t.
+func PruneWebhookDeliveries(t time.Time) (int64, error) {
+	res := DB.Where("status != ? AND created_at < ?", DeliveryPending, t).Delete(&WebhookDelivery{})
+	return res.RowsAffected, res.Error
+}
+
+// WebhookQueue returns a channel which receives a value when new deliveries are queued.
+func WebhookQueue() <-chan struct{} {
+	return webhookQueue
+}
+
+// EventList returns the events of the webhook.
+func (w *Webhook) EventList() []WebhookEvent {
+	var res []WebhookEvent
+	for e := range strings.SplitSeq(w.Events, ",") {
+		if e != "" {
+			res = append(res, WebhookEvent(e))
+		}
+	}
+	return res
+}
+
+// HasEvent reports whether the webhook is subscribed to e.
+func (w *Webhook) HasEvent(e WebhookEvent) bool {
+	return slices.Contains(w.EventList(), e)
+}
+
+// TriggerWebhooks queues deliveries of an event for the webhooks of a user.
+// Errors are logged, because webhooks must not break the triggering action.
+func TriggerWebhooks(uid uint, e WebhookEvent, data *WebhookData) {
+	queueDeliveries(subscribedWebhooks(uid, e), e, data)
+}
+
+// TriggerBookmarkWebhooks queues a bookmark event for the webhooks of the bookmark owner.
+func TriggerBookmarkWebhooks(e WebhookEvent, b *Bookmark) {
+	ws := subscribedWebhooks(b.UserID, e)
+	if len(ws) == 0 {
+		return
+	}
+	queueDeliveries(ws, e, &WebhookData{
+		Bookmark: NewWebhookBookmark(b),
+	})
+}
+
+// TriggerSnapshotWebhooks queues a snapshot event for the webhooks of the bookmark owner.
+// New snapshots also trigger EventPageChanged if their text or the screenshot
+// differs from the previous snapshot of the bookmark.
+func TriggerSnapshotWebhooks(e WebhookEvent, b *Bookmark, s *Snapshot) {
+	if ws := subscribedWebhooks(b.UserID, e); len(ws) > 0 {
+		queueDeliveries(ws, e, &WebhookData{
+			Bookmark: NewWebhookBookmark(b),
+			Snapshot: newWebhookSnapshot(s),
+		})
+	}
+	if e != EventSnapshotCreated {
+		return
+	}
+	ws := subscribedWebhooks(b.UserID, EventPageChanged)
+	if len(ws) == 0 {
+		return
+	}
+	var prev Snapshot
+	err := DB.
+		Where("bookmark_id = ? AND id != ?", b.ID, s.ID).
+		Preload("Resources").
+		Order("created_at desc").
+		First(&prev).Error
+	if err != nil {
+		return
+	}
+	vc := VisualChange(&prev, s)
+	if strings.TrimSpace(prev.Text) == strings.TrimSpace(s.Text) && vc < MinVisualChange {
+		return
+	}
+	queueDeliveries(ws, EventPageChanged, &WebhookData{
+		Bookmark:         NewWebhookBookmark(b),
+		Snapshot:         newWebhookSnapshot(s),
+		PreviousSnapshot: newWebhookSnapshot(&prev),
+		VisualChange:     vc,
+	})
+}
+
+func subscribedWebhooks(uid uint, e WebhookEvent) []*Webhook {
+	ws, err := GetUserWebhooks(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query webhooks")
+		return nil
+	}
+	res := make([]*Webhook, 0, len(ws))
+	for _, w := range ws {
+		if w.HasEvent(e) {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+func queueDeliveries(ws []*Webhook, e WebhookEvent, data *WebhookData) {
+	if len(ws) == 0 {
+		return
+	}
+	payload, err := json.Marshal(&WebhookPayload{
+		Event:     e,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("event", string(e)).Msg("Failed to serialize webhook payload")
+		return
+	}
+	queued := false
+	for _, w := range ws {
+		now := time.Now()
+		d := &WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         e,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := DB.Create(d).Error; err != nil {
+			log.Error().Err(err).Str("event", string(e)).Msg("Failed to queue webhook delivery")
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case webhookQueue <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// NewWebhookBookmark returns the webhook payload representation of a bookmark.
+// Tags are loaded from the database if they are not preloaded.
+func NewWebhookBookmark(b *Bookmark) *WebhookBookmark {
+	bts := b.Tags
+	if bts == nil && b.ID != 0 {
+		if err := DB.Model(b).Association("Tags").Find(&bts); err != nil {
+			log.Debug().Err(err).Msg("Failed to load bookmark tags")
+		}
+	}
+	tags := make([]string, len(bts))
+	for i, t := range bts {
+		tags[i] = t.Text
+	}
+	return &WebhookBookmark{
+		ID:        b.ID,
+		URL:       b.URL,
+		Title:     b.Title,
+		Notes:     b.Notes,
+		Domain:    b.Domain,
+		Public:    b.Public,
+		Unread:    b.Unread,
+		Tags:      tags,
+		CreatedAt: b.CreatedAt,
+		UpdatedAt: b.UpdatedAt,
+	}
+}
+
+func newWebhookSnapshot(s *Snapshot) *WebhookSnapshot {
+	return &WebhookSnapshot{
+		ID:        s.ID,
+		Key:       s.Key,
+		Title:     s.Title,
+		Size:      s.Size,
+		CreatedAt: s.CreatedAt,
+	}
+}
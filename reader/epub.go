@@ -0,0 +1,222 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EPUBMeta contains the metadata of an EPUB book.
+type EPUBMeta struct {
+	// Identifier is the unique ID of the book.
+	Identifier string
+	// URL is the address of the original page.
+	URL      string
+	Language string
+	Date     time.Time
+}
+
+// ImageLoader returns the content and the MIME type of an image of an
+// article.
+type ImageLoader func(src string) ([]byte, string, error)
+
+type epubImage struct {
+	ID       string
+	Path     string
+	MimeType string
+	content  []byte
+}
+
+var epubTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).Parse(`
+{{- define "container" }}<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{ end }}
+{{- define "package" }}<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">{{ .Meta.Identifier | xml }}</dc:identifier>
+    <dc:title>{{ .Article.Title | xml }}</dc:title>
+    <dc:language>{{ .Meta.Language | xml }}</dc:language>
+    {{- if .Meta.URL }}
+    <dc:source>{{ .Meta.URL | xml }}</dc:source>
+    {{- end }}
+    <dc:date>{{ .Meta.Date.UTC.Format "2006-01-02T15:04:05Z" }}</dc:date>
+    <meta property="dcterms:modified">{{ .Meta.Date.UTC.Format "2006-01-02T15:04:05Z" }}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="content" href="content.xhtml" media-type="application/xhtml+xml"/>
+    {{- range .Images }}
+    <item id="{{ .ID }}" href="{{ .Path }}" media-type="{{ .MimeType | xml }}"/>
+    {{- end }}
+  </manifest>
+  <spine>
+    <itemref idref="content"/>
+  </spine>
+</package>
+{{ end }}
+{{- define "nav" }}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>{{ .Article.Title | xml }}</title></head>
+<body>
+  <nav epub:type="toc">
+    <ol><li><a href="content.xhtml">{{ .Article.Title | xml }}</a></li></ol>
+  </nav>
+</body>
+</html>
+{{ end }}
+{{- define "content" }}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{ .Meta.Language | xml }}">
+<head><title>{{ .Article.Title | xml }}</title></head>
+<body>
+<h1>{{ .Article.Title | xml }}</h1>
+{{- if .Meta.URL }}
+<p><a href="{{ .Meta.URL | xml }}">{{ .Meta.URL | xml }}</a></p>
+{{- end }}
+{{ .Content }}
+</body>
+</html>
+{{ end }}`))
+
+// EPUB writes the article as an EPUB 3 book to w.
+// Images of the article are embedded using load, images which cannot be
+// loaded are left out.
+func EPUB(w io.Writer, a *Article, m *EPUBMeta, load ImageLoader) error {
+	if m.Language == "" {
+		m.Language = "en"
+	}
+	content, images, err := epubContent(a.Content, load)
+	if err != nil {
+		return err
+	}
+	data := map[string]any{
+		"Article": a,
+		"Meta":    m,
+		"Images":  images,
+		"Content": content,
+	}
+	z := zip.NewWriter(w)
+	// the mimetype file must be the first uncompressed entry of the archive
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "application/epub+zip"); err != nil {
+		return err
+	}
+	for name, tpl := range map[string]string{
+		"META-INF/container.xml": "container",
+		"EPUB/package.opf":       "package",
+		"EPUB/nav.xhtml":         "nav",
+		"EPUB/content.xhtml":     "content",
+	} {
+		f, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := epubTemplates.ExecuteTemplate(f, tpl, data); err != nil {
+			return err
+		}
+	}
+	for _, img := range images {
+		f, err := z.Create("EPUB/" + img.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(img.content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// epubContent returns the XHTML content of the article with the images
+// pointing to the files of the book.
+func epubContent(content string, load ImageLoader) (string, []*epubImage, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	ns, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return "", nil, err
+	}
+	var images []*epubImage
+	paths := make(map[string]string)
+	out := bytes.NewBuffer(nil)
+	for _, n := range ns {
+		body.AppendChild(n)
+	}
+	var imgs []*html.Node
+	for n := range body.Descendants() {
+		if n.DataAtom == atom.Img {
+			imgs = append(imgs, n)
+		}
+	}
+	for _, n := range imgs {
+		src := ""
+		for _, a := range n.Attr {
+			if a.Key == "src" {
+				src = a.Val
+			}
+		}
+		p, ok := paths[src]
+		if !ok {
+			if b, mimeType, err := load(src); err == nil {
+				img := &epubImage{
+					ID:       fmt.Sprintf("img%d", len(images)+1),
+					MimeType: mimeType,
+					content:  b,
+				}
+				ext := ".img"
+				if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+					ext = exts[0]
+				}
+				img.Path = "images/" + img.ID + ext
+				images = append(images, img)
+				p = img.Path
+			}
+			paths[src] = p
+		}
+		if p == "" {
+			n.Parent.RemoveChild(n)
+			continue
+		}
+		for i, a := range n.Attr {
+			if a.Key == "src" {
+				n.Attr[i].Val = p
+			}
+		}
+	}
+	// html.Render closes void elements, so the output is valid XHTML
+	for n := range body.ChildNodes() {
+		if err := html.Render(out, n); err != nil {
+			return "", nil, err
+		}
+	}
+	return out.String(), images, nil
+}
+
+func xmlEscape(s string) string {
+	b := bytes.NewBuffer(nil)
+	_ = xml.EscapeText(b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,161 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package reader
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Selector anchors a passage in the text of an article.
+// It combines the text quote and the text position selectors of the W3C Web
+// Annotation data model, positions are counted in unicode code points.
+type Selector struct {
+	Exact  string
+	Prefix string
+	Suffix string
+	Start  int
+	End    int
+}
+
+// Locate returns the position of the passage in text.
+// The occurrence of the quote with the best matching prefix and suffix is
+// selected, the stored position decides between equally matching ones.
+func (s *Selector) Locate(text []rune) (int, int, bool) {
+	exact := []rune(s.Exact)
+	if len(exact) == 0 {
+		return 0, 0, false
+	}
+	prefix := []rune(s.Prefix)
+	suffix := []rune(s.Suffix)
+	best, bestScore, bestDist := -1, -1, 0
+	for i := 0; i+len(exact) <= len(text); i++ {
+		if !hasRunes(text[i:], exact) {
+			continue
+		}
+		score := commonSuffix(text[:i], prefix) + commonPrefix(text[i+len(exact):], suffix)
+		dist := abs(i - s.Start)
+		if score > bestScore || (score == bestScore && dist < bestDist) {
+			best, bestScore, bestDist = i, score, dist
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	return best, best + len(exact), true
+}
+
+// Mark wraps the passages of the selectors in the article content into
+// <mark> elements. The data-highlight attribute of the marks contains the
+// index of the selector. Returns the indexes of the found selectors.
+func Mark(content string, sels []*Selector) (string, []int, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	ns, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, n := range ns {
+		body.AppendChild(n)
+	}
+	text := []rune(rawText(body))
+	var found []int
+	for i, s := range sels {
+		start, end, ok := s.Locate(text)
+		if !ok {
+			continue
+		}
+		found = append(found, i)
+		markRange(body, start, end, strconv.Itoa(i))
+	}
+	out := bytes.NewBuffer(nil)
+	for n := range body.ChildNodes() {
+		if err := html.Render(out, n); err != nil {
+			return "", nil, err
+		}
+	}
+	return out.String(), found, nil
+}
+
+// markRange wraps the text nodes between the start and end positions into
+// marks. Text nodes partially covered by the range are split.
+func markRange(root *html.Node, start, end int, id string) {
+	var nodes []*html.Node
+	for n := range root.Descendants() {
+		if n.Type == html.TextNode {
+			nodes = append(nodes, n)
+		}
+	}
+	pos := 0
+	for _, n := range nodes {
+		t := []rune(n.Data)
+		nStart, nEnd := pos, pos+len(t)
+		pos = nEnd
+		if nEnd <= start || nStart >= end {
+			continue
+		}
+		from := max(start, nStart) - nStart
+		to := min(end, nEnd) - nStart
+		if from > 0 {
+			n.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: string(t[:from])}, n)
+		}
+		if to < len(t) {
+			n.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: string(t[to:])}, n.NextSibling)
+		}
+		n.Data = string(t[from:to])
+		m := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "mark",
+			DataAtom: atom.Mark,
+			Attr:     []html.Attribute{{Key: "data-highlight", Val: id}},
+		}
+		n.Parent.InsertBefore(m, n)
+		n.Parent.RemoveChild(n)
+		m.AppendChild(n)
+	}
+}
+
+// rawText returns the concatenated text nodes of n like the textContent
+// property of DOM nodes.
+func rawText(n *html.Node) string {
+	var b strings.Builder
+	for d := range n.Descendants() {
+		if d.Type == html.TextNode {
+			b.WriteString(d.Data)
+		}
+	}
+	return b.String()
+}
+
+// hasRunes reports whether text starts with prefix.
+func hasRunes(text, prefix []rune) bool {
+	return commonPrefix(text, prefix) == len(prefix)
+}
+
+func commonPrefix(a, b []rune) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func commonSuffix(a, b []rune) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[len(a)-1-i] == b[len(b)-1-i] {
+		i++
+	}
+	return i
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
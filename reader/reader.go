@@ -0,0 +1,290 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package reader extracts the readable content of archived web pages.
+//
+// The main content of the page is detected with the same heuristics as the
+// main content option of the snapshot diffs. The content is reduced to a
+// whitelist of text formatting elements, so it can be displayed with the
+// typography of the application or packed into an EPUB file for e-readers.
+//
+// Example usage:
+//
+//	a, err := reader.Extract(snapshot, pageURL, func(src string) string {
+//	    return localImageURL(src)
+//	})
+//	fmt.Printf("%s - %d min read\n", a.Title, a.ReadingTime)
+package reader
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/asciimoo/omnom/contentdiff"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// wordsPerMinute is the average reading speed used to estimate the
+// reading time of articles.
+const wordsPerMinute = 200
+
+// Article is the readable content of a web page.
+type Article struct {
+	Title string `json:"title"`
+	// Content is the sanitized HTML of the main content.
+	Content     string `json:"content"`
+	Words       int    `json:"words"`
+	ReadingTime int    `json:"reading_time"`
+}
+
+// ImageFunc returns the URL of an image of the article.
+// Images are dropped if it returns an empty string.
+type ImageFunc func(src string) string
+
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true,
+	"caption": true, "cite": true, "code": true, "dd": true, "del": true,
+	"dl": true, "dt": true, "em": true, "figcaption": true, "figure": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"hr": true, "i": true, "img": true, "ins": true, "kbd": true, "li": true,
+	"mark": true, "ol": true, "p": true, "pre": true, "q": true, "s": true,
+	"samp": true, "small": true, "strong": true, "sub": true, "sup": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true,
+	"thead": true, "tr": true, "u": true, "ul": true,
+}
+
+var allowedAttrs = map[string]bool{
+	"alt":     true,
+	"colspan": true,
+	"rowspan": true,
+	"start":   true,
+	"title":   true,
+}
+
+var droppedTags = map[string]bool{
+	"aside": true, "audio": true, "button": true, "canvas": true,
+	"embed": true, "footer": true, "form": true, "head": true,
+	"iframe": true, "input": true, "nav": true, "noscript": true,
+	"object": true, "script": true, "select": true, "style": true,
+	"svg": true, "template": true, "textarea": true, "video": true,
+}
+
+var inlineTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "cite": true, "code": true,
+	"del": true, "em": true, "i": true, "ins": true, "kbd": true,
+	"mark": true, "q": true, "s": true, "samp": true, "small": true,
+	"span": true, "strong": true, "sub": true, "sup": true, "u": true,
+}
+
+// emptyTags are kept even if they contain no text.
+var emptyTags = map[string]bool{
+	"br": true, "hr": true, "img": true, "td": true, "th": true,
+}
+
+// Extract returns the readable main content of an HTML document.
+// Relative links are resolved against base.
+func Extract(r io.Reader, base *url.URL, img ImageFunc) (*Article, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	a := &Article{Title: strings.TrimSpace(findText(doc, atom.Title))}
+	main, err := contentdiff.FilterHTML(bytes.NewReader(b), &contentdiff.Options{MainContent: true})
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = html.Parse(bytes.NewReader(main)); err != nil {
+		return nil, err
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	c := &cleaner{base: base, img: img}
+	for n := range doc.Descendants() {
+		if n.DataAtom == atom.Body {
+			c.cleanChildren(n, root)
+			break
+		}
+	}
+	removeEmpty(root)
+	if a.Title == "" {
+		a.Title = strings.TrimSpace(findText(root, atom.H1))
+	}
+	removeTitle(root, a.Title)
+	a.Words = len(strings.Fields(textContent(root)))
+	a.ReadingTime = max(1, (a.Words+wordsPerMinute-1)/wordsPerMinute)
+	out := bytes.NewBuffer(nil)
+	for n := range root.ChildNodes() {
+		if err := html.Render(out, n); err != nil {
+			return nil, err
+		}
+	}
+	a.Content = out.String()
+	return a, nil
+}
+
+type cleaner struct {
+	base *url.URL
+	img  ImageFunc
+}
+
+// cleanChildren appends the sanitized copies of the children of n to dst.
+// Elements which are not allowed are replaced by their children.
+func (c *cleaner) cleanChildren(n, dst *html.Node) {
+	for ch := range n.ChildNodes() {
+		switch ch.Type {
+		case html.TextNode:
+			dst.AppendChild(&html.Node{Type: html.TextNode, Data: ch.Data})
+		case html.ElementNode:
+			if droppedTags[ch.Data] {
+				continue
+			}
+			if !allowedTags[ch.Data] {
+				c.cleanChildren(ch, dst)
+				continue
+			}
+			e := &html.Node{Type: html.ElementNode, Data: ch.Data, DataAtom: ch.DataAtom}
+			if !c.cleanAttrs(ch, e) {
+				c.cleanChildren(ch, dst)
+				continue
+			}
+			dst.AppendChild(e)
+			c.cleanChildren(ch, e)
+		}
+	}
+}
+
+// cleanAttrs copies the allowed attributes of n to e.
+// Returns false if the element has to be replaced by its children.
+func (c *cleaner) cleanAttrs(n, e *html.Node) bool {
+	for _, a := range n.Attr {
+		switch {
+		case a.Namespace != "":
+		case allowedAttrs[a.Key]:
+			e.Attr = append(e.Attr, html.Attribute{Key: a.Key, Val: a.Val})
+		case a.Key == "href" && n.DataAtom == atom.A:
+			if u := c.resolveLink(a.Val); u != "" {
+				e.Attr = append(e.Attr, html.Attribute{Key: "href", Val: u})
+			}
+		case a.Key == "src" && n.DataAtom == atom.Img:
+			if u := c.img(strings.TrimSpace(a.Val)); u != "" {
+				e.Attr = append(e.Attr, html.Attribute{Key: "src", Val: u})
+			}
+		}
+	}
+	switch n.DataAtom {
+	case atom.A:
+		return hasAttr(e, "href")
+	case atom.Img:
+		return hasAttr(e, "src")
+	}
+	return true
+}
+
+// resolveLink returns the absolute URL of a link or an empty string if the
+// link cannot be followed outside of the page.
+func (c *cleaner) resolveLink(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return u.String()
+	}
+	return ""
+}
+
+// removeEmpty removes the elements without text and images.
+func removeEmpty(n *html.Node) {
+	for ch := n.FirstChild; ch != nil; {
+		next := ch.NextSibling
+		if ch.Type == html.ElementNode {
+			removeEmpty(ch)
+			if !emptyTags[ch.Data] && ch.FirstChild == nil {
+				n.RemoveChild(ch)
+			}
+		}
+		ch = next
+	}
+	if n.Type == html.ElementNode && !emptyTags[n.Data] && strings.TrimSpace(textContent(n)) == "" && !hasDescendant(n, atom.Img) {
+		for n.FirstChild != nil {
+			n.RemoveChild(n.FirstChild)
+		}
+	}
+}
+
+// removeTitle removes the first heading if it repeats the title.
+func removeTitle(root *html.Node, title string) {
+	for n := range root.ChildNodes() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.DataAtom == atom.H1 && textContent(n) == title {
+			root.RemoveChild(n)
+		}
+		return
+	}
+}
+
+func findText(n *html.Node, a atom.Atom) string {
+	for d := range n.Descendants() {
+		if d.DataAtom == a {
+			return textContent(d)
+		}
+	}
+	return ""
+}
+
+// textContent returns the normalized text of n.
+// Block elements are separated by spaces, inline elements are not.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	writeText(&b, n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	for ch := range n.ChildNodes() {
+		switch ch.Type {
+		case html.TextNode:
+			b.WriteString(ch.Data)
+		case html.ElementNode:
+			if !inlineTags[ch.Data] {
+				b.WriteString(" ")
+			}
+			writeText(b, ch)
+			if !inlineTags[ch.Data] {
+				b.WriteString(" ")
+			}
+		}
+	}
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDescendant(n *html.Node, a atom.Atom) bool {
+	for d := range n.Descendants() {
+		if d.DataAtom == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testDoc = `<html><head><title>Test article</title><style>p{color:red}</style></head><body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<article>
+<h1>Test article</h1>
+<p onclick="x()" class="lead">First <b>paragraph</b> with a <a href="/next">link</a> and a <a href="javascript:alert(1)">script</a>.</p>
+<div><span></span></div>
+<img src="../../resources/aa/a.png" alt="local"><img src="https://example.com/remote.png">
+<script>alert(1)</script>
+<p>` + strings.Repeat("word ", 400) + `</p>
+</article>
+<footer>Copyright</footer>
+</body></html>`
+
+func testImage(src string) string {
+	if strings.HasPrefix(src, "../../resources/") {
+		return "/static/data/resources/" + strings.TrimPrefix(src, "../../resources/")
+	}
+	return ""
+}
+
+func TestExtract(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/test")
+	a, err := Extract(strings.NewReader(testDoc), base, testImage)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Test article", a.Title)
+	assert.Contains(t, a.Content, `<p>First <b>paragraph</b> with a <a href="https://example.com/next">link</a> and a script.</p>`)
+	assert.Contains(t, a.Content, `<img src="/static/data/resources/aa/a.png" alt="local"/>`)
+	assert.NotContains(t, a.Content, "remote.png")
+	assert.NotContains(t, a.Content, "<h1>")
+	assert.NotContains(t, a.Content, "alert")
+	assert.NotContains(t, a.Content, "Home")
+	assert.NotContains(t, a.Content, "<span>")
+	assert.Equal(t, 408, a.Words)
+	assert.Equal(t, 3, a.ReadingTime)
+}
+
+func TestEPUB(t *testing.T) {
+	a := &Article{
+		Title:   "Tom & Jerry",
+		Content: `<p>text<br>more</p><img src="a.png" alt="a"><img src="a.png"><img src="missing.png">`,
+	}
+	load := func(src string) ([]byte, string, error) {
+		if src == "a.png" {
+			return []byte("PNG"), "image/png", nil
+		}
+		return nil, "", errors.New("not found")
+	}
+	out := bytes.NewBuffer(nil)
+	m := &EPUBMeta{Identifier: "abc", URL: "https://example.com/?a=1&b=2", Date: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if !assert.NoError(t, EPUB(out, a, m, load)) {
+		return
+	}
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "mimetype", z.File[0].Name)
+	assert.Equal(t, zip.Store, z.File[0].Method)
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(r)
+		files[f.Name] = string(b)
+	}
+	assert.Equal(t, "application/epub+zip", files["mimetype"])
+	assert.Contains(t, files["META-INF/container.xml"], `full-path="EPUB/package.opf"`)
+	assert.Contains(t, files["EPUB/package.opf"], "<dc:title>Tom &amp; Jerry</dc:title>")
+	assert.Contains(t, files["EPUB/package.opf"], "<dc:source>https://example.com/?a=1&amp;b=2</dc:source>")
+	assert.Contains(t, files["EPUB/package.opf"], `<meta property="dcterms:modified">2025-01-02T03:04:05Z</meta>`)
+	assert.Contains(t, files["EPUB/package.opf"], `<item id="img1" href="images/img1.png" media-type="image/png"/>`)
+	assert.Contains(t, files["EPUB/content.xhtml"], `<p>text<br/>more</p><img src="images/img1.png" alt="a"/><img src="images/img1.png"/>`)
+	assert.NotContains(t, files["EPUB/content.xhtml"], "missing.png")
+	assert.Equal(t, "PNG", files["EPUB/images/img1.png"])
+	assert.Len(t, z.File, 6)
+}
+
+func TestSelectorLocate(t *testing.T) {
+	text := []rune("the cat saw the cat and the dog")
+	s := &Selector{Exact: "the cat", Prefix: "saw ", Suffix: " and", Start: 0}
+	start, end, ok := s.Locate(text)
+	assert.True(t, ok)
+	assert.Equal(t, 12, start)
+	assert.Equal(t, 19, end)
+	s.Start = 12
+	start, _, _ = s.Locate(text)
+	assert.Equal(t, 12, start)
+	// outdated position
+	s = &Selector{Exact: "dog", Start: 5}
+	start, _, ok = s.Locate(text)
+	assert.True(t, ok)
+	assert.Equal(t, 28, start)
+	s = &Selector{Exact: "bird"}
+	_, _, ok = s.Locate(text)
+	assert.False(t, ok)
+}
+
+func TestMark(t *testing.T) {
+	content := `<p>Hello <b>wörld</b>!</p><p>Second</p>`
+	sels := []*Selector{
+		{Exact: "lo wö", Start: 3},
+		{Exact: "missing"},
+		{Exact: "Sec", Start: 100},
+	}
+	out, found, err := Mark(content, sels)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, found)
+	assert.Equal(t, `<p>Hel<mark data-highlight="0">lo </mark><b><mark data-highlight="0">wö</mark>rld</b>!</p><p><mark data-highlight="2">Sec</mark>ond</p>`, out)
+}
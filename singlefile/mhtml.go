@@ -0,0 +1,102 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package singlefile
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"time"
+)
+
+// base64LineLength is the maximum line length of base64 encoded MIME parts.
+const base64LineLength = 76
+
+type mhtmlPart struct {
+	id       string
+	mimeType string
+	content  []byte
+}
+
+// MHTML writes the snapshot read from r to w as an MHTML document.
+// Resources are referenced with cid: URLs from the document.
+// location is the URL of the archived page.
+func MHTML(w io.Writer, r io.Reader, open Opener, location, title string, date time.Time) error {
+	var parts []*mhtmlPart
+	p := &packer{
+		open: open,
+		embed: func(mimeType string, content []byte) string {
+			part := &mhtmlPart{
+				id:       fmt.Sprintf("part%d@omnom", len(parts)+1),
+				mimeType: mimeType,
+				content:  content,
+			}
+			parts = append(parts, part)
+			return "cid:" + part.id
+		},
+		urls: make(map[string]string),
+	}
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	doc, err := p.packHTML(in, 0)
+	if err != nil {
+		return err
+	}
+	mw := multipart.NewWriter(w)
+	header := fmt.Sprintf("From: <Saved by Omnom>\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/related; type=\"text/html\"; boundary=\"%s\"\r\n\r\n",
+		mime.QEncoding.Encode("utf-8", title), date.Format(time.RFC1123Z), mw.Boundary())
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	h.Set("Content-Location", location)
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(pw)
+	if _, err := qw.Write(doc); err != nil {
+		return err
+	}
+	if err := qw.Close(); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", part.mimeType)
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-ID", "<"+part.id+">")
+		h.Set("Content-Location", "cid:"+part.id)
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if err := writeBase64Lines(pw, part.content); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func writeBase64Lines(w io.Writer, b []byte) error {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 0 {
+		n := min(len(s), base64LineLength)
+		if _, err := io.WriteString(w, s[:n]+"\r\n"); err != nil {
+			return err
+		}
+		s = s[n:]
+	}
+	return nil
+}
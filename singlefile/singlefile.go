@@ -0,0 +1,330 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package singlefile packs snapshots and their resources into
+// self-contained files.
+//
+// Two formats are supported:
+//   - HTML: resources are embedded as data URLs into a single HTML file
+//   - MHTML: resources are stored as the parts of a multipart/related
+//     MIME message (RFC 2557) which browsers can open natively
+//
+// Resources are collected from the src, srcset, poster and data attributes,
+// from stylesheet and icon links, and from CSS url() and @import references
+// of style elements and style attributes. Stylesheets and frames are
+// processed recursively, so fonts and images of stylesheets are embedded too.
+//
+// The snapshots reference their resources with storage specific URLs, so the
+// caller provides an Opener which decides which references are embedded.
+//
+// Example usage:
+//
+//	err := singlefile.HTML(w, snapshot, func(ref string) (*singlefile.Resource, error) {
+//	    if !isLocal(ref) {
+//	        return nil, singlefile.ErrSkip
+//	    }
+//	    return openResource(ref)
+//	})
+package singlefile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+)
+
+// maxDepth limits the embedding of nested frames and stylesheet imports.
+const maxDepth = 3
+
+// ErrSkip is returned by an Opener if a reference is not embedded.
+var ErrSkip = errors.New("resource skipped")
+
+// Resource is an embeddable resource of a snapshot.
+type Resource struct {
+	io.ReadCloser
+	MimeType string
+}
+
+// Opener opens the resource referenced by a snapshot.
+// It returns ErrSkip if the reference has to be kept unmodified.
+type Opener func(ref string) (*Resource, error)
+
+// embedFunc stores the content of a resource and returns its new URL.
+type embedFunc func(mimeType string, content []byte) string
+
+var resourceAttrs = map[string]bool{
+	"background": true,
+	"data":       true,
+	"poster":     true,
+	"src":        true,
+	"srcset":     true,
+}
+
+var linkRels = map[string]bool{
+	"apple-touch-icon": true,
+	"icon":             true,
+	"shortcut":         true,
+	"stylesheet":       true,
+}
+
+type packer struct {
+	open  Opener
+	embed embedFunc
+	urls  map[string]string
+}
+
+// HTML writes the snapshot read from r to w as a single HTML file with
+// every resource embedded as data URL.
+func HTML(w io.Writer, r io.Reader, open Opener) error {
+	p := &packer{
+		open: open,
+		embed: func(mimeType string, content []byte) string {
+			return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(content)
+		},
+		urls: make(map[string]string),
+	}
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	out, err := p.packHTML(in, 0)
+	if err != nil {
+		return err
+	}
+	if !hasDoctype(out) {
+		if _, err := io.WriteString(w, "<!DOCTYPE html>"); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// resolve returns the new URL of a reference.
+// Unavailable resources keep their original reference.
+func (p *packer) resolve(ref string, depth int) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	if u, ok := p.urls[ref]; ok {
+		return u
+	}
+	res, err := p.open(ref)
+	if err != nil {
+		if !errors.Is(err, ErrSkip) {
+			log.Debug().Err(err).Str("ref", ref).Msg("Failed to open snapshot resource")
+		}
+		p.urls[ref] = ref
+		return ref
+	}
+	content, err := io.ReadAll(res)
+	res.Close()
+	if err != nil {
+		log.Debug().Err(err).Str("ref", ref).Msg("Failed to read snapshot resource")
+		p.urls[ref] = ref
+		return ref
+	}
+	mimeType := strings.TrimSpace(strings.Split(res.MimeType, ";")[0])
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	// mark the reference to avoid infinite loops of recursive resources
+	p.urls[ref] = ref
+	if depth < maxDepth {
+		switch mimeType {
+		case "text/css":
+			content = p.packCSS(content, depth+1)
+		case "text/html":
+			if c, err := p.packHTML(content, depth+1); err == nil {
+				content = c
+			}
+		}
+	}
+	u := p.embed(mimeType, content)
+	p.urls[ref] = u
+	return u
+}
+
+// packHTML replaces the resource references of an HTML document.
+// Unmodified tokens are written unchanged.
+func (p *packer) packHTML(in []byte, depth int) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(in)))
+	z := html.NewTokenizer(bytes.NewReader(in))
+	inStyle := false
+	for {
+		tt := z.Next()
+		raw := z.Raw()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return out.Bytes(), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			raw = append([]byte(nil), raw...)
+			t := z.Token()
+			if t.Data == "style" {
+				inStyle = tt == html.StartTagToken
+			}
+			if p.packAttrs(&t, depth) {
+				out.WriteString(t.String())
+				continue
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+		case html.TextToken:
+			if inStyle {
+				out.Write(p.packCSS(raw, depth))
+				continue
+			}
+		}
+		out.Write(raw)
+	}
+}
+
+// packAttrs replaces the resource references of the attributes of a tag.
+// Returns true if the tag has been modified.
+func (p *packer) packAttrs(t *html.Token, depth int) bool {
+	changed := false
+	isLink := t.Data == "link" && isResourceLink(t)
+	for i, a := range t.Attr {
+		var v string
+		switch {
+		case a.Key == "style":
+			v = string(p.packCSS([]byte(a.Val), depth))
+		case a.Key == "srcset":
+			v = p.packSrcset(a.Val, depth)
+		case resourceAttrs[a.Key], isLink && a.Key == "href":
+			v = p.resolve(a.Val, depth)
+		default:
+			continue
+		}
+		if v != a.Val {
+			t.Attr[i].Val = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+// packSrcset replaces the URLs of the image candidates of a srcset attribute.
+// URLs are terminated by whitespace, so data URLs containing commas are
+// handled correctly.
+func (p *packer) packSrcset(s string, depth int) string {
+	var b strings.Builder
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			break
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		u, descriptor := s[:end], ""
+		s = s[end:]
+		if trimmed := strings.TrimRight(u, ","); trimmed != u {
+			u = trimmed
+		} else if i := strings.IndexByte(s, ','); i >= 0 {
+			descriptor, s = strings.TrimSpace(s[:i]), s[i+1:]
+		} else {
+			descriptor, s = strings.TrimSpace(s), ""
+		}
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.resolve(u, depth))
+		if descriptor != "" {
+			b.WriteString(" " + descriptor)
+		}
+	}
+	return b.String()
+}
+
+// packCSS replaces the url() and @import references of a stylesheet.
+func (p *packer) packCSS(in []byte, depth int) []byte {
+	out := bytes.NewBuffer(make([]byte, 0, len(in)))
+	l := css.NewLexer(parse.NewInputBytes(in))
+	inImport := false
+	for {
+		tt, text := l.Next()
+		switch tt {
+		case css.ErrorToken:
+			return out.Bytes()
+		case css.AtKeywordToken:
+			inImport = strings.EqualFold(string(text), "@import")
+		case css.URLToken:
+			inImport = false
+			if u, ok := cssURL(text); ok {
+				out.WriteString(`url("` + cssEscape(p.resolve(u, depth)) + `")`)
+				continue
+			}
+		case css.StringToken:
+			if inImport {
+				inImport = false
+				out.WriteString(`"` + cssEscape(p.resolve(cssString(text), depth)) + `"`)
+				continue
+			}
+		case css.WhitespaceToken, css.CommentToken:
+		default:
+			inImport = false
+		}
+		out.Write(text)
+	}
+}
+
+func isResourceLink(t *html.Token) bool {
+	for _, a := range t.Attr {
+		if a.Key != "rel" {
+			continue
+		}
+		for rel := range strings.FieldsSeq(strings.ToLower(a.Val)) {
+			if linkRels[rel] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cssURL returns the unquoted URL of a CSS url() token.
+func cssURL(t []byte) (string, bool) {
+	s := string(t)
+	if len(s) < 5 || !strings.EqualFold(s[:4], "url(") || !strings.HasSuffix(s, ")") {
+		return "", false
+	}
+	s = strings.TrimSpace(s[4 : len(s)-1])
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		return cssString([]byte(s)), true
+	}
+	return s, true
+}
+
+// cssString returns the content of a quoted CSS string.
+func cssString(t []byte) string {
+	s := string(t)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+func cssEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `).Replace(s)
+}
+
+func hasDoctype(h []byte) bool {
+	h = bytes.TrimSpace(h)
+	return len(h) >= 9 && bytes.EqualFold(h[:9], []byte("<!doctype"))
+}
@@ -0,0 +1,106 @@
+package singlefile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testResources = map[string]*struct {
+	mimeType string
+	content  string
+}{
+	"../../resources/aa/a.png":   {"image/png", "PNG"},
+	"../../resources/bb/b.png":   {"image/png", "PNG2"},
+	"../../resources/cc/c.css":   {"text/css", `@font-face{src:url(../../resources/dd/d.woff2)} @import "../../resources/ee/e.css";`},
+	"../../resources/dd/d.woff2": {"font/woff2", "WOFF"},
+	"../../resources/ee/e.css":   {"text/css", `p{background:url('../../resources/aa/a.png')}`},
+}
+
+func testOpener(ref string) (*Resource, error) {
+	if !strings.HasPrefix(ref, "../../resources/") {
+		return nil, ErrSkip
+	}
+	r, ok := testResources[ref]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &Resource{ReadCloser: io.NopCloser(strings.NewReader(r.content)), MimeType: r.mimeType}, nil
+}
+
+func dataURL(mimeType, content string) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString([]byte(content))
+}
+
+func TestHTML(t *testing.T) {
+	doc := `<html><head><link rel="stylesheet" href="../../resources/cc/c.css"><link rel="canonical" href="https://example.com/"></head>` +
+		`<body><img src="../../resources/aa/a.png" srcset="../../resources/aa/a.png 1x, ../../resources/bb/b.png 2x">` +
+		`<div style="background: url(../../resources/bb/b.png)">x</div><img src="../../resources/xx/missing.png"><img src="https://example.com/a.png"></body></html>`
+	out := bytes.NewBuffer(nil)
+	assert.NoError(t, HTML(out, strings.NewReader(doc), testOpener))
+	s := out.String()
+	a := dataURL("image/png", "PNG")
+	b := dataURL("image/png", "PNG2")
+	assert.True(t, strings.HasPrefix(s, "<!DOCTYPE html><html>"))
+	assert.Contains(t, s, `<img src="`+a+`" srcset="`+a+` 1x, `+b+` 2x">`)
+	assert.Contains(t, s, `style="background: url(&#34;`+b+`&#34;)"`)
+	assert.Contains(t, s, `<img src="../../resources/xx/missing.png">`)
+	assert.Contains(t, s, `<img src="https://example.com/a.png">`)
+	assert.Contains(t, s, `<link rel="canonical" href="https://example.com/">`)
+	e := dataURL("text/css", `p{background:url("`+a+`")}`)
+	c := dataURL("text/css", `@font-face{src:url("`+dataURL("font/woff2", "WOFF")+`")} @import "`+e+`";`)
+	assert.Contains(t, s, `<link rel="stylesheet" href="`+c+`">`)
+}
+
+func TestSrcset(t *testing.T) {
+	p := &packer{open: testOpener, embed: func(_ string, c []byte) string { return "x" + string(c) }, urls: map[string]string{}}
+	assert.Equal(t, "xPNG 100w, xPNG2", p.packSrcset(" ../../resources/aa/a.png 100w,../../resources/bb/b.png", 0))
+	assert.Equal(t, "data:image/png;base64,a,b 1x, xPNG2 2x", p.packSrcset("data:image/png;base64,a,b 1x, ../../resources/bb/b.png 2x", 0))
+	assert.Equal(t, "xPNG 1x, xPNG2 2x", p.packSrcset("../../resources/aa/a.png 1x,../../resources/bb/b.png 2x", 0))
+}
+
+func TestMHTML(t *testing.T) {
+	doc := `<html><body><p>héllo</p><img src="../../resources/aa/a.png"><img src="../../resources/aa/a.png" srcset="../../resources/bb/b.png 2x"></body></html>`
+	out := bytes.NewBuffer(nil)
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, MHTML(out, strings.NewReader(doc), testOpener, "https://example.com/page", "Example", date))
+	m, err := mail.ReadMessage(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Example", m.Header.Get("Subject"))
+	mt, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/related", mt)
+	assert.Equal(t, "text/html", params["type"])
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	root, err := mr.NextPart()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "https://example.com/page", root.Header.Get("Content-Location"))
+	// the multipart reader decodes quoted-printable parts
+	body, _ := io.ReadAll(root)
+	assert.Equal(t, `<html><body><p>héllo</p><img src="cid:part1@omnom"><img src="cid:part1@omnom" srcset="cid:part2@omnom 2x"></body></html>`, string(body))
+	var ids, contents []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		ids = append(ids, part.Header.Get("Content-ID"))
+		b, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		contents = append(contents, string(b))
+	}
+	assert.Equal(t, []string{"<part1@omnom>", "<part2@omnom>"}, ids)
+	assert.Equal(t, []string{"PNG", "PNG2"}, contents)
+}
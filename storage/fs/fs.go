@@ -259,6 +259,36 @@ func (s *Storage) SaveStream(ext string, resource io.Reader) (string, error) {
 	return filepath.Base(path), err
 }
 
+// DeleteSnapshot removes a snapshot file.
+// Missing files are not considered errors.
+func (s *Storage) DeleteSnapshot(key string) error {
+	return remove(key, s.getSnapshotPath(key))
+}
+
+// DeleteResource removes a resource file.
+// Missing files are not considered errors.
+func (s *Storage) DeleteResource(key string) error {
+	return remove(key, s.getResourcePath(key))
+}
+
+// DeleteStream removes a streamable content file.
+// Missing files are not considered errors.
+func (s *Storage) DeleteStream(key string) error {
+	return remove(key, s.getStreamPath(key))
+}
+
+func remove(key, path string) error {
+	// keys are hashes, shorter keys would resolve to directories
+	if len(filepath.Base(key)) < 32 {
+		return nil
+	}
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func mkdir(dir string) error {
 	if _, err := os.Stat(dir); err != nil {
 		if os.IsNotExist(err) {
@@ -62,6 +62,9 @@ type Storage interface {
 	GetStreamSize(string) uint
 	GetResourceURL(string) string
 	GetStreamURL(string) string
+	DeleteSnapshot(string) error
+	DeleteResource(string) error
+	DeleteStream(string) error
 }
 
 // ErrUninitialized is returned when storage is accessed before initialization.
@@ -192,6 +195,36 @@ func SaveStream(ext string, resource io.Reader) (string, error) {
 	return store.SaveStream(ext, resource)
 }
 
+// DeleteSnapshot removes a stored snapshot.
+// Deleting a nonexistent snapshot is not an error.
+// Returns ErrUninitialized if storage is not initialized.
+func DeleteSnapshot(key string) error {
+	if store == nil {
+		return ErrUninitialized
+	}
+	return store.DeleteSnapshot(key)
+}
+
+// DeleteResource removes a stored resource.
+// Deleting a nonexistent resource is not an error.
+// Returns ErrUninitialized if storage is not initialized.
+func DeleteResource(key string) error {
+	if store == nil {
+		return ErrUninitialized
+	}
+	return store.DeleteResource(key)
+}
+
+// DeleteStream removes a stored streamable content.
+// Deleting a nonexistent content is not an error.
+// Returns ErrUninitialized if storage is not initialized.
+func DeleteStream(key string) error {
+	if store == nil {
+		return ErrUninitialized
+	}
+	return store.DeleteStream(key)
+}
+
 // GetSnapshotSize returns the size in bytes of a stored snapshot.
 // Returns 0 if the snapshot doesn't exist.
 // Panics if storage has not been initialized.
@@ -0,0 +1,66 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">{{ .Tr.Msg "blocked actors" }}</h2>
+    <form method="post" action="{{ URLFor "Block actor" }}">
+        <div class="field has-addons">
+            <div class="control is-expanded">
+                <input class="input" type="text" name="actor" placeholder="{{ .Tr.Msg "actor url" }}" />
+            </div>
+            <div class="control">
+                <input class="button is-danger" type="submit" value="{{ .Tr.Msg "block actor" }}" />
+            </div>
+        </div>
+    </form>
+    {{ if not .ActorBlocks }}
+    <p>{{ .Tr.Msg "no blocked actors" }}</p>
+    {{ else }}
+    <div class="list">
+        {{ $Tr := .Tr }}
+        {{ range .ActorBlocks }}
+        <div class="list-item">
+            <form method="post" action="{{ URLFor "Unblock actor" }}">
+                <code class="has-text-dark">{{ .Actor }}</code>
+                <input type="hidden" name="id" value="{{ .ID }}" />
+                <input type="submit" class="button is-small" value="{{ $Tr.Msg "unblock" }}" />
+            </form>
+        </div>
+        {{ end }}
+    </div>
+    {{ end }}
+    <div class="field is-grouped">
+        <form method="post" action="{{ URLFor "Import blocks" }}" enctype="multipart/form-data">
+            <div class="field has-addons">
+                <div class="control">
+                    <input class="input" type="file" name="blocklist" accept=".csv,.txt,text/csv,text/plain" />
+                </div>
+                <div class="control">
+                    <input class="button is-primary" type="submit" value="{{ .Tr.Msg "import blocks" }}" />
+                </div>
+            </div>
+        </form>
+        <p class="control"><a href="{{ URLFor "Export blocks" }}" class="button">{{ .Tr.Msg "export blocks" }}</a></p>
+    </div>
+    {{ if .DomainBlocks }}
+    <h3 class="title">{{ .Tr.Msg "blocked domains" }}</h3>
+    <p>{{ .Tr.Msg "blocked domains description" }}</p>
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "domain" }}</th>
+                <th>{{ .Tr.Msg "severity" }}</th>
+                <th>{{ .Tr.Msg "comment" }}</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .DomainBlocks }}
+            <tr>
+                <td>{{ .Domain }}</td>
+                <td>{{ .Severity }}</td>
+                <td>{{ .PublicComment }}</td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    {{ end }}
+</div>
+{{ end }}
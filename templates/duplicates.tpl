@@ -0,0 +1,42 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">{{ .Tr.Msg "duplicate bookmarks" }}</h2>
+    <p>{{ .Tr.Msg "duplicate bookmarks description" }}</p>
+    {{ $Tr := .Tr }}
+    {{ if not .Groups }}
+    <p>{{ .Tr.Msg "no duplicates found" }}</p>
+    {{ end }}
+    {{ range .Groups }}
+    <h4 class="title is-5"><code class="has-text-dark">{{ .CanonicalURL }}</code></h4>
+    <form method="post" action="{{ URLFor "Merge bookmarks" }}">
+        <div class="table-container">
+        <table class="table">
+            <thead>
+                <tr>
+                    <th>{{ $Tr.Msg "keep" }}</th>
+                    <th>{{ $Tr.Msg "merge" }}</th>
+                    <th>{{ $Tr.Msg "title" }}</th>
+                    <th>{{ $Tr.Msg "snapshots" }}</th>
+                    <th>{{ $Tr.Msg "tags" }}</th>
+                    <th>{{ $Tr.Msg "created" }}</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{ range $i, $b := .Bookmarks }}
+                <tr>
+                    <td><input type="radio" name="target" value="{{ $b.ID }}" aria-label="{{ $Tr.Msg "keep" }}" {{ if eq $i 0 }}checked="checked"{{ end }} /></td>
+                    <td><input type="checkbox" name="ids" value="{{ $b.ID }}" aria-label="{{ $Tr.Msg "merge" }}" checked="checked" /></td>
+                    <td><a href="{{ URLFor "Bookmark" }}?id={{ $b.ID }}">{{ $b.Title }}</a><br /><span class="is-size-7 has-text-grey">{{ $b.URL }}</span></td>
+                    <td>{{ len $b.Snapshots }}</td>
+                    <td>{{ range $b.Tags }}<span class="tag">{{ .Text }}</span> {{ end }}</td>
+                    <td>{{ ToDate $b.CreatedAt }}</td>
+                </tr>
+                {{ end }}
+            </tbody>
+        </table>
+        </div>
+        <input type="submit" class="button is-primary" value="{{ $Tr.Msg "merge bookmarks" }}" />
+    </form>
+    {{ end }}
+</div>
+{{ end }}
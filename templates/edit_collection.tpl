@@ -12,6 +12,16 @@
                 <input class="input" type="text" name="name" value="{{ if .Collection }}{{ .Collection.Name }}{{ end }}" />
             </div>
         </div>
+        <div class="field">
+            <div class="control">
+                <label class="checkbox">
+                    <input type="checkbox" name="public" {{ if and .Collection .Collection.Public }}checked="checked"{{ end }} />
+                    Public
+                </label>
+            </div>
+            <p class="help">Public collections can be followed from the Fediverse. Followers receive only the public bookmarks of the collection.</p>
+            {{ if .FediName }}<p class="help">Fediverse handle: <code>{{ .FediName }}</code></p>{{ end }}
+        </div>
         {{ if .Parents }}
         <div class="field">
             <label class="label">Parent</label>
@@ -36,5 +46,12 @@
             </div>
         </div>
     </form>
+    {{ if .Collection }}
+    <form method="post" action="{{ URLFor "delete collection" }}" class="mt-5">
+        <input type="hidden" name="id" value="{{ .Collection.ID }}" />
+        <input class="button is-danger" type="submit" value="Delete" />
+        <p class="help">Deleted collections and their subcollections are moved to the trash. Bookmarks of the collection are kept.</p>
+    </form>
+    {{ end }}
 </div>
 {{ end }}
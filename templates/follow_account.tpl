@@ -0,0 +1,56 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">{{ .Tr.Msg "follow account" }}</h2>
+    <form method="get" action="{{ URLFor "Follow account" }}">
+        <div class="field has-addons">
+            <div class="control is-expanded">
+                <input class="input" type="text" name="handle" value="{{ .Handle }}" placeholder="@user@instance" />
+            </div>
+            <div class="control">
+                <input class="button is-primary" type="submit" value="{{ .Tr.Msg "search" }}" />
+            </div>
+        </div>
+    </form>
+    {{ if .Preview }}
+    {{ $Tr := .Tr }}
+    <div class="box">
+        <div class="media">
+            <div class="media-left">
+                <figure class="image is-64x64">
+                    {{ if .Preview.Favicon }}
+                    <img src="{{ .Preview.Favicon | ToURL }}" alt="{{ .Tr.Msgf "favicon of" "Title" .Preview.Actor.GetName }}" />
+                    {{ end }}
+                </figure>
+            </div>
+            <div class="media-content">
+                <p class="title is-4">{{ if .Preview.Actor.Name }}{{ .Preview.Actor.Name }}{{ else }}{{ .Preview.Actor.GetName }}{{ end }}</p>
+                <p class="subtitle is-6"><a href="{{ if .Preview.Actor.URL }}{{ .Preview.Actor.URL }}{{ else }}{{ .Preview.Actor.ID }}{{ end }}">{{ .Preview.Handle }}</a></p>
+                <form method="post" action="{{ URLFor "Add feed" }}">
+                    <input type="hidden" name="url" value="{{ .Preview.Actor.ID }}" />
+                    <div class="field has-addons">
+                        <div class="control is-expanded">
+                            <input class="input" type="text" name="name" value="{{ .Preview.Actor.GetName }}" placeholder="{{ .Tr.Msg "name" }}.." />
+                        </div>
+                        <div class="control">
+                            <input class="button is-primary" type="submit" value="{{ .Tr.Msg "follow" }}" />
+                        </div>
+                    </div>
+                </form>
+            </div>
+        </div>
+        {{ if .Preview.Summary }}
+        <div class="content">{{ .Preview.Summary | ToHTML }}</div>
+        {{ end }}
+    </div>
+    <h3 class="title">{{ .Tr.Msg "recent posts" }}</h3>
+    {{ range .Preview.Items }}
+    <div class="box">
+        <p class="subtitle is-6"><a href="{{ .URL }}">{{ .Published }}</a></p>
+        <article class="activitypub content">{{ .Content | ToHTML }}</article>
+    </div>
+    {{ else }}
+    <p>{{ .Tr.Msg "no results found" }}</p>
+    {{ end }}
+    {{ end }}
+</div>
+{{ end }}
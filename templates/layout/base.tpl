@@ -191,6 +191,11 @@
 <div class="media bookmark__container">
     <div class="bookmark__header">
       <div class="bookmark__title">
+        {{ if and (eq .Page "my-bookmarks") (eq .UID .Bookmark.UserID) }}
+        <label class="checkbox mr-2 mt-2">
+            <input type="checkbox" name="ids" value="{{ .Bookmark.ID }}" form="bulk-form" aria-label="{{ .Tr.Msg "select bookmark" }}" />
+        </label>
+        {{ end }}
         <div class="bookmark__favicon">
             <span class="icon">
             {{ if .Bookmark.Favicon }}
@@ -198,6 +203,11 @@
             {{ end }}
             </span>
         </div>
+        {{ with .Bookmark.Thumbnail }}
+        <a href="{{ URLFor "Bookmark" }}?id={{ $.Bookmark.ID }}" class="mr-3">
+            <img src="{{ .Key | ResourceURL }}" width="120" loading="lazy" alt="{{ $.Tr.Msgf "screenshot of" "Title" $.Bookmark.Title }}" />
+        </a>
+        {{ end }}
           <h4 class="title">
               <a href="{{ .Bookmark.URL }}" target="_blank">
                 {{ .Bookmark.Title }}
@@ -244,6 +254,7 @@
           </div>
         </details>
         {{ end }}
+          {{ block "linkStatus" . }}{{ end }}
           <span class="tag">{{ if .Bookmark.Public }}{{ .Tr.Msg "public" }}{{ else }}{{ .Tr.Msg "private" }}{{ end }}</span>
           <a href="{{ URLFor "Bookmark" }}?id={{ .Bookmark.ID }}" title="{{ .Tr.Msg "view" }}"><i class="fas fa-eye"></i></a>
           {{ if eq .UID .Bookmark.UserID }}
@@ -256,6 +267,17 @@
 </div>
 {{ end}}
 
+{{ define "linkStatus" }}
+{{ if eq .Bookmark.LinkStatus "dead" }}
+<span class="tag is-danger" title="{{ if .Bookmark.LinkStatusCode }}HTTP {{ .Bookmark.LinkStatusCode }}{{ end }}">{{ .Tr.Msg "dead link" }}</span>
+{{ with .Bookmark.LatestSnapshot }}
+<a href="{{ URLFor "snapshot" }}?bid={{ .BookmarkID }}&sid={{ .Key }}">{{ $.Tr.Msg "latest snapshot" }}</a>
+{{ end }}
+{{ else if eq .Bookmark.LinkStatus "redirected" }}
+<a href="{{ .Bookmark.LinkRedirectURL }}" target="_blank" title="{{ .Bookmark.LinkRedirectURL }}"><span class="tag is-warning">{{ .Tr.Msg "redirected link" }}</span></a>
+{{ end }}
+{{ end }}
+
 {{ define "snapshots" }}
     {{ range $i,$s := .Snapshots }}
     <div class="snapshot__link">
@@ -283,6 +305,31 @@
     {{ end }}
 {{ end }}
 
+{{ define "highlights" }}
+    {{ range .Highlights }}
+    <div class="highlight" id="highlight-{{ .ID }}">
+        <blockquote>{{ .Exact }}</blockquote>
+        {{ if .Comment }}<p>{{ .Comment }}</p>{{ end }}
+        <div class="level is-mobile">
+            <div class="level-left">
+                <small class="has-text-grey">
+                    {{ .CreatedAt | ToDate }}{{ if .Public }} - Public{{ end }}
+                    {{ if and (HasAttr . "Found") (not .Found) }} - Not found in this snapshot{{ end }}
+                </small>
+            </div>
+            {{ if $.IsOwn }}
+            <div class="level-right">
+                <form method="post" action="{{ URLFor "Delete highlight" }}">
+                    <input type="hidden" name="id" value="{{ .ID }}" />
+                    <button class="button is-small" type="submit" aria-label="Delete highlight"><i class="fas fa-trash"></i></button>
+                </form>
+            </div>
+            {{ end }}
+        </div>
+    </div>
+    {{ end }}
+{{ end }}
+
 {{ define "paging" }}
 <div class="columns is-centered">
     <div class="column is-narrow">
@@ -379,6 +426,24 @@
 {{ end }}
 {{ end }}
 
+{{ define "linkStatusFilter" }}
+<div class="field">
+    <label class="label">{{ .Tr.Msg "link status" }}</label>
+    <div class="control">
+        <div class="select">
+            {{ $ls := .SearchParams.LinkStatus }}
+            <select name="link_status">
+                <option value="">---</option>
+                <option value="ok" {{ if eq $ls "ok" }}selected="selected"{{ end }}>{{ .Tr.Msg "link ok" }}</option>
+                <option value="redirected" {{ if eq $ls "redirected" }}selected="selected"{{ end }}>{{ .Tr.Msg "redirected link" }}</option>
+                <option value="dead" {{ if eq $ls "dead" }}selected="selected"{{ end }}>{{ .Tr.Msg "dead link" }}</option>
+                <option value="error" {{ if eq $ls "error" }}selected="selected"{{ end }}>{{ .Tr.Msg "link check error" }}</option>
+            </select>
+        </div>
+    </div>
+</div>
+{{ end }}
+
 {{ define "dateFilter" }}
 <div class="field is-grouped is-grouped-multiline">
     <div class="control">
@@ -519,6 +584,17 @@
                 {{ block "submit" (.Tr.Msg "submit") }}{{ end }}
             </form>
         </details>
+        <details class="my-4 is-size-4">
+            <summary>{{ .Tr.Msg "follow account" }}</summary>
+            <form action="{{ URLFor "Follow account" }}" method="get">
+                <div class="field">
+                    <div class="control">
+                        <input class="input" type="text" placeholder="@user@instance" name="handle" />
+                    </div>
+                </div>
+                {{ block "submit" (.Tr.Msg "search") }}{{ end }}
+            </form>
+        </details>
         {{ $Tr := .Tr }}
         {{ $IncludeRead := .IncludeRead }}
         <div class="is-hidden-mobile">
@@ -19,6 +19,7 @@
                                 {{ block "domainFilter" .}}{{ end }}
                                 {{ block "ownerFilter" .}}{{ end }}
                                 {{ block "collectionFilter" .}}{{ end }}
+                                {{ block "linkStatusFilter" .}}{{ end }}
                                 {{ block "tagFilter" .}}{{ end }}
                                 {{ block "dateFilter" .}}{{ end }}
                                 {{ block "searchParameters" .}}{{ end }}
@@ -52,6 +53,69 @@
         {{ $page := .Page }}
         {{ $Tr := .Tr }}
         <div class="column bookmark-list">
+            {{ if .Bookmarks }}
+            <details class="mb-4">
+                <summary>{{ .Tr.Msg "bulk edit" }}</summary>
+                <form id="bulk-form" method="post" action="{{ URLFor "Bulk edit bookmarks" }}" class="mt-2">
+                    {{ with .SearchParams }}
+                    <input type="hidden" name="query" value="{{ .Q }}" />
+                    <input type="hidden" name="from" value="{{ .FromDate }}" />
+                    <input type="hidden" name="to" value="{{ .ToDate }}" />
+                    <input type="hidden" name="tag" value="{{ .Tag }}" />
+                    <input type="hidden" name="domain" value="{{ .Domain }}" />
+                    <input type="hidden" name="collection" value="{{ .Collection }}" />
+                    <input type="hidden" name="link_status" value="{{ .LinkStatus }}" />
+                    {{ if .IsPublic }}<input type="hidden" name="public" value="1" />{{ end }}
+                    {{ if .IsPrivate }}<input type="hidden" name="private" value="1" />{{ end }}
+                    {{ if .SearchInSnapshot }}<input type="hidden" name="search_in_snapshot" value="1" />{{ end }}
+                    {{ if .SearchInNote }}<input type="hidden" name="search_in_note" value="1" />{{ end }}
+                    {{ end }}
+                    <div class="field is-grouped is-grouped-multiline">
+                        <div class="control">
+                            <div class="select">
+                                <select name="operation" aria-label="{{ .Tr.Msg "operation" }}">
+                                    <option value="add_tags">{{ .Tr.Msg "add tags" }}</option>
+                                    <option value="remove_tags">{{ .Tr.Msg "remove tags" }}</option>
+                                    <option value="set_collection">{{ .Tr.Msg "move to collection" }}</option>
+                                    <option value="set_public">{{ .Tr.Msg "make public" }}</option>
+                                    <option value="set_private">{{ .Tr.Msg "make private" }}</option>
+                                    <option value="set_unread">{{ .Tr.Msg "mark as unread" }}</option>
+                                    <option value="set_read">{{ .Tr.Msg "mark as read" }}</option>
+                                    <option value="delete">{{ .Tr.Msg "delete" }}</option>
+                                </select>
+                            </div>
+                        </div>
+                        <div class="control">
+                            <input class="input" type="text" name="tags" placeholder="{{ .Tr.Msg "comma separated tags" }}" />
+                        </div>
+                        {{ if .Collections }}
+                        <div class="control">
+                            <div class="select">
+                                <select name="collection_id" aria-label="{{ .Tr.Msg "collection" }}">
+                                    <option value="0">---</option>
+                                    {{ range .Collections }}
+                                    <option value="{{ .ID }}">{{ .Name }}</option>
+                                    {{ range .Children }}
+                                    <option value="{{ .ID }}">{{ .Name }}</option>
+                                    {{ end }}
+                                    {{ end }}
+                                </select>
+                            </div>
+                        </div>
+                        {{ end }}
+                        <div class="control">
+                            <label class="checkbox">
+                                <input type="checkbox" name="all" value="1" />
+                                {{ .Tr.Msgf "all search results" "Count" .BookmarkCount }}
+                            </label>
+                        </div>
+                        <div class="control">
+                            <input class="button is-primary" type="submit" value="{{ .Tr.Msg "apply" }}" />
+                        </div>
+                    </div>
+                </form>
+            </details>
+            {{ end }}
             {{ range .Bookmarks }}
                 {{ block "bookmark" KVData "Bookmark" . "UID" $uid "Page" $page "Tr" $Tr }}{{ end }}
             {{ end }}
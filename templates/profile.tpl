@@ -4,35 +4,81 @@
 	<span class="is-size-7 is-italic has-text-grey">{{ or .User.Email (.Tr.Msg "no email provided") }}</span></h2>
     <p><a href="{{ URLFor "Logout" }}" class="button is-warning">{{ .Tr.Msg "logout" }}</a></p>
     <p>{{ .Tr.Msg "storage usage" }}: <strong>{{ .SnapshotsSize | FormatSize }}</strong></p>
-    {{ if .DisplayTokens }}
-        {{ if not .AddonTokens }}
-        <h3 class="title">{{ .Tr.Msg "no addon token found" }}</h3>
-        {{ else }}
-        <h3 class="title">{{ .Tr.Msg "addon tokens" }}</h3>
-        <div class="columns is-mobile"><div class="column is-narrow">
-            <div class="list"><dl>
-                {{ $Tr := .Tr }}
-                {{ range .AddonTokens }}
-                <div class="list-item">
-                    <li class="pure-list">
-                        <form method="post" action="{{ URLFor "Delete addon token" }}">
-                                <code class="has-text-dark">{{ .Text }}</code>
-                                <input type="hidden" name="id" value="{{ .ID }}" />
-                                <input type="submit" class="button is-danger is-small" value="{{ $Tr.Msg "delete" }}" />
-                        </form>
-                    </li>
-                </div>
-                {{ end}}
-            </dl></div>
-        </div></div>
-        {{ end }}
+    <h3 class="title">{{ .Tr.Msg "api tokens" }}</h3>
+    {{ if not .Tokens }}
+    <p>{{ .Tr.Msg "no token found" }}</p>
     {{ else }}
-        <div class="columns is-mobile"><div class="column is-narrow">
-            <form method="post">
-                <input type="submit" class="button is-primary" value="{{ .Tr.Msg "show addon tokens" }}" />
-            </form>
-        </div></div>
+    {{ $Tr := .Tr }}
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "name" }}</th>
+                <th>{{ .Tr.Msg "token" }}</th>
+                <th>{{ .Tr.Msg "scopes" }}</th>
+                <th>{{ .Tr.Msg "created" }}</th>
+                <th>{{ .Tr.Msg "expires" }}</th>
+                <th>{{ .Tr.Msg "last used" }}</th>
+                <th></th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Tokens }}
+            <tr>
+                <td>{{ .Name }}</td>
+                <td>{{ if .Prefix }}<code class="has-text-dark">{{ .Prefix }}…</code>{{ end }}</td>
+                <td>{{ range .ScopeList }}<span class="tag">{{ . }}</span> {{ end }}</td>
+                <td>{{ ToDate .CreatedAt }}</td>
+                <td>{{ if .ExpiresAt }}{{ if .Expired }}<span class="has-text-danger">{{ $Tr.Msg "expired" }}</span>{{ else }}{{ ToDate .ExpiresAt }}{{ end }}{{ else }}{{ $Tr.Msg "never" }}{{ end }}</td>
+                <td>{{ if .LastUsedAt }}{{ ToDateTime .LastUsedAt }} <span class="is-size-7 has-text-grey">{{ .LastUsedIP }}</span>{{ else }}{{ $Tr.Msg "never" }}{{ end }}</td>
+                <td>
+                    <form method="post" action="{{ URLFor "Delete token" }}">
+                        <input type="hidden" name="id" value="{{ .ID }}" />
+                        <input type="submit" class="button is-danger is-small" value="{{ $Tr.Msg "revoke" }}" />
+                    </form>
+                </td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
     {{ end }}
+    <h4 class="title is-5">{{ .Tr.Msg "create token" }}</h4>
+    <form method="post" action="{{ URLFor "Create token" }}">
+        <div class="field">
+            <label class="label">{{ .Tr.Msg "name" }}</label>
+            <div class="control">
+                <input class="input" type="text" name="name" required />
+            </div>
+        </div>
+        <div class="field">
+            <label class="label">{{ .Tr.Msg "scopes" }}</label>
+            <div class="control">
+                {{ range .TokenScopes }}
+                <label class="checkbox mr-3"><input type="checkbox" name="scopes" value="{{ . }}" {{ if eq . "read" }}checked{{ end }} /> {{ $.Tr.Msg (printf "token scope %s" .) }}</label>
+                {{ end }}
+            </div>
+        </div>
+        <div class="field">
+            <label class="label">{{ .Tr.Msg "expiration" }}</label>
+            <div class="control">
+                <div class="select">
+                    <select name="expiration">
+                        {{ range .TokenExpirations }}
+                        <option value="{{ . }}">{{ if eq . 0 }}{{ $.Tr.Msg "never" }}{{ else }}{{ $.Tr.Msgf "days" "Days" . }}{{ end }}</option>
+                        {{ end }}
+                    </select>
+                </div>
+            </div>
+        </div>
+        <div class="field">
+            <div class="control">
+                <input type="submit" class="button is-primary" value="{{ .Tr.Msg "create token" }}" />
+            </div>
+        </div>
+    </form>
     <a href="{{ URLFor "Generate addon token" }}" class="button is-primary">{{ .Tr.Msg "generate addon token" }}</a>
+    <a href="{{ URLFor "Webhooks" }}" class="button">{{ .Tr.Msg "webhooks" }}</a>
+    <a href="{{ URLFor "Blocks" }}" class="button">{{ .Tr.Msg "blocks" }}</a>
 </div>
 {{ end }}
@@ -7,6 +7,7 @@
             <strong>{{ .Snapshot.CreatedAt | ToDate }}</strong>
             <span class="tag is-info is-light">{{ .Snapshot.Size | FormatSize }}</span> <a href="{{ SnapshotURL .Snapshot.Key }}"><small>Fullscreen</small></a>
             - <a href="{{ URLFor "Download snapshot" }}?sid={{ .Snapshot.Key }}"><small>Download</small></a>
+            (<a href="{{ URLFor "Download snapshot" }}?sid={{ .Snapshot.Key }}&format=mhtml"><small>MHTML</small></a>)
             - <a href="{{ URLFor "Snapshot details" }}?sid={{ .Snapshot.Key }}"><small>Details</small></a>
         </p>
     {{ else }}
@@ -21,6 +22,6 @@
     </div>
 </div>
 {{ if .Snapshot }}
-<iframe src="{{ SnapshotURL .Snapshot.Key }}" title="snapshot of {{ .URL }}" class="snapshot-iframe"></iframe>
+<iframe src="{{ SnapshotURL .Snapshot.Key }}" title="snapshot of {{ .URL }}" class="snapshot-iframe" sandbox="allow-scripts allow-popups allow-popups-to-escape-sandbox"></iframe>
 {{ end }}
 {{ end }}
@@ -18,6 +18,23 @@
             <br />Total resources
         </div>
     </div>
+    {{ $screenshot := .Snapshot.Capture "screenshot" }}
+    {{ $pdf := .Snapshot.Capture "pdf" }}
+    {{ if or $screenshot $pdf }}
+    <h4 class="title">{{ .Tr.Msg "captures" }}</h4>
+    <div class="media">
+        {{ with .Snapshot.Thumbnail }}
+        <div class="media-left">
+            <img src="{{ .Key | ResourceURL }}" width="240" alt="{{ $.Tr.Msgf "screenshot of" "Title" $.Bookmark.Title }}" />
+        </div>
+        {{ end }}
+        <div class="media-content">
+            {{ with $screenshot }}<p><a href="{{ .Key | ResourceURL }}" download="{{ .OriginalFilename }}"><span class="icon"><i class="fas fa-image"></i></span>{{ $.Tr.Msg "download screenshot" }}</a> <span class="tag">{{ .Size | FormatSize }}</span></p>{{ end }}
+            {{ with $pdf }}<p><a href="{{ .Key | ResourceURL }}" download="{{ .OriginalFilename }}"><span class="icon"><i class="fas fa-file-pdf"></i></span>{{ $.Tr.Msg "download pdf" }}</a> <span class="tag">{{ .Size | FormatSize }}</span></p>{{ end }}
+        </div>
+    </div>
+    <hr />
+    {{ end }}
     <h4 class="title">
         Resource List
     </h4>
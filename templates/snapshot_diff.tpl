@@ -6,8 +6,16 @@
             <li><a href="{{ URLFor "Snapshot" }}?sid={{ .S1.Key }}&bid={{ .S1.BookmarkID }}">{{ .S1.CreatedAt | ToDate }}</a></li>
             <li><a href="{{ URLFor "Snapshot" }}?sid={{ .S2.Key }}&bid={{ .S2.BookmarkID }}">{{ .S2.CreatedAt | ToDate }}</a></li>
         </ol>
-    <a href="{{ URLFor "Snapshot diff side by side" }}?s1={{ .S1.Key }}&s2={{ .S2.Key }}">Compare side by side</a>
+    <a href="{{ URLFor "Snapshot diff side by side" }}?s1={{ .S1.Key }}&s2={{ .S2.Key }}">Compare side by side</a> |
+    <a href="{{ .OptionsURL }}">{{ if .Rule }}Edit diff options{{ else }}Diff options{{ end }}</a> |
+    <a href="{{ URLFor "Snapshot timeline" }}?bid={{ .S1.BookmarkID }}">Timeline</a>
     </p>
+    {{ with .Navigation }}
+    <nav class="pagination is-small" role="navigation" aria-label="diff navigation">
+        {{ if .Prev }}<a href="{{ .Prev }}" class="pagination-previous">Previous change</a>{{ end }}
+        {{ if .Next }}<a href="{{ .Next }}" class="pagination-next">Next change</a>{{ end }}
+    </nav>
+    {{ end }}
     <div class="columns">
         <div class="column">
         {{ if .LinkDiffs }}
@@ -55,5 +63,13 @@
         {{ end }}
         </div>
     </div>
+    {{ if .BlockDiffLen }}
+    <h3>Structural changes ({{ .BlockDiffLen }})</h3>
+    <div>
+        {{ .BlockDiff }}
+    </div>
+    {{ else }}
+    <h3>No structural changes</h3>
+    {{ end }}
 </div>
 {{ end }}
@@ -2,22 +2,69 @@
 <div class="content">
     <h2 class="title">Snapshot diff</h2>
     <form method="get" action="{{ URLFor "snapshot diff" }}">
+    <input type="hidden" name="filter" value="1">
     <div class="field">
         <label class="label">First snapshot key</label>
         <div class="control">
-            <input class="input" type="text" name="s1" placeholder="First snapshot">
+            <input class="input" type="text" name="s1" placeholder="First snapshot" value="{{ .S1 }}">
         </div>
     </div>
     <div class="field">
         <label class="label">Second snapshot key</label>
         <div class="control">
-            <input class="input" type="text" name="s2" placeholder="Second snapshot">
+            <input class="input" type="text" name="s2" placeholder="Second snapshot" value="{{ .S2 }}">
         </div>
     </div>
-    <div class="field">
+    <details class="mb-4"{{ if .Rule }} open{{ end }}>
+        <summary>Diff options</summary>
+        {{ if .Rule }}{{ if .Rule.ID }}
+        <p class="help">Stored options of the {{ .Rule.Scope }}{{ if eq .Rule.Scope "domain" }} {{ .Rule.Domain }}{{ end }}</p>
+        {{ end }}{{ end }}
+        <div class="field">
+            <label class="label">Compared elements</label>
+            <div class="control">
+                <textarea class="textarea" name="include" rows="2" placeholder="article .content">{{ if .Rule }}{{ .Rule.Include }}{{ end }}</textarea>
+            </div>
+            <p class="help">CSS selectors, one per line. Everything is compared if empty.</p>
+        </div>
+        <div class="field">
+            <label class="label">Ignored elements</label>
+            <div class="control">
+                <textarea class="textarea" name="exclude" rows="2" placeholder=".cookie-banner">{{ if .Rule }}{{ .Rule.Exclude }}{{ end }}</textarea>
+            </div>
+            <p class="help">CSS selectors, one per line.</p>
+        </div>
+        <div class="field">
+            <label class="label">Ignored text</label>
+            <div class="control">
+                <textarea class="textarea" name="ignore" rows="2" placeholder="\d+ views">{{ if .Rule }}{{ .Rule.Ignore }}{{ end }}</textarea>
+            </div>
+            <p class="help">Regular expressions, one per line. Use them to ignore volatile text like dates and counters.</p>
+        </div>
+        <div class="field">
+            <label class="checkbox">
+                <input type="checkbox" name="main_content" value="1"{{ if .Rule }}{{ if .Rule.MainContent }} checked{{ end }}{{ end }}>
+                Compare only the main content of the pages
+            </label>
+        </div>
+    </details>
+    <div class="field is-grouped">
         <div class="control">
             <button class="button is-link">Submit</button>
         </div>
+        {{ if .Bookmark }}
+        <div class="control">
+            <div class="select">
+                <select name="scope">
+                    <option value="bookmark">for this bookmark</option>
+                    <option value="domain"{{ if .Rule }}{{ if and .Rule.ID (eq .Rule.Scope "domain") }} selected{{ end }}{{ end }}>for {{ .Bookmark.Domain }}</option>
+                </select>
+            </div>
+        </div>
+        <div class="control">
+            <button class="button" formmethod="post" formaction="{{ URLFor "Save snapshot diff options" }}">Save options</button>
+        </div>
+        {{ end }}
     </div>
     </form>
 </div>
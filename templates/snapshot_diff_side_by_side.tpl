@@ -14,14 +14,22 @@
         </div>
     </div>
 </div>
+{{ with .VisualDiff }}
+<div class="container is-fluid content">
+    <details>
+        <summary>{{ $.Tr.Msgf "visual difference" "Percent" (printf "%.2f" .ChangedPercent) }}</summary>
+        <img src="{{ URLFor "Snapshot visual diff" }}?s1={{ $.S1.Key }}&s2={{ $.S2.Key }}" alt="{{ $.Tr.Msg "visual diff of the screenshots" }}" loading="lazy" />
+    </details>
+</div>
+{{ end }}
 <div class="iframe-diff-wrapper">
     <noscript>{{ block "warning" KVData "Warning" "this feature requires javascript" "Tr" .Tr }}{{ end }}</noscript>
     <div class="columns">
         <div class="column">
-            <iframe id="sn1" title="snapshot 1" scrolling="no"></iframe>
+            <iframe id="sn1" title="snapshot 1" scrolling="no" sandbox="allow-same-origin"></iframe>
         </div>
         <div class="column">
-            <iframe title="snapshot 2" id="sn2" scrolling="no"></iframe>
+            <iframe title="snapshot 2" id="sn2" scrolling="no" sandbox="allow-same-origin"></iframe>
         </div>
     </div>
 </div>
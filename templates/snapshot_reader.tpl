@@ -0,0 +1,98 @@
+{{ define "content" }}
+<div class="columns is-centered">
+    <div class="column is-three-quarters-tablet is-two-thirds-desktop">
+        <h2 class="title">{{ .Article.Title }}</h2>
+        <p class="subtitle is-6">
+            <a href="{{ .Bookmark.URL }}">{{ Truncate .Bookmark.URL 100 }}</a><br />
+            <strong>{{ .Snapshot.CreatedAt | ToDate }}</strong>
+            <span class="tag is-info is-light">{{ .Article.ReadingTime }} min read</span>
+            <small>{{ .Article.Words }} words</small>
+            - <a href="{{ URLFor "Snapshot" }}?sid={{ .Snapshot.Key }}&bid={{ .Bookmark.ID }}"><small>Original snapshot</small></a>
+            - <a href="{{ URLFor "Download snapshot" }}?sid={{ .Snapshot.Key }}&format=epub"><small>Download EPUB</small></a>
+        </p>
+        {{ $uid := 0 }}
+        {{ if .User }}{{ $uid = .User.ID }}{{ end }}
+        {{ $isOwn := eq .Bookmark.UserID $uid }}
+        {{ if $isOwn }}<p class="help">Select text to highlight it.</p>{{ end }}
+        <article class="reader content" id="reader-content">{{ .Article.Content | ToHTML }}</article>
+        {{ if $isOwn }}
+        <form method="post" action="{{ URLFor "Add highlight" }}" id="highlight-form" class="box highlight-form is-hidden">
+            <input type="hidden" name="bid" value="{{ .Bookmark.ID }}" />
+            <input type="hidden" name="sid" value="{{ .Snapshot.Key }}" />
+            <input type="hidden" name="exact" />
+            <input type="hidden" name="prefix" />
+            <input type="hidden" name="suffix" />
+            <input type="hidden" name="start" />
+            <input type="hidden" name="end" />
+            <blockquote class="highlight-quote"></blockquote>
+            <div class="field">
+                <div class="control">
+                    <textarea class="textarea" name="comment" rows="2" placeholder="Comment" aria-label="Comment"></textarea>
+                </div>
+            </div>
+            <div class="field is-grouped">
+                <div class="control">
+                    <label class="checkbox"><input type="checkbox" name="public" value="1"{{ if .Bookmark.Public }} checked="checked"{{ end }} /> Public</label>
+                </div>
+                <div class="control">
+                    <button type="submit" class="button is-primary is-small">Save highlight</button>
+                </div>
+                <div class="control">
+                    <button type="button" class="button is-small highlight-cancel">Cancel</button>
+                </div>
+            </div>
+        </form>
+        {{ end }}
+        {{ if .Highlights }}
+        <div class="content mt-6">
+            <h4>Highlights <a href="{{ URLFor "Export highlights" }}?bid={{ .Bookmark.ID }}" class="button is-small">Export Markdown</a></h4>
+            {{ block "highlights" KVData "Highlights" .Highlights "IsOwn" $isOwn }}{{ end }}
+        </div>
+        {{ end }}
+    </div>
+</div>
+{{ if $isOwn }}
+<script>
+// anchor selected passages with their quote, context and position
+(() => {
+    const contextLength = 32;
+    const article = document.getElementById("reader-content");
+    const form = document.getElementById("highlight-form");
+    function selectHighlight() {
+        const sel = window.getSelection();
+        if(!sel.rangeCount || sel.isCollapsed) {
+            return;
+        }
+        const range = sel.getRangeAt(0);
+        if(!article.contains(range.commonAncestorContainer)) {
+            return;
+        }
+        const exact = range.toString();
+        if(!exact.trim()) {
+            return;
+        }
+        const before = document.createRange();
+        before.setStart(article, 0);
+        before.setEnd(range.startContainer, range.startOffset);
+        const after = document.createRange();
+        after.setStart(range.endContainer, range.endOffset);
+        after.setEnd(article, article.childNodes.length);
+        // positions are counted in code points like on the server side
+        const prefix = [...before.toString()];
+        form.elements["exact"].value = exact;
+        form.elements["prefix"].value = prefix.slice(-contextLength).join("");
+        form.elements["suffix"].value = [...after.toString()].slice(0, contextLength).join("");
+        form.elements["start"].value = prefix.length;
+        form.elements["end"].value = prefix.length + [...exact].length;
+        form.querySelector(".highlight-quote").textContent = exact;
+        form.classList.remove("is-hidden");
+    }
+    article.addEventListener("mouseup", selectHighlight);
+    article.addEventListener("keyup", selectHighlight);
+    form.querySelector(".highlight-cancel").addEventListener("click", () => {
+        form.classList.add("is-hidden");
+    });
+})();
+</script>
+{{ end }}
+{{ end }}
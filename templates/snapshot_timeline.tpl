@@ -0,0 +1,61 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">Snapshot timeline of <a href="{{ .Bookmark.URL }}">{{ Truncate .Bookmark.URL 100 }}</a></h2>
+    {{ if .Rule }}{{ with index .Items 0 }}{{ if .Prev }}<p class="help">Compared with the <a href="{{ URLFor "Snapshot diff form" }}?s1={{ .Prev.Key }}&s2={{ .Snapshot.Key }}">diff options</a> of the {{ $.Rule.Scope }}.</p>{{ end }}{{ end }}{{ end }}
+    {{ if .Summary }}
+    <div class="notification is-info is-light">
+        <h4>Changes since bookmarked</h4>
+        <p>
+            {{ .First.CreatedAt | ToDate }} - {{ .Latest.CreatedAt | ToDate }}:
+            {{ template "diff-stat" .Summary }}
+            <a href="{{ URLFor "Snapshot diff" }}?s1={{ .First.Key }}&s2={{ .Latest.Key }}">Show differences</a>
+        </p>
+    </div>
+    {{ end }}
+    {{ range .Items }}
+    <div class="box">
+        <article class="media">
+            {{ with .Snapshot.Thumbnail }}
+            <figure class="media-left">
+                <p class="image"><img src="{{ .Key | ResourceURL }}" width="120" /></p>
+            </figure>
+            {{ end }}
+            <div class="media-content">
+                <p>
+                    <a href="{{ URLFor "Snapshot" }}?sid={{ .Snapshot.Key }}&bid={{ .Snapshot.BookmarkID }}"><strong>{{ .Snapshot.CreatedAt | ToDateTime }}</strong></a>
+                    {{ if .Snapshot.Title }}<br />{{ .Snapshot.Title }}{{ end }}
+                </p>
+                {{ if .Stat }}
+                <progress class="progress is-small {{ if .Stat.HasChanges }}is-warning{{ else }}is-success{{ end }}" value="{{ .Stat.Magnitude }}" max="100">{{ printf "%.1f" .Stat.Magnitude }}%</progress>
+                <p>
+                    {{ template "diff-stat" .Stat }}
+                    {{ if .Stat.HasChanges }}
+                    <a href="{{ URLFor "Snapshot diff" }}?s1={{ .Prev.Key }}&s2={{ .Snapshot.Key }}" class="button is-small">Show differences</a>
+                    <a href="{{ URLFor "Snapshot diff side by side" }}?s1={{ .Prev.Key }}&s2={{ .Snapshot.Key }}" class="button is-small">Compare side by side</a>
+                    {{ end }}
+                </p>
+                {{ else if .Prev }}
+                <p class="has-text-grey">Failed to compare with the previous snapshot</p>
+                {{ else }}
+                <p class="has-text-grey">First snapshot</p>
+                {{ end }}
+            </div>
+        </article>
+    </div>
+    {{ end }}
+    {{ block "paging" . }}{{ end }}
+</div>
+{{ end }}
+
+{{ define "diff-stat" }}
+{{ if .HasChanges }}
+<span class="tag is-warning is-light">{{ printf "%.1f" .Magnitude }}% changed</span>
+{{ if .Added }}<span class="tag is-primary is-light">{{ .Added }} added</span>{{ end }}
+{{ if .Removed }}<span class="tag is-danger is-light">{{ .Removed }} removed</span>{{ end }}
+{{ if .Changed }}<span class="tag is-warning is-light">{{ .Changed }} changed</span>{{ end }}
+{{ if .Moved }}<span class="tag is-info is-light">{{ .Moved }} moved</span>{{ end }}
+{{ if .VisualChange }}<span class="tag is-light">{{ printf "%.1f" .VisualChange }}% of the screenshot changed</span>{{ end }}
+{{ else }}
+<span class="tag is-success is-light">No changes</span>
+{{ end }}
+{{ end }}
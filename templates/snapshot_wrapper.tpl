@@ -6,7 +6,10 @@
             <strong>{{ .Snapshot.CreatedAt | ToDate }}</strong>
             <span class="tag is-info is-light">{{ .Snapshot.Size | FormatSize }}</span> <a href="{{ SnapshotURL .Snapshot.Key }}"><small>Fullscreen</small></a>
             - <a href="{{ URLFor "Download snapshot" }}?sid={{ .Snapshot.Key }}"><small>Download</small></a>
+            (<a href="{{ URLFor "Download snapshot" }}?sid={{ .Snapshot.Key }}&format=mhtml"><small>MHTML</small></a>)
+            - <a href="{{ URLFor "Snapshot reader" }}?sid={{ .Snapshot.Key }}&bid={{ .Bookmark.ID }}"><small>Reader</small></a>
             - <a href="{{ URLFor "Snapshot details" }}?sid={{ .Snapshot.Key }}"><small>Details</small></a>
+            {{ if .OtherSnapshots }}- <a href="{{ URLFor "Snapshot timeline" }}?bid={{ .Bookmark.ID }}"><small>Timeline</small></a>{{ end }}
         </p>
         {{ if .OtherSnapshots }}
         <details>
@@ -25,5 +28,5 @@
         {{ end }}
     </div>
 </div>
-<iframe src="{{ SnapshotURL .Snapshot.Key }}" title="snapshot of {{ .Bookmark.URL }}" class="snapshot-iframe"></iframe>
+<iframe src="{{ SnapshotURL .Snapshot.Key }}" title="snapshot of {{ .Bookmark.URL }}" class="snapshot-iframe" sandbox="allow-scripts allow-popups allow-popups-to-escape-sandbox"></iframe>
 {{ end }}
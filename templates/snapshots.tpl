@@ -19,6 +19,9 @@
             {{ $Tr := .Tr }}
             {{ range .Snapshots }}
             <div class="box">
+                {{ with .Thumbnail }}
+                <img src="{{ .Key | ResourceURL }}" width="160" loading="lazy" alt="" class="is-pulled-right ml-3" />
+                {{ end }}
                 <h4 class="title"><a href="{{ URLFor "Snapshot" }}?sid={{ .Key }}&bid={{ .BookmarkID }}">{{ .Bookmark.Title }}</a></h4>
                 <p>
                     {{ $Tr.Msg "original url" }}: <a href="{{ .Bookmark.URL }}" target="_blank">{{ Truncate .Bookmark.URL 100 }}</a><br />
@@ -0,0 +1,106 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">{{ .Tr.Msg "trash" }}</h2>
+    <p>{{ if .RetentionDays }}{{ .Tr.Msgf "trash description" "Days" .RetentionDays }}{{ else }}{{ .Tr.Msg "trash description without purge" }}{{ end }}</p>
+    {{ $Tr := .Tr }}
+    {{ if .Trash.IsEmpty }}
+    <p>{{ .Tr.Msg "trash is empty" }}</p>
+    {{ else }}
+    {{ if .Trash.Bookmarks }}
+    <h3 class="title">{{ .Tr.Msg "bookmarks" }}</h3>
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "title" }}</th>
+                <th>{{ .Tr.Msg "snapshots" }}</th>
+                <th>{{ .Tr.Msg "tags" }}</th>
+                <th>{{ .Tr.Msg "deleted" }}</th>
+                <th></th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Trash.Bookmarks }}
+            <tr>
+                <td>{{ .Title }}<br /><span class="is-size-7 has-text-grey">{{ .URL }}</span></td>
+                <td>{{ len .Snapshots }}</td>
+                <td>{{ range .Tags }}<span class="tag">{{ .Text }}</span> {{ end }}</td>
+                <td>{{ ToDateTime .DeletedAt.Time }}</td>
+                <td>
+                    <form method="post" action="{{ URLFor "Restore bookmark" }}">
+                        <input type="hidden" name="id" value="{{ .ID }}" />
+                        <input type="submit" class="button is-small" value="{{ $Tr.Msg "restore" }}" />
+                    </form>
+                </td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
+    {{ end }}
+    {{ if .Trash.Snapshots }}
+    <h3 class="title">{{ .Tr.Msg "snapshots" }}</h3>
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "title" }}</th>
+                <th>{{ .Tr.Msg "bookmark" }}</th>
+                <th>{{ .Tr.Msg "created" }}</th>
+                <th>{{ .Tr.Msg "deleted" }}</th>
+                <th></th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Trash.Snapshots }}
+            <tr>
+                <td>{{ .Title }}</td>
+                <td>{{ .Bookmark.Title }}</td>
+                <td>{{ ToDateTime .CreatedAt }}</td>
+                <td>{{ ToDateTime .DeletedAt.Time }}</td>
+                <td>
+                    <form method="post" action="{{ URLFor "Restore snapshot" }}">
+                        <input type="hidden" name="id" value="{{ .ID }}" />
+                        <input type="submit" class="button is-small" value="{{ $Tr.Msg "restore" }}" />
+                    </form>
+                </td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
+    {{ end }}
+    {{ if .Trash.Collections }}
+    <h3 class="title">{{ .Tr.Msg "collections" }}</h3>
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "name" }}</th>
+                <th>{{ .Tr.Msg "deleted" }}</th>
+                <th></th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Trash.Collections }}
+            <tr>
+                <td>{{ .Name }}</td>
+                <td>{{ ToDateTime .DeletedAt.Time }}</td>
+                <td>
+                    <form method="post" action="{{ URLFor "Restore collection" }}">
+                        <input type="hidden" name="id" value="{{ .ID }}" />
+                        <input type="submit" class="button is-small" value="{{ $Tr.Msg "restore" }}" />
+                    </form>
+                </td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
+    {{ end }}
+    <form method="post" action="{{ URLFor "Empty trash" }}">
+        <input type="submit" class="button is-danger" value="{{ .Tr.Msg "empty trash" }}" />
+    </form>
+    {{ end }}
+</div>
+{{ end }}
@@ -19,6 +19,10 @@
             <br />
         {{ end }}
         <span>{{ .Bookmark.CreatedAt | ToDateTime }} - {{ if .Bookmark.Public }}Public{{ else }}Private{{ end }}</span>
+        {{ with .Bookmark.LinkCheckedAt }}
+            <br /><span>{{ $.Tr.Msg "link last checked" }}: {{ ToDateTime . }}{{ if $.Bookmark.LinkStatusCode }} (HTTP {{ $.Bookmark.LinkStatusCode }}){{ end }}</span>
+            {{ block "linkStatus" $ }}{{ end }}
+        {{ end }}
         {{ if .User }}
         {{ if eq .User.ID .Bookmark.UserID }}
             <br /><span> <a href="{{ BaseURL "/edit_bookmark" }}?id={{ .Bookmark.ID }}">edit</a></span>
@@ -29,9 +33,15 @@
         <h4>Notes</h4>
         <p>{{ .Bookmark.Notes }}</p>
     {{ end }}
+    {{ if .Highlights }}
+        <div class="mt-6">
+            <h4>Highlights <a href="{{ URLFor "Export highlights" }}?bid={{ .Bookmark.ID }}" class="button is-small">Export Markdown</a></h4>
+            {{ block "highlights" KVData "Highlights" .Highlights "IsOwn" (eq .Bookmark.UserID $uid ) }}{{ end }}
+        </div>
+    {{ end }}
     {{ if .Bookmark.Snapshots }}
         <div class="mt-6">
-            <h4>Snapshots</h4>
+            <h4>Snapshots{{ if gt (len .Bookmark.Snapshots) 1 }} <a href="{{ URLFor "Snapshot timeline" }}?bid={{ .Bookmark.ID }}" class="button is-small">Timeline</a>{{ end }}</h4>
             {{ block "snapshots" KVData "Snapshots" .Bookmark.Snapshots "IsOwn" (eq .Bookmark.UserID $uid ) }}{{ end }}
         </div>
     {{ end }}
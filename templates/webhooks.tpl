@@ -0,0 +1,93 @@
+{{ define "content" }}
+<div class="content">
+    <h2 class="title">{{ .Tr.Msg "webhooks" }}</h2>
+    <p>{{ .Tr.Msg "webhooks description" }}</p>
+    {{ $Tr := .Tr }}
+    {{ if not .Webhooks }}
+    <p>{{ .Tr.Msg "no webhook found" }}</p>
+    {{ else }}
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "url" }}</th>
+                <th>{{ .Tr.Msg "events" }}</th>
+                <th>{{ .Tr.Msg "secret" }}</th>
+                <th>{{ .Tr.Msg "created" }}</th>
+                <th></th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Webhooks }}
+            <tr>
+                <td><code class="has-text-dark">{{ .URL }}</code></td>
+                <td>{{ range .EventList }}<span class="tag">{{ . }}</span> {{ end }}</td>
+                <td><details><summary>{{ $Tr.Msg "show" }}</summary><code class="has-text-dark">{{ .Secret }}</code></details></td>
+                <td>{{ ToDate .CreatedAt }}</td>
+                <td>
+                    <form method="post" action="{{ URLFor "Delete webhook" }}">
+                        <input type="hidden" name="id" value="{{ .ID }}" />
+                        <input type="submit" class="button is-danger is-small" value="{{ $Tr.Msg "delete" }}" />
+                    </form>
+                </td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
+    {{ end }}
+    <h4 class="title is-5">{{ .Tr.Msg "create webhook" }}</h4>
+    <form method="post" action="{{ URLFor "Create webhook" }}">
+        <div class="field">
+            <label class="label">{{ .Tr.Msg "url" }}</label>
+            <div class="control">
+                <input class="input" type="url" name="url" placeholder="https://" required />
+            </div>
+        </div>
+        <div class="field">
+            <label class="label">{{ .Tr.Msg "events" }}</label>
+            <div class="control">
+                {{ range .WebhookEvents }}
+                <label class="checkbox mr-3"><input type="checkbox" name="events" value="{{ . }}" /> {{ $.Tr.Msg (printf "webhook event %s" .) }}</label>
+                {{ end }}
+            </div>
+        </div>
+        <div class="field">
+            <div class="control">
+                <input type="submit" class="button is-primary" value="{{ .Tr.Msg "create webhook" }}" />
+            </div>
+        </div>
+    </form>
+    <h3 class="title">{{ .Tr.Msg "deliveries" }}</h3>
+    {{ if not .Deliveries }}
+    <p>{{ .Tr.Msg "no deliveries" }}</p>
+    {{ else }}
+    <div class="table-container">
+    <table class="table">
+        <thead>
+            <tr>
+                <th>{{ .Tr.Msg "created" }}</th>
+                <th>{{ .Tr.Msg "event" }}</th>
+                <th>{{ .Tr.Msg "url" }}</th>
+                <th>{{ .Tr.Msg "status" }}</th>
+                <th>{{ .Tr.Msg "attempts" }}</th>
+                <th>{{ .Tr.Msg "response" }}</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{ range .Deliveries }}
+            <tr>
+                <td>{{ ToDateTime .CreatedAt }}</td>
+                <td><span class="tag">{{ .Event }}</span></td>
+                <td><code class="has-text-dark">{{ .Webhook.URL }}</code></td>
+                <td>{{ if eq .Status "succeeded" }}<span class="has-text-success">{{ .Status }}</span>{{ else if eq .Status "failed" }}<span class="has-text-danger">{{ .Status }}</span>{{ else }}{{ .Status }}{{ end }}</td>
+                <td>{{ .Attempts }}</td>
+                <td>{{ if .ResponseCode }}{{ .ResponseCode }}{{ end }} <span class="is-size-7 has-text-grey">{{ .Error }}</span></td>
+            </tr>
+            {{ end }}
+        </tbody>
+    </table>
+    </div>
+    {{ end }}
+</div>
+{{ end }}
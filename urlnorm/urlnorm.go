@@ -0,0 +1,233 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package urlnorm computes canonical forms of URLs.
+//
+// Bookmarks of the same page are often saved with slightly different URLs,
+// e.g. with http:// instead of https://, with a "www." prefix, a trailing
+// slash or with tracking parameters. The Normalizer maps these variants to
+// the same canonical URL, which is used to detect duplicate bookmarks.
+//
+// The normalization steps are:
+//   - the scheme and the host are lowercased, default ports are removed
+//   - http:// is replaced with https:// (unless KeepScheme is set)
+//   - the "www." prefix of the host is removed (unless KeepWWW is set)
+//   - the trailing slash of the path is removed (unless KeepTrailingSlash is set)
+//   - the fragment is removed (unless KeepFragment is set)
+//   - tracking parameters are removed and the rest of the query is sorted
+//
+// Example usage:
+//
+//	n := urlnorm.New(cfg.URLs)
+//	u, err := n.Normalize("http://www.example.com/page/?utm_source=x&b=1&a=2")
+//	// u == "https://example.com/page?a=2&b=1"
+//
+//	// Read the <link rel="canonical"> of an HTML document
+//	c := urlnorm.CanonicalLink(html, "https://example.com/page")
+package urlnorm
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/asciimoo/omnom/config"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultRemoveParams is the list of tracking parameters removed if no
+// parameters are configured.
+var DefaultRemoveParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"msclkid",
+	"yclid",
+	"twclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"oly_anon_id",
+	"oly_enc_id",
+	"vero_id",
+	"ref_src",
+	"ref_url",
+}
+
+// ErrInvalidURL is returned if a URL cannot be normalized.
+var ErrInvalidURL = errors.New("invalid URL")
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer computes canonical URLs according to its configuration.
+type Normalizer struct {
+	cfg          config.URLs
+	removeParams []string
+}
+
+// New creates a Normalizer from the given configuration.
+func New(c config.URLs) *Normalizer {
+	params := c.RemoveParams
+	if len(params) == 0 {
+		params = DefaultRemoveParams
+	}
+	n := &Normalizer{
+		cfg:          c,
+		removeParams: make([]string, 0, len(params)),
+	}
+	for _, p := range params {
+		n.removeParams = append(n.removeParams, strings.ToLower(p))
+	}
+	return n
+}
+
+// Normalize returns the canonical form of an absolute URL.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if u.Scheme == "http" && !n.cfg.KeepScheme {
+		u.Scheme = "https"
+		if port == defaultPorts["https"] {
+			port = ""
+		}
+	}
+	if !n.cfg.KeepWWW {
+		host = strings.TrimPrefix(host, "www.")
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+	if !n.cfg.KeepTrailingSlash {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+	if !n.cfg.KeepFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+func (n *Normalizer) normalizeQuery(q string) string {
+	if q == "" {
+		return ""
+	}
+	v, err := url.ParseQuery(q)
+	if err != nil {
+		return q
+	}
+	for k := range v {
+		if n.isRemovedParam(k) {
+			v.Del(k)
+		}
+	}
+	return v.Encode()
+}
+
+func (n *Normalizer) isRemovedParam(k string) bool {
+	k = strings.ToLower(k)
+	for _, p := range n.removeParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		} else if k == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalLink returns the URL of the <link rel="canonical"> element of
+// an HTML document resolved against base. Only http(s) URLs pointing to the
+// host of base are accepted. It returns an empty string if there is no such
+// link.
+func CanonicalLink(doc []byte, base string) string {
+	bu, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.DataAtom == atom.Body {
+				return ""
+			}
+			if t.DataAtom != atom.Link || !isCanonicalLink(t) {
+				continue
+			}
+			href := attr(t, "href")
+			if href == "" {
+				continue
+			}
+			cu, err := bu.Parse(href)
+			if err != nil || (cu.Scheme != "http" && cu.Scheme != "https") || !SameHost(bu.Hostname(), cu.Hostname()) {
+				return ""
+			}
+			return cu.String()
+		}
+	}
+}
+
+func isCanonicalLink(t html.Token) bool {
+	for _, r := range strings.Fields(attr(t, "rel")) {
+		if strings.EqualFold(r, "canonical") {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// SameHost reports whether a and b are the same host names ignoring
+// their case and their "www." prefix.
+func SameHost(a, b string) bool {
+	a = strings.TrimPrefix(strings.ToLower(a), "www.")
+	b = strings.TrimPrefix(strings.ToLower(b), "www.")
+	return a != "" && a == b
+}
@@ -0,0 +1,55 @@
+package urlnorm
+
+import (
+	"testing"
+
+	"github.com/asciimoo/omnom/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	n := New(config.URLs{})
+	tests := map[string]string{
+		"https://example.com":                                 "https://example.com/",
+		"http://www.Example.COM/":                             "https://example.com/",
+		"https://example.com:443/a/b/":                        "https://example.com/a/b",
+		"http://example.com:8080/a":                           "https://example.com:8080/a",
+		"https://example.com/a?utm_source=x&b=2&a=1&fbclid=y": "https://example.com/a?a=1&b=2",
+		"https://example.com/a?UTM_Medium=x":                  "https://example.com/a",
+		"https://example.com/a#section":                       "https://example.com/a",
+		"https://example.com/a%2Fb/":                          "https://example.com/a%2Fb",
+		"ftp://www.example.com/file":                          "ftp://example.com/file",
+	}
+	for in, out := range tests {
+		res, err := n.Normalize(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, out, res, in)
+	}
+	_, err := n.Normalize("/relative/path")
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	n = New(config.URLs{
+		RemoveParams:      []string{"ref"},
+		KeepScheme:        true,
+		KeepWWW:           true,
+		KeepTrailingSlash: true,
+		KeepFragment:      true,
+	})
+	res, err := n.Normalize("http://www.example.com/a/?ref=x&utm_source=y#top")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://www.example.com/a/?utm_source=y#top", res)
+}
+
+func TestCanonicalLink(t *testing.T) {
+	doc := []byte(`<html><head><link rel="stylesheet" href="/s.css"><link rel="Canonical" href="/article"></head><body></body></html>`)
+	assert.Equal(t, "https://m.example.com/article", CanonicalLink(doc, "https://m.example.com/article?page=2"))
+	doc = []byte(`<html><head><link rel="canonical" href="https://example.com/article"></head></html>`)
+	assert.Equal(t, "https://example.com/article", CanonicalLink(doc, "https://www.example.com/x"))
+	assert.Equal(t, "", CanonicalLink(doc, "https://other.com/x"))
+	assert.Equal(t, "", CanonicalLink(doc, "https://m.example.com/x"))
+	doc = []byte(`<html><head></head><body><link rel="canonical" href="/a"></body></html>`)
+	assert.Equal(t, "", CanonicalLink(doc, "https://example.com/x"))
+	doc = []byte(`<html><head><link rel="canonical" href="javascript:alert(1)"></head></html>`)
+	assert.Equal(t, "", CanonicalLink(doc, "https://example.com/x"))
+}
@@ -4,6 +4,7 @@
 //   - String-based error types for constant errors
 //   - Key-value data structure construction from variadic arguments
 //   - File extension extraction from URLs and paths
+//   - Dialer refusing connections to private network addresses
 //
 // These utilities are used throughout the codebase to reduce code duplication
 // and provide consistent behavior for common operations.
@@ -22,10 +23,17 @@ package utils
 
 import (
 	"errors"
+	"net"
 	"net/url"
 	"path/filepath"
+	"syscall"
+	"time"
 )
 
+// ErrNonPublicAddress is returned by PublicDialer when the destination is
+// not a public address.
+const ErrNonPublicAddress = StringError("connection to non-public address refused")
+
 // StringError is a string that implements the error interface.
 type StringError string
 
@@ -62,3 +70,35 @@ func GetExtension(s string) string {
 	}
 	return ext
 }
+
+// IsPublicIP reports whether ip is a public unicast address.
+// Loopback, private, link-local and shared (100.64.0.0/10) addresses are
+// not public.
+func IsPublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// PublicDialer returns a dialer which refuses to connect to non-public
+// addresses. The addresses are checked after name resolution, so host names
+// resolving to private addresses are refused as well.
+func PublicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return ErrNonPublicAddress
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package validator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Issue is a security issue of an HTML document.
+type Issue struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"poster":     true,
+	"src":        true,
+	"xlink:href": true,
+}
+
+// smilTags are the SVG animation tags which can modify the attributes of
+// their parent element.
+var smilTags = map[string]bool{
+	"animate": true,
+	"set":     true,
+}
+
+// smilValueAttrs are the attributes of SMIL animations holding the values
+// of the animated attribute.
+var smilValueAttrs = map[string]bool{
+	"from":   true,
+	"to":     true,
+	"values": true,
+}
+
+var (
+	cssExpressionRe = regexp.MustCompile(`(?i)[a-z-]*\s*:\s*expression\s*\([^;}]*;?`)
+	cssImportRe     = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?["']?\s*(?:[a-z]+:)?//[^;]*;?`)
+)
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+}
+
+// CheckHTML reports every security issue of an HTML document.
+// Unlike ValidateHTML, it does not stop at the first issue.
+func CheckHTML(h []byte) ([]Issue, error) {
+	s := &sanitizer{}
+	err := s.run(h)
+	return s.issues, err
+}
+
+// SanitizeHTML removes script, object and embed tags, event handler and
+// srcdoc attributes, javascript:, vbscript: and non-image data: URLs, SVG
+// animations of URL attributes, refresh meta tags, base tags and dangerous
+// CSS from an HTML document.
+// The rest of the document is kept unmodified.
+// Returns the sanitized document and the removed issues.
+func SanitizeHTML(h []byte) ([]byte, []Issue, error) {
+	s := &sanitizer{out: bytes.NewBuffer(make([]byte, 0, len(h)))}
+	if err := s.run(h); err != nil {
+		return nil, s.issues, err
+	}
+	return s.out.Bytes(), s.issues, nil
+}
+
+type sanitizer struct {
+	out    *bytes.Buffer
+	issues []Issue
+	line   int
+	col    int
+}
+
+func (s *sanitizer) run(h []byte) error {
+	s.line, s.col = 1, 1
+	z := html.NewTokenizer(bytes.NewReader(h))
+	inScript := false
+	inStyle := false
+	for {
+		tt := z.Next()
+		raw := z.Raw()
+		line, col := s.line, s.col
+		s.advance(raw)
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			raw = append([]byte(nil), raw...)
+			t := z.Token()
+			switch t.Data {
+			case "script":
+				s.report(line, col, "script tag found")
+				inScript = tt == html.StartTagToken
+				continue
+			case "base", "object", "embed":
+				s.report(line, col, t.Data+" tag found")
+				continue
+			case "meta":
+				if isRefresh(t) {
+					s.report(line, col, "refresh meta tag found")
+					continue
+				}
+			case "style":
+				inStyle = tt == html.StartTagToken
+			}
+			if s.sanitizeAttrs(&t, line, col) {
+				s.write([]byte(t.String()))
+				continue
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script":
+				if inScript {
+					inScript = false
+					continue
+				}
+			case "style":
+				inStyle = false
+			case "object", "embed":
+				continue
+			}
+		case html.TextToken:
+			if inScript {
+				continue
+			}
+			if inStyle {
+				css, changed := s.sanitizeCSS(string(raw), line, col)
+				if changed {
+					s.write([]byte(css))
+					continue
+				}
+			}
+		}
+		if !inScript {
+			s.write(raw)
+		}
+	}
+}
+
+// sanitizeAttrs removes the dangerous attributes of a tag.
+// Returns true if the tag has been modified.
+func (s *sanitizer) sanitizeAttrs(t *html.Token, line, col int) bool {
+	changed := false
+	attrs := t.Attr[:0]
+	for _, a := range t.Attr {
+		k := strings.ToLower(a.Key)
+		switch {
+		case strings.HasPrefix(k, "on"):
+			s.report(line, col, "invalid attribute "+k)
+			changed = true
+			continue
+		case k == "srcdoc":
+			s.report(line, col, "srcdoc attribute found")
+			changed = true
+			continue
+		case urlAttrs[k] && dangerousURLScheme(a.Val) != "":
+			s.report(line, col, dangerousURLScheme(a.Val)+" URL in attribute "+k)
+			changed = true
+			continue
+		case smilTags[t.Data] && k == "attributename" && urlAttrs[strings.ToLower(strings.TrimSpace(a.Val))]:
+			s.report(line, col, "animation of attribute "+a.Val)
+			changed = true
+			continue
+		case smilTags[t.Data] && smilValueAttrs[k] && hasDangerousValue(a.Val):
+			s.report(line, col, "dangerous URL in attribute "+k)
+			changed = true
+			continue
+		case k == "style":
+			css, ok := s.sanitizeCSS(a.Val, line, col)
+			if ok {
+				a.Val = css
+				changed = true
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	t.Attr = attrs
+	return changed
+}
+
+// sanitizeCSS removes expressions and remote imports from a stylesheet.
+// Returns true if the stylesheet has been modified.
+func (s *sanitizer) sanitizeCSS(css string, line, col int) (string, bool) {
+	changed := false
+	if cssExpressionRe.MatchString(css) {
+		s.report(line, col, "CSS expression found")
+		css = cssExpressionRe.ReplaceAllString(css, "")
+		changed = true
+	}
+	if cssImportRe.MatchString(css) {
+		s.report(line, col, "remote CSS import found")
+		css = cssImportRe.ReplaceAllString(css, "")
+		changed = true
+	}
+	return css, changed
+}
+
+func (s *sanitizer) report(line, col int, msg string) {
+	s.issues = append(s.issues, Issue{Line: line, Column: col, Message: msg})
+}
+
+func (s *sanitizer) write(b []byte) {
+	if s.out != nil {
+		s.out.Write(b)
+	}
+}
+
+// advance moves the position after the raw content of a token.
+func (s *sanitizer) advance(raw []byte) {
+	if i := bytes.LastIndexByte(raw, '\n'); i >= 0 {
+		s.line += bytes.Count(raw, []byte{'\n'})
+		s.col = 1 + utf8.RuneCount(raw[i+1:])
+		return
+	}
+	s.col += utf8.RuneCount(raw)
+}
+
+func isRefresh(t html.Token) bool {
+	for _, a := range t.Attr {
+		if strings.EqualFold(a.Key, "http-equiv") && strings.EqualFold(strings.TrimSpace(a.Val), "refresh") {
+			return true
+		}
+	}
+	return false
+}
+
+// dangerousURLScheme returns the scheme of u if it is a javascript:,
+// vbscript: or non-image data: URL, otherwise an empty string.
+// Browsers ignore whitespace and control characters in URL schemes.
+func dangerousURLScheme(u string) string {
+	u = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u))
+	switch {
+	case strings.HasPrefix(u, "javascript:"):
+		return "javascript"
+	case strings.HasPrefix(u, "vbscript:"):
+		return "vbscript"
+	case strings.HasPrefix(u, "data:") && !strings.HasPrefix(u, "data:image/"):
+		return "data"
+	}
+	return ""
+}
+
+// hasDangerousValue reports whether any value of a semicolon separated SMIL
+// value list is a dangerous URL.
+func hasDangerousValue(v string) bool {
+	for p := range strings.SplitSeq(v, ";") {
+		if dangerousURLScheme(p) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHTML(t *testing.T) {
+	doc := "<html>\n<head><base href=\"https://example.com/\"></head>\n<body onload=\"x()\">\n  <a href=\" JaVa\tscript:alert(1)\">a</a><script>alert(1)</script>\n</body></html>"
+	issues, err := CheckHTML([]byte(doc))
+	assert.NoError(t, err)
+	assert.Equal(t, []Issue{
+		{Line: 2, Column: 7, Message: "base tag found"},
+		{Line: 3, Column: 1, Message: "invalid attribute onload"},
+		{Line: 4, Column: 3, Message: "javascript URL in attribute href"},
+		{Line: 4, Column: 40, Message: "script tag found"},
+	}, issues)
+	assert.Equal(t, "3:1: invalid attribute onload", issues[1].String())
+
+	issues, err = CheckHTML([]byte(`<p class="x">hello</p>`))
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct {
+		in     string
+		out    string
+		issues int
+	}{
+		{`<p>a<script>alert("<p>")</script>b</p>`, `<p>ab</p>`, 1},
+		{`<p>a<script src="x.js"/>b</p>`, `<p>ab</p>`, 1},
+		{`<img src="a.png" onerror="alert(1)" alt="x">`, `<img src="a.png" alt="x">`, 1},
+		{`<a href="javascript:void(0)" title="t">x</a>`, `<a title="t">x</a>`, 1},
+		{`<a href="https://example.com/?javascript:">x</a>`, `<a href="https://example.com/?javascript:">x</a>`, 0},
+		{`<meta http-equiv="Refresh" content="0; url=https://example.com/"><meta charset="utf-8">`, `<meta charset="utf-8">`, 1},
+		{`<head><base href="/"></head>`, `<head></head>`, 1},
+		{`<style>@import url("https://example.com/a.css");p{color:red}</style>`, `<style>p{color:red}</style>`, 1},
+		{`<style>@import "local.css";</style>`, `<style>@import "local.css";</style>`, 0},
+		{`<div style="width: expression(alert(1)); color: red">x</div>`, `<div style=" color: red">x</div>`, 1},
+		{`<svg><a xlink:href="javascript:alert(1)">x</a></svg>`, `<svg><a>x</a></svg>`, 1},
+		{`<a href="VBScript:msgbox(1)">x</a>`, `<a>x</a>`, 1},
+		{`<iframe src="data:text/html,<script>alert(1)</script>"></iframe>`, `<iframe></iframe>`, 1},
+		{`<img src="data:image/png;base64,AAAA">`, `<img src="data:image/png;base64,AAAA">`, 0},
+		{`<iframe srcdoc="<script>alert(1)</script>" title="t"></iframe>`, `<iframe title="t"></iframe>`, 1},
+		{`<object data="a.swf"><embed src="a.swf">x</object>`, `x`, 2},
+		{`<svg><a><animate attributeName="href" to="javascript:alert(1)"/>x</a></svg>`, `<svg><a><animate/>x</a></svg>`, 2},
+		{`<svg><a><set attributeName="title" values="a;javascript:alert(1)"/>x</a></svg>`, `<svg><a><set attributename="title"/>x</a></svg>`, 1},
+	}
+	for _, c := range cases {
+		out, issues, err := SanitizeHTML([]byte(c.in))
+		assert.NoError(t, err)
+		assert.Equal(t, c.out, string(out), c.in)
+		assert.Len(t, issues, c.issues, c.in)
+		assert.Nil(t, ValidateHTML(out).Error, c.in)
+	}
+}
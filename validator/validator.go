@@ -14,6 +14,12 @@
 // attacks and other security issues. It parses HTML using golang.org/x/net/html
 // and reports any security concerns.
 //
+// CheckHTML reports every issue with its line and column, and SanitizeHTML
+// rewrites documents without scripts, plugins, event handlers, srcdoc
+// attributes, script and non-image data: URLs, SVG animations of URLs,
+// refresh meta tags, base tags and dangerous CSS, so snapshots can be stored
+// cleaned instead of being refused.
+//
 // Example usage:
 //
 //	result := validator.ValidateHTML(htmlContent)
@@ -24,6 +30,8 @@
 //	if result.HasShadowDOM {
 //	    log.Println("Content uses Shadow DOM")
 //	}
+//
+//	clean, issues, err := validator.SanitizeHTML(htmlContent)
 package validator
 
 import (
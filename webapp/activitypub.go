@@ -1,18 +1,11 @@
 package webapp
 
 import (
-	"crypto"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 const (
@@ -35,11 +29,17 @@ const (
 	likeAction     = "Like"
 )
 
+// apCollectionSeparator separates the owner and the ID in the handles of
+// collection actors. Mastodon accepts only letters, numbers, underscores,
+// dots and dashes in the usernames of remote accounts.
+const apCollectionSeparator = "."
+
 const contentTpl = `<h1><a href="%[1]s">%[2]s</a></h1>
 %[3]s
 Bookmarked by <a href="https://github.com/asciimoo/omnom">Omnom</a> - <a href="%[4]s">view bookmark</a>`
 
-var apSigHeaderRe = regexp.MustCompile(`keyId="([^"]+)".*,headers="([^"]+)",.*signature="([^"]+)"`)
+// apKeyCache stores the public keys of remote actors used to verify HTTP signatures.
+var apKeyCache = ap.NewKeyCache()
 
 func apOutboxResponse(c *gin.Context) {
 	user := model.GetUser(c.Param("username"))
@@ -48,32 +48,59 @@ func apOutboxResponse(c *gin.Context) {
 		notFoundView(c)
 		return
 	}
+	u := getFullURL(c, URLFor("User", user.Username))
+	if !apAuthorizedFetch(c, u, user.ID) {
+		return
+	}
+	q := model.DB.Model(&model.Bookmark{}).Where("bookmarks.public = 1 AND bookmarks.user_id = ?", user.ID)
+	apWriteOutbox(c, q, u, func(b *model.Bookmark) *ap.OutboxItem {
+		return apCreateBookmarkItem(c, b, u)
+	})
+}
+
+func apCollectionOutboxResponse(c *gin.Context) {
+	col := model.GetPublicCollection(c.Param("cid"))
+	if col == nil {
+		log.Debug().Msg("Unknown collection")
+		notFoundView(c)
+		return
+	}
+	u := apCollectionURL(c, col)
+	if !apAuthorizedFetch(c, u, col.UserID) {
+		return
+	}
+	q := model.DB.Model(&model.Bookmark{}).Where("bookmarks.public = 1 AND bookmarks.collection_id = ?", col.ID)
+	apWriteOutbox(c, q, u, func(b *model.Bookmark) *ap.OutboxItem {
+		return apCreateCollectionBookmarkItem(c, b, col, u)
+	})
+}
+
+func apWriteOutbox(c *gin.Context, q *gorm.DB, actor string, createItem func(*model.Bookmark) *ap.OutboxItem) {
+	q = q.Session(&gorm.Session{})
 	var bs []*model.Bookmark
 	var bc int64
-	if err := model.DB.Model(&model.Bookmark{}).Where("bookmarks.public = 1 AND bookmarks.user_id = ?", user.ID).Count(&bc).Error; err != nil {
-		log.Error().Err(err).Msg("Failed to fetch bookmarks")
+	if err := q.Count(&bc).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to count bookmarks")
 		notFoundView(c)
 		return
 	}
 	//nolint: gosec // conversion is safe
-	if err := model.DB.Limit(int(resultsPerPage)).Where("bookmarks.public = 1 AND bookmarks.user_id = ?", user.ID).Preload("Tags").Find(&bs).Error; err != nil {
-		log.Error().Err(err).Msg("Failed to count bookmarks")
+	if err := q.Limit(int(resultsPerPage)).Preload("Tags").Find(&bs).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to fetch bookmarks")
 		notFoundView(c)
 		return
 	}
 	c.Header("Content-Type", "application/activity+json; charset=utf-8")
-	u := getFullURL(c, URLFor("User", user.Username))
 	resp := ap.Outbox{
 		Context:      "https://www.w3.org/ns/activitystreams",
-		ID:           u,
+		ID:           actor,
 		Type:         "OrderedCollection",
-		Summary:      "Recent bookmarks of " + u,
+		Summary:      "Recent bookmarks of " + actor,
 		TotalItems:   bc,
 		OrderedItems: make([]*ap.OutboxItem, len(bs)),
 	}
 	for i, b := range bs {
-		item := apCreateBookmarkItem(c, b, u)
-		resp.OrderedItems[i] = item
+		resp.OrderedItems[i] = createItem(b)
 	}
 
 	j, err := json.Marshal(resp)
@@ -148,22 +175,40 @@ func apCreateBookmarkItem(c *gin.Context, b *model.Bookmark, actor string) *ap.O
 	return item
 }
 
+// apCreateCollectionBookmarkItem creates a bookmark item published by a
+// collection actor. The IDs differ from the item published by the owner,
+// because receivers can follow both actors.
+func apCreateCollectionBookmarkItem(c *gin.Context, b *model.Bookmark, col *model.Collection, actor string) *ap.OutboxItem {
+	item := apCreateBookmarkItem(c, b, actor)
+	item.Object.ID = fmt.Sprintf("%s#collection-%d", item.Object.ID, col.ID)
+	item.ID = item.Object.ID + "-activity"
+	return item
+}
+
+func apCollectionURL(c *gin.Context, col *model.Collection) string {
+	return getFullURL(c, URLFor("Collection", strconv.FormatUint(uint64(col.ID), 10)))
+}
+
+// apCollectionHandle returns the preferred username of a collection actor.
+// Usernames in the same format are rejected at signup and existing users
+// take precedence over collections in WebFinger lookups.
+func apCollectionHandle(col *model.Collection) string {
+	return fmt.Sprintf("%s%s%d", col.User.Username, apCollectionSeparator, col.ID)
+}
+
+// apParseCollectionHandle splits a collection handle into the username of
+// the owner and the ID of the collection.
+func apParseCollectionHandle(handle string) (string, string, bool) {
+	username, cid, ok := strings.Cut(handle, apCollectionSeparator)
+	if !ok || username == "" || cid == "" || strings.Trim(cid, "0123456789") != "" {
+		return "", "", false
+	}
+	return username, cid, true
+}
+
 func apIdentityResponse(c *gin.Context, user *model.User) {
-	c.Header("Content-Type", "application/activity+json; charset=utf-8")
 	id := getFullURL(c, c.Request.URL.String())
-	cfg, _ := c.Get("config")
-	pk, err := cfg.(*config.Config).ActivityPub.ExportPubKey()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to serialize JSON")
-		return
-	}
-	j, err := json.Marshal(ap.Identity{
-		Context: &ap.Context{
-			Parts: []any{
-				"https://www.w3.org/ns/activitystreams",
-				"https://w3id.org/security/v1",
-			},
-		},
+	apWriteIdentity(c, &ap.Identity{
 		ID:                id,
 		Type:              "Person",
 		Inbox:             getFullURL(c, URLFor("ActivityPub inbox", user.Username)),
@@ -171,23 +216,75 @@ func apIdentityResponse(c *gin.Context, user *model.User) {
 		PreferredUsername: user.Username,
 		Name:              user.Username,
 		URL:               id,
-		Discoverable:      true,
-		Icon: &ap.Image{
-			Type:      "Image",
-			MediaType: "image/png",
-			URL:       getFullURL(c, "/static/icons/addon_icon.png"),
-		},
-		Image: &ap.Image{
-			Type:      "Image",
-			MediaType: "image/png",
-			URL:       getFullURL(c, "/static/icons/addon_icon.png"),
-		},
-		PubKey: ap.PubKey{
-			ID:           id + "#key",
-			Owner:        id,
-			PublicKeyPem: string(pk),
+	}, user.ID)
+}
+
+func apCollectionIdentityResponse(c *gin.Context, col *model.Collection) {
+	id := apCollectionURL(c, col)
+	cid := strconv.FormatUint(uint64(col.ID), 10)
+	followers := getFullURL(c, URLFor("Collection followers", cid))
+	apWriteIdentity(c, &ap.Identity{
+		ID:                id,
+		Type:              "Group",
+		Inbox:             getFullURL(c, URLFor("Collection inbox", cid)),
+		Outbox:            getFullURL(c, URLFor("Collection outbox", cid)),
+		Followers:         &followers,
+		PreferredUsername: apCollectionHandle(col),
+		Name:              col.Name,
+		Summary:           fmt.Sprintf("Public bookmarks of the %s collection of %s", col.Name, col.User.Username),
+		URL:               id,
+	}, col.UserID)
+}
+
+// apWriteIdentity writes the actor document of a local actor owned by uid.
+// In authorized fetch mode unsigned requests receive only the data required
+// to verify signatures, because remote servers can fetch keys without
+// signing their requests.
+func apWriteIdentity(c *gin.Context, i *ap.Identity, uid uint) {
+	cfg, _ := c.Get("config")
+	if cfg.(*config.Config).ActivityPub.AuthorizedFetch {
+		if c.Request.Header.Get("Signature") == "" {
+			i = &ap.Identity{
+				ID:                i.ID,
+				Type:              i.Type,
+				Inbox:             i.Inbox,
+				Outbox:            i.Outbox,
+				PreferredUsername: i.PreferredUsername,
+				URL:               i.URL,
+			}
+		} else if !apAuthorizedFetch(c, i.ID, uid) {
+			return
+		}
+	}
+	c.Header("Content-Type", "application/activity+json; charset=utf-8")
+	pk, err := cfg.(*config.Config).ActivityPub.ExportPubKey()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to serialize JSON")
+		return
+	}
+	i.Context = &ap.Context{
+		Parts: []any{
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1",
 		},
-	})
+	}
+	i.Discoverable = true
+	i.Icon = &ap.Image{
+		Type:      "Image",
+		MediaType: "image/png",
+		URL:       getFullURL(c, "/static/icons/addon_icon.png"),
+	}
+	i.Image = &ap.Image{
+		Type:      "Image",
+		MediaType: "image/png",
+		URL:       getFullURL(c, "/static/icons/addon_icon.png"),
+	}
+	i.PubKey = ap.PubKey{
+		ID:           i.ID + "#key",
+		Owner:        i.ID,
+		PublicKeyPem: string(pk),
+	}
+	j, err := json.Marshal(i)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to serialize JSON")
 	}
@@ -198,6 +295,69 @@ func apIdentityResponse(c *gin.Context, user *model.User) {
 }
 
 func apInboxResponse(c *gin.Context) {
+	d, actor, ok := apReadInboxRequest(c, getFullURL(c, URLFor("User", c.Param("username"))))
+	if !ok {
+		return
+	}
+	switch d.Type {
+	case followAction:
+		go apInboxFollowResponse(c, d, actor)
+	case unfollowAction:
+		go apInboxUnfollowResponse(c, d, actor)
+	case createAction:
+		go apInboxCreateResponse(c, d)
+	case announceAction:
+		go apInboxAnnounceResponse(c, d)
+	case likeAction:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Not supported",
+		})
+		return
+	default:
+		log.Debug().Str("type", d.Type).Str("id", d.ID).Msg("Unhandled ActivityPub inbox message")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown action type",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{
+		"status": "OK",
+	})
+}
+
+func apCollectionInboxResponse(c *gin.Context) {
+	col := model.GetPublicCollection(c.Param("cid"))
+	if col == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Unknown collection",
+		})
+		return
+	}
+	d, actor, ok := apReadInboxRequest(c, apCollectionURL(c, col))
+	if !ok {
+		return
+	}
+	switch d.Type {
+	case followAction:
+		go apCollectionFollowResponse(c.Copy(), d, actor, col)
+	case unfollowAction:
+		go apCollectionUnfollowResponse(c.Copy(), d, col)
+	default:
+		log.Debug().Str("type", d.Type).Str("id", d.ID).Msg("Unhandled ActivityPub collection inbox message")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Not supported",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{
+		"status": "OK",
+	})
+}
+
+// apReadInboxRequest parses an inbox request and verifies its signature.
+// localActor is the actor owning the inbox.
+// Returns false if the request is invalid and the response has been sent.
+func apReadInboxRequest(c *gin.Context, localActor string) (*ap.InboxRequest, *ap.Identity, bool) {
 	c.Header("Content-Type", "application/activity+json; charset=utf-8")
 	body, err := c.GetRawData()
 	if err != nil {
@@ -205,7 +365,7 @@ func apInboxResponse(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, nil, false
 	}
 	d := &ap.InboxRequest{}
 	err = json.Unmarshal(body, d)
@@ -215,56 +375,60 @@ func apInboxResponse(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, nil, false
 	}
 	if d.Object.ID == "" || d.Actor == "" {
 		log.Error().Bytes("body", body).Msg("Inbox request has missing objectID or actor")
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "Missing attributes",
 		})
-		return
+		return nil, nil, false
 	}
-	cfg, _ := c.Get("config")
-	key := cfg.(*config.Config).ActivityPub.PrivK
-	actor, err := ap.FetchActor(d.Actor, d.Object.ID+"#key", key)
-	if err != nil {
-		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to fetch actor")
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "Network error",
+	if apIsBlocked(c, d.Actor) {
+		log.Debug().Str("actor", d.Actor).Msg("Rejected inbox request from blocked actor")
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Blocked",
 		})
-		return
+		return nil, nil, false
 	}
-	if err := apCheckSignature(c, actor, body); err != nil {
+	owner, err := apVerifySignature(c, localActor, body)
+	if err != nil {
 		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to validate signature")
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid signature",
 		})
-		return
+		return nil, nil, false
 	}
-	switch d.Type {
-	case followAction:
-		go apInboxFollowResponse(c, d, actor)
-	case unfollowAction:
-		go apInboxUnfollowResponse(c, d, actor)
-	case createAction:
-		go apInboxCreateResponse(c, d)
-	case announceAction:
-		go apInboxAnnounceResponse(c, d)
-	case likeAction:
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "Not supported",
+	if owner != d.Actor {
+		log.Error().Str("actor", d.Actor).Str("owner", owner).Msg("Signature key does not belong to the actor")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid signature",
 		})
-		return
-	default:
-		log.Debug().Str("type", d.Type).Bytes("msg", body).Msg("Unhandled ActivityPub inbox message")
+		return nil, nil, false
+	}
+	cfg, _ := c.Get("config")
+	key := cfg.(*config.Config).ActivityPub.PrivK
+	actor, err := ap.FetchActor(d.Actor, localActor+"#key", key)
+	if err != nil {
+		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to fetch actor")
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "Unknown action type",
+			"error": "Network error",
 		})
-		return
+		return nil, nil, false
 	}
-	c.JSON(http.StatusOK, map[string]string{
-		"status": "OK",
-	})
+	return d, actor, true
+}
+
+// apIsBlocked checks the actor against the instance domain blocks and the
+// actor blocks of the inbox owner.
+func apIsBlocked(c *gin.Context, actor string) bool {
+	var uid uint
+	if u := model.GetUser(c.Param("username")); u != nil {
+		uid = u.ID
+	} else if col := model.GetPublicCollection(c.Param("cid")); col != nil {
+		uid = col.UserID
+	}
+	return model.IsAPActorBlocked(uid, actor)
 }
 
 func apInboxAnnounceResponse(c *gin.Context, d *ap.InboxRequest) {
@@ -277,6 +441,10 @@ func apInboxAnnounceResponse(c *gin.Context, d *ap.InboxRequest) {
 		log.Error().Err(err).Str("Type", obj.Type).Msg("Unsupported object type")
 		return
 	}
+	if obj.AttributedTo != "" && apIsBlocked(c, obj.AttributedTo) {
+		log.Debug().Str("actor", obj.AttributedTo).Msg("Skipping announced object of blocked actor")
+		return
+	}
 	d.Object = obj
 	apInboxCreateResponse(c, d)
 }
@@ -378,28 +546,140 @@ func apInboxUnfollowResponse(c *gin.Context, d *ap.InboxRequest, actor *ap.Ident
 		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to send HTTP request")
 		return
 	}
-	err = model.DB.Delete(&model.APFollower{}, "user_id= ? and follower = ?", user.ID, d.Actor).Error
+	err = model.DB.Delete(&model.APFollower{}, "user_id= ? and collection_id = 0 and follower = ?", user.ID, d.Actor).Error
+	if err != nil {
+		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to delete AP follower")
+		return
+	}
+}
+
+func apCollectionFollowResponse(c *gin.Context, d *ap.InboxRequest, actor *ap.Identity, col *model.Collection) {
+	u := apCollectionURL(c, col)
+	if d.Object.ID != u {
+		log.Error().Str("object", d.Object.ID).Msg("Inbox request objectID does not match the collection")
+		return
+	}
+	cfg, _ := c.Get("config")
+	key := cfg.(*config.Config).ActivityPub.PrivK
+	data, err := json.Marshal(ap.FollowResponseItem{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      getFullURL(c, "/"+uuid.New().String()),
+		Type:    "Accept",
+		Actor:   u,
+		Object: ap.FollowResponseObject{
+			ID:     d.ID,
+			Type:   followAction,
+			Actor:  d.Actor,
+			Object: u,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to serialize AP inbox response")
+		return
+	}
+	err = ap.SendSignedPostRequest(actor.Inbox, u+"#key", data, key)
+	if err != nil {
+		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to send HTTP request")
+		return
+	}
+	err = model.CreateAPCollectionFollower(col.UserID, col.ID, d.Actor)
+	if err != nil {
+		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to create AP follower")
+		return
+	}
+}
+
+func apCollectionUnfollowResponse(c *gin.Context, d *ap.InboxRequest, col *model.Collection) {
+	if d.Object.Object != apCollectionURL(c, col) {
+		log.Error().Str("object", d.Object.Object).Msg("Inbox request object does not match the collection")
+		return
+	}
+	err := model.DB.Delete(&model.APFollower{}, "user_id = ? and collection_id = ? and follower = ?", col.UserID, col.ID, d.Actor).Error
 	if err != nil {
 		log.Error().Err(err).Str("actor", d.Actor).Msg("Failed to delete AP follower")
 		return
 	}
 }
 
+func apCollectionFollowersResponse(c *gin.Context) {
+	col := model.GetPublicCollection(c.Param("cid"))
+	if col == nil {
+		log.Debug().Msg("Unknown collection")
+		notFoundView(c)
+		return
+	}
+	if !apAuthorizedFetch(c, apCollectionURL(c, col), col.UserID) {
+		return
+	}
+	fs, err := model.GetAPFollowers(col.UserID, col.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch followers")
+		notFoundView(c)
+		return
+	}
+	resp := ap.Followers{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		ID:           getFullURL(c, URLFor("Collection followers", c.Param("cid"))),
+		Type:         "OrderedCollection",
+		TotalItems:   int64(len(fs)),
+		OrderedItems: make([]string, len(fs)),
+	}
+	for i, f := range fs {
+		resp.OrderedItems[i] = f.Follower
+	}
+	c.Header("Content-Type", "application/activity+json; charset=utf-8")
+	j, err := json.Marshal(resp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to serialize JSON")
+	}
+	_, err = c.Writer.Write(j)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to write response")
+	}
+}
+
 func apNotifyFollowers(c *gin.Context, b *model.Bookmark) {
 	if !b.Public {
 		return
 	}
-	var followers []*model.APFollower
-	err := model.DB.Model(&model.APFollower{}).Where("user_id = ?", b.UserID).Find(&followers).Error
+	followers, err := model.GetAPFollowers(b.UserID, 0)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch followers")
 		return
 	}
+	u := getFullURL(c, URLFor("User", b.User.Username))
+	apSendBookmark(c, followers, u, func() *ap.OutboxItem {
+		return apCreateBookmarkItem(c, b, u)
+	})
+	apNotifyCollectionFollowers(c, b)
+}
+
+// apNotifyCollectionFollowers delivers a bookmark only to the followers
+// of its collection if the collection is public.
+func apNotifyCollectionFollowers(c *gin.Context, b *model.Bookmark) {
+	if !b.Public || b.CollectionID == 0 {
+		return
+	}
+	col := model.GetPublicCollection(strconv.FormatUint(uint64(b.CollectionID), 10))
+	if col == nil {
+		return
+	}
+	followers, err := model.GetAPFollowers(col.UserID, col.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch collection followers")
+		return
+	}
+	u := apCollectionURL(c, col)
+	apSendBookmark(c, followers, u, func() *ap.OutboxItem {
+		return apCreateCollectionBookmarkItem(c, b, col, u)
+	})
+}
+
+func apSendBookmark(c *gin.Context, followers []*model.APFollower, u string, createItem func() *ap.OutboxItem) {
 	cfg, _ := c.Get("config")
 	key := cfg.(*config.Config).ActivityPub.PrivK
 	for _, f := range followers {
-		u := getFullURL(c, URLFor("User", b.User.Username))
-		item := apCreateBookmarkItem(c, b, u)
+		item := createItem()
 		if item == nil {
 			continue
 		}
@@ -422,77 +702,49 @@ func apNotifyFollowers(c *gin.Context, b *model.Bookmark) {
 		}
 		log.Debug().Str("actor", f.Follower).Msg("Bookmark sent to inbox")
 	}
-	return
-}
-
-func apParseSigHeader(c *gin.Context, digest string) (string, []byte, error) {
-	sh := c.Request.Header.Get("Signature")
-	sigParts := apSigHeaderRe.FindStringSubmatch(sh)
-	if len(sigParts) < 4 {
-		return "", nil, errors.New("invalid Signature header format: " + sh)
-	}
-	signature := sigParts[3]
-	sigHeaders := make([]string, 0, 3)
-	for h := range strings.SplitSeq(sigParts[2], " ") {
-		if h == "(request-target)" {
-			method := strings.ToLower(c.Request.Method)
-			// TODO perhaps it is better to use the path information from
-			// the ActivityPub request payload, because c.Request.URL.Path
-			// can be different in case of some weird reverse proxying shenanigans
-			s := fmt.Sprintf("(request-target): %s %s", method, c.Request.URL.Path)
-			if c.Request.URL.RawQuery != "" {
-				s += "?" + c.Request.URL.RawQuery
-			}
-			sigHeaders = append(sigHeaders, s)
-		} else if h == "host" {
-			u, err := url.Parse(getFullURL(c, "/"))
-			if err != nil {
-				return "", nil, err
-			}
-			sigHeaders = append(sigHeaders, "host: "+u.Host)
-		} else {
-			hv := c.Request.Header.Get(strings.Title(h))
-			if h == "digest" {
-				if hv != digest {
-					return "", nil, errors.New("digest hash mismatch")
-				}
-			}
-			sigHeaders = append(sigHeaders, fmt.Sprintf("%s: %s", h, hv))
-		}
-	}
-	sigH := []byte(strings.Join(sigHeaders, "\n"))
-	hash := sha256.Sum256(sigH)
-	return signature, hash[:], nil
 }
 
-func apCheckSignature(c *gin.Context, actor *ap.Identity, payload []byte) error {
-	pubKey := actor.PubKey.PublicKeyPem
-	pHash := sha256.Sum256(payload)
-	digest := fmt.Sprintf("SHA-256=%s", base64.StdEncoding.EncodeToString(pHash[:]))
-	signature, hash, err := apParseSigHeader(c, digest)
+// apVerifySignature verifies the HTTP signature of the request and returns
+// the owner of the signing key. Remote keys are fetched with requests signed
+// by localActor.
+func apVerifySignature(c *gin.Context, localActor string, body []byte) (string, error) {
+	cfg, _ := c.Get("config")
+	apCfg := cfg.(*config.Config).ActivityPub
+	u, err := url.Parse(getFullURL(c, "/"))
 	if err != nil {
-		return fmt.Errorf("failed to parse signature header: %w", err)
+		return "", err
 	}
-	pb, _ := pem.Decode([]byte(pubKey))
-	if pb == nil || pb.Type != "PUBLIC KEY" {
-		return errors.New("failed to decode PEM block containing public key")
+	v := &ap.Verifier{
+		Keys:         apKeyCache,
+		Fetch:        ap.ActorKeyFetcher(localActor+"#key", apCfg.PrivK),
+		Host:         u.Host,
+		MaxClockSkew: time.Duration(apCfg.MaxClockSkew) * time.Second,
 	}
-	pk, err := x509.ParsePKIXPublicKey(pb.Bytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse client cert: %w", err)
-	}
-	if _, ok := pk.(*rsa.PublicKey); !ok {
-		return errors.New("invalid key type")
+	return v.Verify(c.Request, body)
+}
+
+// apAuthorizedFetch verifies the signature of ActivityPub GET requests
+// in authorized fetch mode. Returns false if the request is rejected.
+func apAuthorizedFetch(c *gin.Context, localActor string, uid uint) bool {
+	cfg, _ := c.Get("config")
+	if !cfg.(*config.Config).ActivityPub.AuthorizedFetch {
+		return true
 	}
-	signatureDecoded, err := base64.StdEncoding.DecodeString(signature)
+	owner, err := apVerifySignature(c, localActor, nil)
 	if err != nil {
-		return fmt.Errorf("failed to decode signature: %w", err)
+		log.Debug().Err(err).Msg("Unauthorized ActivityPub fetch")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid signature",
+		})
+		return false
 	}
-	err = rsa.VerifyPKCS1v15(pk.(*rsa.PublicKey), crypto.SHA256, hash, signatureDecoded)
-	if err != nil {
-		return fmt.Errorf("invalid signature: %w", err)
+	if model.IsAPActorBlocked(uid, owner) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Blocked",
+		})
+		return false
 	}
-	return nil
+	return true
 }
 
 func apWebfingerResponse(c *gin.Context) {
@@ -504,6 +756,16 @@ func apWebfingerResponse(c *gin.Context) {
 		uname = sParts[1]
 	}
 	u := getFullURL(c, URLFor("User", uname))
+	if username, cid, ok := apParseCollectionHandle(uname); ok && model.GetUser(uname) == nil {
+		col := model.GetPublicCollection(cid)
+		if col == nil || !strings.EqualFold(col.User.Username, username) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Unknown collection",
+			})
+			return
+		}
+		u = apCollectionURL(c, col)
+	}
 	j, err := json.Marshal(ap.Webfinger{
 		Subject: s,
 		Aliases: []string{u},
@@ -1,11 +1,15 @@
 package webapp
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	ap "github.com/asciimoo/omnom/activitypub"
 	"github.com/asciimoo/omnom/config"
@@ -70,6 +74,56 @@ func initTestApp() *gin.Engine {
 	return createEngine(testCfg)
 }
 
+// initTestUser initializes the application with an empty storage and creates
+// a user with an API token of the given scopes. The token is empty if no
+// scopes are given.
+func initTestUser(t *testing.T, name string, scopes ...model.TokenScope) (*gin.Engine, *model.User, string) {
+	t.Helper()
+	router := initTestApp()
+	if err := storage.Init(config.Storage{Filesystem: &config.StorageFilesystem{RootDir: t.TempDir()}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CreateUser(name, name+"@test.com"); err != nil {
+		t.Fatal(err)
+	}
+	u := model.GetUser(name)
+	if len(scopes) == 0 {
+		return router, u, ""
+	}
+	_, tok, err := model.CreateToken(u.ID, "test", scopes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, u, tok
+}
+
+// testRequest sends a request to the application. The request is
+// authenticated with tok if it is not empty. String bodies are sent as JSON,
+// url.Values bodies as forms.
+func testRequest(router *gin.Engine, method, path, tok string, body any) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	var req *http.Request
+	switch b := body.(type) {
+	case url.Values:
+		req, _ = http.NewRequest(method, path, strings.NewReader(b.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case string:
+		if b != "" {
+			req, _ = http.NewRequest(method, path, strings.NewReader(b))
+			req.Header.Set("Content-Type", "application/json")
+			break
+		}
+		req, _ = http.NewRequest(method, path, nil)
+	default:
+		req, _ = http.NewRequest(method, path, nil)
+	}
+	if tok != "" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func TestAPIdentity(t *testing.T) {
 	router := initTestApp()
 	err := model.CreateUser("test", "test@test.com")
@@ -128,30 +182,169 @@ func TestAPWebfinger(t *testing.T) {
 	}
 }
 
-func TestAPSigHeaderParse(t *testing.T) {
+func TestAPCollectionActor(t *testing.T) {
+	router := initTestApp()
+	err := model.CreateUser("test", "test@test.com")
+	if !assert.Nil(t, err) {
+		log.Debug().Msg("failed to create test user")
+		return
+	}
+	u := model.GetUser("test")
+	pub := &model.Collection{Name: "pub", UserID: u.ID, Public: true}
+	priv := &model.Collection{Name: "priv", UserID: u.ID}
+	if !assert.Nil(t, model.DB.Create(pub).Error) || !assert.Nil(t, model.DB.Create(priv).Error) {
+		return
+	}
+	pubID := strconv.FormatUint(uint64(pub.ID), 10)
+	privID := strconv.FormatUint(uint64(priv.ID), 10)
+
 	w := httptest.NewRecorder()
-	ctx, _ := gin.CreateTestContext(w)
-	ctx.Set("config", testCfg)
-	ctx.Request = &http.Request{
-		Header: make(http.Header),
-		URL: &url.URL{
-			Path: "/test",
-		},
+	req, _ := http.NewRequest("GET", URLFor("Collection", pubID), nil)
+	req.Header.Add("Accept", "application/activity+json")
+	router.ServeHTTP(w, req)
+	if !assert.Equal(t, 200, w.Code) {
+		return
+	}
+	var i ap.Identity
+	err = json.Unmarshal(w.Body.Bytes(), &i)
+	if !assert.Nil(t, err) {
+		log.Debug().Bytes("body", w.Body.Bytes()).Msg("failed to parse JSON")
+		return
+	}
+	assert.Equal(t, "Group", i.Type)
+	assert.Equal(t, "test."+pubID, i.PreferredUsername)
+	// Mastodon accepts only these characters in the usernames of remote accounts
+	assert.Regexp(t, `^[a-zA-Z0-9_.-]+$`, i.PreferredUsername)
+	assert.Equal(t, "https://test.com/collection_inbox/"+pubID, i.Inbox)
+	if assert.NotNil(t, i.Followers) {
+		assert.Equal(t, "https://test.com/collection_followers/"+pubID, *i.Followers)
+	}
+
+	webfinger := func(handle string) (ap.Webfinger, int) {
+		var wf ap.Webfinger
+		w := testRequest(router, "GET", "/.well-known/webfinger?resource=acct:"+handle+"@test.com", "", "")
+		if w.Code == http.StatusOK {
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &wf))
+		}
+		return wf, w.Code
+	}
+	wf, code := webfinger(i.PreferredUsername)
+	if !assert.Equal(t, http.StatusOK, code) || !assert.Len(t, wf.Links, 2) {
+		return
+	}
+	assert.Equal(t, "https://test.com/collection/"+pubID, wf.Links[0].Href)
+
+	_, code = webfinger("test." + privID)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// users take precedence over collections with the same handle
+	assert.NotNil(t, validateUsername("test."+pubID))
+	assert.Nil(t, validateUsername("test_"+pubID))
+	assert.Nil(t, model.CreateUser("test."+pubID, "test"+pubID+"@test.com"))
+	wf, code = webfinger("test." + pubID)
+	if assert.Equal(t, http.StatusOK, code) && assert.Len(t, wf.Links, 2) {
+		assert.Equal(t, URLFor("User", "test."+pubID), wf.Links[0].Href)
+	}
+}
+
+func TestNodeInfo(t *testing.T) {
+	router := initTestApp()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/.well-known/nodeinfo", nil)
+	router.ServeHTTP(w, req)
+	if !assert.Equal(t, 200, w.Code) {
+		return
+	}
+	var l nodeInfoLinks
+	err := json.Unmarshal(w.Body.Bytes(), &l)
+	if !assert.Nil(t, err) || !assert.Len(t, l.Links, 1) {
+		return
+	}
+	if !assert.Equal(t, nodeInfoSchema, l.Links[0].Rel) {
+		return
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", URLFor("NodeInfo"), nil)
+	router.ServeHTTP(w, req)
+	if !assert.Equal(t, 200, w.Code) {
+		return
+	}
+	var ni nodeInfo
+	err = json.Unmarshal(w.Body.Bytes(), &ni)
+	if !assert.Nil(t, err) {
+		log.Debug().Bytes("body", w.Body.Bytes()).Msg("failed to parse JSON")
+		return
 	}
-	testHeader := `keyId="https://my.example.com/actor#main-key",headers="(request-target) host date",signature="aabbccdd"`
-	ctx.Request.Header.Set("Signature", testHeader)
-	ctx.Request.Header.Set("Digest", "testdigest")
-	sig, _, err := apParseSigHeader(ctx, "testdigest")
+	assert.Equal(t, "2.1", ni.Version)
+	assert.Equal(t, "omnom", ni.Software.Name)
+	assert.True(t, ni.OpenRegistrations)
+}
+
+func TestAPSigHeaderParse(t *testing.T) {
+	testHeader := `signature="aabbccdd",headers="(request-target) host date",keyId="https://my.example.com/actor#main-key"`
+	sig, err := ap.ParseSignatureHeader(testHeader)
 	if !assert.Nil(t, err) {
 		log.Debug().Msg("failed to parse signature header")
 		return
 	}
-	if !assert.Equal(t, sig, "aabbccdd") {
+	assert.Equal(t, "https://my.example.com/actor#main-key", sig.KeyID)
+	assert.Equal(t, []string{"(request-target)", "host", "date"}, sig.Headers)
+	if !assert.Equal(t, "aabbccdd", base64.StdEncoding.EncodeToString(sig.Signature)) {
 		log.Debug().Msg("failed to parse signature")
 		return
 	}
 }
 
+func TestAPSignatureVerify(t *testing.T) {
+	_, _ = testCfg.ActivityPub.ExportPrivKey()
+	pub, err := testCfg.ActivityPub.ExportPubKey()
+	if !assert.Nil(t, err) {
+		return
+	}
+	keyID := "https://remote.com/users/test#main-key"
+	v := &ap.Verifier{
+		Keys: ap.NewKeyCache(),
+		Fetch: func(string) (*ap.PubKey, error) {
+			return &ap.PubKey{
+				ID:           keyID,
+				Owner:        "https://remote.com/users/test",
+				PublicKeyPem: string(pub),
+			}, nil
+		},
+		Host: "test.com",
+	}
+	body := []byte(`{"type":"Follow"}`)
+	newReq := func() *http.Request {
+		r := httptest.NewRequest("POST", "https://test.com/inbox/test", strings.NewReader(string(body)))
+		err := ap.SignRequest(r, keyID, testCfg.ActivityPub.PrivK, body)
+		assert.Nil(t, err)
+		return r
+	}
+
+	owner, err := v.Verify(newReq(), body)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://remote.com/users/test", owner)
+
+	_, err = v.Verify(newReq(), []byte(`{"type":"Undo"}`))
+	assert.NotNil(t, err)
+
+	r := newReq()
+	r.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	_, err = v.Verify(r, body)
+	assert.NotNil(t, err)
+
+	r = newReq()
+	r.Header.Set("Date", time.Now().Add(-2*time.Hour).UTC().Format(http.TimeFormat))
+	_, err = v.Verify(r, body)
+	assert.NotNil(t, err)
+
+	r = newReq()
+	r.Header.Del("Signature")
+	_, err = v.Verify(r, body)
+	assert.ErrorIs(t, err, ap.ErrMissingSignature)
+}
+
 func TestAPActorOutbox(t *testing.T) {
 	router := initTestApp()
 	err := model.CreateUser("test", "test@test.com")
@@ -176,6 +369,38 @@ func TestAPActorOutbox(t *testing.T) {
 	}
 }
 
+func TestAPInboxBlocked(t *testing.T) {
+	router := initTestApp()
+	err := model.CreateUser("test", "test@test.com")
+	if !assert.Nil(t, err) {
+		log.Debug().Msg("failed to create test user")
+		return
+	}
+	err = model.BlockDomain(&model.DomainBlock{Domain: "blocked.example"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	u := model.GetUser("test")
+	err = model.BlockActor(u.ID, "https://other.example/users/troll")
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, actor := range []string{
+		"https://blocked.example/users/x",
+		"https://sub.blocked.example/users/x",
+		"https://other.example/users/troll",
+	} {
+		body := `{"type":"Follow","actor":"` + actor + `","object":{"id":"https://test.com/users/test"}}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", URLFor("ActivityPub inbox", "test"), strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/activity+json")
+		router.ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusForbidden, w.Code, actor) {
+			return
+		}
+	}
+}
+
 func TestAPActorParse(t *testing.T) {
 	i := &ap.Identity{}
 	err := json.Unmarshal(testActorJSON, i)
@@ -7,6 +7,9 @@ package webapp
 import (
 	"net/http"
 
+	ap "github.com/asciimoo/omnom/activitypub"
+	"github.com/asciimoo/omnom/model"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,6 +24,8 @@ const (
 	PATCH string = "PATCH"
 	// HEAD is HTTP HEAD request type
 	HEAD string = "HEAD"
+	// DELETE is HTTP DELETE request type
+	DELETE string = "DELETE"
 )
 
 // EndpointArg represents an API endpoint argument.
@@ -42,6 +47,12 @@ type Endpoint struct {
 	Description  string
 	Args         []*EndpointArg
 	RSS          string
+	// Scope is the token scope required to access the endpoint with an API token.
+	// Endpoints without scope require admin tokens if authentication is required.
+	Scope model.TokenScope `json:"-"`
+	// Response is an instance of the JSON response type of the endpoint.
+	// It is used to generate the response schema of the OpenAPI specification.
+	Response any `json:"-"`
 }
 
 // Endpoints contains all registered API endpoints.
@@ -180,6 +191,156 @@ func init() {
 					Required:    true,
 					Description: "ID of the second snapshot",
 				},
+				&EndpointArg{
+					Name:        "filter",
+					Type:        "bool",
+					Required:    false,
+					Description: "Use the diff options of the query instead of the stored diff rule",
+				},
+				&EndpointArg{
+					Name:        "include",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the compared elements",
+				},
+				&EndpointArg{
+					Name:        "exclude",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the ignored elements",
+				},
+				&EndpointArg{
+					Name:        "ignore",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated regular expressions of the ignored text",
+				},
+				&EndpointArg{
+					Name:        "main_content",
+					Type:        "bool",
+					Required:    false,
+					Description: "Compare only the automatically detected main content",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Snapshot timeline",
+			Path:         "/snapshot_timeline",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      snapshotTimeline,
+			Description:  "Display the snapshots of the URL of a bookmark with the magnitude of their changes",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "bid",
+					Type:        "int",
+					Required:    true,
+					Description: "ID of the bookmark",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Snapshot diff form",
+			Path:         "/snapshot_diff_form",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      snapshotDiffForm,
+			Description:  "Snapshot diff form with the diff options",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "s1",
+					Type:        "string",
+					Required:    false,
+					Description: "ID of the first snapshot",
+				},
+				&EndpointArg{
+					Name:        "s2",
+					Type:        "string",
+					Required:    false,
+					Description: "ID of the second snapshot",
+				},
+				&EndpointArg{
+					Name:        "filter",
+					Type:        "bool",
+					Required:    false,
+					Description: "Use the diff options of the query instead of the stored diff rule",
+				},
+				&EndpointArg{
+					Name:        "include",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the compared elements",
+				},
+				&EndpointArg{
+					Name:        "exclude",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the ignored elements",
+				},
+				&EndpointArg{
+					Name:        "ignore",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated regular expressions of the ignored text",
+				},
+				&EndpointArg{
+					Name:        "main_content",
+					Type:        "bool",
+					Required:    false,
+					Description: "Compare only the automatically detected main content",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Save snapshot diff options",
+			Path:         "/snapshot_diff_form",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      saveDiffRule,
+			Description:  "Save the diff options for the bookmark or the domain of the first snapshot",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "s1",
+					Type:        "string",
+					Required:    true,
+					Description: "ID of the first snapshot",
+				},
+				&EndpointArg{
+					Name:        "s2",
+					Type:        "string",
+					Required:    true,
+					Description: "ID of the second snapshot",
+				},
+				&EndpointArg{
+					Name:        "scope",
+					Type:        "string",
+					Required:    true,
+					Description: `Scope of the options. Possible values are "bookmark" and "domain"`,
+				},
+				&EndpointArg{
+					Name:        "include",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the compared elements",
+				},
+				&EndpointArg{
+					Name:        "exclude",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated CSS selectors of the ignored elements",
+				},
+				&EndpointArg{
+					Name:        "ignore",
+					Type:        "string",
+					Required:    false,
+					Description: "Newline separated regular expressions of the ignored text",
+				},
+				&EndpointArg{
+					Name:        "main_content",
+					Type:        "bool",
+					Required:    false,
+					Description: "Compare only the automatically detected main content",
+				},
 			},
 		},
 		&Endpoint{
@@ -204,6 +365,28 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Snapshot visual diff",
+			Path:         "/snapshot_visual_diff",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      snapshotVisualDiff,
+			Description:  "PNG image highlighting the differences of the screenshots of two snapshots",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "s1",
+					Type:        "string",
+					Required:    true,
+					Description: "ID of the first snapshot",
+				},
+				&EndpointArg{
+					Name:        "s2",
+					Type:        "string",
+					Required:    true,
+					Description: "ID of the second snapshot",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Archive",
 			Path:         "/archive/*url",
@@ -301,6 +484,142 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Snapshot reader",
+			Path:         "/snapshot_reader",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      snapshotReader,
+			Description:  "Displays the main content of a snapshot in reader mode",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "sid",
+					Type:        "string",
+					Required:    true,
+					Description: "Snapshot key",
+				},
+				&EndpointArg{
+					Name:        "bid",
+					Type:        "int",
+					Required:    true,
+					Description: "Bookmark ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Add highlight",
+			Path:         "/add_highlight",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      addHighlight,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Saves a highlighted passage of a snapshot",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "bid",
+					Type:        "int",
+					Required:    true,
+					Description: "Bookmark ID",
+				},
+				&EndpointArg{
+					Name:        "sid",
+					Type:        "string",
+					Required:    true,
+					Description: "Snapshot key",
+				},
+				&EndpointArg{
+					Name:        "exact",
+					Type:        "string",
+					Required:    true,
+					Description: "Highlighted text",
+				},
+				&EndpointArg{
+					Name:        "prefix",
+					Type:        "string",
+					Required:    false,
+					Description: "Text before the highlighted passage",
+				},
+				&EndpointArg{
+					Name:        "suffix",
+					Type:        "string",
+					Required:    false,
+					Description: "Text after the highlighted passage",
+				},
+				&EndpointArg{
+					Name:        "start",
+					Type:        "int",
+					Required:    true,
+					Description: "Start position of the passage in the text of the reader view",
+				},
+				&EndpointArg{
+					Name:        "end",
+					Type:        "int",
+					Required:    true,
+					Description: "End position of the passage in the text of the reader view",
+				},
+				&EndpointArg{
+					Name:        "comment",
+					Type:        "string",
+					Required:    false,
+					Description: "Comment of the highlight",
+				},
+				&EndpointArg{
+					Name:        "public",
+					Type:        "bool",
+					Required:    false,
+					Description: "Show the highlight to everyone and share it with the followers if the bookmark is public",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Delete highlight",
+			Path:         "/delete_highlight",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      deleteHighlight,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Deletes a highlight",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Highlight ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Highlight",
+			Path:         "/highlight",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      viewHighlight,
+			Description:  "Returns a public highlight as a W3C Web Annotation",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Highlight ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Export highlights",
+			Path:         "/export_highlights",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      exportHighlights,
+			Description:  "Downloads the highlights of a bookmark as a Markdown file",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "bid",
+					Type:        "int",
+					Required:    true,
+					Description: "Bookmark ID",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Download snapshot",
 			Path:         "/download_snapshot",
@@ -315,6 +634,18 @@ func init() {
 					Required:    true,
 					Description: "Snapshot key",
 				},
+				&EndpointArg{
+					Name:        "format",
+					Type:        "string",
+					Required:    false,
+					Description: "Download format. Possible values are \"html\" (default), \"mhtml\" and \"epub\"",
+				},
+				&EndpointArg{
+					Name:        "streams",
+					Type:        "bool",
+					Required:    false,
+					Description: "Embed video and audio files up to 32MB",
+				},
 			},
 		},
 		&Endpoint{
@@ -339,6 +670,7 @@ func init() {
 			Method:       POST,
 			AuthRequired: false,
 			Handler:      pageInfo,
+			Scope:        model.ScopeRead,
 			Description:  "View information about a given webpage - mainly used by the addon",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -361,6 +693,7 @@ func init() {
 			Method:       POST,
 			AuthRequired: false,
 			Handler:      addBookmark,
+			Scope:        model.ScopeBookmarks,
 			Description:  "Add new bookmark",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -431,6 +764,7 @@ func init() {
 			Method:       POST,
 			AuthRequired: false,
 			Handler:      addResource,
+			Scope:        model.ScopeBookmarks,
 			Description:  "Add new resource to a snapshot",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -466,6 +800,7 @@ func init() {
 			Method:       GET,
 			AuthRequired: false,
 			Handler:      checkBookmark,
+			Scope:        model.ScopeRead,
 			Description:  "Checks if a bookmark is already exists",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -536,6 +871,7 @@ func init() {
 			Method:       POST,
 			AuthRequired: false,
 			Handler:      checkAddonToken,
+			Scope:        model.ScopeRead,
 			Description:  "Verifies addon tokens",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -577,6 +913,7 @@ func init() {
 			Method:       GET,
 			AuthRequired: false,
 			Handler:      apWebfingerResponse,
+			Response:     &ap.Webfinger{},
 			Description:  "Webfinger response for ActivityPub",
 			Args: []*EndpointArg{
 				&EndpointArg{
@@ -587,6 +924,74 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Collection",
+			Path:         "/collection/:cid",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      collectionProfile,
+			Description:  "ActivityPub actor of a public collection",
+		},
+		&Endpoint{
+			Name:         "Collection inbox",
+			Path:         "/collection_inbox/:cid",
+			Method:       POST,
+			AuthRequired: false,
+			Handler:      apCollectionInboxResponse,
+			Description:  "Inbox of a public collection to handle follow requests",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "message",
+					Type:               "JSON",
+					Required:           true,
+					SkipAutoValidation: true,
+					Description:        "ActivityPub message",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Collection outbox",
+			Path:         "/collection_outbox/:cid",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      apCollectionOutboxResponse,
+			Description:  "Outbox of a public collection",
+		},
+		&Endpoint{
+			Name:         "Collection followers",
+			Path:         "/collection_followers/:cid",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      apCollectionFollowersResponse,
+			Description:  "Followers of a public collection",
+		},
+		&Endpoint{
+			Name:         "NodeInfo links",
+			Path:         "/.well-known/nodeinfo",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      nodeInfoLinksResponse,
+			Response:     &nodeInfoLinks{},
+			Description:  "NodeInfo discovery document",
+		},
+		&Endpoint{
+			Name:         "NodeInfo",
+			Path:         "/nodeinfo/2.1",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      nodeInfoResponse,
+			Response:     &nodeInfo{},
+			Description:  "NodeInfo 2.1 document containing software and usage information",
+		},
+		&Endpoint{
+			Name:         "OpenAPI specification",
+			Path:         "/api/openapi.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      openAPISpec,
+			Response:     map[string]any{},
+			Description:  "OpenAPI 3 specification of the endpoints",
+		},
 		/****************************************\
 		| LOGIN REQUIRED FOR THE ENDPOINTS BELOW |
 		\****************************************/
@@ -596,15 +1001,7 @@ func init() {
 			Method:       GET,
 			AuthRequired: true,
 			Handler:      profile,
-			Description:  "Displays the user profile page",
-		},
-		&Endpoint{
-			Name:         "Profile page",
-			Path:         "/profile",
-			Method:       POST,
-			AuthRequired: true,
-			Handler:      profile,
-			Description:  "Displays the profile page with addon tokens",
+			Description:  "Displays the user profile page with the API tokens",
 		},
 		&Endpoint{
 			Name:         "Generate addon token",
@@ -612,15 +1009,43 @@ func init() {
 			Method:       GET,
 			AuthRequired: true,
 			Handler:      generateAddonToken,
-			Description:  "Creates a new addon token",
+			Description:  "Creates a new addon token with read and bookmark write scopes",
+		},
+		&Endpoint{
+			Name:         "Create token",
+			Path:         "/create_token",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      createToken,
+			Description:  "Creates a new API token. The token is displayed only once",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "name",
+					Type:        "string",
+					Required:    true,
+					Description: "Token name",
+				},
+				&EndpointArg{
+					Name:        "scopes",
+					Type:        "string list",
+					Required:    true,
+					Description: "Token scopes (read, bookmarks, feeds or admin)",
+				},
+				&EndpointArg{
+					Name:        "expiration",
+					Type:        "int",
+					Required:    false,
+					Description: "Lifetime of the token in days, 0 means no expiration",
+				},
+			},
 		},
 		&Endpoint{
-			Name:         "Delete addon token",
-			Path:         "/delete_addon_token",
+			Name:         "Delete token",
+			Path:         "/delete_token",
 			Method:       POST,
 			AuthRequired: true,
-			Handler:      deleteAddonToken,
-			Description:  "Deletes an addon token",
+			Handler:      deleteToken,
+			Description:  "Revokes an API token",
 			Args: []*EndpointArg{
 				&EndpointArg{
 					Name:        "id",
@@ -630,6 +1055,211 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Webhooks",
+			Path:         "/webhooks",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      webhooks,
+			Description:  "Lists the webhooks of the user with their recent deliveries",
+		},
+		&Endpoint{
+			Name:         "Create webhook",
+			Path:         "/create_webhook",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      createWebhook,
+			Description:  "Creates a webhook which receives the selected events",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "URL",
+					Required:    true,
+					Description: "Receiver URL of the webhook",
+				},
+				&EndpointArg{
+					Name:        "events",
+					Type:        "string list",
+					Required:    true,
+					Description: "Subscribed events",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Delete webhook",
+			Path:         "/delete_webhook",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      deleteWebhook,
+			Description:  "Deletes a webhook with its delivery log",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Webhook ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Duplicate bookmarks",
+			Path:         "/duplicates",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      duplicateBookmarks,
+			Description:  "Lists the bookmarks of the user with the same canonical URL",
+		},
+		&Endpoint{
+			Name:         "Merge bookmarks",
+			Path:         "/merge_bookmarks",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      mergeBookmarks,
+			Description:  "Moves the snapshots and tags of bookmarks to a target bookmark and moves the merged bookmarks to the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "target",
+					Type:        "int",
+					Required:    true,
+					Description: "Bookmark ID which receives the snapshots and tags",
+				},
+				&EndpointArg{
+					Name:        "ids",
+					Type:        "int list",
+					Required:    true,
+					Description: "Merged bookmark IDs",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Trash",
+			Path:         "/trash",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      trash,
+			Description:  "Lists the deleted bookmarks, snapshots and collections of the user",
+		},
+		&Endpoint{
+			Name:         "Restore bookmark",
+			Path:         "/restore_bookmark",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      restoreBookmark,
+			Description:  "Restores a bookmark with its snapshots from the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Bookmark ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Restore snapshot",
+			Path:         "/restore_snapshot",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      restoreSnapshot,
+			Description:  "Restores a snapshot from the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Snapshot ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Restore collection",
+			Path:         "/restore_collection",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      restoreCollection,
+			Description:  "Restores a collection with its subcollections from the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Collection ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Empty trash",
+			Path:         "/empty_trash",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      emptyTrash,
+			Description:  "Permanently removes every item from the trash",
+		},
+		&Endpoint{
+			Name:         "Blocks",
+			Path:         "/blocks",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      blocks,
+			Description:  "Lists blocked ActivityPub actors and instance level domain blocks",
+		},
+		&Endpoint{
+			Name:         "Block actor",
+			Path:         "/block_actor",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      blockActor,
+			Description:  "Blocks an ActivityPub actor",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "actor",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the actor",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Unblock actor",
+			Path:         "/unblock_actor",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      unblockActor,
+			Description:  "Removes an ActivityPub actor block",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Block ID",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Import blocks",
+			Path:         "/import_blocks",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      importBlocks,
+			Description:  "Imports blocked ActivityPub actors from a CSV file",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "blocklist",
+					Type:               "multipart file",
+					Required:           true,
+					Description:        "CSV file containing one actor URL per line",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Export blocks",
+			Path:         "/export_blocks",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      exportBlocks,
+			Description:  "Exports blocked ActivityPub actors as CSV",
+		},
 		&Endpoint{
 			Name:         "Create bookmark form",
 			Path:         "/create_bookmark",
@@ -867,7 +1497,23 @@ func init() {
 					Name:        "url",
 					Type:        "string",
 					Required:    true,
-					Description: "Feed URL",
+					Description: "Feed URL or fediverse handle",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Follow account",
+			Path:         "/follow_account",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      followAccount,
+			Description:  "Find a fediverse account by its @user@instance handle",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "handle",
+					Type:        "string",
+					Required:    false,
+					Description: "Fediverse handle",
 				},
 			},
 		},
@@ -959,6 +1605,46 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Bulk edit bookmarks",
+			Path:         "/bulk_bookmarks",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      bulkBookmarks,
+			Description:  "Applies an operation to the selected bookmarks or to all the bookmarks matching the search parameters",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "operation",
+					Type:        "string",
+					Required:    true,
+					Description: "Operation: add_tags, remove_tags, set_collection, set_public, set_private, set_unread, set_read, delete or restore",
+				},
+				&EndpointArg{
+					Name:        "ids",
+					Type:        "int list",
+					Required:    false,
+					Description: "Selected bookmark IDs",
+				},
+				&EndpointArg{
+					Name:        "all",
+					Type:        "bool",
+					Required:    false,
+					Description: "Apply the operation to all the bookmarks matching the search parameters of My bookmarks instead of the selected ones",
+				},
+				&EndpointArg{
+					Name:        "tags",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated list of tags to add or remove",
+				},
+				&EndpointArg{
+					Name:        "collection_id",
+					Type:        "int",
+					Required:    false,
+					Description: "Target collection ID, 0 removes the bookmarks from their collection",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Add tag",
 			Path:         "/add_tag",
@@ -1035,7 +1721,26 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "delete collection",
+			Path:         "/delete_collection",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      deleteCollection,
+			Description:  "Moves a collection with its subcollections to the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "id",
+					Type:        "int",
+					Required:    true,
+					Description: "Collection ID",
+				},
+			},
+		},
 	}
+	Endpoints = append(Endpoints, apiV1Endpoints()...)
+	Endpoints = append(Endpoints, pinboardEndpoints()...)
+	Endpoints = append(Endpoints, wallabagEndpoints()...)
 }
 
 func api(c *gin.Context) {
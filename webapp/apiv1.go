@@ -0,0 +1,1489 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/feed"
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	apiV1Path         = "/api/v1"
+	apiMaxPerPage     = 100
+	apiDefaultPerPage = 20
+)
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type apiErrorResponse struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiListResponse[T any] struct {
+	Items   []T   `json:"items"`
+	Total   int64 `json:"total"`
+	Page    uint  `json:"page,omitempty"`
+	PerPage uint  `json:"per_page,omitempty"`
+}
+
+type apiBookmark struct {
+	ID              uint             `json:"id"`
+	URL             string           `json:"url"`
+	CanonicalURL    string           `json:"canonical_url"`
+	Title           string           `json:"title"`
+	Notes           string           `json:"notes"`
+	Domain          string           `json:"domain"`
+	Public          bool             `json:"public"`
+	Unread          bool             `json:"unread"`
+	Tags            []string         `json:"tags"`
+	CollectionID    uint             `json:"collection_id"`
+	Snapshots       []*apiSnapshot   `json:"snapshots"`
+	LinkStatus      model.LinkStatus `json:"link_status"`
+	LinkStatusCode  int              `json:"link_status_code"`
+	LinkRedirectURL string           `json:"link_redirect_url"`
+	LinkCheckedAt   *time.Time       `json:"link_checked_at"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+type apiBookmarkRequest struct {
+	URL          string    `json:"url"`
+	Title        *string   `json:"title"`
+	Notes        *string   `json:"notes"`
+	Tags         *[]string `json:"tags"`
+	Public       *bool     `json:"public"`
+	Unread       *bool     `json:"unread"`
+	CollectionID *uint     `json:"collection_id"`
+}
+
+type apiBulkSearch struct {
+	Query      string `json:"query"`
+	Tag        string `json:"tag"`
+	Domain     string `json:"domain"`
+	Collection string `json:"collection"`
+	LinkStatus string `json:"link_status"`
+}
+
+type apiBulkRequest struct {
+	Operation    string         `json:"operation"`
+	IDs          []uint         `json:"ids"`
+	Search       *apiBulkSearch `json:"search"`
+	Tags         []string       `json:"tags"`
+	CollectionID uint           `json:"collection_id"`
+}
+
+type apiBulkResponse struct {
+	Operation string `json:"operation"`
+	Affected  int64  `json:"affected"`
+}
+
+type apiDuplicateGroup struct {
+	CanonicalURL string         `json:"canonical_url"`
+	Bookmarks    []*apiBookmark `json:"bookmarks"`
+}
+
+type apiMergeRequest struct {
+	TargetID uint   `json:"target_id"`
+	IDs      []uint `json:"ids"`
+}
+
+type apiSnapshot struct {
+	ID         uint      `json:"id"`
+	BookmarkID uint      `json:"bookmark_id"`
+	Title      string    `json:"title"`
+	Key        string    `json:"key"`
+	Size       uint      `json:"size"`
+	URL        string    `json:"url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type apiCollection struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  uint      `json:"parent_id"`
+	Public    bool      `json:"public"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type apiCollectionRequest struct {
+	Name     *string `json:"name"`
+	ParentID *uint   `json:"parent_id"`
+	Public   *bool   `json:"public"`
+}
+
+type apiTag struct {
+	ID    uint   `json:"id"`
+	Text  string `json:"text"`
+	Count int64  `json:"count"`
+}
+
+type apiTagRequest struct {
+	Text string `json:"text"`
+}
+
+type apiFeed struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Type      string    `json:"type"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeedRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type apiFeedItem struct {
+	ID        uint      `json:"id"`
+	FeedName  string    `json:"feed_name"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Unread    bool      `json:"unread"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeedItemRequest struct {
+	Unread *bool `json:"unread"`
+}
+
+type apiFeedItemStatus struct {
+	ID     uint `json:"id"`
+	Unread bool `json:"unread"`
+}
+
+func apiV1Endpoints() []*Endpoint {
+	idArg := &EndpointArg{
+		Name:               "id",
+		Type:               "int",
+		Required:           true,
+		Description:        "ID (path parameter)",
+		SkipAutoValidation: true,
+	}
+	pageArgs := []*EndpointArg{
+		&EndpointArg{
+			Name:        "pageno",
+			Type:        "int",
+			Required:    false,
+			Description: "Page number",
+		},
+		&EndpointArg{
+			Name:        "per_page",
+			Type:        "int",
+			Required:    false,
+			Description: fmt.Sprintf("Number of items per page (max %d)", apiMaxPerPage),
+		},
+	}
+	bookmarkArgs := []*EndpointArg{
+		&EndpointArg{
+			Name:        "title",
+			Type:        "string",
+			Required:    false,
+			Description: "Bookmark title",
+		},
+		&EndpointArg{
+			Name:        "notes",
+			Type:        "string",
+			Required:    false,
+			Description: "Bookmark notes",
+		},
+		&EndpointArg{
+			Name:        "tags",
+			Type:        "string list",
+			Required:    false,
+			Description: "Bookmark tags",
+		},
+		&EndpointArg{
+			Name:        "public",
+			Type:        "boolean",
+			Required:    false,
+			Description: "Public bookmark",
+		},
+		&EndpointArg{
+			Name:        "unread",
+			Type:        "boolean",
+			Required:    false,
+			Description: "Unread bookmark",
+		},
+		&EndpointArg{
+			Name:        "collection_id",
+			Type:        "int",
+			Required:    false,
+			Description: "Collection ID, 0 removes the bookmark from its collection",
+		},
+	}
+	collectionArgs := []*EndpointArg{
+		&EndpointArg{
+			Name:        "parent_id",
+			Type:        "int",
+			Required:    false,
+			Description: "Parent collection ID",
+		},
+		&EndpointArg{
+			Name:        "public",
+			Type:        "boolean",
+			Required:    false,
+			Description: "Public collection",
+		},
+	}
+	return []*Endpoint{
+		&Endpoint{
+			Name:         "API list bookmarks",
+			Path:         apiV1Path + "/bookmarks",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListBookmarks,
+			Scope:        model.ScopeRead,
+			Response:     apiListResponse[*apiBookmark]{},
+			Description:  "List bookmarks of the user",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:        "query",
+					Type:        "string",
+					Required:    false,
+					Description: "Text search in title and notes",
+				},
+				&EndpointArg{
+					Name:        "tag",
+					Type:        "string",
+					Required:    false,
+					Description: "Tag filter",
+				},
+				&EndpointArg{
+					Name:        "domain",
+					Type:        "string",
+					Required:    false,
+					Description: "Domain filter",
+				},
+				&EndpointArg{
+					Name:        "collection",
+					Type:        "string",
+					Required:    false,
+					Description: "Collection name filter",
+				},
+				&EndpointArg{
+					Name:        "link_status",
+					Type:        "string",
+					Required:    false,
+					Description: `Link check status filter. Possible values are "ok", "redirected", "dead" and "error"`,
+				},
+			}, pageArgs...),
+		},
+		&Endpoint{
+			Name:         "API create bookmark",
+			Path:         apiV1Path + "/bookmarks",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      apiCreateBookmark,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiBookmark{},
+			Description:  "Create a bookmark. Returns the existing bookmark with status 200 if the URL is already bookmarked",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "URL",
+					Required:    true,
+					Description: "Bookmark URL",
+				},
+			}, requireArg(bookmarkArgs, "title")...),
+		},
+		&Endpoint{
+			Name:         "API get bookmark",
+			Path:         apiV1Path + "/bookmarks/:id",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiGetBookmark,
+			Scope:        model.ScopeRead,
+			Response:     &apiBookmark{},
+			Description:  "Get a bookmark",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API update bookmark",
+			Path:         apiV1Path + "/bookmarks/:id",
+			Method:       PATCH,
+			AuthRequired: true,
+			Handler:      apiUpdateBookmark,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiBookmark{},
+			Description:  "Update a bookmark. Only the specified fields are changed",
+			Args:         append([]*EndpointArg{idArg}, bookmarkArgs...),
+		},
+		&Endpoint{
+			Name:         "API delete bookmark",
+			Path:         apiV1Path + "/bookmarks/:id",
+			Method:       DELETE,
+			AuthRequired: true,
+			Handler:      apiDeleteBookmark,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Move a bookmark with its snapshots to the trash",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API bulk bookmarks",
+			Path:         apiV1Path + "/bookmarks/bulk",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      apiBulkBookmarks,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiBulkResponse{},
+			Description:  "Apply an operation to multiple bookmarks in a single transaction. The bookmarks are selected either by ids or by the results of a search",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "operation",
+					Type:        "string",
+					Required:    true,
+					Description: "Operation: add_tags, remove_tags, set_collection, set_public, set_private, set_unread, set_read, delete or restore",
+				},
+				&EndpointArg{
+					Name:        "ids",
+					Type:        "int list",
+					Required:    false,
+					Description: "Bookmark IDs",
+				},
+				&EndpointArg{
+					Name:        "search",
+					Type:        "object",
+					Required:    false,
+					Description: "Select all the bookmarks matching the query, tag, domain and collection fields of this object",
+				},
+				&EndpointArg{
+					Name:        "tags",
+					Type:        "string list",
+					Required:    false,
+					Description: "Tags to add or remove",
+				},
+				&EndpointArg{
+					Name:        "collection_id",
+					Type:        "int",
+					Required:    false,
+					Description: "Target collection ID of set_collection, 0 removes the bookmarks from their collection",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API duplicate bookmarks",
+			Path:         apiV1Path + "/bookmarks/duplicates",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiDuplicateBookmarks,
+			Scope:        model.ScopeRead,
+			Response:     []*apiDuplicateGroup{},
+			Description:  "List the possible duplicate bookmarks of the user grouped by their canonical URL",
+		},
+		&Endpoint{
+			Name:         "API merge bookmarks",
+			Path:         apiV1Path + "/bookmarks/merge",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      apiMergeBookmarks,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiBookmark{},
+			Description:  "Merge bookmarks into a target bookmark. Snapshots and tags are moved to the target, the merged bookmarks are moved to the trash",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "target_id",
+					Type:        "int",
+					Required:    true,
+					Description: "ID of the bookmark which receives the snapshots and tags",
+				},
+				&EndpointArg{
+					Name:        "ids",
+					Type:        "int list",
+					Required:    true,
+					Description: "IDs of the merged bookmarks",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API list snapshots",
+			Path:         apiV1Path + "/snapshots",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListSnapshots,
+			Scope:        model.ScopeRead,
+			Response:     apiListResponse[*apiSnapshot]{},
+			Description:  "List snapshots of the user",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:        "bookmark_id",
+					Type:        "int",
+					Required:    false,
+					Description: "Bookmark ID filter",
+				},
+			}, pageArgs...),
+		},
+		&Endpoint{
+			Name:         "API get snapshot",
+			Path:         apiV1Path + "/snapshots/:id",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiGetSnapshot,
+			Scope:        model.ScopeRead,
+			Response:     &apiSnapshot{},
+			Description:  "Get a snapshot",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API delete snapshot",
+			Path:         apiV1Path + "/snapshots/:id",
+			Method:       DELETE,
+			AuthRequired: true,
+			Handler:      apiDeleteSnapshot,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Move a snapshot to the trash",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API list collections",
+			Path:         apiV1Path + "/collections",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListCollections,
+			Scope:        model.ScopeRead,
+			Response:     apiListResponse[*apiCollection]{},
+			Description:  "List collections of the user",
+		},
+		&Endpoint{
+			Name:         "API create collection",
+			Path:         apiV1Path + "/collections",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      apiCreateCollection,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiCollection{},
+			Description:  "Create a collection",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:        "name",
+					Type:        "string",
+					Required:    true,
+					Description: "Collection name",
+				},
+			}, collectionArgs...),
+		},
+		&Endpoint{
+			Name:         "API get collection",
+			Path:         apiV1Path + "/collections/:id",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiGetCollection,
+			Scope:        model.ScopeRead,
+			Response:     &apiCollection{},
+			Description:  "Get a collection",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API update collection",
+			Path:         apiV1Path + "/collections/:id",
+			Method:       PATCH,
+			AuthRequired: true,
+			Handler:      apiUpdateCollection,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiCollection{},
+			Description:  "Update a collection. Only the specified fields are changed",
+			Args: append([]*EndpointArg{
+				idArg,
+				&EndpointArg{
+					Name:        "name",
+					Type:        "string",
+					Required:    false,
+					Description: "Collection name",
+				},
+			}, collectionArgs...),
+		},
+		&Endpoint{
+			Name:         "API delete collection",
+			Path:         apiV1Path + "/collections/:id",
+			Method:       DELETE,
+			AuthRequired: true,
+			Handler:      apiDeleteCollection,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Move a collection with its child collections to the trash. Bookmarks of the collection are kept",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API list tags",
+			Path:         apiV1Path + "/tags",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListTags,
+			Scope:        model.ScopeRead,
+			Response:     apiListResponse[*apiTag]{},
+			Description:  "List tags of the user's bookmarks with usage counts",
+		},
+		&Endpoint{
+			Name:         "API rename tag",
+			Path:         apiV1Path + "/tags/:id",
+			Method:       PATCH,
+			AuthRequired: true,
+			Handler:      apiRenameTag,
+			Scope:        model.ScopeBookmarks,
+			Response:     &apiTag{},
+			Description:  "Rename a tag on every bookmark of the user",
+			Args: []*EndpointArg{
+				idArg,
+				&EndpointArg{
+					Name:        "text",
+					Type:        "string",
+					Required:    true,
+					Description: "New tag",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API delete tag",
+			Path:         apiV1Path + "/tags/:id",
+			Method:       DELETE,
+			AuthRequired: true,
+			Handler:      apiDeleteTag,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Remove a tag from every bookmark of the user",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API list feeds",
+			Path:         apiV1Path + "/feeds",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListFeeds,
+			Scope:        model.ScopeFeeds,
+			Response:     apiListResponse[*apiFeed]{},
+			Description:  "List feed subscriptions of the user",
+		},
+		&Endpoint{
+			Name:         "API create feed",
+			Path:         apiV1Path + "/feeds",
+			Method:       POST,
+			AuthRequired: true,
+			Handler:      apiCreateFeed,
+			Scope:        model.ScopeFeeds,
+			Response:     &apiFeed{},
+			Description:  "Subscribe to a feed",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "name",
+					Type:        "string",
+					Required:    true,
+					Description: "Feed name",
+				},
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "Feed URL or fediverse handle",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API get feed",
+			Path:         apiV1Path + "/feeds/:id",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiGetFeed,
+			Scope:        model.ScopeFeeds,
+			Response:     &apiFeed{},
+			Description:  "Get a feed subscription",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API update feed",
+			Path:         apiV1Path + "/feeds/:id",
+			Method:       PATCH,
+			AuthRequired: true,
+			Handler:      apiUpdateFeed,
+			Scope:        model.ScopeFeeds,
+			Response:     &apiFeed{},
+			Description:  "Rename a feed subscription",
+			Args: []*EndpointArg{
+				idArg,
+				&EndpointArg{
+					Name:        "name",
+					Type:        "string",
+					Required:    true,
+					Description: "Feed name",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API delete feed",
+			Path:         apiV1Path + "/feeds/:id",
+			Method:       DELETE,
+			AuthRequired: true,
+			Handler:      apiDeleteFeed,
+			Scope:        model.ScopeFeeds,
+			Description:  "Unsubscribe from a feed",
+			Args:         []*EndpointArg{idArg},
+		},
+		&Endpoint{
+			Name:         "API list feed items",
+			Path:         apiV1Path + "/feed_items",
+			Method:       GET,
+			AuthRequired: true,
+			Handler:      apiListFeedItems,
+			Scope:        model.ScopeFeeds,
+			Response:     apiListResponse[*apiFeedItem]{},
+			Description:  "List feed items of the user",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "query",
+					Type:        "string",
+					Required:    false,
+					Description: "Text search in title and content",
+				},
+				&EndpointArg{
+					Name:        "feed_id",
+					Type:        "int",
+					Required:    false,
+					Description: "Feed ID filter",
+				},
+				&EndpointArg{
+					Name:        "include_read_items",
+					Type:        "boolean",
+					Required:    false,
+					Description: "Include read items",
+				},
+				&EndpointArg{
+					Name:        "per_page",
+					Type:        "int",
+					Required:    false,
+					Description: fmt.Sprintf("Number of items (max %d)", apiMaxPerPage),
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "API update feed item",
+			Path:         apiV1Path + "/feed_items/:id",
+			Method:       PATCH,
+			AuthRequired: true,
+			Handler:      apiUpdateFeedItem,
+			Scope:        model.ScopeFeeds,
+			Response:     &apiFeedItemStatus{},
+			Description:  "Mark a feed item as read or unread",
+			Args: []*EndpointArg{
+				idArg,
+				&EndpointArg{
+					Name:        "unread",
+					Type:        "boolean",
+					Required:    true,
+					Description: "Unread status",
+				},
+			},
+		},
+	}
+}
+
+// requireArg returns a copy of args with the named argument marked required.
+func requireArg(args []*EndpointArg, name string) []*EndpointArg {
+	res := make([]*EndpointArg, len(args))
+	for i, a := range args {
+		if a.Name == name {
+			ra := *a
+			ra.Required = true
+			a = &ra
+		}
+		res[i] = a
+	}
+	return res
+}
+
+func isAPIRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, apiV1Path+"/")
+}
+
+func isJSONRequest(c *gin.Context) bool {
+	return c.ContentType() == gin.MIMEJSON
+}
+
+// readJSONArgs parses the JSON body of the request without consuming it.
+func readJSONArgs(c *gin.Context) (map[string]any, error) {
+	args := make(map[string]any)
+	if c.Request.Body == nil {
+		return args, nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return args, nil
+	}
+	err = json.Unmarshal(body, &args)
+	return args, err
+}
+
+func apiError(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, apiErrorResponse{
+		Error: apiErrorBody{
+			Status:  status,
+			Message: msg,
+		},
+	})
+}
+
+func apiAuthMiddleware(c *gin.Context) {
+	if _, ok := c.Get("token"); ok {
+		c.Next()
+		return
+	}
+	tok, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(tok) == "" {
+		apiError(c, http.StatusUnauthorized, "Missing bearer token")
+		return
+	}
+	apiError(c, http.StatusUnauthorized, "Invalid token")
+}
+
+func apiUser(c *gin.Context) *model.User {
+	u, _ := c.Get("user")
+	return u.(*model.User)
+}
+
+func apiPagination(c *gin.Context) (uint, uint) {
+	perPage := uint(apiDefaultPerPage)
+	if pp, err := strconv.ParseUint(c.Query("per_page"), 10, 64); err == nil && pp > 0 {
+		perPage = uint(min(pp, apiMaxPerPage))
+	}
+	return getPageno(c), perPage
+}
+
+func apiBindJSON(c *gin.Context, v any) bool {
+	if err := c.ShouldBindJSON(v); err != nil {
+		apiError(c, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func apiDBError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	log.Error().Err(err).Msg("API database error")
+	apiError(c, http.StatusInternalServerError, "Database error")
+}
+
+func newAPIBookmark(b *model.Bookmark) *apiBookmark {
+	ab := &apiBookmark{
+		ID:              b.ID,
+		URL:             b.URL,
+		CanonicalURL:    b.CanonicalURL,
+		Title:           b.Title,
+		Notes:           b.Notes,
+		Domain:          b.Domain,
+		Public:          b.Public,
+		Unread:          b.Unread,
+		Tags:            make([]string, 0, len(b.Tags)),
+		CollectionID:    b.CollectionID,
+		Snapshots:       make([]*apiSnapshot, 0, len(b.Snapshots)),
+		LinkStatus:      b.LinkStatus,
+		LinkStatusCode:  b.LinkStatusCode,
+		LinkRedirectURL: b.LinkRedirectURL,
+		LinkCheckedAt:   b.LinkCheckedAt,
+		CreatedAt:       b.CreatedAt,
+		UpdatedAt:       b.UpdatedAt,
+	}
+	for _, t := range b.Tags {
+		ab.Tags = append(ab.Tags, t.Text)
+	}
+	for _, s := range b.Snapshots {
+		ab.Snapshots = append(ab.Snapshots, newAPISnapshot(&s))
+	}
+	return ab
+}
+
+func newAPISnapshot(s *model.Snapshot) *apiSnapshot {
+	return &apiSnapshot{
+		ID:         s.ID,
+		BookmarkID: s.BookmarkID,
+		Title:      s.Title,
+		Key:        s.Key,
+		Size:       s.Size,
+		URL:        URLFor("Snapshot") + "?sid=" + s.Key + "&bid=" + strconv.FormatUint(uint64(s.BookmarkID), 10),
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+func newAPICollection(col *model.Collection) *apiCollection {
+	return &apiCollection{
+		ID:        col.ID,
+		Name:      col.Name,
+		ParentID:  col.ParentID,
+		Public:    col.Public,
+		CreatedAt: col.CreatedAt,
+		UpdatedAt: col.UpdatedAt,
+	}
+}
+
+func newAPIFeed(uf *model.UserFeed) *apiFeed {
+	af := &apiFeed{
+		ID:        uf.ID,
+		Name:      uf.Name,
+		CreatedAt: uf.CreatedAt,
+	}
+	if uf.Feed != nil {
+		af.URL = uf.Feed.URL
+		af.Type = uf.Feed.Type
+		af.Author = uf.Feed.Author
+	}
+	return af
+}
+
+func getAPIBookmark(uid uint, id string) (*model.Bookmark, error) {
+	var b *model.Bookmark
+	err := model.DB.
+		Where("id = ? and user_id = ?", id, uid).
+		Preload("Snapshots").
+		Preload("Tags").
+		First(&b).Error
+	return b, err
+}
+
+func getAPITags(names []string) []model.Tag {
+	tags := make([]model.Tag, 0, len(names))
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n != "" {
+			tags = append(tags, model.GetOrCreateTag(n))
+		}
+	}
+	return tags
+}
+
+func apiListBookmarks(c *gin.Context) {
+	uid := apiUser(c).ID
+	page, perPage := apiPagination(c)
+	var bs []*model.Bookmark
+	var total int64
+	cq := model.DB.Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", uid)
+	//nolint: gosec // uint -> int conversion is safe
+	q := model.DB.Limit(int(perPage)).Offset(int((page-1)*perPage)).Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", uid).Preload("Snapshots").Preload("Tags")
+	filterText(c.Query("query"), true, false, uid, q, cq)
+	filterDomain(c.Query("domain"), q, cq)
+	filterTag(c.Query("tag"), q, cq)
+	filterCollection(c.Query("collection"), uid, q, cq)
+	filterLinkStatus(c.Query("link_status"), q, cq)
+	if err := cq.Count(&total).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	if err := q.Order("bookmarks.updated_at desc").Find(&bs).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiBookmark, 0, len(bs))
+	for _, b := range bs {
+		res = append(res, newAPIBookmark(b))
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiBookmark]{
+		Items:   res,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+func apiCreateBookmark(c *gin.Context) {
+	var r apiBookmarkRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	u := apiUser(c)
+	var tags, notes, public, unread, col string
+	if r.Tags != nil {
+		tags = strings.Join(*r.Tags, ",")
+	}
+	if r.Notes != nil {
+		notes = *r.Notes
+	}
+	if r.Public != nil && *r.Public {
+		public = "1"
+	}
+	if r.Unread != nil && *r.Unread {
+		unread = "1"
+	}
+	if r.CollectionID != nil && *r.CollectionID > 0 {
+		if model.GetCollection(u.ID, strconv.FormatUint(uint64(*r.CollectionID), 10)) == nil {
+			apiError(c, http.StatusBadRequest, "Unknown collection")
+			return
+		}
+		col = strconv.FormatUint(uint64(*r.CollectionID), 10)
+	}
+	title := ""
+	if r.Title != nil {
+		title = *r.Title
+	}
+	b, isNew, err := model.GetOrCreateBookmark(u, r.URL, title, tags, notes, public, "", col, unread)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+		go apNotifyFollowers(c.Copy(), b)
+	}
+	b, err = getAPIBookmark(u.ID, strconv.FormatUint(uint64(b.ID), 10))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(status, newAPIBookmark(b))
+}
+
+func apiGetBookmark(c *gin.Context) {
+	b, err := getAPIBookmark(apiUser(c).ID, c.Param("id"))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIBookmark(b))
+}
+
+func apiUpdateBookmark(c *gin.Context) {
+	var r apiBookmarkRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	uid := apiUser(c).ID
+	var b *model.Bookmark
+	if err := model.DB.Where("id = ? and user_id = ?", c.Param("id"), uid).First(&b).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	if r.Title != nil {
+		if *r.Title == "" {
+			apiError(c, http.StatusBadRequest, "Empty title")
+			return
+		}
+		b.Title = *r.Title
+	}
+	if r.Notes != nil {
+		b.Notes = *r.Notes
+	}
+	if r.Public != nil {
+		b.Public = *r.Public
+	}
+	if r.Unread != nil {
+		b.Unread = *r.Unread
+	}
+	collectionChanged := false
+	if r.CollectionID != nil && *r.CollectionID != b.CollectionID {
+		if *r.CollectionID > 0 && model.GetCollection(uid, strconv.FormatUint(uint64(*r.CollectionID), 10)) == nil {
+			apiError(c, http.StatusBadRequest, "Unknown collection")
+			return
+		}
+		b.CollectionID = *r.CollectionID
+		collectionChanged = b.CollectionID > 0
+	}
+	if err := model.DB.Save(b).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	if r.Tags != nil {
+		if err := model.DB.Model(b).Association("Tags").Replace(getAPITags(*r.Tags)); err != nil {
+			apiDBError(c, err)
+			return
+		}
+	}
+	if collectionChanged {
+		go apNotifyCollectionFollowers(c.Copy(), b)
+	}
+	b, err := getAPIBookmark(uid, c.Param("id"))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
+	c.JSON(http.StatusOK, newAPIBookmark(b))
+}
+
+func apiDeleteBookmark(c *gin.Context) {
+	if err := model.DeleteBookmark(apiUser(c).ID, c.Param("id")); err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func apiBulkBookmarks(c *gin.Context) {
+	var r apiBulkRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	if (len(r.IDs) == 0) == (r.Search == nil) {
+		apiError(c, http.StatusBadRequest, "Either ids or search must be specified")
+		return
+	}
+	a := &model.BulkAction{
+		Operation:    model.BulkOperation(r.Operation),
+		Tags:         r.Tags,
+		CollectionID: r.CollectionID,
+	}
+	var sp *searchParams
+	if r.Search != nil {
+		sp = &searchParams{
+			Q:            r.Search.Query,
+			Tag:          r.Search.Tag,
+			Domain:       r.Search.Domain,
+			Collection:   r.Search.Collection,
+			LinkStatus:   r.Search.LinkStatus,
+			SearchInNote: true,
+		}
+	}
+	uid := apiUser(c).ID
+	n, err := model.BulkUpdateBookmarks(uid, bulkSelection(uid, r.IDs, sp), a)
+	if errors.Is(err, model.ErrInvalidBulkOperation) {
+		apiError(c, http.StatusBadRequest, "Invalid operation or arguments")
+		return
+	}
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &apiBulkResponse{
+		Operation: r.Operation,
+		Affected:  n,
+	})
+}
+
+func apiDuplicateBookmarks(c *gin.Context) {
+	gs, err := model.GetDuplicateBookmarks(apiUser(c).ID)
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiDuplicateGroup, 0, len(gs))
+	for _, g := range gs {
+		ag := &apiDuplicateGroup{
+			CanonicalURL: g.CanonicalURL,
+			Bookmarks:    make([]*apiBookmark, 0, len(g.Bookmarks)),
+		}
+		for _, b := range g.Bookmarks {
+			ag.Bookmarks = append(ag.Bookmarks, newAPIBookmark(b))
+		}
+		res = append(res, ag)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func apiMergeBookmarks(c *gin.Context) {
+	var r apiMergeRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	b, err := model.MergeBookmarks(apiUser(c).ID, r.TargetID, r.IDs)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if errors.Is(err, model.ErrInvalidMerge) {
+		apiError(c, http.StatusBadRequest, "No bookmarks to merge")
+		return
+	}
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIBookmark(b))
+}
+
+func apiSnapshotQuery(uid uint) *gorm.DB {
+	return model.DB.
+		Model(&model.Snapshot{}).
+		Joins("join bookmarks on bookmarks.id = snapshots.bookmark_id").
+		Where("bookmarks.user_id = ?", uid)
+}
+
+func apiListSnapshots(c *gin.Context) {
+	uid := apiUser(c).ID
+	page, perPage := apiPagination(c)
+	q := apiSnapshotQuery(uid)
+	if bid := c.Query("bookmark_id"); bid != "" {
+		q = q.Where("snapshots.bookmark_id = ?", bid)
+	}
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	var ss []*model.Snapshot
+	//nolint: gosec // uint -> int conversion is safe
+	err := q.Order("snapshots.created_at desc").Limit(int(perPage)).Offset(int((page - 1) * perPage)).Find(&ss).Error
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiSnapshot, 0, len(ss))
+	for _, s := range ss {
+		res = append(res, newAPISnapshot(s))
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiSnapshot]{
+		Items:   res,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+func apiGetSnapshot(c *gin.Context) {
+	var s *model.Snapshot
+	if err := apiSnapshotQuery(apiUser(c).ID).Where("snapshots.id = ?", c.Param("id")).First(&s).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPISnapshot(s))
+}
+
+func apiDeleteSnapshot(c *gin.Context) {
+	var s *model.Snapshot
+	if err := apiSnapshotQuery(apiUser(c).ID).Where("snapshots.id = ?", c.Param("id")).First(&s).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	if err := model.DB.Delete(&model.Snapshot{}, "id = ?", s.ID).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func apiListCollections(c *gin.Context) {
+	cols := model.GetCollections(apiUser(c).ID)
+	res := make([]*apiCollection, 0, len(cols))
+	for _, col := range cols {
+		res = append(res, newAPICollection(col))
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiCollection]{
+		Items: res,
+		Total: int64(len(res)),
+	})
+}
+
+// applyAPICollectionRequest updates col with the fields of r.
+// Returns false if the request is invalid and the response has been sent.
+func applyAPICollectionRequest(c *gin.Context, col *model.Collection, r *apiCollectionRequest) bool {
+	if r.Name != nil {
+		if strings.TrimSpace(*r.Name) == "" {
+			apiError(c, http.StatusBadRequest, "Invalid collection name")
+			return false
+		}
+		col.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.ParentID != nil {
+		if *r.ParentID > 0 {
+			p := model.GetCollection(col.UserID, strconv.FormatUint(uint64(*r.ParentID), 10))
+			if p == nil || p.ID == col.ID {
+				apiError(c, http.StatusBadRequest, "Invalid parent collection")
+				return false
+			}
+		}
+		col.ParentID = *r.ParentID
+	}
+	if r.Public != nil {
+		col.Public = *r.Public
+	}
+	return true
+}
+
+func apiCreateCollection(c *gin.Context) {
+	var r apiCollectionRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	uid := apiUser(c).ID
+	col := &model.Collection{UserID: uid}
+	if !applyAPICollectionRequest(c, col, &r) {
+		return
+	}
+	if model.GetCollectionByName(uid, col.Name) != nil {
+		apiError(c, http.StatusConflict, "Collection already exists")
+		return
+	}
+	if err := model.DB.Create(col).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newAPICollection(col))
+}
+
+func apiGetCollection(c *gin.Context) {
+	col := model.GetCollection(apiUser(c).ID, c.Param("id"))
+	if col == nil {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	c.JSON(http.StatusOK, newAPICollection(col))
+}
+
+func apiUpdateCollection(c *gin.Context) {
+	var r apiCollectionRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	uid := apiUser(c).ID
+	col := model.GetCollection(uid, c.Param("id"))
+	if col == nil {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if !applyAPICollectionRequest(c, col, &r) {
+		return
+	}
+	if ec := model.GetCollectionByName(uid, col.Name); ec != nil && ec.ID != col.ID {
+		apiError(c, http.StatusConflict, "Collection already exists")
+		return
+	}
+	if err := model.DB.Save(col).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPICollection(col))
+}
+
+func apiDeleteCollection(c *gin.Context) {
+	err := model.TrashCollection(apiUser(c).ID, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func apiListTags(c *gin.Context) {
+	tags, err := model.GetUserTags(apiUser(c).ID)
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiTag, 0, len(tags))
+	for _, t := range tags {
+		res = append(res, &apiTag{
+			ID:    t.ID,
+			Text:  t.Tag,
+			Count: t.Count,
+		})
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiTag]{
+		Items: res,
+		Total: int64(len(res)),
+	})
+}
+
+func apiTagID(c *gin.Context) (uint, bool) {
+	tid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apiError(c, http.StatusNotFound, "Not found")
+		return 0, false
+	}
+	return uint(tid), true
+}
+
+func apiRenameTag(c *gin.Context) {
+	var r apiTagRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	tid, ok := apiTagID(c)
+	if !ok {
+		return
+	}
+	t, err := model.RenameUserTag(apiUser(c).ID, tid, r.Text)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, &apiTag{
+		ID:   t.ID,
+		Text: t.Text,
+	})
+}
+
+func apiDeleteTag(c *gin.Context) {
+	tid, ok := apiTagID(c)
+	if !ok {
+		return
+	}
+	n, err := model.DeleteUserTag(apiUser(c).ID, tid)
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	if n == 0 {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getAPIFeed(uid uint, id string) (*model.UserFeed, error) {
+	var uf *model.UserFeed
+	err := model.DB.Where("id = ? and user_id = ?", id, uid).Preload("Feed").First(&uf).Error
+	return uf, err
+}
+
+func apiListFeeds(c *gin.Context) {
+	var ufs []*model.UserFeed
+	if err := model.DB.Where("user_id = ?", apiUser(c).ID).Preload("Feed").Order("name").Find(&ufs).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiFeed, 0, len(ufs))
+	for _, uf := range ufs {
+		res = append(res, newAPIFeed(uf))
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiFeed]{
+		Items: res,
+		Total: int64(len(res)),
+	})
+}
+
+func apiCreateFeed(c *gin.Context) {
+	var r apiFeedRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	uid := apiUser(c).ID
+	cfg, _ := c.Get("config")
+	uf, err := feed.AddFeed(cfg.(*config.Config), r.Name, r.URL, uid)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "Failed to add feed: "+err.Error())
+		return
+	}
+	uf, err = getAPIFeed(uid, strconv.FormatUint(uint64(uf.ID), 10))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newAPIFeed(uf))
+}
+
+func apiGetFeed(c *gin.Context) {
+	uf, err := getAPIFeed(apiUser(c).ID, c.Param("id"))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIFeed(uf))
+}
+
+func apiUpdateFeed(c *gin.Context) {
+	var r apiFeedRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	uf, err := getAPIFeed(apiUser(c).ID, c.Param("id"))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	uf.Name = r.Name
+	if err := model.DB.Omit("Feed").Save(uf).Error; err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIFeed(uf))
+}
+
+func apiDeleteFeed(c *gin.Context) {
+	uf, err := getAPIFeed(apiUser(c).ID, c.Param("id"))
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	cfg, _ := c.Get("config")
+	if err := feed.DeleteFeed(cfg.(*config.Config), uf); err != nil {
+		apiDBError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func apiListFeedItems(c *gin.Context) {
+	_, perPage := apiPagination(c)
+	var fid uint
+	if i, err := strconv.ParseUint(c.Query("feed_id"), 10, 64); err == nil {
+		fid = uint(i)
+	}
+	includeRead, _ := strconv.ParseBool(c.Query("include_read_items"))
+	items, total, err := model.SearchFeedItems(apiUser(c).ID, perPage, c.Query("query"), fid, includeRead)
+	if err != nil {
+		apiDBError(c, err)
+		return
+	}
+	res := make([]*apiFeedItem, 0, len(items))
+	for _, i := range items {
+		res = append(res, &apiFeedItem{
+			ID:        i.UserFeedItemID,
+			FeedName:  i.FeedName,
+			URL:       i.URL,
+			Title:     i.Title,
+			Content:   i.Content,
+			Unread:    i.Unread,
+			CreatedAt: i.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, apiListResponse[*apiFeedItem]{
+		Items:   res,
+		Total:   total,
+		PerPage: perPage,
+	})
+}
+
+func apiUpdateFeedItem(c *gin.Context) {
+	var r apiFeedItemRequest
+	if !apiBindJSON(c, &r) {
+		return
+	}
+	if r.Unread == nil {
+		apiError(c, http.StatusBadRequest, "Missing argument: unread")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	res := model.DB.Table("user_feed_items").Where("user_id = ? AND id = ?", apiUser(c).ID, id).Update("unread", *r.Unread)
+	if res.Error != nil {
+		apiDBError(c, res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
+	c.JSON(http.StatusOK, &apiFeedItemStatus{
+		ID:     uint(id),
+		Unread: *r.Unread,
+	})
+}
@@ -0,0 +1,330 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIv1(t *testing.T) {
+	router, u, tok := initTestUser(t, "apitest", model.ScopeRead, model.ScopeBookmarks)
+	_, roTok, err := model.CreateToken(u.ID, "read-only", []model.TokenScope{model.ScopeRead}, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	expiresAt := time.Now().Add(-time.Hour)
+	_, expTok, err := model.CreateToken(u.ID, "expired", []model.TokenScope{model.ScopeRead}, &expiresAt)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	w := testRequest(router, "GET", "/api/v1/bookmarks", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	var e apiErrorResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, http.StatusUnauthorized, e.Error.Status)
+
+	w = testRequest(router, "GET", "/api/v1/bookmarks", expTok, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = testRequest(router, "POST", "/api/v1/collections", roTok, `{"name":"reading"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = testRequest(router, "GET", "/api/v1/feeds", tok, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = testRequest(router, "GET", "/api/v1/bookmarks", roTok, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = testRequest(router, "GET", "/profile", roTok, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	_, adminTok, err := model.CreateToken(u.ID, "admin", []model.TokenScope{model.ScopeAdmin}, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	w = testRequest(router, "GET", "/profile", adminTok, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), adminTok[:8])
+	w = testRequest(router, "GET", "/profile?token="+adminTok, "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	// an unauthenticated bearer header does not bypass CSRF protection
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader("username=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer invalid")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = testRequest(router, "POST", "/api/v1/collections", tok, `{"name":"reading"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	var col apiCollection
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &col))
+
+	w = testRequest(router, "POST", "/api/v1/bookmarks", tok, `{"url":"https://example.com/"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = testRequest(router, "POST", "/api/v1/bookmarks", tok, `{"url":"https://example.com/","title":"x","collection_id":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// HTML endpoints ignore malformed optional arguments
+	w = testRequest(router, "GET", "/bookmarks?from=yesterday", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body := fmt.Sprintf(`{"url":"https://example.com/","title":"Example","tags":["a","b"],"collection_id":%d}`, col.ID)
+	w = testRequest(router, "POST", "/api/v1/bookmarks", tok, body)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	var b apiBookmark
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	assert.Equal(t, "Example", b.Title)
+	assert.ElementsMatch(t, []string{"a", "b"}, b.Tags)
+	assert.Equal(t, col.ID, b.CollectionID)
+
+	bu := fmt.Sprintf("/api/v1/bookmarks/%d", b.ID)
+	w = testRequest(router, "PATCH", bu, tok, `{"title":"Updated","tags":["c"]}`)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	assert.Equal(t, "Updated", b.Title)
+	assert.Equal(t, []string{"c"}, b.Tags)
+	assert.Equal(t, "https://example.com/", b.URL)
+
+	w = testRequest(router, "GET", "/api/v1/bookmarks?tag=c", tok, "")
+	var l struct {
+		Items []*apiBookmark `json:"items"`
+		Total int64          `json:"total"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &l))
+	assert.Equal(t, int64(1), l.Total)
+
+	w = testRequest(router, "DELETE", bu, tok, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = testRequest(router, "GET", bu, tok, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = testRequest(router, "GET", "/api/v1/unknown", tok, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+}
+
+func TestAPIv1Bulk(t *testing.T) {
+	router, u, tok := initTestUser(t, "bulktest", model.ScopeRead, model.ScopeBookmarks)
+	ids := make([]uint, 0, 3)
+	for _, d := range []string{"a.example.com", "b.example.com", "other.test"} {
+		w := testRequest(router, "POST", "/api/v1/bookmarks", tok, fmt.Sprintf(`{"url":"https://%s/","title":"%s"}`, d, d))
+		var b apiBookmark
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+		ids = append(ids, b.ID)
+	}
+	bulk := func(body string) (apiBulkResponse, int) {
+		var r apiBulkResponse
+		w := testRequest(router, "POST", "/api/v1/bookmarks/bulk", tok, body)
+		_ = json.Unmarshal(w.Body.Bytes(), &r)
+		return r, w.Code
+	}
+	count := func(q string) int64 {
+		var l apiListResponse[*apiBookmark]
+		w := testRequest(router, "GET", "/api/v1/bookmarks?"+q, tok, "")
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &l))
+		return l.Total
+	}
+
+	_, code := bulk(`{"operation":"add_tags","tags":["x"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	_, code = bulk(`{"operation":"unknown","ids":[1]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	_, code = bulk(`{"operation":"add_tags","ids":["1"],"tags":["x"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	r, code := bulk(fmt.Sprintf(`{"operation":"add_tags","ids":[%d,%d,999],"tags":["x","y"]}`, ids[0], ids[1]))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(2), r.Affected)
+	assert.Equal(t, int64(2), count("tag=y"))
+	r, _ = bulk(fmt.Sprintf(`{"operation":"add_tags","ids":[%d,%d],"tags":["x"]}`, ids[0], ids[2]))
+	assert.Equal(t, int64(2), r.Affected)
+	assert.Equal(t, int64(3), count("tag=x"))
+
+	r, _ = bulk(`{"operation":"remove_tags","search":{"domain":"example.com"},"tags":["y"]}`)
+	assert.Equal(t, int64(2), r.Affected)
+	assert.Equal(t, int64(0), count("tag=y"))
+
+	r, _ = bulk(`{"operation":"set_unread","search":{"tag":"x","domain":"other"}}`)
+	assert.Equal(t, int64(1), r.Affected)
+	assert.Equal(t, int64(1), model.GetUnreadBookmarkCount(u.ID))
+
+	r, _ = bulk(fmt.Sprintf(`{"operation":"set_public","ids":[%d]}`, ids[0]))
+	assert.Equal(t, int64(1), r.Affected)
+	// the search of the bulk edit form can select only the private bookmarks
+	n, err := model.BulkUpdateBookmarks(u.ID, bulkSelection(u.ID, nil, &searchParams{IsPrivate: true}), &model.BulkAction{
+		Operation: model.BulkAddTags,
+		Tags:      []string{"private"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, int64(2), count("tag=private"))
+	assert.Equal(t, int64(0), count("tag=private&domain=a.example.com"))
+	assert.Equal(t, int64(1), count("tag=private&domain=b.example.com"))
+
+	r, _ = bulk(`{"operation":"delete","search":{"domain":"example.com"}}`)
+	assert.Equal(t, int64(2), r.Affected)
+	assert.Equal(t, int64(1), count(""))
+}
+
+func TestAPIv1Trash(t *testing.T) {
+	router, u, tok := initTestUser(t, "trashtest", model.ScopeRead, model.ScopeBookmarks)
+	var col apiCollection
+	w := testRequest(router, "POST", "/api/v1/collections", tok, `{"name":"trashed"}`)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &col))
+	ids := make([]uint, 0, 2)
+	for _, d := range []string{"a.example.com", "b.example.com"} {
+		body := fmt.Sprintf(`{"url":"https://%s/","title":"%s","tags":["tt"],"collection_id":%d}`, d, d, col.ID)
+		w = testRequest(router, "POST", "/api/v1/bookmarks", tok, body)
+		var b apiBookmark
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+		ids = append(ids, b.ID)
+	}
+	count := func(q string) int64 {
+		var l apiListResponse[*apiBookmark]
+		w := testRequest(router, "GET", "/api/v1/bookmarks?"+q, tok, "")
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &l))
+		return l.Total
+	}
+
+	w = testRequest(router, "DELETE", fmt.Sprintf("/api/v1/bookmarks/%d", ids[0]), tok, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = testRequest(router, "DELETE", fmt.Sprintf("/api/v1/collections/%d", col.ID), tok, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, int64(1), count(""))
+	tr, err := model.GetTrash(u.ID)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, tr.Bookmarks, 1)
+	assert.Len(t, tr.Collections, 1)
+
+	w = testRequest(router, "POST", "/api/v1/bookmarks/bulk", tok, fmt.Sprintf(`{"operation":"restore","ids":[%d,%d]}`, ids[0], ids[1]))
+	var r apiBulkResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &r))
+	assert.Equal(t, int64(1), r.Affected)
+	assert.Equal(t, int64(2), count("tag=tt"))
+	_, err = model.RestoreCollection(u.ID, fmt.Sprint(col.ID))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count("collection=trashed"))
+
+	// adding a bookmark in the trash again restores it
+	assert.Nil(t, model.DeleteBookmark(u.ID, fmt.Sprint(ids[0])))
+	w = testRequest(router, "POST", "/api/v1/bookmarks", tok, `{"url":"https://a.example.com/","title":"again"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var b apiBookmark
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	assert.Equal(t, ids[0], b.ID)
+	assert.Equal(t, int64(2), count("tag=tt"))
+
+	assert.Nil(t, model.DeleteBookmark(u.ID, fmt.Sprint(ids[1])))
+	n, err := model.PurgeTrash(u.ID, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = model.RestoreBookmark(u.ID, fmt.Sprint(ids[1]))
+	assert.ErrorIs(t, err, model.ErrNotInTrash)
+	assert.Equal(t, int64(1), count(""))
+}
+
+func TestAPIv1Duplicates(t *testing.T) {
+	router, _, tok := initTestUser(t, "duptest", model.ScopeRead, model.ScopeBookmarks)
+	create := func(u, tags string) (*apiBookmark, int) {
+		var b apiBookmark
+		w := testRequest(router, "POST", "/api/v1/bookmarks", tok, fmt.Sprintf(`{"url":"%s","title":"t","tags":[%s]}`, u, tags))
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+		return &b, w.Code
+	}
+	b1, code := create("https://example.com/article", `"a"`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "https://example.com/article", b1.CanonicalURL)
+	b, code := create("http://www.Example.com/article/?utm_source=feed#top", `"b"`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, b1.ID, b.ID)
+	b2, code := create("https://example.com/article?page=2", `"b"`)
+	assert.Equal(t, http.StatusCreated, code)
+
+	var gs []*apiDuplicateGroup
+	w := testRequest(router, "GET", "/api/v1/bookmarks/duplicates", tok, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &gs))
+	assert.Len(t, gs, 0)
+
+	mb := &model.Bookmark{URL: b2.URL, CanonicalURL: b2.CanonicalURL}
+	mb.ID = b2.ID
+	assert.Nil(t, model.SetCanonicalLink(mb, "https://other.com/article"))
+	assert.Nil(t, model.SetCanonicalLink(mb, "https://example.com/"))
+	assert.Equal(t, b2.CanonicalURL, mb.CanonicalURL)
+	assert.Nil(t, model.SetCanonicalLink(mb, "https://www.example.com/article"))
+	w = testRequest(router, "GET", "/api/v1/bookmarks/duplicates", tok, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &gs))
+	if !assert.Len(t, gs, 1) {
+		return
+	}
+	assert.Len(t, gs[0].Bookmarks, 2)
+
+	w = testRequest(router, "POST", "/api/v1/bookmarks/merge", tok, fmt.Sprintf(`{"target_id":%d,"ids":[%d]}`, b1.ID, b1.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = testRequest(router, "POST", "/api/v1/bookmarks/merge", tok, fmt.Sprintf(`{"target_id":%d,"ids":[%d]}`, b1.ID, b2.ID))
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	assert.ElementsMatch(t, []string{"a", "b"}, b.Tags)
+	w = testRequest(router, "GET", fmt.Sprintf("/api/v1/bookmarks/%d", b2.ID), tok, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOpenAPI(t *testing.T) {
+	router := initTestApp()
+	w := testRequest(router, "GET", "/api/openapi.json", "", "")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var spec struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if !assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &spec)) {
+		return
+	}
+	assert.Equal(t, openAPIVersion, spec.OpenAPI)
+	op, ok := spec.Paths["/api/v1/bookmarks/{id}"]["patch"]
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Contains(t, op, "requestBody")
+	assert.Contains(t, spec.Paths["/docs/{page}"], "get")
+	assert.Equal(t, "apiListBookmarks", spec.Paths["/api/v1/bookmarks"]["get"]["operationId"])
+}
+
+func TestAPIv1LinkStatusFilter(t *testing.T) {
+	router, _, tok := initTestUser(t, "linktest", model.ScopeRead, model.ScopeBookmarks)
+	var b apiBookmark
+	w := testRequest(router, "POST", "/api/v1/bookmarks", tok, `{"url":"https://example.com/dead","title":"dead"}`)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	testRequest(router, "POST", "/api/v1/bookmarks", tok, `{"url":"https://example.com/alive","title":"alive"}`)
+	assert.Nil(t, model.SetLinkStatus(b.ID, model.LinkDead, http.StatusGone, ""))
+
+	var res apiListResponse[*apiBookmark]
+	w = testRequest(router, "GET", "/api/v1/bookmarks?link_status=dead", tok, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if !assert.Len(t, res.Items, 1) {
+		return
+	}
+	assert.Equal(t, b.ID, res.Items[0].ID)
+	assert.Equal(t, model.LinkDead, res.Items[0].LinkStatus)
+	assert.Equal(t, http.StatusGone, res.Items[0].LinkStatusCode)
+	assert.NotNil(t, res.Items[0].LinkCheckedAt)
+
+	w = testRequest(router, "GET", "/api/v1/bookmarks?link_status=invalid", tok, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int64(2), res.Total)
+}
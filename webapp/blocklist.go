@@ -0,0 +1,82 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func blocks(c *gin.Context) {
+	u, _ := c.Get("user")
+	uid := u.(*model.User).ID
+	abs, err := model.GetActorBlocks(uid)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+	}
+	dbs, err := model.GetDomainBlocks()
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+	}
+	for _, b := range dbs {
+		b.Domain = b.PublicDomain()
+	}
+	render(c, http.StatusOK, "blocks", map[string]any{
+		"ActorBlocks":  abs,
+		"DomainBlocks": dbs,
+	})
+}
+
+func blockActor(c *gin.Context) {
+	u, _ := c.Get("user")
+	if err := model.BlockActor(u.(*model.User).ID, c.PostForm("actor")); err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Actor blocked", true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/blocks"))
+}
+
+func unblockActor(c *gin.Context) {
+	u, _ := c.Get("user")
+	if err := model.UnblockActor(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Actor unblocked", true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/blocks"))
+}
+
+func importBlocks(c *gin.Context) {
+	u, _ := c.Get("user")
+	f, _, err := c.Request.FormFile("blocklist")
+	if err != nil {
+		setNotification(c, nError, "Missing blocklist file", true)
+		c.Redirect(http.StatusFound, baseURL("/blocks"))
+		return
+	}
+	defer f.Close()
+	n, err := model.ImportActorBlocks(u.(*model.User).ID, f)
+	if err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, fmt.Sprintf("%d actors blocked", n), true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/blocks"))
+}
+
+func exportBlocks(c *gin.Context) {
+	u, _ := c.Get("user")
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="omnom_blocks.csv"`)
+	if err := model.ExportActorBlocks(u.(*model.User).ID, c.Writer); err != nil {
+		log.Error().Err(err).Msg("Failed to export actor blocks")
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"net/http"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,12 +24,14 @@ import (
 	"github.com/asciimoo/omnom/model"
 	"github.com/asciimoo/omnom/static"
 	"github.com/asciimoo/omnom/storage"
+	"github.com/asciimoo/omnom/urlnorm"
 	"github.com/asciimoo/omnom/validator"
 
 	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 const (
@@ -70,6 +74,8 @@ var bookmarkSubmenu = []struct {
 	{"my", "My bookmarks", "my-bookmarks"},
 	{"public", "Public bookmarks", "bookmarks"},
 	{"create", "Create bookmark form", "create-bookmark"},
+	{"duplicates", "Duplicate bookmarks", "duplicates"},
+	{"trash", "Trash", "trash"},
 }
 
 type browserSnapshotResponse struct {
@@ -101,11 +107,18 @@ func bookmarks(c *gin.Context) {
 	}
 	cq := model.DB.Model(&model.Bookmark{}).Where("bookmarks.public = 1")
 	//nolint: gosec // uint -> int conversion is safe
-	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Where("bookmarks.public = 1").Preload("Snapshots").Preload("Tags").Preload("User").Preload("Collection")
+	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Where("bookmarks.public = 1").Preload("Snapshots").Preload("Snapshots.Resources", "kind = ?", model.ResourceThumbnail).Preload("Tags").Preload("User").Preload("Collection")
 	if !reflect.DeepEqual(*sp, searchParams{}) {
 		hasSearch = true
-		filterText(sp.Q, sp.SearchInNote, sp.SearchInSnapshot, q, cq)
+		var uid uint
+		if u, ok := c.Get("user"); ok && u != nil {
+			uid = u.(*model.User).ID
+		}
+		filterText(sp.Q, sp.SearchInNote, sp.SearchInSnapshot, uid, q, cq)
 		filterOwner(sp.Owner, q, cq)
+		if o := model.GetUser(sp.Owner); o != nil {
+			filterCollection(sp.Collection, o.ID, q, cq)
+		}
 		_ = filterFromDate(sp.FromDate, q, cq)
 		_ = filterToDate(sp.ToDate, q, cq)
 		filterDomain(sp.Domain, q, cq)
@@ -151,7 +164,7 @@ func myBookmarks(c *gin.Context) {
 	var bookmarkCount int64
 	cq := model.DB.Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", uid)
 	//nolint: gosec // uint -> int conversion is safe
-	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", u.(*model.User).ID).Preload("Snapshots").Preload("Tags").Preload("User").Preload("Collection")
+	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", u.(*model.User).ID).Preload("Snapshots").Preload("Snapshots.Resources", "kind = ?", model.ResourceThumbnail).Preload("Tags").Preload("User").Preload("Collection")
 	sp := &searchParams{}
 	hasSearch := false
 	if err := c.ShouldBind(sp); err != nil {
@@ -161,18 +174,7 @@ func myBookmarks(c *gin.Context) {
 	}
 	if !reflect.DeepEqual(*sp, searchParams{}) {
 		hasSearch = true
-		filterText(sp.Q, sp.SearchInNote, sp.SearchInSnapshot, q, cq)
-		_ = filterFromDate(sp.FromDate, q, cq)
-		_ = filterToDate(sp.ToDate, q, cq)
-		filterDomain(sp.Domain, q, cq)
-		filterTag(sp.Tag, q, cq)
-		filterCollection(sp.Collection, uid, q, cq)
-		if sp.IsPublic {
-			filterPublic(q, cq)
-		}
-		if sp.IsPrivate {
-			filterPublic(q, cq)
-		}
+		filterUserBookmarks(sp, uid, q, cq)
 	}
 	cq.Count(&bookmarkCount)
 	orderBy := c.Query("order_by")
@@ -202,6 +204,73 @@ func myBookmarks(c *gin.Context) {
 	})
 }
 
+// bulkSelection returns a query selecting bookmark IDs either from an
+// explicit ID list or, if sp is not nil, from the search results of a user.
+// Bookmarks in the trash are selected too, model.BulkUpdateBookmarks
+// filters them according to the operation.
+func bulkSelection(uid uint, ids []uint, sp *searchParams) *gorm.DB {
+	q := model.DB.Unscoped().Model(&model.Bookmark{}).Select("bookmarks.id").Where("bookmarks.user_id = ?", uid)
+	if sp == nil {
+		return q.Where("bookmarks.id IN ?", ids)
+	}
+	filterUserBookmarks(sp, uid, q, model.DB.Model(&model.Bookmark{}))
+	return q
+}
+
+func bulkBookmarks(c *gin.Context) {
+	u, _ := c.Get("user")
+	uid := u.(*model.User).ID
+	sp := &searchParams{}
+	if err := c.ShouldBind(sp); err != nil {
+		setNotification(c, nError, err.Error(), true)
+		c.Redirect(http.StatusFound, URLFor("My bookmarks"))
+		return
+	}
+	redirectURL := URLFor("My bookmarks") + "?" + sp.Serialize()
+	a := model.BulkAction{Operation: model.BulkOperation(c.PostForm("operation"))}
+	if !slices.Contains(model.BulkOperations, a.Operation) {
+		setNotification(c, nError, "Unknown operation", true)
+		c.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+	if a.Operation == model.BulkRestore {
+		redirectURL = URLFor("Trash")
+	}
+	a.Tags = strings.Split(c.PostForm("tags"), ",")
+	if cid := c.PostForm("collection_id"); cid != "" {
+		id, err := strconv.ParseUint(cid, 10, 64)
+		if err != nil {
+			setNotification(c, nError, "Invalid collection", true)
+			c.Redirect(http.StatusFound, redirectURL)
+			return
+		}
+		a.CollectionID = uint(id)
+	}
+	var ids []uint
+	if c.PostForm("all") == "" {
+		for _, s := range c.PostFormArray("ids") {
+			id, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				continue
+			}
+			ids = append(ids, uint(id))
+		}
+		if len(ids) == 0 {
+			setNotification(c, nError, "No bookmark selected", true)
+			c.Redirect(http.StatusFound, redirectURL)
+			return
+		}
+		sp = nil
+	}
+	n, err := model.BulkUpdateBookmarks(uid, bulkSelection(uid, ids, sp), &a)
+	if err != nil {
+		setNotification(c, nError, "Failed to update bookmarks: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, fmt.Sprintf("%d bookmarks updated", n), true)
+	}
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
 func createBookmarkForm(c *gin.Context) {
 	cfg, _ := c.Get("config")
 	u, _ := c.Get("user")
@@ -223,10 +292,11 @@ func createBookmark(c *gin.Context) {
 	u, _ := cu.(*model.User)
 
 	bs := &browserSnapshotResponse{}
+	var captures *snapshotCaptures
 	bsCreated := false
 	var err error
 	if cfg.(*config.Config).App.CreateSnapshotFromWebapp {
-		bs, err = createSnapshot(c.PostForm("url"), cfg.(*config.Config).App.WebappSnapshotterTimeout)
+		bs, captures, err = createSnapshot(c.PostForm("url"), &cfg.(*config.Config).App)
 		if err != nil {
 			setNotification(c, nError, "Failed to create snapshot: "+err.Error(), true)
 		} else {
@@ -259,6 +329,7 @@ func createBookmark(c *gin.Context) {
 			c.Redirect(http.StatusFound, URLFor("Create bookmark form"))
 			return
 		}
+		updateCanonicalURL(b, []byte(bs.DOM))
 
 		s := &model.Snapshot{
 			Key:        key,
@@ -286,18 +357,24 @@ func createBookmark(c *gin.Context) {
 			// TODO check error in GetOrCreateResource
 			s.Resources = append(s.Resources, model.GetOrCreateResource(key, r.Mimetype, r.Filename, size))
 		}
+		if err := storeCaptures(s, captures); err != nil {
+			setNotification(c, nError, "Failed to create bookmark: "+err.Error(), true)
+			c.Redirect(http.StatusFound, URLFor("Create bookmark form"))
+			return
+		}
 		if err := model.DB.Save(s).Error; err != nil {
 			setNotification(c, nError, "Failed to create bookmark: "+err.Error(), true)
 			c.Redirect(http.StatusFound, URLFor("Create bookmark form"))
 			return
 		}
+		model.TriggerSnapshotWebhooks(model.EventSnapshotCreated, b, s)
 	}
 
 	setNotification(c, nInfo, "Bookmark successfully created", true)
 	c.Redirect(http.StatusFound, fmt.Sprintf("%s?id=%d", URLFor("Bookmark"), b.ID))
 }
 
-func createSnapshot(urlString string, to int) (*browserSnapshotResponse, error) {
+func createSnapshot(urlString string, ac *config.App) (*browserSnapshotResponse, *snapshotCaptures, error) {
 	if snapshotJS == "" {
 		b, err := static.FS.ReadFile("js/snapshot.js")
 		if err != nil {
@@ -313,8 +390,8 @@ func createSnapshot(urlString string, to int) (*browserSnapshotResponse, error)
 		//chromedp.WithErrorf(log.Printf),
 	)
 	defer cancel()
-	if to > 0 {
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(to)*time.Second)
+	if ac.WebappSnapshotterTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ac.WebappSnapshotterTimeout)*time.Second)
 	}
 	defer cancel()
 	res := &browserSnapshotResponse{}
@@ -329,13 +406,15 @@ func createSnapshot(urlString string, to int) (*browserSnapshotResponse, error)
 			return p.WithAwaitPromise(true)
 		}),
 	)
-	return res, err
+	if err != nil {
+		return res, nil, err
+	}
+	return res, capturePage(ctx, ac), nil
 }
 
 func addBookmark(c *gin.Context) {
 	// TODO error handling
-	tok := c.PostForm("token")
-	u := model.GetUserBySubmissionToken(tok)
+	u := tokenUser(c)
 	if u == nil {
 		setNotification(c, nError, "Invalid token", false)
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -392,6 +471,7 @@ func addBookmark(c *gin.Context) {
 			})
 			return
 		}
+		updateCanonicalURL(b, snapshot)
 		s := &model.Snapshot{
 			Key:        key,
 			Text:       c.PostForm("snapshot_text"),
@@ -410,6 +490,7 @@ func addBookmark(c *gin.Context) {
 			})
 			return
 		}
+		model.TriggerSnapshotWebhooks(model.EventSnapshotCreated, b, s)
 		sSize = s.Size
 		sKey = key
 	}
@@ -424,14 +505,13 @@ func addBookmark(c *gin.Context) {
 
 func checkBookmark(c *gin.Context) {
 	resp := make(map[string]any)
-	tok, ok := c.GetQuery("token")
-	if !ok {
-		resp["error"] = "missing token"
+	u := tokenUser(c)
+	if u == nil {
+		resp["error"] = "invalid token"
 		c.JSON(401, resp)
 		return
 	}
-	var URL string
-	URL, ok = c.GetQuery("url")
+	URL, ok := c.GetQuery("url")
 	if !ok {
 		resp["error"] = "missing URL"
 		c.JSON(400, resp)
@@ -440,9 +520,7 @@ func checkBookmark(c *gin.Context) {
 	var bc int64
 	model.DB.
 		Model(&model.Bookmark{}).
-		Joins("join users on bookmarks.user_id = users.id").
-		Joins("join tokens on tokens.user_id = users.id").
-		Where("tokens.text = ? and bookmarks.url = ? and tokens.deleted_at IS NULL", tok, URL).
+		Where("user_id = ? and (url = ? or canonical_url = ?)", u.ID, URL, model.CanonicalURL(URL)).
 		Limit(1).
 		Count(&bc)
 
@@ -469,7 +547,8 @@ func viewBookmark(c *gin.Context) {
 		return
 	}
 	render(c, http.StatusOK, "view-bookmark", map[string]any{
-		"Bookmark": b,
+		"Bookmark":   b,
+		"Highlights": model.GetHighlights(b.ID, u == nil || u.(*model.User).ID != b.UserID),
 	})
 }
 
@@ -526,8 +605,10 @@ func saveBookmark(c *gin.Context) {
 		b.Title = t
 	}
 	col := model.GetCollectionByName(uid, c.PostForm("collection"))
-	if col != nil {
+	collectionChanged := false
+	if col != nil && b.CollectionID != col.ID {
 		b.CollectionID = col.ID
+		collectionChanged = true
 	}
 	b.Public = c.PostForm("public") != ""
 	b.Unread = c.PostForm("unread") != ""
@@ -537,6 +618,10 @@ func saveBookmark(c *gin.Context) {
 		setNotification(c, nError, "Failed to save bookmark: "+err.Error(), true)
 	} else {
 		setNotification(c, nInfo, "Bookmark saved", true)
+		model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
+		if collectionChanged {
+			go apNotifyCollectionFollowers(c.Copy(), b)
+		}
 	}
 	c.Redirect(http.StatusFound, baseURL("/edit_bookmark?id="+bid))
 }
@@ -547,20 +632,12 @@ func deleteBookmark(c *gin.Context) {
 		return
 	}
 	u, _ := c.Get("user")
-	var b *model.Bookmark
-	err := model.DB.
-		Model(&model.Bookmark{}).
-		Where("bookmarks.id = ? and bookmarks.user_id", id, u.(*model.User).ID).First(&b).Error
+	err := model.DeleteBookmark(u.(*model.User).ID, id)
 	if err != nil {
 		setNotification(c, nError, "Failed to delete bookmark: "+err.Error(), true)
 	} else {
 		setNotification(c, nInfo, "Bookmark deleted", true)
 	}
-	if b != nil {
-		model.DB.Delete(&model.Snapshot{}, "bookmark_id = ?", id)
-		model.DB.Delete(&model.Bookmark{}, "id = ?", id)
-		model.DB.Delete("bookmark_tags", "bookmark_id = ?", id)
-	}
 	c.Redirect(http.StatusFound, baseURL("/"))
 }
 
@@ -590,6 +667,7 @@ func addTag(c *gin.Context) {
 		c.Redirect(http.StatusFound, baseURL("/edit_bookmark?id="+bid))
 		return
 	}
+	model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
 	setNotification(c, nInfo, "Tag added", true)
 	c.Redirect(http.StatusFound, baseURL("/edit_bookmark?id="+bid))
 }
@@ -621,11 +699,19 @@ func deleteTag(c *gin.Context) {
 		c.Redirect(http.StatusFound, baseURL("/edit_bookmark?id="+bid))
 		return
 	}
+	model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
 	setNotification(c, nInfo, "Tag deleted", true)
 	c.Redirect(http.StatusFound, baseURL("/edit_bookmark?id="+bid))
 }
 
 func storeSnapshot(sb []byte) (string, []*model.Resource, error) {
+	sb, issues, err := validator.SanitizeHTML(sb)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(issues) > 0 {
+		log.Debug().Int("issues", len(issues)).Msg("Snapshot sanitized")
+	}
 	vr := validator.ValidateHTML(sb)
 	if vr.Error != nil {
 		return "", nil, vr.Error
@@ -636,7 +722,6 @@ func storeSnapshot(sb []byte) (string, []*model.Resource, error) {
 	}
 
 	var rs []*model.Resource
-	var err error
 	if vr.HasMultimedia {
 		var nsb []byte
 		nsb, rs, _ = saveMultimedia(sb)
@@ -653,8 +738,16 @@ func storeSnapshot(sb []byte) (string, []*model.Resource, error) {
 	return key, rs, nil
 }
 
+// updateCanonicalURL sets the canonical URL of a bookmark
+// from the <link rel="canonical"> element of its snapshot.
+func updateCanonicalURL(b *model.Bookmark, snapshot []byte) {
+	if err := model.SetCanonicalLink(b, urlnorm.CanonicalLink(snapshot, b.URL)); err != nil {
+		log.Error().Err(err).Msg("Failed to update canonical URL")
+	}
+}
+
 func pageInfo(c *gin.Context) {
-	u := model.GetUserBySubmissionToken(c.PostForm("token"))
+	u := tokenUser(c)
 	if u == nil {
 		c.IndentedJSON(http.StatusOK, nil)
 		return
@@ -0,0 +1,130 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	thumbnailWidth   = 320
+	thumbnailHeight  = 240
+	thumbnailQuality = 80
+)
+
+// snapshotCaptures holds the screenshot and the PDF print of a page.
+type snapshotCaptures struct {
+	Screenshot []byte
+	PDF        []byte
+}
+
+// capturePage creates the screenshot and the PDF print of the page loaded in
+// ctx if they are enabled in the config. Failed captures are skipped.
+func capturePage(ctx context.Context, c *config.App) *snapshotCaptures {
+	res := &snapshotCaptures{}
+	if c.SnapshotScreenshot {
+		if err := chromedp.Run(ctx, chromedp.FullScreenshot(&res.Screenshot, 100)); err != nil {
+			log.Error().Err(err).Msg("Failed to create screenshot")
+		}
+	}
+	if c.SnapshotPDF {
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			res.PDF, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return err
+		}))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create PDF")
+		}
+	}
+	return res
+}
+
+// storeCaptures saves the captures of a page with the thumbnail of the
+// screenshot and adds them to the resources of the snapshot.
+func storeCaptures(s *model.Snapshot, pc *snapshotCaptures) error {
+	add := func(kind, ext, mimeType, fname string, content []byte) error {
+		if len(content) == 0 {
+			return nil
+		}
+		key, err := storage.SaveResource(ext, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		size := storage.GetResourceSize(key)
+		s.Size += size
+		s.Resources = append(s.Resources, model.GetOrCreateCapture(kind, key, mimeType, fname, size))
+		return nil
+	}
+	if err := add(model.ResourceScreenshot, ".png", "image/png", "screenshot.png", pc.Screenshot); err != nil {
+		return err
+	}
+	if len(pc.Screenshot) > 0 {
+		t, err := createThumbnail(pc.Screenshot)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create thumbnail")
+		} else if err := add(model.ResourceThumbnail, ".jpg", "image/jpeg", "thumbnail.jpg", t); err != nil {
+			return err
+		}
+	}
+	return add(model.ResourcePDF, ".pdf", "application/pdf", "page.pdf", pc.PDF)
+}
+
+// createThumbnail scales down the top of a PNG screenshot to thumbnail width
+// and returns it in JPEG format.
+func createThumbnail(screenshot []byte) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, err
+	}
+	sb := src.Bounds()
+	sw := sb.Dx()
+	sh := min(sb.Dy(), sw*thumbnailHeight/thumbnailWidth)
+	dh := max(1, sh*thumbnailWidth/sw)
+	dst := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, dh))
+	for y := range dh {
+		y0 := sb.Min.Y + y*sh/dh
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sh/dh)
+		for x := range thumbnailWidth {
+			x0 := sb.Min.X + x*sw/thumbnailWidth
+			x1 := max(x0+1, sb.Min.X+(x+1)*sw/thumbnailWidth)
+			dst.Set(x, y, averageColor(src, x0, y0, x1, y1))
+		}
+	}
+	out := bytes.NewBuffer(nil)
+	if err := jpeg.Encode(out, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func averageColor(img image.Image, x0, y0, x1, y1 int) color.RGBA64 {
+	var r, g, b, a, n uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			b += uint64(cb)
+			a += uint64(ca)
+			n++
+		}
+	}
+	//nolint: gosec // averages of 16 bit values fit into uint16
+	return color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)}
+}
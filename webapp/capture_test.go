@@ -0,0 +1,59 @@
+package webapp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptures(t *testing.T) {
+	initTestApp()
+	err := storage.Init(config.Storage{Filesystem: &config.StorageFilesystem{RootDir: t.TempDir()}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 3000))
+	for y := range 3000 {
+		for x := range 1200 {
+			img.Set(x, y, color.RGBA{R: uint8(x % 256), G: uint8(y % 256), A: 255})
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if !assert.Nil(t, png.Encode(buf, img)) {
+		return
+	}
+
+	th, err := createThumbnail(buf.Bytes())
+	if !assert.Nil(t, err) {
+		return
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(th))
+	assert.Nil(t, err)
+	assert.Equal(t, thumbnailWidth, cfg.Width)
+	assert.Equal(t, thumbnailHeight, cfg.Height)
+	_, err = createThumbnail([]byte("invalid"))
+	assert.NotNil(t, err)
+
+	s := &model.Snapshot{}
+	err = storeCaptures(s, &snapshotCaptures{
+		Screenshot: buf.Bytes(),
+		PDF:        []byte("%PDF-1.4"),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, s.Resources, 3)
+	assert.Equal(t, "image/png", s.Capture(model.ResourceScreenshot).MimeType)
+	assert.Equal(t, "application/pdf", s.Capture(model.ResourcePDF).MimeType)
+	assert.Equal(t, "image/jpeg", s.Thumbnail().MimeType)
+	assert.NotZero(t, s.Size)
+}
@@ -1,23 +1,50 @@
 package webapp
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/asciimoo/omnom/model"
 
 	"github.com/gin-gonic/gin"
-	//"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog/log"
 )
 
+func collectionProfile(c *gin.Context) {
+	col := model.GetPublicCollection(c.Param("cid"))
+	if col == nil {
+		log.Debug().Str("cid", c.Param("cid")).Msg("Unknown collection")
+		notFoundView(c)
+		return
+	}
+	if isActivityPubHeader(c.Request.Header.Get("Accept")) {
+		apCollectionIdentityResponse(c, col)
+		return
+	}
+	v := url.Values{}
+	v.Set("owner", col.User.Username)
+	v.Set("collection", col.Name)
+	c.Redirect(http.StatusFound, URLFor("Public bookmarks")+"?"+v.Encode())
+}
+
 func editCollection(c *gin.Context) {
 	cid, _ := c.GetQuery("cid")
 	u, _ := c.Get("user")
 	col := model.GetCollection(u.(*model.User).ID, cid)
 	var parents []*model.Collection
 	model.DB.Where("parent_id is NULL or parent_id == 0").Where("user_id = ?", u.(*model.User).ID).Order("name desc").Find(&parents)
+	fediName := ""
+	if col != nil && col.Public {
+		col.User = *u.(*model.User)
+		if bu, err := url.Parse(getFullURL(c, "/")); err == nil {
+			fediName = fmt.Sprintf("%s@%s", apCollectionHandle(col), bu.Host)
+		}
+	}
 	render(c, http.StatusOK, "edit-collection", gin.H{
 		"Collection": col,
 		"Parents":    parents,
+		"FediName":   fediName,
 	})
 }
 
@@ -42,6 +69,7 @@ func saveCollection(c *gin.Context) {
 		col.ParentID = 0
 	}
 	col.UserID = u.(*model.User).ID
+	col.Public = c.PostForm("public") != ""
 	err := model.DB.Save(col).Error
 	if err != nil {
 		setNotification(c, nError, "Failed to save collection: "+err.Error(), true)
@@ -51,3 +79,14 @@ func saveCollection(c *gin.Context) {
 	setNotification(c, nInfo, "Save success", true)
 	c.Redirect(http.StatusFound, URLFor("my bookmarks"))
 }
+
+func deleteCollection(c *gin.Context) {
+	u, _ := c.Get("user")
+	if err := model.TrashCollection(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, "Failed to delete collection: "+err.Error(), true)
+		c.Redirect(http.StatusFound, URLFor("my bookmarks"))
+		return
+	}
+	setNotification(c, nInfo, "Collection moved to the trash", true)
+	c.Redirect(http.StatusFound, URLFor("my bookmarks"))
+}
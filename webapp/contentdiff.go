@@ -1,13 +1,18 @@
 package webapp
 
 import (
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
+	"image/png"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/asciimoo/omnom/contentdiff"
 	"github.com/asciimoo/omnom/model"
@@ -17,6 +22,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// visualDiffCacheSize is the number of visual diffs kept in memory.
+const visualDiffCacheSize = 16
+
 func snapshotDiff(c *gin.Context) {
 	s1, err := model.GetSnapshotWithResources(c.Query("s1"))
 	if err != nil {
@@ -30,22 +38,24 @@ func snapshotDiff(c *gin.Context) {
 	}
 
 	var sURL string
-	err = model.DB.
-		Model(&model.Bookmark{}).
-		Select("bookmarks.url").
-		Joins("join snapshots on bookmarks.id = snapshots.bookmark_id").
-		Where("snapshots.id == ?", s1.ID).First(&sURL).Error
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch URL for snapshot")
+	var b *model.Bookmark
+	if err := model.DB.Where("id = ?", s1.BookmarkID).First(&b).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to fetch bookmark of snapshot")
+		b = nil
+	} else {
+		sURL = b.URL
 	}
 
-	tds := contentdiff.DiffText(s1.Text, s2.Text)
-	iKeys := contentdiff.DiffList(getImageResources(s1), getImageResources(s2))
-	tdLen := 0
-	for _, d := range tds {
-		if d.Type != "0" && strings.TrimSpace(d.Text) != "" {
-			tdLen++
-		}
+	rule := getDiffRule(c, b)
+	o := rule.Options()
+	if err := o.Validate(); err != nil {
+		setNotification(c, nError, err.Error(), false)
+		render(c, http.StatusOK, "snapshot-diff-form", gin.H{
+			"S1":   s1.Key,
+			"S2":   s2.Key,
+			"Rule": rule,
+		})
+		return
 	}
 
 	sr1, err := createSnapshotReader(s1.Key)
@@ -62,19 +72,162 @@ func snapshotDiff(c *gin.Context) {
 		render(c, http.StatusOK, "snapshot-diff-form", gin.H{})
 		return
 	}
-	c1 := contentdiff.ExtractHTMLContent(sr1)
-	c2 := contentdiff.ExtractHTMLContent(sr2)
+	h1, err := readSnapshot(sr1, o)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read snapshot")
+	}
+	h2, err := readSnapshot(sr2, o)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read snapshot")
+	}
+	c1 := contentdiff.ExtractHTMLContent(bytes.NewReader(h1))
+	c2 := contentdiff.ExtractHTMLContent(bytes.NewReader(h2))
+
+	// the stored texts contain the whole pages
+	t1, t2 := s1.Text, s2.Text
+	if !o.IsEmpty() {
+		t1, t2 = c1.Text, c2.Text
+	}
+	tds := contentdiff.DiffText(t1, t2)
+	iKeys := contentdiff.DiffList(getImageResources(s1), getImageResources(s2))
+	tdLen := 0
+	for _, d := range tds {
+		if d.Type != "0" && strings.TrimSpace(d.Text) != "" {
+			tdLen++
+		}
+	}
+	b1, err := contentdiff.ExtractBlocks(bytes.NewReader(h1))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract snapshot blocks")
+	}
+	b2, err := contentdiff.ExtractBlocks(bytes.NewReader(h2))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract snapshot blocks")
+	}
+	bds := contentdiff.DiffBlocks(b1, b2)
+	// cache only the summaries of the owner with the stored options
+	u, _ := c.Get("user")
+	isOwner := b != nil && u != nil && u.(*model.User).ID == b.UserID
+	if isOwner && (rule == nil || rule.ID != 0) && s1.Key != s2.Key && model.GetDiffStat(s1.Key, s2.Key, o) == nil {
+		if _, err := model.SaveDiffStat(s1, s2, o, bds.Stats()); err != nil {
+			log.Error().Err(err).Msg("Failed to save diff stats")
+		}
+	}
+	bdLen := 0
+	for _, d := range bds {
+		if d.Type != "0" {
+			bdLen++
+		}
+	}
 	render(c, http.StatusOK, "snapshot-diff", gin.H{
-		"TextDiff":    renderTextDiffs(tds),
-		"TextDiffLen": tdLen,
-		"ImageDiffs":  iKeys,
-		"LinkDiffs":   contentdiff.DiffLink(c1.Links, c2.Links),
-		"SURL":        sURL,
-		"S1":          s1,
-		"S2":          s2,
+		"TextDiff":     renderTextDiffs(tds),
+		"TextDiffLen":  tdLen,
+		"BlockDiff":    renderBlockDiffs(bds),
+		"BlockDiffLen": bdLen,
+		"ImageDiffs":   iKeys,
+		"LinkDiffs":    contentdiff.DiffLink(c1.Links, c2.Links),
+		"SURL":         sURL,
+		"S1":           s1,
+		"S2":           s2,
+		"Rule":         rule,
+		"OptionsURL":   URLFor("Snapshot diff form") + "?" + diffQuery(s1.Key, s2.Key, rule),
+		"Navigation":   getDiffNavigation(c, b, s1, s2, rule),
 	})
 }
 
+func snapshotDiffForm(c *gin.Context) {
+	tplData := gin.H{
+		"S1": c.Query("s1"),
+		"S2": c.Query("s2"),
+	}
+	s1, err := model.GetSnapshotWithResources(c.Query("s1"))
+	var b *model.Bookmark
+	if err == nil && model.DB.Where("id = ?", s1.BookmarkID).First(&b).Error == nil {
+		tplData["Rule"] = getDiffRule(c, b)
+		if u, ok := c.Get("user"); ok && u != nil && u.(*model.User).ID == b.UserID {
+			tplData["Bookmark"] = b
+		}
+	}
+	render(c, http.StatusOK, "snapshot-diff-form", tplData)
+}
+
+func saveDiffRule(c *gin.Context) {
+	u, _ := c.Get("user")
+	s1, s2 := c.PostForm("s1"), c.PostForm("s2")
+	var b *model.Bookmark
+	err := model.DB.
+		Model(&model.Bookmark{}).
+		Joins("join snapshots on bookmarks.id = snapshots.bookmark_id").
+		Where("snapshots.key = ? AND bookmarks.user_id = ?", s1, u.(*model.User).ID).
+		First(&b).Error
+	if err != nil {
+		notFoundView(c)
+		return
+	}
+	rule := &model.DiffRule{
+		Include:     c.PostForm("include"),
+		Exclude:     c.PostForm("exclude"),
+		Ignore:      c.PostForm("ignore"),
+		MainContent: c.PostForm("main_content") != "",
+	}
+	o := rule.Options()
+	if err := o.Validate(); err != nil {
+		setNotification(c, nError, err.Error(), true)
+		c.Redirect(http.StatusFound, URLFor("Snapshot diff form")+"?"+diffQuery(s1, s2, rule))
+		return
+	}
+	if err := model.SaveDiffRule(b, c.PostForm("scope"), o); err != nil {
+		log.Error().Err(err).Msg("Failed to save diff rule")
+		setNotification(c, nError, "Failed to save diff options", true)
+	} else {
+		setNotification(c, nInfo, "Diff options saved", true)
+	}
+	c.Redirect(http.StatusFound, URLFor("Snapshot diff")+"?"+url.Values{"s1": {s1}, "s2": {s2}}.Encode())
+}
+
+// getDiffRule returns the diff options defined in the query if the current
+// user owns the bookmark, otherwise the stored diff rule of the bookmark.
+func getDiffRule(c *gin.Context, b *model.Bookmark) *model.DiffRule {
+	if b == nil {
+		return nil
+	}
+	u, _ := c.Get("user")
+	if c.Query("filter") != "" && u != nil && u.(*model.User).ID == b.UserID {
+		return &model.DiffRule{
+			Include:     c.Query("include"),
+			Exclude:     c.Query("exclude"),
+			Ignore:      c.Query("ignore"),
+			MainContent: c.Query("main_content") != "",
+		}
+	}
+	return model.GetDiffRule(b)
+}
+
+func diffQuery(s1, s2 string, r *model.DiffRule) string {
+	if r == nil {
+		return url.Values{"s1": {s1}, "s2": {s2}}.Encode()
+	}
+	q := url.Values{
+		"s1":      {s1},
+		"s2":      {s2},
+		"filter":  {"1"},
+		"include": {r.Include},
+		"exclude": {r.Exclude},
+		"ignore":  {r.Ignore},
+	}
+	if r.MainContent {
+		q.Set("main_content", "1")
+	}
+	return q.Encode()
+}
+
+func readSnapshot(r io.Reader, o *contentdiff.Options) ([]byte, error) {
+	if o.IsEmpty() {
+		return io.ReadAll(r)
+	}
+	return contentdiff.FilterHTML(r, o)
+}
+
 func renderTextDiffs(tds []contentdiff.TextDiff) template.HTML {
 	var s strings.Builder
 	for _, d := range tds {
@@ -95,6 +248,44 @@ func renderTextDiffs(tds []contentdiff.TextDiff) template.HTML {
 	return template.HTML(strings.ReplaceAll(s.String(), "|||", "<br />")) //nolint: gosec // conversion is safe
 }
 
+// renderBlockDiffs displays the changed blocks grouped by the sections
+// of the page.
+func renderBlockDiffs(bds contentdiff.BlockDiffs) template.HTML {
+	var s strings.Builder
+	section := ""
+	for _, d := range bds {
+		class := ""
+		label := ""
+		switch d.Type {
+		case "0":
+			continue
+		case "+":
+			class, label = "is-primary", "added"
+		case "-":
+			class, label = "is-danger", "removed"
+		case "~":
+			class, label = "is-warning", "changed"
+		case "m":
+			class, label = "is-info", "moved"
+		}
+		if d.Section != section && d.Section != d.Text {
+			section = d.Section
+			if section != "" {
+				s.WriteString(fmt.Sprintf(`<h5 class="has-text-grey">%s</h5>`, html.EscapeString(section)))
+			}
+		}
+		text := html.EscapeString(d.Text)
+		if d.Type == "~" {
+			text = string(renderTextDiffs(d.Changes))
+		}
+		s.WriteString(fmt.Sprintf(
+			`<div class="message %s"><div class="message-body"><span class="tag">%s</span> <span class="tag is-light">%s</span> %s</div></div>`,
+			class, label, d.Tag, text,
+		))
+	}
+	return template.HTML(s.String()) //nolint: gosec // conversion is safe
+}
+
 func snapshotDiffSideBySide(c *gin.Context) {
 	s1, err := model.GetSnapshotWithResources(c.Query("s1"))
 	if err != nil {
@@ -115,14 +306,94 @@ func snapshotDiffSideBySide(c *gin.Context) {
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch URL for snapshot")
 	}
+	var vd *visualDiff
+	if s1.Capture(model.ResourceScreenshot) != nil && s2.Capture(model.ResourceScreenshot) != nil {
+		vd, err = getVisualDiff(s1, s2)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to compare screenshots")
+		}
+	}
 	render(c, http.StatusOK, "snapshot-diff-side-by-side", gin.H{
 		"SURL":       sURL,
 		"S1":         s1,
 		"S2":         s2,
+		"VisualDiff": vd,
 		"hideFooter": true,
 	})
 }
 
+func snapshotVisualDiff(c *gin.Context) {
+	s1, err := model.GetSnapshotWithResources(c.Query("s1"))
+	if err != nil {
+		notFoundView(c)
+		return
+	}
+	s2, err := model.GetSnapshotWithResources(c.Query("s2"))
+	if err != nil {
+		notFoundView(c)
+		return
+	}
+	vd, err := getVisualDiff(s1, s2)
+	if err != nil {
+		if !errors.Is(err, model.ErrNoScreenshot) {
+			log.Error().Err(err).Msg("Failed to compare screenshots")
+		}
+		notFoundView(c)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", vd.Overlay)
+}
+
+// visualDiff is a comparison of the screenshots of two snapshots.
+type visualDiff struct {
+	ChangedPercent float64
+	// Overlay is the PNG encoded image highlighting the changes.
+	Overlay []byte
+}
+
+// visualDiffs caches the most recent visual diffs, because the side by side
+// view and the overlay image of the view are requested separately.
+var visualDiffs = struct {
+	sync.Mutex
+	diffs map[string]*visualDiff
+	keys  []string
+}{diffs: make(map[string]*visualDiff)}
+
+// getVisualDiff compares the screenshots of two snapshots.
+// Results are cached per snapshot pair.
+func getVisualDiff(s1, s2 *model.Snapshot) (*visualDiff, error) {
+	k := s1.Key + ":" + s2.Key
+	visualDiffs.Lock()
+	vd, ok := visualDiffs.diffs[k]
+	visualDiffs.Unlock()
+	if ok {
+		return vd, nil
+	}
+	d, err := model.DiffScreenshots(s1, s2)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, d.Overlay()); err != nil {
+		return nil, err
+	}
+	vd = &visualDiff{
+		ChangedPercent: d.ChangedPercent,
+		Overlay:        buf.Bytes(),
+	}
+	visualDiffs.Lock()
+	defer visualDiffs.Unlock()
+	if _, ok := visualDiffs.diffs[k]; !ok {
+		if len(visualDiffs.keys) >= visualDiffCacheSize {
+			delete(visualDiffs.diffs, visualDiffs.keys[0])
+			visualDiffs.keys = visualDiffs.keys[1:]
+		}
+		visualDiffs.keys = append(visualDiffs.keys, k)
+	}
+	visualDiffs.diffs[k] = vd
+	return vd, nil
+}
+
 func getImageResources(s *model.Snapshot) []string {
 	ret := make([]string, 0, 8)
 	for _, r := range s.Resources {
@@ -0,0 +1,69 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDiffRule(t *testing.T) {
+	router, u, tok := initTestUser(t, "difftest", model.ScopeAdmin)
+	b := &model.Bookmark{URL: "https://example.com/news", Domain: "example.com", Title: "news", UserID: u.ID}
+	if !assert.Nil(t, model.DB.Create(b).Error) {
+		return
+	}
+	pages := map[string]string{
+		"ab01": `<html><body><p>The park opens in spring.</p><p class="views">12 views</p></body></html>`,
+		"ab02": `<html><body><p>The park opens in summer.</p><p class="views">34 views</p></body></html>`,
+	}
+	for k, p := range pages {
+		assert.Nil(t, storage.SaveSnapshot(k, []byte(p)))
+		assert.Nil(t, model.DB.Create(&model.Snapshot{BookmarkID: b.ID, Key: k, Title: "news"}).Error)
+	}
+
+	diffURL := URLFor("Snapshot diff") + "?s1=ab01&s2=ab02"
+
+	w := testRequest(router, "GET", diffURL, tok, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Structural changes (2)")
+
+	form := url.Values{"s1": {"ab01"}, "s2": {"ab02"}, "scope": {model.DiffRuleDomain}, "ignore": {`\d+ views`}}
+	w = testRequest(router, "POST", URLFor("Save snapshot diff options"), tok, form)
+	assert.Equal(t, http.StatusFound, w.Code)
+	r := model.GetDiffRule(b)
+	if !assert.NotNil(t, r) {
+		return
+	}
+	assert.Equal(t, "example.com", r.Domain)
+	assert.Equal(t, model.DiffRuleDomain, r.Scope())
+
+	w = testRequest(router, "GET", diffURL, tok, "")
+	assert.Contains(t, w.Body.String(), "Structural changes (1)")
+
+	// only the owner can override the stored options
+	w = testRequest(router, "GET", diffURL+"&filter=1", "", "")
+	assert.Contains(t, w.Body.String(), "Structural changes (1)")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", diffURL+"&filter=1", nil)
+	c.Set("user", u)
+	assert.Equal(t, "", getDiffRule(c, b).Ignore)
+
+	w = testRequest(router, "GET", URLFor("Snapshot diff form")+"?s1=ab01&s2=ab02", tok, "")
+	assert.Contains(t, w.Body.String(), "Stored options of the domain example.com")
+	assert.Contains(t, w.Body.String(), `\d&#43; views</textarea>`)
+
+	form.Set("ignore", "(")
+	testRequest(router, "POST", URLFor("Save snapshot diff options"), tok, form)
+	assert.Equal(t, `\d+ views`, model.GetDiffRule(b).Ignore)
+
+	form.Set("ignore", "")
+	testRequest(router, "POST", URLFor("Save snapshot diff options"), tok, form)
+	assert.Nil(t, model.GetDiffRule(b))
+}
@@ -38,6 +38,7 @@ var docTOCNames = []string{
 	"extension",
 	"web_interface",
 	"fediverse",
+	"rest_api",
 	"development",
 }
 
@@ -0,0 +1,53 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func duplicateBookmarks(c *gin.Context) {
+	u, _ := c.Get("user")
+	gs, err := model.GetDuplicateBookmarks(u.(*model.User).ID)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+	}
+	render(c, http.StatusOK, "duplicates", map[string]any{
+		"Groups":  gs,
+		"Submenu": bookmarkSubmenu,
+	})
+}
+
+func mergeBookmarks(c *gin.Context) {
+	u, _ := c.Get("user")
+	target, err := strconv.ParseUint(c.PostForm("target"), 10, 64)
+	if err != nil {
+		setNotification(c, nError, "Invalid target bookmark", true)
+		c.Redirect(http.StatusFound, URLFor("Duplicate bookmarks"))
+		return
+	}
+	var ids []uint
+	for _, s := range c.PostFormArray("ids") {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	b, err := model.MergeBookmarks(u.(*model.User).ID, uint(target), ids)
+	if err != nil {
+		setNotification(c, nError, "Failed to merge bookmarks: "+err.Error(), true)
+		c.Redirect(http.StatusFound, URLFor("Duplicate bookmarks"))
+		return
+	}
+	setNotification(c, nInfo, "Bookmarks merged", true)
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s?id=%d", URLFor("Bookmark"), b.ID))
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	ap "github.com/asciimoo/omnom/activitypub"
 	"github.com/asciimoo/omnom/config"
 	"github.com/asciimoo/omnom/feed"
 	"github.com/asciimoo/omnom/model"
@@ -160,7 +161,7 @@ func addFeed(c *gin.Context) {
 	u, _ := c.Get("user")
 	uid := u.(*model.User).ID
 	cfg, _ := c.Get("config")
-	err := feed.AddFeed(cfg.(*config.Config), name, url, uid)
+	_, err := feed.AddFeed(cfg.(*config.Config), name, url, uid)
 	if err != nil {
 		setNotification(c, nError, "Failed to save feed: "+err.Error(), true)
 	} else {
@@ -169,6 +170,32 @@ func addFeed(c *gin.Context) {
 	c.Redirect(http.StatusFound, URLFor("feeds"))
 }
 
+func followAccount(c *gin.Context) {
+	h := strings.TrimSpace(c.Query("handle"))
+	if h == "" {
+		render(c, http.StatusOK, "follow-account", nil)
+		return
+	}
+	if !ap.IsHandle(h) {
+		setNotification(c, nError, "Invalid handle", false)
+		render(c, http.StatusOK, "follow-account", map[string]any{
+			"Handle": h,
+		})
+		return
+	}
+	u, _ := c.Get("user")
+	cfg, _ := c.Get("config")
+	p, err := feed.PreviewActivityPubHandle(cfg.(*config.Config), h, u.(*model.User).ID)
+	if err != nil {
+		log.Debug().Err(err).Str("handle", h).Msg("Failed to resolve handle")
+		setNotification(c, nError, "Failed to find account: "+err.Error(), false)
+	}
+	render(c, http.StatusOK, "follow-account", map[string]any{
+		"Handle":  h,
+		"Preview": p,
+	})
+}
+
 func getUserFeedOrAbort(c *gin.Context) (*model.UserFeed, error) {
 	u, _ := c.Get("user")
 	uid := u.(*model.User).ID
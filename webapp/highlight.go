@@ -0,0 +1,261 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	ap "github.com/asciimoo/omnom/activitypub"
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/reader"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxHighlightLength is the maximum length of highlighted passages and
+	// comments in characters.
+	maxHighlightLength = 10000
+	// maxHighlightContext is the maximum length of the quote prefix and suffix.
+	maxHighlightContext = 64
+)
+
+func addHighlight(c *gin.Context) {
+	u, _ := c.Get("user")
+	uid := u.(*model.User).ID
+	bid := c.PostForm("bid")
+	var s *model.Snapshot
+	err := model.DB.
+		Where("key = ? and bookmark_id = ?", c.PostForm("sid"), bid).
+		Preload("Bookmark").
+		First(&s).Error
+	if err != nil || s.Bookmark.UserID != uid {
+		setNotification(c, nError, "Missing snapshot", true)
+		c.Redirect(http.StatusFound, baseURL("/"))
+		return
+	}
+	readerURL := fmt.Sprintf("%s?sid=%s&bid=%d", URLFor("Snapshot reader"), s.Key, s.BookmarkID)
+	start, err1 := strconv.Atoi(c.PostForm("start"))
+	end, err2 := strconv.Atoi(c.PostForm("end"))
+	h := &model.Highlight{
+		UserID:     uid,
+		BookmarkID: s.BookmarkID,
+		SnapshotID: s.ID,
+		Exact:      c.PostForm("exact"),
+		Prefix:     lastChars(c.PostForm("prefix"), maxHighlightContext),
+		Suffix:     firstChars(c.PostForm("suffix"), maxHighlightContext),
+		Start:      start,
+		End:        end,
+		Comment:    strings.TrimSpace(c.PostForm("comment")),
+		Public:     c.PostForm("public") != "",
+	}
+	if strings.TrimSpace(h.Exact) == "" || err1 != nil || err2 != nil || start < 0 || end < start {
+		setNotification(c, nError, "Invalid highlight", true)
+		c.Redirect(http.StatusFound, readerURL)
+		return
+	}
+	if utf8.RuneCountInString(h.Exact) > maxHighlightLength || utf8.RuneCountInString(h.Comment) > maxHighlightLength {
+		setNotification(c, nError, fmt.Sprintf("Highlights and comments can be up to %d characters long", maxHighlightLength), true)
+		c.Redirect(http.StatusFound, readerURL)
+		return
+	}
+	if err := model.DB.Create(h).Error; err != nil {
+		setNotification(c, nError, "Failed to save highlight: "+err.Error(), true)
+		c.Redirect(http.StatusFound, readerURL)
+		return
+	}
+	setNotification(c, nInfo, "Highlight saved", true)
+	if h.Public && s.Bookmark.Public {
+		h.Bookmark = s.Bookmark
+		go apNotifyHighlight(c.Copy(), h, u.(*model.User))
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s#highlight-%d", readerURL, h.ID))
+}
+
+func deleteHighlight(c *gin.Context) {
+	u, _ := c.Get("user")
+	var h *model.Highlight
+	err := model.DB.Where("id = ? and user_id = ?", c.PostForm("id"), u.(*model.User).ID).First(&h).Error
+	if err != nil {
+		setNotification(c, nError, "Missing highlight", true)
+		c.Redirect(http.StatusFound, baseURL("/"))
+		return
+	}
+	if err := model.DB.Delete(h).Error; err != nil {
+		setNotification(c, nError, "Failed to delete highlight: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Highlight deleted", true)
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s?id=%d", URLFor("Bookmark"), h.BookmarkID))
+}
+
+func exportHighlights(c *gin.Context) {
+	b, hs := getVisibleHighlights(c, c.Query("bid"))
+	if b == nil {
+		notFoundView(c)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=omnom_highlights_%d.md;", b.ID))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(highlightsMarkdown(b, hs)))
+}
+
+func viewHighlight(c *gin.Context) {
+	var h *model.Highlight
+	err := model.DB.Where("id = ? and public = 1", c.Query("id")).Preload("Bookmark").Preload("Bookmark.User").First(&h).Error
+	if err != nil || !h.Bookmark.Public {
+		notFoundView(c)
+		return
+	}
+	a := highlightAnnotation(c, h, getFullURL(c, URLFor("User", h.Bookmark.User.Username)))
+	a.Context = ap.AnnotationContext
+	c.Header("Content-Type", ap.AnnotationMediaType)
+	c.JSON(http.StatusOK, a)
+}
+
+// getVisibleHighlights returns the bookmark and its highlights if the bookmark
+// is visible to the current user. Other users see only the public highlights.
+func getVisibleHighlights(c *gin.Context, bid string) (*model.Bookmark, []*model.Highlight) {
+	var uid uint
+	if u, _ := c.Get("user"); u != nil {
+		uid = u.(*model.User).ID
+	}
+	var b *model.Bookmark
+	if err := model.DB.Where("id = ?", bid).First(&b).Error; err != nil {
+		return nil, nil
+	}
+	if !b.Public && b.UserID != uid {
+		return nil, nil
+	}
+	return b, model.GetHighlights(b.ID, b.UserID != uid)
+}
+
+// highlightSelectors returns the reader selectors of the highlights.
+func highlightSelectors(hs []*model.Highlight) []*reader.Selector {
+	sels := make([]*reader.Selector, len(hs))
+	for i, h := range hs {
+		sels[i] = &reader.Selector{
+			Exact:  h.Exact,
+			Prefix: h.Prefix,
+			Suffix: h.Suffix,
+			Start:  h.Start,
+			End:    h.End,
+		}
+	}
+	return sels
+}
+
+func highlightsMarkdown(b *model.Bookmark, hs []*model.Highlight) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n<%s>\n", b.Title, b.URL)
+	for _, h := range hs {
+		sb.WriteString("\n")
+		for l := range strings.Lines(strings.TrimSpace(h.Exact)) {
+			sb.WriteString("> " + strings.TrimRight(l, "\n") + "\n")
+		}
+		if h.Comment != "" {
+			sb.WriteString("\n" + h.Comment + "\n")
+		}
+		fmt.Fprintf(&sb, "\n*%s*\n", h.CreatedAt.Format(time.DateOnly))
+	}
+	return sb.String()
+}
+
+// highlightAnnotation returns the W3C Web Annotation representation of a
+// highlight. The bookmark of the highlight must be loaded.
+func highlightAnnotation(c *gin.Context, h *model.Highlight, actor string) *ap.Annotation {
+	start, end := h.Start, h.End
+	a := &ap.Annotation{
+		ID:         getFullURL(c, fmt.Sprintf("%s?id=%d", URLFor("Highlight"), h.ID)),
+		Type:       "Annotation",
+		Motivation: "highlighting",
+		Creator:    actor,
+		Created:    h.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		Target: &ap.AnnotationTarget{
+			Source: h.Bookmark.URL,
+			Selector: []*ap.AnnotationSelector{
+				{Type: "TextQuoteSelector", Exact: h.Exact, Prefix: h.Prefix, Suffix: h.Suffix},
+				{Type: "TextPositionSelector", Start: &start, End: &end},
+			},
+		},
+	}
+	if h.Comment != "" {
+		a.Motivation = "commenting"
+		a.Body = []*ap.AnnotationBody{{
+			Type:    "TextualBody",
+			Value:   h.Comment,
+			Format:  "text/plain",
+			Purpose: "commenting",
+		}}
+	}
+	return a
+}
+
+// apNotifyHighlight delivers a public highlight as a Web Annotation to the
+// followers of the user.
+func apNotifyHighlight(c *gin.Context, h *model.Highlight, u *model.User) {
+	followers, err := model.GetAPFollowers(u.ID, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch followers")
+		return
+	}
+	cfg, _ := c.Get("config")
+	key := cfg.(*config.Config).ActivityPub.PrivK
+	actorURL := getFullURL(c, URLFor("User", u.Username))
+	for _, f := range followers {
+		actor, err := ap.FetchActor(f.Follower, actorURL+"#key", key)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch actor")
+			continue
+		}
+		a := highlightAnnotation(c, h, actorURL)
+		item := &ap.AnnotationActivity{
+			Context:   []string{"https://www.w3.org/ns/activitystreams", ap.AnnotationContext},
+			ID:        a.ID + "#activity",
+			Type:      createAction,
+			Actor:     actorURL,
+			To:        []string{"https://www.w3.org/ns/activitystreams#Public", actor.ID},
+			Cc:        []string{},
+			Published: a.Created,
+			Object:    a,
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to marshal highlight")
+			continue
+		}
+		err = ap.SendSignedPostRequest(actor.Inbox, actorURL+"#key", data, key)
+		if err != nil {
+			log.Error().Err(err).Str("actor", f.Follower).Msg("Failed to send HTTP request")
+			continue
+		}
+		log.Debug().Str("actor", f.Follower).Msg("Highlight sent to inbox")
+	}
+}
+
+// firstChars returns the first n characters of s.
+func firstChars(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// lastChars returns the last n characters of s.
+func lastChars(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[len(r)-n:])
+}
@@ -0,0 +1,93 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	ap "github.com/asciimoo/omnom/activitypub"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlights(t *testing.T) {
+	router, u, tok := initTestUser(t, "highlighttest", model.ScopeBookmarks)
+	doc := `<html><head><title>Highlight test</title></head><body><article><p>The quick brown fox jumps over the lazy dog.</p><p>The quick brown fox sleeps.</p></article></body></html>`
+	assert.Nil(t, storage.SaveSnapshot("fc01", []byte(doc)))
+	b := &model.Bookmark{URL: "https://example.com/fox", Title: "Fox", UserID: u.ID, Public: true}
+	assert.Nil(t, model.DB.Create(b).Error)
+	assert.Nil(t, model.DB.Create(&model.Snapshot{BookmarkID: b.ID, Key: "fc01", Title: "Fox"}).Error)
+
+	add := func(exact, prefix string, start int, comment string, public bool) *httptest.ResponseRecorder {
+		v := url.Values{
+			"bid":     {fmt.Sprint(b.ID)},
+			"sid":     {"fc01"},
+			"exact":   {exact},
+			"prefix":  {prefix},
+			"start":   {fmt.Sprint(start)},
+			"end":     {fmt.Sprint(start + len(exact))},
+			"comment": {comment},
+		}
+		if public {
+			v.Set("public", "1")
+		}
+		return testRequest(router, "POST", URLFor("Add highlight"), tok, v)
+	}
+	// the position is outdated, the prefix selects the second occurrence
+	w := add("quick brown fox", "dog.The ", 0, "citable", true)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, http.StatusFound, add("lazy dog", "over the ", 35, "secret remark", false).Code)
+	add("", "", 0, "", true)
+	hs := model.GetHighlights(b.ID, false)
+	if !assert.Len(t, hs, 2) {
+		return
+	}
+	assert.Equal(t, "citable", hs[0].Comment)
+
+	w = testRequest(router, "GET", URLFor("Snapshot reader")+fmt.Sprintf("?sid=fc01&bid=%d", b.ID), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<p>The <mark data-highlight="0">quick brown fox</mark> sleeps.</p>`)
+	assert.NotContains(t, w.Body.String(), "lazy <mark")
+	assert.NotContains(t, w.Body.String(), "highlight-form")
+
+	w = testRequest(router, "GET", URLFor("Bookmark")+fmt.Sprintf("?id=%d", b.ID), "", "")
+	assert.Contains(t, w.Body.String(), "citable")
+	assert.NotContains(t, w.Body.String(), "secret remark")
+
+	w = testRequest(router, "GET", URLFor("Export highlights")+fmt.Sprintf("?bid=%d", b.ID), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "# Fox\n\n<https://example.com/fox>\n\n> quick brown fox\n\ncitable\n"))
+	assert.NotContains(t, w.Body.String(), "lazy dog")
+
+	res, _, err := model.SearchBookmarks(0, 10, "citable")
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	res, _, _ = model.SearchBookmarks(0, 10, "secret")
+	assert.Len(t, res, 0)
+	res, _, _ = model.SearchBookmarks(u.ID, 10, "secret")
+	assert.Len(t, res, 1)
+
+	pub := hs[0]
+	w = testRequest(router, "GET", URLFor("Highlight")+fmt.Sprintf("?id=%d", pub.ID), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ap.AnnotationMediaType, w.Header().Get("Content-Type"))
+	var a ap.Annotation
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &a))
+	assert.Equal(t, "Annotation", a.Type)
+	assert.Equal(t, "commenting", a.Motivation)
+	assert.Equal(t, "https://example.com/fox", a.Target.Source)
+	assert.Equal(t, "quick brown fox", a.Target.Selector[0].Exact)
+	assert.Equal(t, "citable", a.Body[0].Value)
+	w = testRequest(router, "GET", URLFor("Highlight")+fmt.Sprintf("?id=%d", hs[1].ID), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = testRequest(router, "POST", URLFor("Delete highlight"), tok, url.Values{"id": {fmt.Sprint(pub.ID)}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Len(t, model.GetHighlights(b.ID, false), 1)
+}
@@ -0,0 +1,134 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/static"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotURLLifetime is the validity of the signed snapshot URLs.
+const snapshotURLLifetime = time.Hour
+
+const isolatedPrefix = "/isolated"
+
+// snapshotOrigin holds the settings of the separate origin serving the
+// archived snapshots. Snapshots are served from the origin of the
+// application if BaseURL is empty.
+var snapshotOrigin = struct {
+	BaseURL   string
+	Host      string
+	AppOrigin string
+	Key       []byte
+}{}
+
+// snapshotCSP allows only the shadow DOM rendering script of the
+// snapshots to run and denies every external request.
+// The sandbox directive puts the snapshots into a unique origin
+// even if they are opened directly. As a consequence, the snapshot view
+// can't access the selection of the snapshot, highlights are created in
+// the reader view.
+var snapshotCSP = strings.Join([]string{
+	"default-src 'none'",
+	"script-src '" + scriptHash(shadowDOMScript) + "'",
+	"style-src 'self' 'unsafe-inline' data:",
+	"img-src 'self' data: blob:",
+	"media-src 'self' data: blob:",
+	"font-src 'self' data:",
+	"frame-src 'self'",
+	"base-uri 'none'",
+	"form-action 'none'",
+	"sandbox allow-scripts allow-popups allow-popups-to-escape-sandbox",
+}, "; ")
+
+func initSnapshotOrigin(cfg *config.Config) {
+	snapshotOrigin.BaseURL = cfg.Server.SnapshotBaseURL
+	snapshotOrigin.Host = ""
+	if snapshotOrigin.BaseURL == "" {
+		return
+	}
+	if u, err := url.Parse(snapshotOrigin.BaseURL); err == nil {
+		snapshotOrigin.Host = u.Host
+	}
+	if u, err := url.Parse(cfg.Server.BaseURL); err == nil {
+		snapshotOrigin.AppOrigin = u.Scheme + "://" + u.Host
+	}
+	// the key is derived from the configuration, so signed URLs stay valid
+	// after restarts and across the instances of the application
+	k := sha256.Sum256([]byte(cfg.Server.SnapshotURLSecret))
+	snapshotOrigin.Key = k[:]
+}
+
+// getIsolatedURL returns a signed URL of a snapshot file on the snapshot
+// origin. Resources referenced from the snapshot with relative URLs are
+// accessible with the same signature.
+func getIsolatedURL(key, name string, now time.Time) string {
+	exp := strconv.FormatInt(now.Add(snapshotURLLifetime).Unix(), 10)
+	return fmt.Sprintf("%s%s/%s/%s/%s/%s", snapshotOrigin.BaseURL, isolatedPrefix, key, exp, signSnapshotURL(key, exp), name)
+}
+
+func signSnapshotURL(key, exp string) string {
+	mac := hmac.New(sha256.New, snapshotOrigin.Key)
+	mac.Write([]byte(key + "/" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isolatedSnapshot(c *gin.Context) {
+	if snapshotOrigin.BaseURL == "" || c.Request.Host != snapshotOrigin.Host {
+		notFoundView(c)
+		return
+	}
+	key, exp := c.Param("key"), c.Param("expires")
+	if !hmac.Equal([]byte(c.Param("signature")), []byte(signSnapshotURL(key, exp))) {
+		notFoundView(c)
+		return
+	}
+	e, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > e {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(c.Param("filepath")), "/")
+	if !strings.HasPrefix(name, "data/") {
+		notFoundView(c)
+		return
+	}
+	if strings.HasPrefix(name, "data/snapshots/") && path.Base(name) != key+".gz" {
+		notFoundView(c)
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", snapshotOrigin.AppOrigin)
+	serveStaticFile(c, name, static.FS, storage.FS())
+}
+
+// setSnapshotHeaders prevents the archived content from accessing the
+// application even if the sanitizer misses something.
+func setSnapshotHeaders(c *gin.Context) {
+	c.Header("Content-Security-Policy", snapshotCSP)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "no-referrer")
+}
+
+func scriptHash(s []byte) string {
+	s = bytes.TrimPrefix(s, []byte("<script>"))
+	s = bytes.TrimSuffix(s, []byte("</script>"))
+	h := sha256.Sum256(s)
+	return "sha256-" + base64.StdEncoding.EncodeToString(h[:])
+}
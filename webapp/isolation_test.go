@@ -0,0 +1,62 @@
+package webapp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsolatedSnapshot(t *testing.T) {
+	testCfg.Server.SnapshotBaseURL = "https://snapshots.test.com"
+	testCfg.Server.SnapshotURLSecret = "secret"
+	defer func() {
+		testCfg.Server.SnapshotBaseURL = ""
+		testCfg.Server.SnapshotURLSecret = ""
+		initSnapshotOrigin(testCfg)
+	}()
+	router := initTestApp()
+	err := storage.Init(config.Storage{Filesystem: &config.StorageFilesystem{RootDir: t.TempDir()}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, storage.SaveSnapshot("ef01", []byte(`<html><body><img src="../../resources/ab/x.png"></body></html>`)))
+	assert.Nil(t, storage.SaveSnapshot("ef02", []byte(`<html><body>other</body></html>`)))
+
+	su := getSnapshotURL("ef01")
+	assert.True(t, strings.HasPrefix(su, "https://snapshots.test.com/isolated/ef01/"), su)
+	w := testRequest(router, "GET", su, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, snapshotCSP, w.Header().Get("Content-Security-Policy"))
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "sandbox allow-scripts")
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "https://test.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// signed URLs stay valid after restarts
+	initSnapshotOrigin(testCfg)
+	w = testRequest(router, "GET", su, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the signature is valid only for the signed snapshot
+	w = testRequest(router, "GET", strings.Replace(su, "ef/ef01.gz", "ef/ef02.gz", 1), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = testRequest(router, "GET", strings.Replace(su, "/ef01/", "/ef02/", 1), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = testRequest(router, "GET", strings.Replace(su, "/data/snapshots/ef/ef01.gz", "/templates/base.tpl", 1), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// snapshots are not served on the origin of the application
+	w = testRequest(router, "GET", strings.Replace(su, "https://snapshots.test.com", "https://test.com", 1), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = testRequest(router, "GET", "https://test.com/static/data/snapshots/ef/ef01.gz", "", "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "https://snapshots.test.com/isolated/ef01/"))
+
+	w = testRequest(router, "GET", getIsolatedURL("ef01", "data/snapshots/ef/ef01.gz", time.Now().Add(-2*snapshotURLLifetime)), "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,121 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	nodeInfoSchema        = "http://nodeinfo.diaspora.software/ns/schema/2.1"
+	nodeInfoContentType   = `application/json; profile="` + nodeInfoSchema + `#"`
+	nodeInfoCacheDuration = time.Hour
+)
+
+type nodeInfoLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+type nodeInfoLinks struct {
+	Links []nodeInfoLink `json:"links"`
+}
+
+type nodeInfoSoftware struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+	Homepage   string `json:"homepage"`
+}
+
+type nodeInfoServices struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+type nodeInfoUsers struct {
+	Total int64 `json:"total"`
+}
+
+type nodeInfoUsage struct {
+	Users      nodeInfoUsers `json:"users"`
+	LocalPosts int64         `json:"localPosts"`
+}
+
+type nodeInfo struct {
+	Version           string           `json:"version"`
+	Software          nodeInfoSoftware `json:"software"`
+	Protocols         []string         `json:"protocols"`
+	Services          nodeInfoServices `json:"services"`
+	OpenRegistrations bool             `json:"openRegistrations"`
+	Usage             nodeInfoUsage    `json:"usage"`
+	Metadata          map[string]any   `json:"metadata"`
+}
+
+// nodeInfoUsageCache stores the usage statistics to avoid counting
+// users and bookmarks on every crawler request.
+var nodeInfoUsageCache = struct {
+	sync.Mutex
+	usage   nodeInfoUsage
+	expires time.Time
+}{}
+
+func getNodeInfoUsage() nodeInfoUsage {
+	nodeInfoUsageCache.Lock()
+	defer nodeInfoUsageCache.Unlock()
+	if time.Now().Before(nodeInfoUsageCache.expires) {
+		return nodeInfoUsageCache.usage
+	}
+	nodeInfoUsageCache.usage = nodeInfoUsage{
+		Users: nodeInfoUsers{
+			Total: model.GetUserCount(),
+		},
+		LocalPosts: model.GetPublicBookmarkCount(),
+	}
+	nodeInfoUsageCache.expires = time.Now().Add(nodeInfoCacheDuration)
+	return nodeInfoUsageCache.usage
+}
+
+func nodeInfoLinksResponse(c *gin.Context) {
+	c.JSON(http.StatusOK, nodeInfoLinks{
+		Links: []nodeInfoLink{
+			nodeInfoLink{
+				Rel:  nodeInfoSchema,
+				Href: getFullURL(c, URLFor("NodeInfo")),
+			},
+		},
+	})
+}
+
+func nodeInfoResponse(c *gin.Context) {
+	cfg, _ := c.Get("config")
+	c.Header("Content-Type", nodeInfoContentType)
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.JSON(http.StatusOK, nodeInfo{
+		Version: "2.1",
+		Software: nodeInfoSoftware{
+			Name:       "omnom",
+			Version:    strings.TrimPrefix(config.Version, "v"),
+			Repository: "https://github.com/asciimoo/omnom",
+			Homepage:   "https://github.com/asciimoo/omnom",
+		},
+		Protocols: []string{"activitypub"},
+		Services: nodeInfoServices{
+			Inbound:  []string{"atom1.0", "rss2.0"},
+			Outbound: []string{"rss2.0"},
+		},
+		OpenRegistrations: !cfg.(*config.Config).App.DisableSignup,
+		Usage:             getNodeInfoUsage(),
+		Metadata:          map[string]any{},
+	})
+}
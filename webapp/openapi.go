@@ -0,0 +1,488 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/asciimoo/omnom/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const openAPIVersion = "3.0.3"
+
+const (
+	argInPath  = "path"
+	argInQuery = "query"
+	argInBody  = "body"
+)
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Required    bool           `json:"required"`
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	TokenScope  string                      `json:"x-token-scope,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+// argTypes maps the EndpointArg types to JSON schemas.
+// Both the OpenAPI specification and the argument validation use it.
+var argTypes = map[string]openAPISchema{
+	"string":      {Type: "string"},
+	"URL":         {Type: "string", Format: "uri"},
+	"date":        {Type: "string", Pattern: `^\d{4}\.\d{2}\.\d{2}$`},
+	"int":         {Type: "integer"},
+	"bool":        {Type: "boolean"},
+	"boolean":     {Type: "boolean"},
+	"JSON":        {Type: "string"},
+	"JSON string": {Type: "string"},
+	"string list": {Type: "array", Items: &openAPISchema{Type: "string"}},
+	"int list":    {Type: "array", Items: &openAPISchema{Type: "integer"}},
+	"object":      {Type: "object"},
+	"multipart file": {
+		Type:   "string",
+		Format: "binary",
+	},
+	"multipart files": {
+		Type:  "array",
+		Items: &openAPISchema{Type: "string", Format: "binary"},
+	},
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func (a *EndpointArg) schema() *openAPISchema {
+	s, ok := argTypes[a.Type]
+	if !ok {
+		s = openAPISchema{Type: "string"}
+	}
+	return &s
+}
+
+// validFormValue checks if the form or query value v matches the type of the argument.
+// Only integers are checked, because forms submit booleans in various formats.
+func (a *EndpointArg) validFormValue(v string) bool {
+	if a.schema().Type != "integer" {
+		return true
+	}
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+// validJSONValue checks if the decoded JSON value v matches the schema s.
+func validJSONValue(s *openAPISchema, v any) bool {
+	switch s.Type {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		l, ok := v.([]any)
+		if !ok {
+			return false
+		}
+		for _, i := range l {
+			if !validJSONValue(s.Items, i) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (e *Endpoint) isAPI() bool {
+	return strings.HasPrefix(e.Path, apiV1Path+"/")
+}
+
+// argLocation returns where the argument is expected in the request.
+func (e *Endpoint) argLocation(a *EndpointArg) string {
+	for _, p := range strings.Split(e.Path, "/") {
+		if len(p) > 1 && (p[0] == ':' || p[0] == '*') && p[1:] == a.Name {
+			return argInPath
+		}
+	}
+	switch e.Method {
+	case GET, HEAD, DELETE:
+		return argInQuery
+	}
+	return argInBody
+}
+
+// GenerateOpenAPI creates an OpenAPI 3 specification from the registered endpoints.
+func GenerateOpenAPI(serverURL string) ([]byte, error) {
+	g := &openAPIGenerator{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "Omnom",
+			Version: strings.TrimPrefix(config.Version, "v"),
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"bearerAuth": &openAPISecurityScheme{
+					Type:   "http",
+					Scheme: "bearer",
+				},
+				"cookieAuth": &openAPISecurityScheme{
+					Type: "apiKey",
+					In:   "cookie",
+					Name: "SID",
+				},
+			},
+		},
+	}
+	if serverURL != "" {
+		doc.Servers = []openAPIServer{{URL: strings.TrimSuffix(serverURL, "/")}}
+	}
+	errSchema := g.schemaOf(reflect.TypeFor[apiErrorResponse]())
+	opIDs := make(map[string]bool)
+	for _, e := range Endpoints {
+		p := openAPIPath(e.Path)
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = make(map[string]*openAPIOperation)
+		}
+		op := g.operation(e, errSchema)
+		if opIDs[op.OperationID] {
+			op.OperationID += strings.ToUpper(e.Method[:1]) + strings.ToLower(e.Method[1:])
+		}
+		opIDs[op.OperationID] = true
+		doc.Paths[p][strings.ToLower(e.Method)] = op
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func openAPISpec(c *gin.Context) {
+	spec, err := GenerateOpenAPI(getFullURL(c, "/"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate OpenAPI specification")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Data(http.StatusOK, gin.MIMEJSON, spec)
+}
+
+// openAPIPath converts gin path parameters to OpenAPI path templates.
+func openAPIPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, s := range parts {
+		if len(s) > 1 && (s[0] == ':' || s[0] == '*') {
+			parts[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func operationID(name string) string {
+	var b strings.Builder
+	for i, w := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if i == 0 {
+			b.WriteString(strings.ToLower(w))
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+type openAPIGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func (g *openAPIGenerator) operation(e *Endpoint, errSchema *openAPISchema) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: operationID(e.Name),
+		Summary:     e.Name,
+		Description: e.Description,
+		Responses:   make(map[string]*openAPIResponse),
+		TokenScope:  string(e.Scope),
+	}
+	body := &openAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*openAPISchema),
+	}
+	bodyContentType := "application/x-www-form-urlencoded"
+	pathArgs := make(map[string]bool)
+	for _, a := range e.Args {
+		loc := e.argLocation(a)
+		switch loc {
+		case argInBody:
+			as := a.schema()
+			as.Description = a.Description
+			body.Properties[a.Name] = as
+			if a.Required {
+				body.Required = append(body.Required, a.Name)
+			}
+			if strings.HasPrefix(a.Type, "multipart") {
+				bodyContentType = "multipart/form-data"
+			}
+		default:
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:        a.Name,
+				In:          loc,
+				Required:    a.Required || loc == argInPath,
+				Description: a.Description,
+				Schema:      a.schema(),
+			})
+			pathArgs[a.Name] = loc == argInPath
+		}
+	}
+	// path parameters are mandatory in the specification even if they are undocumented
+	for _, s := range strings.Split(e.Path, "/") {
+		if len(s) > 1 && (s[0] == ':' || s[0] == '*') && !pathArgs[s[1:]] {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:     s[1:],
+				In:       argInPath,
+				Required: true,
+				Schema:   &openAPISchema{Type: "string"},
+			})
+		}
+	}
+	if len(body.Properties) > 0 {
+		if e.isAPI() {
+			bodyContentType = gin.MIMEJSON
+		}
+		op.RequestBody = &openAPIRequestBody{
+			Required: len(body.Required) > 0,
+			Content: map[string]*openAPIMediaType{
+				bodyContentType: &openAPIMediaType{Schema: body},
+			},
+		}
+	}
+	if e.isAPI() {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+	} else if e.AuthRequired {
+		op.Security = []map[string][]string{{"cookieAuth": {}}}
+	}
+	op.Responses[successStatus(e)] = g.response(e)
+	if e.isAPI() {
+		op.Responses["default"] = &openAPIResponse{
+			Description: "Error",
+			Content: map[string]*openAPIMediaType{
+				gin.MIMEJSON: &openAPIMediaType{Schema: errSchema},
+			},
+		}
+	}
+	return op
+}
+
+func successStatus(e *Endpoint) string {
+	if e.isAPI() {
+		switch e.Method {
+		case POST:
+			return strconv.Itoa(http.StatusCreated)
+		case DELETE:
+			return strconv.Itoa(http.StatusNoContent)
+		}
+	}
+	return strconv.Itoa(http.StatusOK)
+}
+
+func (g *openAPIGenerator) response(e *Endpoint) *openAPIResponse {
+	r := &openAPIResponse{
+		Description: "Successful response",
+		Content:     make(map[string]*openAPIMediaType),
+	}
+	switch {
+	case e.Response != nil:
+		r.Content[gin.MIMEJSON] = &openAPIMediaType{Schema: g.schemaOf(reflect.TypeOf(e.Response))}
+	case e.isAPI():
+		r.Content = nil
+	default:
+		r.Content[gin.MIMEHTML] = &openAPIMediaType{}
+		if e.RSS != "" {
+			r.Content["application/rss+xml"] = &openAPIMediaType{}
+		}
+	}
+	return r
+}
+
+// schemaOf returns the JSON schema of a Go type.
+// Named structs are added to the components and referenced.
+func (g *openAPIGenerator) schemaOf(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.schemaName(t)
+			g.names[t] = name
+			// register before the fields to handle recursive types
+			g.schemas[name] = &openAPISchema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	return &openAPISchema{}
+}
+
+func (g *openAPIGenerator) schemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if _, exists := g.schemas[name]; exists {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*openAPISchema),
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			es := g.structSchema(ft)
+			for k, v := range es.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, es.Required...)
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
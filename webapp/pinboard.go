@@ -0,0 +1,577 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"crypto/md5" //nolint: gosec // used as a change identifier as in the Pinboard API
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	pinboardPath           = "/api/pinboard/v1"
+	pinboardTimeFormat     = "2006-01-02T15:04:05Z"
+	pinboardDateFormat     = "2006-01-02"
+	pinboardMaxTags        = 3
+	pinboardDefaultRecent  = 15
+	pinboardMaxRecent      = 100
+	pinboardResultDone     = "done"
+	pinboardResultNotFound = "item not found"
+)
+
+type pinboardResult struct {
+	XMLName xml.Name `json:"-" xml:"result"`
+	Code    string   `json:"result_code" xml:"code,attr"`
+}
+
+type pinboardUpdate struct {
+	XMLName xml.Name `json:"-" xml:"update"`
+	Time    string   `json:"update_time" xml:"time,attr"`
+}
+
+type pinboardPost struct {
+	XMLName     xml.Name `json:"-" xml:"post"`
+	Href        string   `json:"href" xml:"href,attr"`
+	Description string   `json:"description" xml:"description,attr"`
+	Extended    string   `json:"extended" xml:"extended,attr"`
+	Meta        string   `json:"meta" xml:"meta,attr"`
+	Hash        string   `json:"hash" xml:"hash,attr"`
+	Time        string   `json:"time" xml:"time,attr"`
+	Shared      string   `json:"shared" xml:"shared,attr"`
+	ToRead      string   `json:"toread" xml:"toread,attr"`
+	Tags        string   `json:"tags" xml:"tag,attr"`
+}
+
+type pinboardPosts struct {
+	XMLName xml.Name        `json:"-" xml:"posts"`
+	Date    string          `json:"date" xml:"dt,attr,omitempty"`
+	User    string          `json:"user" xml:"user,attr"`
+	Posts   []*pinboardPost `json:"posts"`
+}
+
+type pinboardTag struct {
+	XMLName xml.Name `xml:"tag"`
+	Count   int64    `xml:"count,attr"`
+	Tag     string   `xml:"tag,attr"`
+}
+
+type pinboardTags struct {
+	XMLName xml.Name `xml:"tags"`
+	Tags    []*pinboardTag
+}
+
+func pinboardEndpoints() []*Endpoint {
+	commonArgs := []*EndpointArg{
+		&EndpointArg{
+			Name:               "auth_token",
+			Type:               "string",
+			Required:           true,
+			Description:        "API token in username:token format",
+			SkipAutoValidation: true,
+		},
+		&EndpointArg{
+			Name:        "format",
+			Type:        "string",
+			Required:    false,
+			Description: "Response format, 'json' or 'xml' (default)",
+		},
+	}
+	tagArg := &EndpointArg{
+		Name:        "tag",
+		Type:        "string",
+		Required:    false,
+		Description: fmt.Sprintf("Filter by up to %d space separated tags", pinboardMaxTags),
+	}
+	return []*Endpoint{
+		&Endpoint{
+			Name:         "Pinboard update",
+			Path:         pinboardPath + "/posts/update",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsUpdate,
+			Scope:        model.ScopeRead,
+			Description:  "Pinboard compatible API: returns the last modification time of the bookmarks",
+			Args:         commonArgs,
+		},
+		&Endpoint{
+			Name:         "Pinboard add post",
+			Path:         pinboardPath + "/posts/add",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsAdd,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Pinboard compatible API: creates or replaces a bookmark",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:               "url",
+					Type:               "URL",
+					Required:           true,
+					Description:        "Bookmark URL",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "description",
+					Type:               "string",
+					Required:           true,
+					Description:        "Bookmark title",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:        "extended",
+					Type:        "string",
+					Required:    false,
+					Description: "Bookmark notes",
+				},
+				&EndpointArg{
+					Name:        "tags",
+					Type:        "string",
+					Required:    false,
+					Description: "Space or comma separated list of tags",
+				},
+				&EndpointArg{
+					Name:        "dt",
+					Type:        "string",
+					Required:    false,
+					Description: "Creation time in CCYY-MM-DDThh:mm:ssZ format",
+				},
+				&EndpointArg{
+					Name:        "replace",
+					Type:        "string",
+					Required:    false,
+					Description: "Replace existing bookmark, 'yes' (default) or 'no'",
+				},
+				&EndpointArg{
+					Name:        "shared",
+					Type:        "string",
+					Required:    false,
+					Description: "Public bookmark, 'yes' or 'no' (default)",
+				},
+				&EndpointArg{
+					Name:        "toread",
+					Type:        "string",
+					Required:    false,
+					Description: "Unread bookmark, 'yes' or 'no' (default)",
+				},
+			}, commonArgs...),
+		},
+		&Endpoint{
+			Name:         "Pinboard delete post",
+			Path:         pinboardPath + "/posts/delete",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsDelete,
+			Scope:        model.ScopeBookmarks,
+			Description:  "Pinboard compatible API: deletes a bookmark",
+			Args: append([]*EndpointArg{
+				&EndpointArg{
+					Name:               "url",
+					Type:               "URL",
+					Required:           true,
+					Description:        "Bookmark URL",
+					SkipAutoValidation: true,
+				},
+			}, commonArgs...),
+		},
+		&Endpoint{
+			Name:         "Pinboard get posts",
+			Path:         pinboardPath + "/posts/get",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsGet,
+			Scope:        model.ScopeRead,
+			Description:  "Pinboard compatible API: returns the bookmarks of a single day or a single URL. Defaults to the most recent day with bookmarks",
+			Args: append([]*EndpointArg{
+				tagArg,
+				&EndpointArg{
+					Name:        "dt",
+					Type:        "string",
+					Required:    false,
+					Description: "Date in CCYY-MM-DD format",
+				},
+				&EndpointArg{
+					Name:        "url",
+					Type:        "URL",
+					Required:    false,
+					Description: "Bookmark URL",
+				},
+			}, commonArgs...),
+		},
+		&Endpoint{
+			Name:         "Pinboard recent posts",
+			Path:         pinboardPath + "/posts/recent",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsRecent,
+			Scope:        model.ScopeRead,
+			Description:  "Pinboard compatible API: returns the most recent bookmarks",
+			Args: append([]*EndpointArg{
+				tagArg,
+				&EndpointArg{
+					Name:        "count",
+					Type:        "int",
+					Required:    false,
+					Description: fmt.Sprintf("Number of bookmarks (default %d, max %d)", pinboardDefaultRecent, pinboardMaxRecent),
+				},
+			}, commonArgs...),
+		},
+		&Endpoint{
+			Name:         "Pinboard all posts",
+			Path:         pinboardPath + "/posts/all",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardPostsAll,
+			Scope:        model.ScopeRead,
+			Description:  "Pinboard compatible API: returns all the bookmarks",
+			Args: append([]*EndpointArg{
+				tagArg,
+				&EndpointArg{
+					Name:        "start",
+					Type:        "int",
+					Required:    false,
+					Description: "Offset of the results",
+				},
+				&EndpointArg{
+					Name:        "results",
+					Type:        "int",
+					Required:    false,
+					Description: "Number of results",
+				},
+				&EndpointArg{
+					Name:        "fromdt",
+					Type:        "string",
+					Required:    false,
+					Description: "Return only bookmarks created after this time (CCYY-MM-DDThh:mm:ssZ)",
+				},
+				&EndpointArg{
+					Name:        "todt",
+					Type:        "string",
+					Required:    false,
+					Description: "Return only bookmarks created before this time (CCYY-MM-DDThh:mm:ssZ)",
+				},
+			}, commonArgs...),
+		},
+		&Endpoint{
+			Name:         "Pinboard tags",
+			Path:         pinboardPath + "/tags/get",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      pinboardTagsGet,
+			Scope:        model.ScopeRead,
+			Description:  "Pinboard compatible API: returns the tags of the user with their usage counts",
+			Args:         commonArgs,
+		},
+	}
+}
+
+// pinboardUser returns the user authenticated by the auth_token argument.
+// Session authentication is not accepted, because the
+// API modifies bookmarks via GET requests.
+func pinboardUser(c *gin.Context) *model.User {
+	u := tokenUser(c)
+	if u == nil {
+		c.String(http.StatusUnauthorized, "API requires authentication")
+		c.Abort()
+	}
+	return u
+}
+
+func pinboardRender(c *gin.Context, jsonData, xmlData any) {
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, jsonData)
+		return
+	}
+	out, err := xml.Marshal(xmlData)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to serialize Pinboard response")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+func pinboardRenderResult(c *gin.Context, code string) {
+	r := &pinboardResult{Code: code}
+	pinboardRender(c, r, r)
+}
+
+func pinboardYes(s string) bool {
+	return strings.EqualFold(s, "yes")
+}
+
+func pinboardYesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func pinboardSplitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+func pinboardHash(s string) string {
+	h := md5.Sum([]byte(s)) //nolint: gosec // used as a change identifier
+	return hex.EncodeToString(h[:])
+}
+
+func newPinboardPost(b *model.Bookmark) *pinboardPost {
+	tags := make([]string, len(b.Tags))
+	for i, t := range b.Tags {
+		tags[i] = t.Text
+	}
+	return &pinboardPost{
+		Href:        b.URL,
+		Description: b.Title,
+		Extended:    b.Notes,
+		Meta:        pinboardHash(b.UpdatedAt.UTC().Format(time.RFC3339Nano)),
+		Hash:        pinboardHash(b.URL),
+		Time:        b.CreatedAt.UTC().Format(pinboardTimeFormat),
+		Shared:      pinboardYesNo(b.Public),
+		ToRead:      pinboardYesNo(b.Unread),
+		Tags:        strings.Join(tags, " "),
+	}
+}
+
+func newPinboardPosts(bs []*model.Bookmark) []*pinboardPost {
+	ps := make([]*pinboardPost, len(bs))
+	for i, b := range bs {
+		ps[i] = newPinboardPost(b)
+	}
+	return ps
+}
+
+// pinboardQuery returns the bookmarks query of the user filtered by the tag argument.
+func pinboardQuery(c *gin.Context, uid uint) (*gorm.DB, bool) {
+	q := model.DB.
+		Model(&model.Bookmark{}).
+		Where("bookmarks.user_id = ?", uid).
+		Preload("Tags").
+		Order("bookmarks.created_at desc")
+	tags := pinboardSplitTags(c.Query("tag"))
+	if len(tags) > pinboardMaxTags {
+		pinboardRenderResult(c, "too many tags")
+		return nil, false
+	}
+	return filterAllTags(tags, q), true
+}
+
+func pinboardPostsUpdate(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	var b model.Bookmark
+	t := time.Time{}
+	if err := model.DB.Where("user_id = ?", u.ID).Order("updated_at desc").First(&b).Error; err == nil {
+		t = b.UpdatedAt
+	}
+	r := &pinboardUpdate{Time: t.UTC().Format(pinboardTimeFormat)}
+	pinboardRender(c, r, r)
+}
+
+func pinboardPostsAdd(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	us := c.Query("url")
+	if us == "" {
+		pinboardRenderResult(c, "missing url")
+		return
+	}
+	title := c.Query("description")
+	if title == "" {
+		pinboardRenderResult(c, "missing description")
+		return
+	}
+	tags := pinboardSplitTags(c.Query("tags"))
+	public := ""
+	if pinboardYes(c.Query("shared")) {
+		public = "1"
+	}
+	unread := ""
+	if pinboardYes(c.Query("toread")) {
+		unread = "1"
+	}
+	b, isNew, err := model.GetOrCreateBookmark(u, us, title, strings.Join(tags, ","), c.Query("extended"), public, "", "", unread)
+	if err != nil {
+		pinboardRenderResult(c, err.Error())
+		return
+	}
+	if !isNew {
+		if strings.EqualFold(c.Query("replace"), "no") {
+			pinboardRenderResult(c, "item already exists")
+			return
+		}
+		b.Title = title
+		b.Notes = c.Query("extended")
+		b.Public = public != ""
+		b.Unread = unread != ""
+		if err := model.DB.Model(b).Association("Tags").Replace(getAPITags(tags)); err != nil {
+			log.Error().Err(err).Msg("Failed to replace bookmark tags")
+			pinboardRenderResult(c, "database error")
+			return
+		}
+	}
+	if dt, err := time.Parse(pinboardTimeFormat, c.Query("dt")); err == nil {
+		b.CreatedAt = dt
+	}
+	if err := model.DB.Omit("Tags", "Snapshots", "User").Save(b).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to save bookmark")
+		pinboardRenderResult(c, "database error")
+		return
+	}
+	if isNew {
+		go apNotifyFollowers(c.Copy(), b)
+	} else {
+		model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
+	}
+	pinboardRenderResult(c, pinboardResultDone)
+}
+
+func pinboardPostsDelete(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	var b model.Bookmark
+	if err := model.DB.Where("user_id = ? AND url = ?", u.ID, c.Query("url")).First(&b).Error; err != nil {
+		pinboardRenderResult(c, pinboardResultNotFound)
+		return
+	}
+	if err := model.DeleteBookmark(u.ID, strconv.FormatUint(uint64(b.ID), 10)); err != nil {
+		log.Error().Err(err).Msg("Failed to delete bookmark")
+		pinboardRenderResult(c, "database error")
+		return
+	}
+	pinboardRenderResult(c, pinboardResultDone)
+}
+
+func pinboardPostsGet(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	q, ok := pinboardQuery(c, u.ID)
+	if !ok {
+		return
+	}
+	res := &pinboardPosts{User: u.Username}
+	var bs []*model.Bookmark
+	if us := c.Query("url"); us != "" {
+		q = q.Where("bookmarks.url = ?", us)
+	} else {
+		day := time.Time{}
+		if dt := c.Query("dt"); dt != "" {
+			var err error
+			day, err = time.Parse(pinboardDateFormat, dt)
+			if err != nil {
+				pinboardRenderResult(c, "invalid date")
+				return
+			}
+		} else {
+			var last model.Bookmark
+			if err := q.Session(&gorm.Session{}).First(&last).Error; err != nil {
+				res.Posts = []*pinboardPost{}
+				pinboardRender(c, res, res)
+				return
+			}
+			y, m, d := last.CreatedAt.Date()
+			day = time.Date(y, m, d, 0, 0, 0, 0, last.CreatedAt.Location())
+		}
+		res.Date = day.Format(pinboardTimeFormat)
+		q = q.Where("bookmarks.created_at >= ? AND bookmarks.created_at < ?", day, day.AddDate(0, 0, 1))
+	}
+	if err := q.Find(&bs).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to query bookmarks")
+	}
+	res.Posts = newPinboardPosts(bs)
+	pinboardRender(c, res, res)
+}
+
+func pinboardPostsRecent(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	q, ok := pinboardQuery(c, u.ID)
+	if !ok {
+		return
+	}
+	count := pinboardDefaultRecent
+	if n, err := strconv.Atoi(c.Query("count")); err == nil && n > 0 {
+		count = min(n, pinboardMaxRecent)
+	}
+	var bs []*model.Bookmark
+	if err := q.Limit(count).Find(&bs).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to query bookmarks")
+	}
+	res := &pinboardPosts{
+		User:  u.Username,
+		Date:  time.Now().UTC().Format(pinboardTimeFormat),
+		Posts: newPinboardPosts(bs),
+	}
+	pinboardRender(c, res, res)
+}
+
+func pinboardPostsAll(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	q, ok := pinboardQuery(c, u.ID)
+	if !ok {
+		return
+	}
+	if n, err := strconv.Atoi(c.Query("start")); err == nil && n > 0 {
+		q = q.Offset(n)
+	}
+	if n, err := strconv.Atoi(c.Query("results")); err == nil && n > 0 {
+		q = q.Limit(n)
+	}
+	if t, err := time.Parse(pinboardTimeFormat, c.Query("fromdt")); err == nil {
+		q = q.Where("bookmarks.created_at >= ?", t)
+	}
+	if t, err := time.Parse(pinboardTimeFormat, c.Query("todt")); err == nil {
+		q = q.Where("bookmarks.created_at <= ?", t)
+	}
+	var bs []*model.Bookmark
+	if err := q.Find(&bs).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to query bookmarks")
+	}
+	ps := newPinboardPosts(bs)
+	pinboardRender(c, ps, &pinboardPosts{User: u.Username, Posts: ps})
+}
+
+func pinboardTagsGet(c *gin.Context) {
+	u := pinboardUser(c)
+	if u == nil {
+		return
+	}
+	tags, err := model.GetUserTags(u.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query tags")
+	}
+	jr := make(map[string]int64, len(tags))
+	xr := &pinboardTags{Tags: make([]*pinboardTag, len(tags))}
+	for i, t := range tags {
+		jr[t.Tag] = t.Count
+		xr.Tags[i] = &pinboardTag{Tag: t.Tag, Count: t.Count}
+	}
+	pinboardRender(c, jr, xr)
+}
@@ -0,0 +1,83 @@
+package webapp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinboard(t *testing.T) {
+	router, u, _ := initTestUser(t, "pinboard")
+	_, tok, err := model.CreateAddonToken(u.ID)
+	if !assert.Nil(t, err) {
+		return
+	}
+	pbReq := func(p string, args url.Values) ([]byte, int) {
+		args.Set("auth_token", u.Username+":"+tok)
+		w := testRequest(router, "GET", pinboardPath+p+"?"+args.Encode(), "", "")
+		return w.Body.Bytes(), w.Code
+	}
+
+	w := testRequest(router, "GET", pinboardPath+"/posts/recent", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = testRequest(router, "GET", pinboardPath+"/posts/recent?auth_token=other:"+tok, "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	body, code := pbReq("/posts/add", url.Values{
+		"url":         {"https://example.com/pb"},
+		"description": {"Example"},
+		"extended":    {"notes"},
+		"tags":        {"a b"},
+		"toread":      {"yes"},
+	})
+	assert.Equal(t, http.StatusOK, code)
+	var xr pinboardResult
+	assert.Nil(t, xml.Unmarshal(body, &xr))
+	assert.Equal(t, pinboardResultDone, xr.Code)
+
+	body, _ = pbReq("/posts/add", url.Values{
+		"url":         {"https://example.com/pb"},
+		"description": {"Example"},
+		"replace":     {"no"},
+		"format":      {"json"},
+	})
+	var jr pinboardResult
+	assert.Nil(t, json.Unmarshal(body, &jr))
+	assert.Equal(t, "item already exists", jr.Code)
+
+	body, _ = pbReq("/posts/recent", url.Values{"format": {"json"}, "tag": {"a b"}})
+	var posts pinboardPosts
+	assert.Nil(t, json.Unmarshal(body, &posts))
+	if assert.Len(t, posts.Posts, 1) {
+		p := posts.Posts[0]
+		assert.Equal(t, "https://example.com/pb", p.Href)
+		assert.Equal(t, "Example", p.Description)
+		assert.Equal(t, "notes", p.Extended)
+		assert.Equal(t, "yes", p.ToRead)
+		assert.Equal(t, "no", p.Shared)
+	}
+
+	body, _ = pbReq("/posts/get", url.Values{"format": {"json"}})
+	assert.Nil(t, json.Unmarshal(body, &posts))
+	assert.Len(t, posts.Posts, 1)
+
+	body, _ = pbReq("/tags/get", url.Values{"format": {"json"}})
+	var tags map[string]int64
+	assert.Nil(t, json.Unmarshal(body, &tags))
+	assert.Equal(t, map[string]int64{"a": 1, "b": 1}, tags)
+
+	body, _ = pbReq("/posts/delete", url.Values{"url": {"https://example.com/pb"}, "format": {"json"}})
+	assert.Nil(t, json.Unmarshal(body, &jr))
+	assert.Equal(t, pinboardResultDone, jr.Code)
+
+	body, _ = pbReq("/posts/all", url.Values{"format": {"json"}})
+	var all []*pinboardPost
+	assert.Nil(t, json.Unmarshal(body, &all))
+	assert.Empty(t, all)
+}
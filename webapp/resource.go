@@ -26,8 +26,7 @@ type ResourceMeta struct {
 type ResourceMetas []ResourceMeta
 
 func addResource(c *gin.Context) {
-	tok := c.PostForm("token")
-	u := model.GetUserBySubmissionToken(tok)
+	u := tokenUser(c)
 	if u == nil {
 		setNotification(c, nError, "Invalid token", false)
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -81,6 +80,12 @@ func addResource(c *gin.Context) {
 		s.Resources = append(s.Resources, model.GetOrCreateResource(key, m.Mimetype, m.Filename, size))
 	}
 	model.DB.Save(s)
+	if len(meta) > 0 {
+		var b model.Bookmark
+		if err := model.DB.Preload("Tags").First(&b, s.BookmarkID).Error; err == nil {
+			model.TriggerSnapshotWebhooks(model.EventSnapshotUpdated, &b, s)
+		}
+	}
 	c.JSON(200, map[string]any{
 		"success": true,
 	})
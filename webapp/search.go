@@ -30,6 +30,7 @@ type searchParams struct {
 	Tag              string `form:"tag"`
 	Domain           string `form:"domain"`
 	Collection       string `form:"collection"`
+	LinkStatus       string `form:"link_status"`
 	IsPublic         bool   `form:"public"`
 	IsPrivate        bool   `form:"private"`
 	SearchInSnapshot bool   `form:"search_in_snapshot"`
@@ -78,6 +79,7 @@ func (s *searchParams) Serialize() string {
 	v.Add("tag", s.Tag)
 	v.Add("domain", s.Domain)
 	v.Add("collection", s.Collection)
+	v.Add("link_status", s.LinkStatus)
 	if s.IsPublic {
 		v.Add("public", "1")
 	}
@@ -113,6 +115,9 @@ func (s *searchParams) String() string {
 	if s.Domain != "" {
 		parts = append(parts, ".d_"+s.Domain)
 	}
+	if s.LinkStatus != "" {
+		parts = append(parts, ".l_"+s.LinkStatus)
+	}
 	if s.IsPublic {
 		parts = append(parts, ".public")
 	}
@@ -128,23 +133,43 @@ func (s *searchParams) String() string {
 	return strings.Join(parts, "")
 }
 
-func filterText(qs string, inNote bool, inSnapshot bool, q, cq *gorm.DB) {
+// filterUserBookmarks applies the search parameters to the bookmark queries of a user.
+func filterUserBookmarks(sp *searchParams, uid uint, q, cq *gorm.DB) {
+	filterText(sp.Q, sp.SearchInNote, sp.SearchInSnapshot, uid, q, cq)
+	_ = filterFromDate(sp.FromDate, q, cq)
+	_ = filterToDate(sp.ToDate, q, cq)
+	filterDomain(sp.Domain, q, cq)
+	filterTag(sp.Tag, q, cq)
+	filterCollection(sp.Collection, uid, q, cq)
+	filterLinkStatus(sp.LinkStatus, q, cq)
+	if sp.IsPublic {
+		filterPublic(q, cq)
+	}
+	if sp.IsPrivate {
+		filterPrivate(q, cq)
+	}
+}
+
+// filterText matches the query against the bookmark titles and optionally
+// against the notes, highlights and snapshots. Private highlights of other
+// users than uid are ignored.
+func filterText(qs string, inNote bool, inSnapshot bool, uid uint, q, cq *gorm.DB) {
 	if qs == "" {
 		return
 	}
 	qs = model.CreateGlob(qs)
 	query := "LOWER(bookmarks.title) LIKE LOWER(@query)"
 	if inNote {
-		query += " or LOWER(bookmarks.notes) LIKE LOWER(@query)"
+		query += " or LOWER(bookmarks.notes) LIKE LOWER(@query) or " + model.HighlightSearchCondition
 	}
 	if inSnapshot {
-		q = q.Joins("join snapshots on snapshots.bookmark_id = bookmarks.id")
-		cq = cq.Joins("join snapshots on snapshots.bookmark_id = bookmarks.id")
+		q = q.Joins("join snapshots on snapshots.bookmark_id = bookmarks.id and snapshots.deleted_at IS NULL")
+		cq = cq.Joins("join snapshots on snapshots.bookmark_id = bookmarks.id and snapshots.deleted_at IS NULL")
 		query += " or LOWER(snapshots.text) LIKE LOWER(@query)"
 	}
 	query = "(" + query + ")"
-	q = q.Where(query, sql.Named("query", qs))   //nolint: staticcheck,wastedassign // it is used in later funcs
-	cq = cq.Where(query, sql.Named("query", qs)) //nolint: staticcheck,wastedassign // it is used in later funcs
+	q = q.Where(query, sql.Named("query", qs), sql.Named("uid", uid))   //nolint: staticcheck,wastedassign // it is used in later funcs
+	cq = cq.Where(query, sql.Named("query", qs), sql.Named("uid", uid)) //nolint: staticcheck,wastedassign // it is used in later funcs
 }
 
 func filterCollection(cid string, uid uint, q, cq *gorm.DB) {
@@ -152,11 +177,11 @@ func filterCollection(cid string, uid uint, q, cq *gorm.DB) {
 		return
 	}
 	q = q. //nolint: staticcheck,wastedassign // it is used in later funcs
-		Joins("join collections on bookmarks.collection_id == collections.id").
+		Joins("join collections on bookmarks.collection_id == collections.id and collections.deleted_at IS NULL").
 		Where("collections.name = ?", cid).
 		Where("collections.user_id = ? ", uid)
 	cq = cq. //nolint: staticcheck,wastedassign // it is used in later funcs
-			Joins("join collections on bookmarks.collection_id == collections.id").
+			Joins("join collections on bookmarks.collection_id == collections.id and collections.deleted_at IS NULL").
 			Where("collections.name = ?", cid).
 			Where("collections.user_id = ? ", uid)
 }
@@ -181,6 +206,15 @@ func filterDomain(d string, q, cq *gorm.DB) {
 	cq = cq.Where("domain LIKE ?", fmt.Sprintf("%%%s%%", d)) //nolint: staticcheck,wastedassign // it is used in later funcs
 }
 
+func filterLinkStatus(s string, q, cq *gorm.DB) {
+	ls := model.ParseLinkStatus(s)
+	if ls == "" {
+		return
+	}
+	q = q.Where("bookmarks.link_status = ?", ls)   //nolint: staticcheck,wastedassign // it is used in later funcs
+	cq = cq.Where("bookmarks.link_status = ?", ls) //nolint: staticcheck,wastedassign // it is used in later funcs
+}
+
 func filterTag(t string, q, cq *gorm.DB) {
 	if t == "" {
 		return
@@ -195,6 +229,20 @@ func filterTag(t string, q, cq *gorm.DB) {
 			Where("tags.text = ?", t)
 }
 
+// filterAllTags restricts q to bookmarks having every tag of tags.
+func filterAllTags(tags []string, q *gorm.DB) *gorm.DB {
+	for _, t := range tags {
+		q = q.Where(
+			"bookmarks.id IN (?)",
+			model.DB.Table("bookmark_tags").
+				Select("bookmark_tags.bookmark_id").
+				Joins("join tags on tags.id = bookmark_tags.tag_id").
+				Where("tags.text = ?", t),
+		)
+	}
+	return q
+}
+
 func filterFromDate(d string, q, cq *gorm.DB) error {
 	if d == "" {
 		return nil
@@ -225,3 +273,8 @@ func filterPublic(q, cq *gorm.DB) {
 	q.Where("public == true")
 	cq.Where("public == true")
 }
+
+func filterPrivate(q, cq *gorm.DB) {
+	q.Where("public == false")
+	cq.Where("public == false")
+}
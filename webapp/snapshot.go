@@ -7,34 +7,33 @@ package webapp
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/base64"
-	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"path"
 	"strings"
 	"time"
 
-	"golang.org/x/net/html"
-
 	"github.com/asciimoo/omnom/config"
 	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/reader"
+	"github.com/asciimoo/omnom/singlefile"
 	"github.com/asciimoo/omnom/storage"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
-	"github.com/tdewolff/parse/v2"
-	"github.com/tdewolff/parse/v2/css"
 )
 
-var resourceAttributes = map[string]string{
-	"img":    "src",
-	"link":   "href",
-	"iframe": "src",
-}
+// maxEmbeddedStreamSize is the size limit in bytes of the video and audio
+// files embedded into downloaded snapshots.
+const maxEmbeddedStreamSize = 32 * 1024 * 1024
+
+// maxEmbeddedSize is the size limit in bytes of all the resources embedded
+// into a downloaded snapshot. Resources over the limit are not embedded.
+const maxEmbeddedSize = 128 * 1024 * 1024
 
 func snapshotWrapper(c *gin.Context) {
 	sid, ok := c.GetQuery("sid")
@@ -96,169 +95,238 @@ func snapshotWrapper(c *gin.Context) {
 	})
 }
 
-func downloadSnapshot(c *gin.Context) {
-	id, ok := c.GetQuery("sid")
-	if !ok {
+func snapshotReader(c *gin.Context) {
+	var s *model.Snapshot
+	err := model.DB.Where("key = ? and bookmark_id = ?", c.Query("sid"), c.Query("bid")).Preload("Bookmark").First(&s).Error
+	if err != nil {
+		notFoundView(c)
 		return
 	}
-	r, err := storage.GetSnapshot(id)
+	a, err := extractArticle(s, func(src string) string {
+		if !strings.HasPrefix(src, "../../resources/") {
+			return ""
+		}
+		return baseURL(storage.GetResourceURL(path.Base(src)))
+	})
 	if err != nil {
+		log.Error().Err(err).Str("key", s.Key).Msg("Failed to extract snapshot content")
+		notFoundView(c)
 		return
 	}
+	var uid uint
+	if u, _ := c.Get("user"); u != nil {
+		uid = u.(*model.User).ID
+	}
+	hs := model.GetHighlights(s.BookmarkID, s.Bookmark.UserID != uid)
+	content, found, err := reader.Mark(a.Content, highlightSelectors(hs))
+	if err == nil {
+		a.Content = content
+	}
+	items := make([]*readerHighlight, len(hs))
+	for i, h := range hs {
+		items[i] = &readerHighlight{Highlight: h, Index: i}
+	}
+	for _, i := range found {
+		items[i].Found = true
+	}
+	render(c, http.StatusOK, "snapshot-reader", map[string]any{
+		"Bookmark":   &s.Bookmark,
+		"Snapshot":   s,
+		"Article":    a,
+		"Highlights": items,
+	})
+}
+
+// readerHighlight is a highlight displayed in the reader view.
+// Index identifies the marks of the highlight in the content.
+type readerHighlight struct {
+	*model.Highlight
+	Index int
+	Found bool
+}
+
+// extractArticle returns the readable content of a snapshot.
+func extractArticle(s *model.Snapshot, img reader.ImageFunc) (*reader.Article, error) {
+	r, err := createSnapshotReader(s.Key)
+	if err != nil {
+		return nil, err
+	}
 	defer r.Close()
-	gr, err := gzip.NewReader(r)
+	// links are kept relative if the bookmark has an invalid URL
+	base, _ := url.Parse(s.Bookmark.URL)
+	a, err := reader.Extract(r, base, img)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if a.Title == "" {
+		a.Title = s.Title
 	}
-	c.Header("Content-Type", "text/html; charset=utf-8")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=omnom_snapshot_%s.html;", id))
-	c.Status(http.StatusOK)
-	if !writeBytes(c.Writer, []byte("<!DOCTYPE html>")) {
+	return a, nil
+}
+
+// downloadEPUB writes the readable content of a snapshot as an EPUB book.
+func downloadEPUB(c *gin.Context, s *model.Snapshot) {
+	a, err := extractArticle(s, func(src string) string {
+		if !strings.HasPrefix(src, "../../resources/") {
+			return ""
+		}
+		return src
+	})
+	if err != nil {
+		log.Error().Err(err).Str("key", s.Key).Msg("Failed to extract snapshot content")
+		notFoundView(c)
 		return
 	}
-	doc := html.NewTokenizer(gr)
-	for {
-		tt := doc.Next()
-		switch tt {
-		case html.ErrorToken:
-			err := doc.Err()
-			if errors.Is(err, io.EOF) {
-				return
-			}
-			log.Error().Err(err).Msg("Failed to parse HTML")
-			return
-		case html.SelfClosingTagToken:
-		case html.StartTagToken:
-			if !writeBytes(c.Writer, []byte("<")) {
-				return
-			}
-			tn, hasAttr := doc.TagName()
-			if !writeBytes(c.Writer, tn) {
-				return
-			}
-			if hasAttr {
-				generateTagAttributes(tn, doc, c.Writer)
-			}
-			if !writeBytes(c.Writer, []byte(">")) {
-				return
-			}
-		case html.TextToken:
-			if !writeBytes(c.Writer, doc.Text()) {
-				return
-			}
-		case html.EndTagToken:
-			tn, _ := doc.TagName()
-			if !writeBytes(c.Writer, fmt.Appendf(nil, `</%s>`, tn)) {
-				return
-			}
+	out := bytes.NewBuffer(nil)
+	m := &reader.EPUBMeta{
+		Identifier: "urn:omnom:snapshot:" + s.Key,
+		URL:        s.Bookmark.URL,
+		Date:       s.CreatedAt,
+	}
+	err = reader.EPUB(out, a, m, func(src string) ([]byte, string, error) {
+		key := path.Base(src)
+		r, err := createResourceReader(key)
+		if err != nil {
+			return nil, "", err
 		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		return b, mime.TypeByExtension(path.Ext(key)), err
+	})
+	if err != nil {
+		log.Error().Err(err).Str("key", s.Key).Msg("Failed to create EPUB")
+		notFoundView(c)
+		return
 	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=omnom_snapshot_%s.epub;", s.Key))
+	c.Data(http.StatusOK, "application/epub+zip", out.Bytes())
 }
 
-func generateTagAttributes(tagName []byte, doc *html.Tokenizer, out io.Writer) {
-	for {
-		aName, aVal, moreAttr := doc.TagAttr()
-		if !writeBytes(out, fmt.Appendf(nil, ` %s="`, aName)) {
-			return
-		}
-		if attributeHasResource(tagName, aName, aVal) {
-			href := string(aVal)
-			res, err := storage.GetResource(filepath.Base(href))
-			if err == nil {
-				defer res.Close()
-				gres, err := gzip.NewReader(res)
-				if err == nil {
-					ext := filepath.Ext(href)
-					if !writeBytes(out, fmt.Appendf(nil, "data:%s;base64,", strings.Split(mime.TypeByExtension(ext), ";")[0])) {
-						return
-					}
-					if ext == ".css" {
-						err = writeB64(generateCSS(gres), out)
-					} else {
-						err = writeB64(gres, out)
-					}
-					if err != nil {
-						log.Error().Err(err).Str("href", href).Msg("IO error")
-					}
-				}
-			} else {
-				if !writeBytes(out, aVal) {
-					return
-				}
-			}
-		} else {
-			if !writeBytes(out, []byte(html.EscapeString(string(aVal)))) {
-				return
-			}
-		}
-		if !writeBytes(out, []byte(`"`)) {
-			return
-		}
-		if !moreAttr {
-			break
+func downloadSnapshot(c *gin.Context) {
+	id, ok := c.GetQuery("sid")
+	if !ok {
+		return
+	}
+	var s *model.Snapshot
+	if err := model.DB.Where("key = ?", id).Preload("Bookmark").First(&s).Error; err != nil {
+		notFoundView(c)
+		return
+	}
+	if c.Query("format") == "epub" {
+		downloadEPUB(c, s)
+		return
+	}
+	r, err := createSnapshotReader(s.Key)
+	if err != nil {
+		notFoundView(c)
+		return
+	}
+	defer r.Close()
+	open := snapshotResourceOpener(c.Query("streams") != "")
+	contentType := "text/html; charset=utf-8"
+	ext := "html"
+	if c.Query("format") == "mhtml" {
+		contentType = "application/x-mimearchive"
+		ext = "mhtml"
+	}
+	setSnapshotHeaders(c)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=omnom_snapshot_%s.%s;", id, ext))
+	if ext == "mhtml" {
+		err = singlefile.MHTML(c.Writer, r, open, s.Bookmark.URL, s.Title, s.CreatedAt)
+	} else {
+		err = singlefile.HTML(c.Writer, r, open)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("key", s.Key).Msg("Failed to pack snapshot")
+		// the response can't be changed once its writing has started
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			notFoundView(c)
 		}
 	}
 }
 
-func generateCSS(in io.Reader) io.Reader {
-	out := bytes.NewBuffer(nil)
-	l := css.NewLexer(parse.NewInput(in))
-	for {
-		tt, text := l.Next()
-		switch tt {
-		case css.ErrorToken:
-			return out
-		case css.URLToken:
-			href := string(text[5 : len(text)-2])
-			if strings.HasPrefix(href, "../../resources/") {
-				ext := filepath.Ext(href)
-				res, err := storage.GetResource(filepath.Base(href))
-				if err == nil {
-					defer res.Close()
-					writeBytes(out, fmt.Appendf(nil, "url(\"data:%s;base64,", strings.Split(mime.TypeByExtension(ext), ";")[0]))
-					err := writeB64(res, out)
-					if err != nil {
-						log.Error().Err(err).Msg("CSS IO error")
-					}
-					writeBytes(out, []byte("\")"))
-				} else {
-					out.Write(text)
-				}
-			} else {
-				out.Write(text)
+// snapshotResourceOpener opens the stored resources of snapshots.
+// Streams are embedded only if streams is true and their size is
+// under maxEmbeddedStreamSize. Resources are skipped once their total size
+// reaches maxEmbeddedSize.
+func snapshotResourceOpener(streams bool) singlefile.Opener {
+	streamPrefix := baseURL("/static/data/streams/")
+	var budget uint = maxEmbeddedSize
+	return func(ref string) (*singlefile.Resource, error) {
+		switch {
+		case strings.HasPrefix(ref, "../../resources/"):
+			key := path.Base(ref)
+			b, err := readResource(key, budget)
+			if err != nil {
+				return nil, err
+			}
+			//nolint: gosec // int -> uint conversion is safe
+			budget -= uint(len(b))
+			return &singlefile.Resource{ReadCloser: io.NopCloser(bytes.NewReader(b)), MimeType: mime.TypeByExtension(path.Ext(key))}, nil
+		case streams && strings.HasPrefix(ref, streamPrefix):
+			key := path.Base(ref)
+			size := storage.GetStreamSize(key)
+			if size > maxEmbeddedStreamSize || size > budget {
+				return nil, singlefile.ErrSkip
+			}
+			r, err := storage.GetStream(key)
+			if err != nil {
+				return nil, err
 			}
-		// TODO handle CSS @import
-		default:
-			out.Write(text)
+			budget -= size
+			return &singlefile.Resource{ReadCloser: r, MimeType: mime.TypeByExtension(path.Ext(key))}, nil
 		}
+		return nil, singlefile.ErrSkip
 	}
-	return out
 }
 
-func writeB64(in io.Reader, out io.Writer) error {
-	bw := base64.NewEncoder(base64.StdEncoding, out)
-	// TODO configure file size
-	if _, err := io.CopyN(bw, in, 1024*1024); err != nil && !errors.Is(err, io.EOF) {
-		log.Error().Err(err).Msg("IO copy error")
-		return err
-	}
-	defer bw.Close()
-	return nil
+// readResource returns the uncompressed content of a resource.
+// Returns singlefile.ErrSkip if the content is larger than limit.
+func readResource(key string, limit uint) ([]byte, error) {
+	r, err := createResourceReader(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	//nolint: gosec // int -> uint conversion is safe
+	if uint(len(b)) > limit {
+		return nil, singlefile.ErrSkip
+	}
+	return b, nil
 }
 
-func attributeHasResource(tag, name, val []byte) bool {
-	if resourceAttributes[string(tag)] == string(name) && bytes.HasPrefix(val, []byte("../../resources/")) {
-		return true
+// createResourceReader returns the uncompressed content of a resource.
+func createResourceReader(key string) (io.ReadCloser, error) {
+	r, err := storage.GetResource(key)
+	if err != nil {
+		return nil, err
 	}
-	return false
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &resourceReader{Reader: gr, file: r}, nil
+}
+
+// resourceReader closes the stored file of a resource with its decompressor.
+type resourceReader struct {
+	*gzip.Reader
+	file io.Closer
 }
 
-func writeBytes(w io.Writer, data []byte) bool {
-	if l, err := w.Write(data); err != nil || l != len(data) {
-		log.Error().Err(err).Msg("IO error")
-		return false
+func (r *resourceReader) Close() error {
+	err := r.Reader.Close()
+	if ferr := r.file.Close(); err == nil {
+		err = ferr
 	}
-	return true
+	return err
 }
 
 func deleteSnapshot(c *gin.Context) {
@@ -308,7 +376,7 @@ func snapshots(c *gin.Context) {
 	var sc int64
 	//cq := model.DB.Model(&model.Snapshot{}).Where("s.public = 1")
 	//nolint: gosec // uint -> int conversion is safe
-	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Joins("left join bookmarks on bookmarks.id = snapshots.bookmark_id").Where("bookmarks.url like ?", "%"+qs+"%").Where("bookmarks.public == true or bookmarks.user_id == ?", uid).Preload("Bookmark")
+	q := model.DB.Limit(int(resultsPerPage)).Offset(int(offset)).Joins("left join bookmarks on bookmarks.id = snapshots.bookmark_id").Where("bookmarks.url like ?", "%"+qs+"%").Where("bookmarks.public == true or bookmarks.user_id == ?", uid).Preload("Bookmark").Preload("Resources", "kind = ?", model.ResourceThumbnail)
 	cq := model.DB.Model(&model.Snapshot{}).Joins("left join bookmarks on bookmarks.id = snapshots.bookmark_id").Where("bookmarks.url like ?", "%"+qs+"%").Where("bookmarks.public == true or bookmarks.user_id == ?", uid)
 	cq.Count(&sc)
 	q.Order("snapshots.created_at").Find(&ss)
@@ -347,9 +415,10 @@ func snapshotDetails(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	s.Resources = res
 	rs := make(map[string]map[string][]*model.Resource)
 	for _, v := range res {
-		if strings.TrimSpace(v.OriginalFilename) == "" {
+		if v.Kind != "" || strings.TrimSpace(v.OriginalFilename) == "" {
 			continue
 		}
 		m, _, err := mime.ParseMediaType(v.MimeType)
@@ -0,0 +1,92 @@
+package webapp
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/singlefile"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadSnapshot(t *testing.T) {
+	router, u, _ := initTestUser(t, "downloadtest")
+	img, err := storage.SaveResource(".png", strings.NewReader("PNG"))
+	assert.Nil(t, err)
+	video, err := storage.SaveStream(".mp4", strings.NewReader("MP4"))
+	assert.Nil(t, err)
+	doc := `<html><body><img srcset="../../resources/` + img[:2] + "/" + img + ` 2x"><video src="` + baseURL(storage.GetStreamURL(video)) + `"></video></body></html>`
+	assert.Nil(t, storage.SaveSnapshot("fa01", []byte(doc)))
+	b := &model.Bookmark{URL: "https://example.com/video", Title: "video", UserID: u.ID}
+	assert.Nil(t, model.DB.Create(b).Error)
+	assert.Nil(t, model.DB.Create(&model.Snapshot{BookmarkID: b.ID, Key: "fa01", Title: "video"}).Error)
+
+	w := testRequest(router, "GET", URLFor("Download snapshot")+"?sid=fa01", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<img srcset="data:image/png;base64,UE5H 2x">`)
+	assert.Contains(t, w.Body.String(), `/static/data/streams/`)
+	assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+
+	w = testRequest(router, "GET", URLFor("Download snapshot")+"?sid=fa01&streams=1", "", "")
+	assert.Contains(t, w.Body.String(), `<video src="data:video/mp4;base64,TVA0">`)
+
+	w = testRequest(router, "GET", URLFor("Download snapshot")+"?sid=fa01&format=mhtml", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-mimearchive", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "omnom_snapshot_fa01.mhtml")
+	assert.Contains(t, w.Body.String(), "Content-Location: https://example.com/video")
+	assert.Contains(t, w.Body.String(), "Content-Id: <part1@omnom>")
+
+	w = testRequest(router, "GET", URLFor("Download snapshot")+"?sid=fa02", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// resources over the remaining size budget are not embedded
+	_, err = readResource(img, 2)
+	assert.ErrorIs(t, err, singlefile.ErrSkip)
+	content, err := readResource(img, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "PNG", string(content))
+}
+
+func TestSnapshotReader(t *testing.T) {
+	router, u, _ := initTestUser(t, "readertest")
+	img, err := storage.SaveResource(".png", strings.NewReader("PNG"))
+	assert.Nil(t, err)
+	doc := `<html><head><title>Reader test</title></head><body><nav>Menu</nav><article><p>Some <a href="/about">text</a>.</p>` +
+		`<img src="../../resources/` + img[:2] + "/" + img + `"><img src="https://example.com/x.png"></article></body></html>`
+	assert.Nil(t, storage.SaveSnapshot("fb01", []byte(doc)))
+	b := &model.Bookmark{URL: "https://example.com/article", Title: "article", UserID: u.ID}
+	assert.Nil(t, model.DB.Create(b).Error)
+	assert.Nil(t, model.DB.Create(&model.Snapshot{BookmarkID: b.ID, Key: "fb01", Title: "article"}).Error)
+
+	w := testRequest(router, "GET", URLFor("Snapshot reader")+fmt.Sprintf("?sid=fb01&bid=%d", b.ID), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Reader test")
+	assert.Contains(t, w.Body.String(), "1 min read")
+	assert.Contains(t, w.Body.String(), `<a href="https://example.com/about">text</a>`)
+	assert.Contains(t, w.Body.String(), `<img src="`+baseURL(storage.GetResourceURL(img))+`"/>`)
+	assert.NotContains(t, w.Body.String(), "x.png")
+
+	w = testRequest(router, "GET", URLFor("Snapshot reader")+"?sid=fb01&bid=0", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = testRequest(router, "GET", URLFor("Download snapshot")+"?sid=fb01&format=epub", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/epub+zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "omnom_snapshot_fb01.epub")
+	z, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "EPUB/images/img1.png")
+}
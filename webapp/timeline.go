@@ -0,0 +1,180 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/asciimoo/omnom/contentdiff"
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// timelineItem is a snapshot of a timeline with the summary of its
+// differences from the previous snapshot.
+type timelineItem struct {
+	Snapshot *model.Snapshot
+	Prev     *model.Snapshot
+	Stat     *model.DiffStat
+}
+
+// diffNavigation contains the diff URLs of the neighbouring snapshot pairs
+// of the timeline of two consecutive snapshots.
+type diffNavigation struct {
+	Prev string
+	Next string
+}
+
+func snapshotTimeline(c *gin.Context) {
+	u, _ := c.Get("user")
+	var b *model.Bookmark
+	err := model.DB.Where("id = ?", c.Query("bid")).First(&b).Error
+	if err != nil || (!b.Public && (u == nil || u.(*model.User).ID != b.UserID)) {
+		notFoundView(c)
+		return
+	}
+	ss, err := getTimelineSnapshots(c, b)
+	pageno := getPageno(c)
+	offset := (pageno - 1) * resultsPerPage
+	//nolint: gosec // int -> uint conversion is safe
+	if err != nil || offset >= uint(len(ss)) {
+		notFoundView(c)
+		return
+	}
+	// only the owner caches the summaries, the options of the bookmark
+	// are applied to the comparisons of every user
+	save := u != nil && u.(*model.User).ID == b.UserID
+	rule := model.GetDiffRule(b)
+	o := rule.Options()
+	items := make([]*timelineItem, 0, resultsPerPage)
+	//nolint: gosec // uint -> int conversion is safe
+	for i := len(ss) - 1 - int(offset); i >= 0 && len(items) < int(resultsPerPage); i-- {
+		item := &timelineItem{Snapshot: ss[i]}
+		if i > 0 {
+			item.Prev = ss[i-1]
+			item.Stat, err = getDiffStat(ss[i-1], ss[i], o, save)
+			if err != nil {
+				log.Error().Err(err).Str("key", ss[i].Key).Msg("Failed to compare snapshots")
+			}
+		}
+		items = append(items, item)
+	}
+	tplData := gin.H{
+		"Bookmark": b,
+		"Items":    items,
+		"Rule":     rule,
+		"Pageno":   pageno,
+		//nolint: gosec // int -> uint conversion is safe
+		"HasNextPage": offset+resultsPerPage < uint(len(ss)),
+	}
+	if pageno > 1 {
+		render(c, http.StatusOK, "snapshot-timeline", tplData)
+		return
+	}
+	// the first snapshot of the bookmark compared to the latest snapshot of the URL
+	latest := ss[len(ss)-1]
+	for _, s := range ss {
+		if s.BookmarkID != b.ID {
+			continue
+		}
+		if s.ID != latest.ID {
+			st, err := getDiffStat(s, latest, o, save)
+			if err != nil {
+				log.Error().Err(err).Str("key", s.Key).Msg("Failed to compare snapshots")
+			}
+			tplData["First"] = s
+			tplData["Latest"] = latest
+			tplData["Summary"] = st
+		}
+		break
+	}
+	render(c, http.StatusOK, "snapshot-timeline", tplData)
+}
+
+// getTimelineSnapshots returns the snapshots of the public bookmarks and the
+// bookmarks of the current user with the URL or the canonical URL of the
+// bookmark in chronological order.
+func getTimelineSnapshots(c *gin.Context, b *model.Bookmark) ([]*model.Snapshot, error) {
+	var uid uint
+	if cu, ok := c.Get("user"); ok && cu != nil {
+		uid = cu.(*model.User).ID
+	}
+	q := model.DB.
+		Model(&model.Snapshot{}).
+		Joins("join bookmarks on bookmarks.id = snapshots.bookmark_id")
+	if b.CanonicalURL != "" {
+		q = q.Where("(bookmarks.url = ? OR bookmarks.canonical_url = ?)", b.URL, b.CanonicalURL)
+	} else {
+		q = q.Where("bookmarks.url = ?", b.URL)
+	}
+	var ss []*model.Snapshot
+	err := q.
+		Where("bookmarks.deleted_at IS NULL").
+		Where("(bookmarks.public = 1 OR bookmarks.user_id = ?)", uid).
+		Preload("Resources", "kind IN ?", []string{model.ResourceThumbnail, model.ResourceScreenshot}).
+		Order("snapshots.created_at, snapshots.id").
+		Find(&ss).Error
+	return ss, err
+}
+
+// getDiffNavigation returns the neighbouring diffs if s1 and s2 are
+// consecutive snapshots of the timeline of their URL.
+func getDiffNavigation(c *gin.Context, b *model.Bookmark, s1, s2 *model.Snapshot, rule *model.DiffRule) *diffNavigation {
+	if b == nil {
+		return nil
+	}
+	ss, err := getTimelineSnapshots(c, b)
+	if err != nil {
+		return nil
+	}
+	i := slices.IndexFunc(ss, func(s *model.Snapshot) bool { return s.ID == s1.ID })
+	if i < 0 || i+1 >= len(ss) || ss[i+1].ID != s2.ID {
+		return nil
+	}
+	// keep the options of the query, stored rules are applied automatically
+	if rule != nil && rule.ID != 0 {
+		rule = nil
+	}
+	n := &diffNavigation{}
+	if i > 0 {
+		n.Prev = URLFor("Snapshot diff") + "?" + diffQuery(ss[i-1].Key, s1.Key, rule)
+	}
+	if i+2 < len(ss) {
+		n.Next = URLFor("Snapshot diff") + "?" + diffQuery(s2.Key, ss[i+2].Key, rule)
+	}
+	return n
+}
+
+// getDiffStat returns the summary of the differences of two snapshots.
+// Cached summaries are reused, new summaries are cached if save is true.
+func getDiffStat(s1, s2 *model.Snapshot, o *contentdiff.Options, save bool) (*model.DiffStat, error) {
+	if s1.Key == s2.Key {
+		return &model.DiffStat{Key1: s1.Key, Key2: s2.Key}, nil
+	}
+	if st := model.GetDiffStat(s1.Key, s2.Key, o); st != nil {
+		return st, nil
+	}
+	r1, err := createSnapshotReader(s1.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer r1.Close()
+	r2, err := createSnapshotReader(s2.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer r2.Close()
+	d, err := contentdiff.DiffHTML(r1, r2, o)
+	if err != nil {
+		return nil, err
+	}
+	if !save {
+		return model.NewDiffStat(s1, s2, o, d.Blocks.Stats()), nil
+	}
+	return model.SaveDiffStat(s1, s2, o, d.Blocks.Stats())
+}
@@ -0,0 +1,77 @@
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTimeline(t *testing.T) {
+	router, u, _ := initTestUser(t, "timelinetest")
+	b := &model.Bookmark{URL: "https://example.com/timeline", CanonicalURL: "https://example.com/timeline", Domain: "example.com", Title: "timeline", UserID: u.ID, Public: true}
+	if !assert.Nil(t, model.DB.Create(b).Error) {
+		return
+	}
+	// bookmarks with the same canonical URL share the timeline
+	b2 := &model.Bookmark{URL: "https://example.com/timeline?ref=feed", CanonicalURL: b.CanonicalURL, Domain: "example.com", Title: "timeline", UserID: u.ID, Public: true}
+	if !assert.Nil(t, model.DB.Create(b2).Error) {
+		return
+	}
+	pages := []string{
+		`<html><body><h1>Menu</h1><p>Soup of the day: tomato</p><p>Open daily</p></body></html>`,
+		`<html><body><h1>Menu</h1><p>Soup of the day: potato</p><p>Open daily</p></body></html>`,
+		`<html><body><h1>Menu</h1><p>Soup of the day: potato</p><p>Open daily</p><p>Closed on holidays</p></body></html>`,
+	}
+	ss := make([]*model.Snapshot, len(pages))
+	for i, p := range pages {
+		ss[i] = &model.Snapshot{
+			BookmarkID: b.ID,
+			Key:        fmt.Sprintf("cd%02d", i),
+			Title:      "timeline",
+		}
+		if i == len(pages)-1 {
+			ss[i].BookmarkID = b2.ID
+		}
+		ss[i].CreatedAt = time.Now().Add(time.Duration(i-len(pages)) * time.Hour)
+		assert.Nil(t, storage.SaveSnapshot(ss[i].Key, []byte(p)))
+		assert.Nil(t, model.DB.Create(ss[i]).Error)
+	}
+
+	w := testRequest(router, "GET", URLFor("Snapshot timeline")+fmt.Sprintf("?bid=%d", b.ID), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "Changes since bookmarked")
+	assert.Contains(t, body, "1 changed")
+	assert.Contains(t, body, "1 added")
+	assert.Contains(t, body, "First snapshot")
+
+	// only the summaries of the owner are cached
+	assert.Nil(t, model.GetDiffStat(ss[0].Key, ss[1].Key, nil))
+	_, err := getDiffStat(ss[0], ss[1], nil, true)
+	assert.Nil(t, err)
+	st := model.GetDiffStat(ss[0].Key, ss[1].Key, nil)
+	if assert.NotNil(t, st) {
+		assert.Equal(t, 1, st.Changed)
+		assert.Greater(t, st.Magnitude, 0.0)
+	}
+
+	defer func(n uint) { resultsPerPage = n }(resultsPerPage)
+	resultsPerPage = 2
+	w = testRequest(router, "GET", URLFor("Snapshot timeline")+fmt.Sprintf("?bid=%d", b.ID), "", "")
+	assert.NotContains(t, w.Body.String(), "First snapshot")
+	assert.Contains(t, w.Body.String(), "pageno=2")
+	w = testRequest(router, "GET", URLFor("Snapshot timeline")+fmt.Sprintf("?bid=%d&pageno=2", b.ID), "", "")
+	assert.Contains(t, w.Body.String(), "First snapshot")
+	assert.NotContains(t, w.Body.String(), "Changes since bookmarked")
+
+	w = testRequest(router, "GET", URLFor("Snapshot diff")+"?s1=cd00&s2=cd01", "", "")
+	assert.NotContains(t, w.Body.String(), "Previous change")
+	assert.Contains(t, w.Body.String(), "Next change")
+	assert.Contains(t, w.Body.String(), "s1=cd01&amp;s2=cd02")
+}
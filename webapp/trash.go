@@ -0,0 +1,72 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func trash(c *gin.Context) {
+	u, _ := c.Get("user")
+	cfg, _ := c.Get("config")
+	t, err := model.GetTrash(u.(*model.User).ID)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+		t = &model.Trash{}
+	}
+	render(c, http.StatusOK, "trash", map[string]any{
+		"Trash":         t,
+		"RetentionDays": cfg.(*config.Config).App.TrashRetentionDays,
+		"Submenu":       bookmarkSubmenu,
+	})
+}
+
+func restoreBookmark(c *gin.Context) {
+	u, _ := c.Get("user")
+	if _, err := model.RestoreBookmark(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, "Failed to restore bookmark: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Bookmark restored", true)
+	}
+	c.Redirect(http.StatusFound, URLFor("Trash"))
+}
+
+func restoreSnapshot(c *gin.Context) {
+	u, _ := c.Get("user")
+	if _, err := model.RestoreSnapshot(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, "Failed to restore snapshot: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Snapshot restored", true)
+	}
+	c.Redirect(http.StatusFound, URLFor("Trash"))
+}
+
+func restoreCollection(c *gin.Context) {
+	u, _ := c.Get("user")
+	if _, err := model.RestoreCollection(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, "Failed to restore collection: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Collection restored", true)
+	}
+	c.Redirect(http.StatusFound, URLFor("Trash"))
+}
+
+func emptyTrash(c *gin.Context) {
+	u, _ := c.Get("user")
+	n, err := model.PurgeTrash(u.(*model.User).ID, time.Now())
+	if err != nil {
+		setNotification(c, nError, "Failed to empty trash: "+err.Error(), true)
+	} else {
+		setNotification(c, nInfo, fmt.Sprintf("%d items removed permanently", n), true)
+	}
+	c.Redirect(http.StatusFound, URLFor("Trash"))
+}
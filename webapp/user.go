@@ -10,7 +10,9 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asciimoo/omnom/config"
 	"github.com/asciimoo/omnom/mail"
@@ -21,7 +23,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-var userRe = regexp.MustCompile(`[a-zA-Z0-9_]+`)
+var userRe = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 
 func isActivityPubHeader(s string) bool {
 	return strings.HasPrefix(s, "application/activity+json") || strings.HasPrefix(s, `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
@@ -52,10 +54,7 @@ func userProfile(c *gin.Context) {
 	if err := model.DB.Model(&model.Bookmark{}).Where("bookmarks.user_id = ? and bookmarks.public = 1", user.ID).Count(&bc).Error; err != nil {
 		log.Error().Err(err).Msg("Failed to count bookmarks")
 	}
-	var fc int64
-	if err := model.DB.Model(&model.APFollower{}).Where("ap_followers.user_id = ?", user.ID).Count(&fc).Error; err != nil {
-		log.Error().Err(err).Msg("Failed to count bookmarks")
-	}
+	fc := model.GetAPFollowerCount(user.ID, 0)
 	render(c, http.StatusOK, "user", gin.H{
 		"User":          user,
 		"FediName":      fName,
@@ -68,6 +67,9 @@ func validateUsername(username string) error {
 	if strings.ToLower(username) == "admin" {
 		return errors.New("reserved username")
 	}
+	if _, _, ok := apParseCollectionHandle(username); ok {
+		return errors.New("reserved username. Usernames in the format of collection handles are not allowed")
+	}
 	if match := userRe.MatchString(username); !match {
 		return errors.New("invalid username. Use only letters, numbers and underscore")
 	}
@@ -247,15 +249,13 @@ func profile(c *gin.Context) {
 		return
 	}
 	uid := u.(*model.User).ID
-	if c.Request.Method == POST {
-		var ts []*model.Token
-		err := model.DB.Where("user_id = ?", uid).Find(&ts).Error
-		if err != nil {
-			setNotification(c, nError, err.Error(), false)
-		}
-		tplData["AddonTokens"] = ts
-		tplData["DisplayTokens"] = true
+	ts, err := model.GetUserTokens(uid)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
 	}
+	tplData["Tokens"] = ts
+	tplData["TokenScopes"] = model.TokenScopes
+	tplData["TokenExpirations"] = tokenExpirations
 	var sSize uint
 	model.DB.
 		Model(&model.Snapshot{}).
@@ -268,21 +268,45 @@ func profile(c *gin.Context) {
 	render(c, http.StatusOK, "profile", tplData)
 }
 
+// tokenExpirations are the selectable token lifetimes in days, 0 means no expiry.
+var tokenExpirations = []int{0, 7, 30, 90, 365}
+
 func generateAddonToken(c *gin.Context) {
 	u, _ := c.Get("user")
-	tok, err := model.CreateAddonToken(u.(*model.User).ID)
+	_, tok, err := model.CreateAddonToken(u.(*model.User).ID)
+	if err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Token created: "+tok, true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/profile"))
+}
+
+func createToken(c *gin.Context) {
+	u, _ := c.Get("user")
+	scopes, err := model.ParseTokenScopes(c.PostFormArray("scopes"))
+	if err != nil {
+		setNotification(c, nError, err.Error(), true)
+		c.Redirect(http.StatusFound, baseURL("/profile"))
+		return
+	}
+	var expiresAt *time.Time
+	if days, err := strconv.Atoi(c.PostForm("expiration")); err == nil && days > 0 {
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+	_, tok, err := model.CreateToken(u.(*model.User).ID, c.PostForm("name"), scopes, expiresAt)
 	if err != nil {
 		setNotification(c, nError, err.Error(), true)
 	} else {
-		setNotification(c, nInfo, "Token created: "+tok.Text, true)
+		setNotification(c, nInfo, "Token created: "+tok, true)
 	}
 	c.Redirect(http.StatusFound, baseURL("/profile"))
 }
 
-func deleteAddonToken(c *gin.Context) {
-	id := c.PostForm("id")
+func deleteToken(c *gin.Context) {
 	u, _ := c.Get("user")
-	err := model.DB.Where("user_id = ? AND id = ?", u.(*model.User).ID, id).Delete(&model.Token{}).Error
+	err := model.DeleteToken(u.(*model.User).ID, c.PostForm("id"))
 	if err != nil {
 		setNotification(c, nError, err.Error(), true)
 	} else {
@@ -292,10 +316,7 @@ func deleteAddonToken(c *gin.Context) {
 }
 
 func checkAddonToken(c *gin.Context) {
-	tok := c.PostForm("token")
-	var t model.Token
-	err := model.DB.Where("text = ?", tok).First(&t).Error
-	if err != nil {
+	if tokenUser(c) == nil {
 		c.JSON(http.StatusForbidden, gin.H{
 			"message": "Invalid token. Check your addon tokens on your profile page of the webapp.",
 		})
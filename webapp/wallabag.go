@@ -0,0 +1,953 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	wallabagPath            = "/wallabag"
+	wallabagVersion         = "2.6.10"
+	wallabagTimeFormat      = "2006-01-02T15:04:05-0700"
+	wallabagDefaultPerPage  = 30
+	wallabagMaxPerPage      = 500
+	wallabagTokenLifetime   = 365 * 24 * 60 * 60
+	wallabagWordsPerMinute  = 200
+	wallabagMaxContentBytes = 10 * 1024 * 1024
+)
+
+type wallabagTag struct {
+	ID    uint   `json:"id"`
+	Label string `json:"label"`
+	Slug  string `json:"slug"`
+}
+
+type wallabagEntry struct {
+	ID             uint           `json:"id"`
+	Title          string         `json:"title"`
+	URL            string         `json:"url"`
+	GivenURL       string         `json:"given_url"`
+	IsArchived     int            `json:"is_archived"`
+	IsStarred      int            `json:"is_starred"`
+	IsPublic       bool           `json:"is_public"`
+	Content        string         `json:"content"`
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	ArchivedAt     *string        `json:"archived_at"`
+	StarredAt      *string        `json:"starred_at"`
+	Mimetype       string         `json:"mimetype"`
+	Language       *string        `json:"language"`
+	ReadingTime    int            `json:"reading_time"`
+	DomainName     string         `json:"domain_name"`
+	PreviewPicture *string        `json:"preview_picture"`
+	Tags           []*wallabagTag `json:"tags"`
+	Annotations    []any          `json:"annotations"`
+	UserID         uint           `json:"user_id"`
+	UserName       string         `json:"user_name"`
+}
+
+type wallabagLink struct {
+	Href string `json:"href"`
+}
+
+type wallabagEntries struct {
+	Page     int                     `json:"page"`
+	Limit    int                     `json:"limit"`
+	Pages    int                     `json:"pages"`
+	Total    int64                   `json:"total"`
+	Links    map[string]wallabagLink `json:"_links"`
+	Embedded struct {
+		Items []*wallabagEntry `json:"items"`
+	} `json:"_embedded"`
+}
+
+type wallabagOAuthToken struct {
+	AccessToken string  `json:"access_token"`
+	ExpiresIn   int64   `json:"expires_in"`
+	TokenType   string  `json:"token_type"`
+	Scope       *string `json:"scope"`
+}
+
+type wallabagInfo struct {
+	AppName             string `json:"appname"`
+	Version             string `json:"version"`
+	AllowedRegistration bool   `json:"allowed_registration"`
+}
+
+// wallabagContentPolicy sanitizes snapshots for reader applications.
+// Snapshot resources are rewritten to absolute URLs, because
+// the content is displayed outside of Omnom.
+var wallabagContentPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RewriteSrc(func(u *url.URL) {
+		if !strings.HasPrefix(u.Path, "../../resources/") {
+			return
+		}
+		ru, err := url.Parse(baseURL(storage.GetResourceURL(filepath.Base(u.Path))))
+		if err == nil {
+			*u = *ru
+		}
+	})
+	return p
+}()
+
+func wallabagEndpoints() []*Endpoint {
+	entryArg := &EndpointArg{
+		Name:               "entry",
+		Type:               "string",
+		Required:           true,
+		Description:        "Entry ID, optionally with .json suffix",
+		SkipAutoValidation: true,
+	}
+	tagsArg := &EndpointArg{
+		Name:               "tags",
+		Type:               "string",
+		Required:           false,
+		Description:        "Comma separated list of tags",
+		SkipAutoValidation: true,
+	}
+	archiveArg := &EndpointArg{
+		Name:               "archive",
+		Type:               "int",
+		Required:           false,
+		Description:        "1 to mark the entry as read, 0 to mark it as unread",
+		SkipAutoValidation: true,
+	}
+	publicArg := &EndpointArg{
+		Name:               "public",
+		Type:               "int",
+		Required:           false,
+		Description:        "1 to make the entry public",
+		SkipAutoValidation: true,
+	}
+	return []*Endpoint{
+		&Endpoint{
+			Name:         "Wallabag token",
+			Path:         wallabagPath + "/oauth/v2/token",
+			Method:       POST,
+			AuthRequired: false,
+			Handler:      wallabagToken,
+			Response:     &wallabagOAuthToken{},
+			Description:  "Wallabag compatible API: returns an access token. The password must be an API token of the user",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "grant_type",
+					Type:               "string",
+					Required:           true,
+					Description:        "Only the 'password' grant type is supported",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "client_id",
+					Type:               "string",
+					Required:           false,
+					Description:        "OAuth client ID, it is ignored",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "client_secret",
+					Type:               "string",
+					Required:           false,
+					Description:        "OAuth client secret, it is ignored",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "username",
+					Type:               "string",
+					Required:           false,
+					Description:        "Username",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "password",
+					Type:               "string",
+					Required:           false,
+					Description:        "API token",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag version",
+			Path:         wallabagPath + "/api/version.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagGetVersion,
+			Description:  "Wallabag compatible API: returns the emulated Wallabag version",
+		},
+		&Endpoint{
+			Name:         "Wallabag info",
+			Path:         wallabagPath + "/api/info.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagGetInfo,
+			Response:     &wallabagInfo{},
+			Description:  "Wallabag compatible API: returns information about the application",
+		},
+		&Endpoint{
+			Name:         "Wallabag entries",
+			Path:         wallabagPath + "/api/entries.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagListEntries,
+			Scope:        model.ScopeRead,
+			Response:     &wallabagEntries{},
+			Description:  "Wallabag compatible API: lists bookmarks as entries",
+			Args: []*EndpointArg{
+				archiveArg,
+				&EndpointArg{
+					Name:               "starred",
+					Type:               "int",
+					Required:           false,
+					Description:        "1 to return only starred entries. Bookmarks cannot be starred, so it returns no entries",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "sort",
+					Type:               "string",
+					Required:           false,
+					Description:        "'created' (default) or 'updated'",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "order",
+					Type:               "string",
+					Required:           false,
+					Description:        "'desc' (default) or 'asc'",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "page",
+					Type:               "int",
+					Required:           false,
+					Description:        "Page number",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "perPage",
+					Type:               "int",
+					Required:           false,
+					Description:        "Number of entries per page",
+					SkipAutoValidation: true,
+				},
+				tagsArg,
+				&EndpointArg{
+					Name:               "since",
+					Type:               "int",
+					Required:           false,
+					Description:        "Return only entries updated since this timestamp",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "domain_name",
+					Type:               "string",
+					Required:           false,
+					Description:        "Filter by domain",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "detail",
+					Type:               "string",
+					Required:           false,
+					Description:        "'full' (default) or 'metadata' to omit the content",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag create entry",
+			Path:         wallabagPath + "/api/entries.json",
+			Method:       POST,
+			AuthRequired: false,
+			Handler:      wallabagCreateEntry,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: creates an unread bookmark. Existing bookmarks are returned unchanged",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "url",
+					Type:               "URL",
+					Required:           true,
+					Description:        "Entry URL",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "title",
+					Type:               "string",
+					Required:           false,
+					Description:        "Entry title",
+					SkipAutoValidation: true,
+				},
+				tagsArg,
+				archiveArg,
+				publicArg,
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag entry exists",
+			Path:         wallabagPath + "/api/entries/exists.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagEntryExists,
+			Scope:        model.ScopeRead,
+			Description:  "Wallabag compatible API: checks if a URL is bookmarked",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "url",
+					Type:               "URL",
+					Required:           true,
+					Description:        "Entry URL",
+					SkipAutoValidation: true,
+				},
+				&EndpointArg{
+					Name:               "return_id",
+					Type:               "int",
+					Required:           false,
+					Description:        "1 to return the ID of the entry instead of a boolean",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag entry",
+			Path:         wallabagPath + "/api/entries/:entry",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagGetEntry,
+			Scope:        model.ScopeRead,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: returns a bookmark with the content of its latest snapshot",
+			Args:         []*EndpointArg{entryArg},
+		},
+		&Endpoint{
+			Name:         "Wallabag update entry",
+			Path:         wallabagPath + "/api/entries/:entry",
+			Method:       PATCH,
+			AuthRequired: false,
+			Handler:      wallabagUpdateEntry,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: updates a bookmark. Tags are added to the existing tags",
+			Args: []*EndpointArg{
+				entryArg,
+				&EndpointArg{
+					Name:               "title",
+					Type:               "string",
+					Required:           false,
+					Description:        "Entry title",
+					SkipAutoValidation: true,
+				},
+				tagsArg,
+				archiveArg,
+				publicArg,
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag delete entry",
+			Path:         wallabagPath + "/api/entries/:entry",
+			Method:       DELETE,
+			AuthRequired: false,
+			Handler:      wallabagDeleteEntry,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: deletes a bookmark",
+			Args:         []*EndpointArg{entryArg},
+		},
+		&Endpoint{
+			Name:         "Wallabag entry tags",
+			Path:         wallabagPath + "/api/entries/:entry/tags.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagGetEntryTags,
+			Scope:        model.ScopeRead,
+			Response:     []*wallabagTag{},
+			Description:  "Wallabag compatible API: returns the tags of a bookmark",
+			Args:         []*EndpointArg{entryArg},
+		},
+		&Endpoint{
+			Name:         "Wallabag add entry tags",
+			Path:         wallabagPath + "/api/entries/:entry/tags.json",
+			Method:       POST,
+			AuthRequired: false,
+			Handler:      wallabagAddEntryTags,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: adds tags to a bookmark",
+			Args:         []*EndpointArg{entryArg, tagsArg},
+		},
+		&Endpoint{
+			Name:         "Wallabag delete entry tag",
+			Path:         wallabagPath + "/api/entries/:entry/tags/:tag",
+			Method:       DELETE,
+			AuthRequired: false,
+			Handler:      wallabagDeleteEntryTag,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagEntry{},
+			Description:  "Wallabag compatible API: removes a tag from a bookmark",
+			Args: []*EndpointArg{
+				entryArg,
+				&EndpointArg{
+					Name:               "tag",
+					Type:               "string",
+					Required:           true,
+					Description:        "Tag ID, optionally with .json suffix",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Wallabag tags",
+			Path:         wallabagPath + "/api/tags.json",
+			Method:       GET,
+			AuthRequired: false,
+			Handler:      wallabagGetTags,
+			Scope:        model.ScopeRead,
+			Response:     []*wallabagTag{},
+			Description:  "Wallabag compatible API: returns the tags of the user",
+		},
+		&Endpoint{
+			Name:         "Wallabag delete tag",
+			Path:         wallabagPath + "/api/tags/:tag",
+			Method:       DELETE,
+			AuthRequired: false,
+			Handler:      wallabagDeleteTag,
+			Scope:        model.ScopeBookmarks,
+			Response:     &wallabagTag{},
+			Description:  "Wallabag compatible API: removes a tag from every bookmark of the user",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:               "tag",
+					Type:               "string",
+					Required:           true,
+					Description:        "Tag ID, optionally with .json suffix",
+					SkipAutoValidation: true,
+				},
+			},
+		},
+	}
+}
+
+func wallabagError(c *gin.Context, status int, code, msg string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"error":             code,
+		"error_description": msg,
+	})
+}
+
+// wallabagUser returns the user authenticated by the bearer token.
+func wallabagUser(c *gin.Context) *model.User {
+	u := tokenUser(c)
+	if u == nil {
+		wallabagError(c, http.StatusUnauthorized, "access_denied", "OAuth2 authentication required")
+	}
+	return u
+}
+
+// wallabagArgs collects the request arguments.
+// Wallabag clients send form encoded or JSON bodies,
+// JSON values are converted to their form encoded representation.
+func wallabagArgs(c *gin.Context) map[string]string {
+	args := make(map[string]string)
+	for k, v := range c.Request.URL.Query() {
+		args[k] = v[0]
+	}
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodDelete {
+		return args
+	}
+	if !isJSONRequest(c) {
+		_ = c.Request.ParseForm()
+		for k, v := range c.Request.PostForm {
+			args[k] = v[0]
+		}
+		return args
+	}
+	jargs, err := readJSONArgs(c)
+	if err != nil {
+		return args
+	}
+	for k, v := range jargs {
+		switch v := v.(type) {
+		case string:
+			args[k] = v
+		case float64:
+			args[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			args[k] = "0"
+			if v {
+				args[k] = "1"
+			}
+		case []any:
+			vs := make([]string, 0, len(v))
+			for _, e := range v {
+				if s, ok := e.(string); ok {
+					vs = append(vs, s)
+				}
+			}
+			args[k] = strings.Join(vs, ",")
+		}
+	}
+	return args
+}
+
+func wallabagSplitTags(s string) []string {
+	var tags []string
+	for t := range strings.SplitSeq(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func wallabagFlag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func wallabagTrimID(s string) string {
+	return strings.TrimSuffix(s, ".json")
+}
+
+func newWallabagTag(id uint, label string) *wallabagTag {
+	return &wallabagTag{
+		ID:    id,
+		Label: label,
+		Slug:  strings.ReplaceAll(strings.ToLower(label), " ", "-"),
+	}
+}
+
+func newWallabagEntry(b *model.Bookmark, u *model.User, withContent bool) *wallabagEntry {
+	e := &wallabagEntry{
+		ID:          b.ID,
+		Title:       b.Title,
+		URL:         b.URL,
+		GivenURL:    b.URL,
+		IsArchived:  wallabagFlag(!b.Unread),
+		IsPublic:    b.Public,
+		CreatedAt:   b.CreatedAt.Format(wallabagTimeFormat),
+		UpdatedAt:   b.UpdatedAt.Format(wallabagTimeFormat),
+		Mimetype:    "text/html",
+		DomainName:  b.Domain,
+		Tags:        make([]*wallabagTag, len(b.Tags)),
+		Annotations: []any{},
+		UserID:      u.ID,
+		UserName:    u.Username,
+	}
+	if e.IsArchived == 1 {
+		e.ArchivedAt = &e.UpdatedAt
+	}
+	for i, t := range b.Tags {
+		e.Tags[i] = newWallabagTag(t.ID, t.Text)
+	}
+	var s model.Snapshot
+	if err := model.DB.Where("bookmark_id = ?", b.ID).Order("created_at desc").First(&s).Error; err != nil {
+		if withContent && b.Notes != "" {
+			e.Content = "<p>" + html.EscapeString(b.Notes) + "</p>"
+		}
+		return e
+	}
+	e.ReadingTime = len(strings.Fields(s.Text)) / wallabagWordsPerMinute
+	if withContent {
+		e.Content = wallabagSnapshotContent(&s)
+	}
+	return e
+}
+
+// wallabagSnapshotContent returns the sanitized HTML of a snapshot.
+// The extracted text of the snapshot is used if the HTML is not available.
+func wallabagSnapshotContent(s *model.Snapshot) string {
+	r, err := storage.GetSnapshot(s.Key)
+	if err == nil {
+		defer r.Close()
+		var gr *gzip.Reader
+		gr, err = gzip.NewReader(r)
+		if err == nil {
+			var buf bytes.Buffer
+			_, err = io.Copy(&buf, io.LimitReader(gr, wallabagMaxContentBytes))
+			if err == nil {
+				return wallabagContentPolicy.SanitizeReader(&buf).String()
+			}
+		}
+	}
+	log.Debug().Err(err).Str("key", s.Key).Msg("Failed to read snapshot")
+	var sb strings.Builder
+	for p := range strings.SplitSeq(s.Text, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			sb.WriteString("<p>" + html.EscapeString(p) + "</p>")
+		}
+	}
+	return sb.String()
+}
+
+// wallabagBookmark returns the bookmark specified by the entry path parameter.
+func wallabagBookmark(c *gin.Context, uid uint) *model.Bookmark {
+	var b model.Bookmark
+	err := model.DB.
+		Where("id = ? AND user_id = ?", wallabagTrimID(c.Param("entry")), uid).
+		Preload("Tags").
+		First(&b).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			wallabagError(c, http.StatusNotFound, "not_found", "Entry not found")
+		} else {
+			log.Error().Err(err).Msg("Failed to query bookmark")
+			wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		}
+		return nil
+	}
+	return &b
+}
+
+// wallabagUpdateBookmark saves the modifications of b and adds tags to it.
+func wallabagUpdateBookmark(c *gin.Context, b *model.Bookmark, tags []string) bool {
+	if len(tags) > 0 {
+		if err := model.DB.Model(b).Association("Tags").Append(getAPITags(tags)); err != nil {
+			log.Error().Err(err).Msg("Failed to add bookmark tags")
+			wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+			return false
+		}
+	}
+	if err := model.DB.Omit("Tags", "Snapshots", "User").Save(b).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to save bookmark")
+		wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return false
+	}
+	model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
+	return true
+}
+
+func wallabagToken(c *gin.Context) {
+	args := wallabagArgs(c)
+	// refresh tokens are not issued, the API token is the only credential
+	// of the clients and they have to send it again to get a new access token
+	if args["grant_type"] != "password" {
+		wallabagError(c, http.StatusBadRequest, "unsupported_grant_type", "Invalid grant_type parameter or parameter missing")
+		return
+	}
+	tok := args["password"]
+	t := model.GetToken(tok)
+	if t == nil || !strings.EqualFold(t.User.Username, args["username"]) {
+		wallabagError(c, http.StatusBadRequest, "invalid_grant", "Invalid username and password combination")
+		return
+	}
+	expiresIn := int64(wallabagTokenLifetime)
+	if t.ExpiresAt != nil {
+		expiresIn = int64(time.Until(*t.ExpiresAt).Seconds())
+	}
+	c.JSON(http.StatusOK, &wallabagOAuthToken{
+		AccessToken: tok,
+		ExpiresIn:   expiresIn,
+		TokenType:   "bearer",
+	})
+}
+
+func wallabagGetVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, wallabagVersion)
+}
+
+func wallabagGetInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, &wallabagInfo{
+		AppName: "wallabag",
+		Version: wallabagVersion,
+	})
+}
+
+func wallabagListEntries(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	args := wallabagArgs(c)
+	page := 1
+	if p, err := strconv.Atoi(args["page"]); err == nil && p > 0 {
+		page = p
+	}
+	perPage := wallabagDefaultPerPage
+	if pp, err := strconv.Atoi(args["perPage"]); err == nil && pp > 0 {
+		perPage = min(pp, wallabagMaxPerPage)
+	}
+	q := model.DB.Model(&model.Bookmark{}).Where("bookmarks.user_id = ?", u.ID)
+	switch args["archive"] {
+	case "1":
+		q = q.Where("bookmarks.unread = ?", false)
+	case "0":
+		q = q.Where("bookmarks.unread = ?", true)
+	}
+	// bookmarks cannot be starred
+	if args["starred"] == "1" {
+		q = q.Where("1 = 0")
+	}
+	q = filterAllTags(wallabagSplitTags(args["tags"]), q)
+	if since, err := strconv.ParseInt(args["since"], 10, 64); err == nil && since > 0 {
+		q = q.Where("bookmarks.updated_at >= ?", time.Unix(since, 0))
+	}
+	if d := args["domain_name"]; d != "" {
+		q = q.Where("bookmarks.domain = ?", d)
+	}
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to count bookmarks")
+		wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return
+	}
+	order := "bookmarks.created_at"
+	if args["sort"] == "updated" || args["sort"] == "archived" {
+		order = "bookmarks.updated_at"
+	}
+	if args["order"] == "asc" {
+		order += " asc"
+	} else {
+		order += " desc"
+	}
+	var bs []*model.Bookmark
+	if err := q.Preload("Tags").Order(order).Limit(perPage).Offset((page - 1) * perPage).Find(&bs).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to query bookmarks")
+		wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return
+	}
+	pages := max(int(math.Ceil(float64(total)/float64(perPage))), 1)
+	pageLink := func(p int) wallabagLink {
+		v := c.Request.URL.Query()
+		v.Set("page", strconv.Itoa(p))
+		v.Set("perPage", strconv.Itoa(perPage))
+		return wallabagLink{Href: baseURL(c.Request.URL.Path + "?" + v.Encode())}
+	}
+	res := &wallabagEntries{
+		Page:  page,
+		Limit: perPage,
+		Pages: pages,
+		Total: total,
+		Links: map[string]wallabagLink{
+			"self":  pageLink(page),
+			"first": pageLink(1),
+			"last":  pageLink(pages),
+		},
+	}
+	if page < pages {
+		res.Links["next"] = pageLink(page + 1)
+	}
+	withContent := args["detail"] != "metadata"
+	res.Embedded.Items = make([]*wallabagEntry, len(bs))
+	for i, b := range bs {
+		res.Embedded.Items[i] = newWallabagEntry(b, u, withContent)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func wallabagCreateEntry(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	args := wallabagArgs(c)
+	us := args["url"]
+	if us == "" {
+		wallabagError(c, http.StatusBadRequest, "invalid_request", "Missing url")
+		return
+	}
+	title := args["title"]
+	if title == "" {
+		title = us
+	}
+	unread := "1"
+	if args["archive"] == "1" {
+		unread = ""
+	}
+	public := ""
+	if args["public"] == "1" {
+		public = "1"
+	}
+	tags := wallabagSplitTags(args["tags"])
+	b, isNew, err := model.GetOrCreateBookmark(u, us, title, strings.Join(tags, ","), "", public, "", "", unread)
+	if err != nil {
+		wallabagError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if isNew {
+		go apNotifyFollowers(c.Copy(), b)
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, true))
+}
+
+func wallabagEntryExists(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	var b model.Bookmark
+	err := model.DB.Where("user_id = ? AND url = ?", u.ID, c.Query("url")).First(&b).Error
+	if c.Query("return_id") == "1" {
+		var id *uint
+		if err == nil {
+			id = &b.ID
+		}
+		c.JSON(http.StatusOK, gin.H{"exists": id})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exists": err == nil})
+}
+
+func wallabagGetEntry(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, true))
+}
+
+func wallabagUpdateEntry(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	args := wallabagArgs(c)
+	if t := args["title"]; t != "" {
+		b.Title = t
+	}
+	if a, ok := args["archive"]; ok {
+		b.Unread = a == "0"
+	}
+	if p, ok := args["public"]; ok {
+		b.Public = p == "1"
+	}
+	if !wallabagUpdateBookmark(c, b, wallabagSplitTags(args["tags"])) {
+		return
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, true))
+}
+
+func wallabagDeleteEntry(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	e := newWallabagEntry(b, u, false)
+	if err := model.DeleteBookmark(u.ID, strconv.FormatUint(uint64(b.ID), 10)); err != nil {
+		log.Error().Err(err).Msg("Failed to delete bookmark")
+		wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+func wallabagGetEntryTags(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, false).Tags)
+}
+
+func wallabagAddEntryTags(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	if !wallabagUpdateBookmark(c, b, wallabagSplitTags(wallabagArgs(c)["tags"])) {
+		return
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, true))
+}
+
+func wallabagDeleteEntryTag(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	b := wallabagBookmark(c, u.ID)
+	if b == nil {
+		return
+	}
+	tid := wallabagTrimID(c.Param("tag"))
+	for _, t := range b.Tags {
+		if strconv.FormatUint(uint64(t.ID), 10) != tid {
+			continue
+		}
+		if err := model.DB.Model(b).Association("Tags").Delete(&t); err != nil {
+			log.Error().Err(err).Msg("Failed to delete bookmark tag")
+			wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+			return
+		}
+		model.TriggerBookmarkWebhooks(model.EventBookmarkUpdated, b)
+		break
+	}
+	c.JSON(http.StatusOK, newWallabagEntry(b, u, true))
+}
+
+func wallabagGetTags(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	tags, err := model.GetUserTags(u.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query tags")
+	}
+	res := make([]*wallabagTag, len(tags))
+	for i, t := range tags {
+		res[i] = newWallabagTag(t.ID, t.Tag)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func wallabagDeleteTag(c *gin.Context) {
+	u := wallabagUser(c)
+	if u == nil {
+		return
+	}
+	var t model.Tag
+	if err := model.DB.Where("id = ?", wallabagTrimID(c.Param("tag"))).First(&t).Error; err != nil {
+		wallabagError(c, http.StatusNotFound, "not_found", "Tag not found")
+		return
+	}
+	if _, err := model.DeleteUserTag(u.ID, t.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to delete tag")
+		wallabagError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return
+	}
+	c.JSON(http.StatusOK, newWallabagTag(t.ID, t.Text))
+}
@@ -0,0 +1,72 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallabag(t *testing.T) {
+	router, _, tok := initTestUser(t, "wallabag", model.ScopeRead, model.ScopeBookmarks)
+
+	w := testRequest(router, "POST", wallabagPath+"/oauth/v2/token", "", url.Values{"grant_type": {"password"}, "username": {"wallabag"}, "password": {"invalid"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = testRequest(router, "POST", wallabagPath+"/oauth/v2/token", "", url.Values{"grant_type": {"password"}, "username": {"wallabag"}, "password": {tok}, "client_id": {"x"}})
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var ot wallabagOAuthToken
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &ot))
+	assert.Equal(t, tok, ot.AccessToken)
+	assert.Equal(t, "bearer", ot.TokenType)
+
+	w = testRequest(router, "GET", wallabagPath+"/api/entries.json", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = testRequest(router, "POST", wallabagPath+"/api/entries.json", ot.AccessToken, `{"url":"https://example.com/wb","tags":"a,b"}`)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var e wallabagEntry
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, "https://example.com/wb", e.Title)
+	assert.Equal(t, 0, e.IsArchived)
+	assert.Len(t, e.Tags, 2)
+	eu := fmt.Sprintf("%s/api/entries/%d", wallabagPath, e.ID)
+
+	w = testRequest(router, "PATCH", eu+".json", ot.AccessToken, `{"title":"Read later","archive":1,"tags":"c"}`)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, "Read later", e.Title)
+	assert.Equal(t, 1, e.IsArchived)
+	assert.Len(t, e.Tags, 3)
+
+	var l wallabagEntries
+	w = testRequest(router, "GET", wallabagPath+"/api/entries.json?archive=0", ot.AccessToken, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &l))
+	assert.Equal(t, int64(0), l.Total)
+	w = testRequest(router, "GET", wallabagPath+"/api/entries.json?archive=1&tags=a,c", ot.AccessToken, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &l))
+	assert.Equal(t, int64(1), l.Total)
+	assert.Len(t, l.Embedded.Items, 1)
+
+	w = testRequest(router, "DELETE", fmt.Sprintf("%s/tags/%d.json", eu, e.Tags[0].ID), ot.AccessToken, "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Len(t, e.Tags, 2)
+
+	w = testRequest(router, "GET", wallabagPath+"/api/entries/exists.json?url=https://example.com/wb", ot.AccessToken, "")
+	assert.JSONEq(t, `{"exists":true}`, w.Body.String())
+
+	w = testRequest(router, "DELETE", eu+".json", ot.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = testRequest(router, "GET", eu+".json", ot.AccessToken, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
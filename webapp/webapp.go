@@ -158,6 +158,9 @@ func truncate(s string, maxLen int) string {
 }
 
 func getSnapshotURL(key string) string {
+	if snapshotOrigin.BaseURL != "" {
+		return getIsolatedURL(key, fmt.Sprintf("data/snapshots/%s/%s.gz", key[:2], key), time.Now())
+	}
 	return fmt.Sprintf("%s%s/%s.gz", baseURL("/static/data/snapshots/"), key[:2], key)
 }
 
@@ -181,7 +184,12 @@ func createRenderer(tplFS fs.FS) multitemplate.Renderer {
 	addTemplate(r, tplFS, true, "snapshots", "snapshots.tpl")
 	addTemplate(r, tplFS, true, "my-bookmarks", "my_bookmarks.tpl")
 	addTemplate(r, tplFS, true, "profile", "profile.tpl")
+	addTemplate(r, tplFS, true, "blocks", "blocks.tpl")
+	addTemplate(r, tplFS, true, "webhooks", "webhooks.tpl")
+	addTemplate(r, tplFS, true, "trash", "trash.tpl")
+	addTemplate(r, tplFS, true, "duplicates", "duplicates.tpl")
 	addTemplate(r, tplFS, true, "snapshot-wrapper", "snapshot_wrapper.tpl")
+	addTemplate(r, tplFS, true, "snapshot-reader", "snapshot_reader.tpl")
 	addTemplate(r, tplFS, true, "snapshot-archive", "snapshot_archive.tpl")
 	addTemplate(r, tplFS, true, "snapshot-details", "snapshot_details.tpl")
 	addTemplate(r, tplFS, true, "view-bookmark", "view_bookmark.tpl")
@@ -190,11 +198,13 @@ func createRenderer(tplFS fs.FS) multitemplate.Renderer {
 	addTemplate(r, tplFS, true, "snapshot-diff-form", "snapshot_diff_form.tpl")
 	addTemplate(r, tplFS, true, "snapshot-diff", "snapshot_diff.tpl")
 	addTemplate(r, tplFS, true, "snapshot-diff-side-by-side", "snapshot_diff_side_by_side.tpl")
+	addTemplate(r, tplFS, true, "snapshot-timeline", "snapshot_timeline.tpl")
 	addTemplate(r, tplFS, true, "edit-collection", "edit_collection.tpl")
 	addTemplate(r, tplFS, true, "feeds", "feeds.tpl")
 	addTemplate(r, tplFS, true, "feed-search", "feed_search.tpl")
 	addTemplate(r, tplFS, true, "search", "search.tpl")
 	addTemplate(r, tplFS, true, "edit-feed", "edit_feed.tpl")
+	addTemplate(r, tplFS, true, "follow-account", "follow_account.tpl")
 	addTemplate(r, tplFS, true, "user", "user.tpl")
 	addTemplate(r, tplFS, true, "api", "api.tpl")
 	addTemplate(r, tplFS, true, "error", "error.tpl")
@@ -308,9 +318,17 @@ func renderRSS(c *gin.Context, status int, vars map[string]any) {
 }
 
 func registerEndpoint(r *gin.RouterGroup, e *Endpoint) {
-	hs := make([]gin.HandlerFunc, 0, 2)
+	hs := make([]gin.HandlerFunc, 0, 5)
+	hs = append(hs, createTokenAuthMiddleware(e))
+	switch {
+	case e.isAPI():
+		hs = append(hs, apiAuthMiddleware)
+	case e.AuthRequired:
+		hs = append(hs, authRequiredMiddleware)
+	}
+	hs = append(hs, CSRFMiddleware())
 	if len(e.Args) > 0 {
-		hs = append(hs, createValidateArgsMiddleware(e.Method, e.Args))
+		hs = append(hs, createValidateArgsMiddleware(e))
 	}
 	if e.RSS != "" {
 		hs = append(hs, RSSEndpointWrapper(e.Handler, e.RSS))
@@ -328,28 +346,60 @@ func registerEndpoint(r *gin.RouterGroup, e *Endpoint) {
 		r.PATCH(e.Path, hs...)
 	case HEAD:
 		r.HEAD(e.Path, hs...)
+	case DELETE:
+		r.DELETE(e.Path, hs...)
 	}
 }
 
-func createValidateArgsMiddleware(method string, args []*EndpointArg) gin.HandlerFunc {
+// createValidateArgsMiddleware checks the presence of the required arguments.
+// The types of the arguments are validated only on API endpoints, HTML
+// endpoints handle malformed optional values themselves.
+func createValidateArgsMiddleware(e *Endpoint) gin.HandlerFunc {
+	typeCheck := e.isAPI()
 	return func(c *gin.Context) {
-		for _, a := range args {
-			if !a.Required || a.SkipAutoValidation {
+		var jsonArgs map[string]any
+		if e.Method != GET && isJSONRequest(c) {
+			var err error
+			jsonArgs, err = readJSONArgs(c)
+			if err != nil {
+				argumentError(c, "Invalid JSON body")
+				return
+			}
+		}
+		for _, a := range e.Args {
+			if a.SkipAutoValidation {
 				continue
 			}
-			val := ""
-			if method == POST {
-				val = c.PostForm(a.Name)
+			var v string
+			var present bool
+			switch e.argLocation(a) {
+			case argInPath:
+				continue
+			case argInQuery:
+				v, present = c.GetQuery(a.Name)
+			case argInBody:
+				if jsonArgs != nil {
+					jv, ok := jsonArgs[a.Name]
+					if ok && jv != nil && jv != "" {
+						if typeCheck && !validJSONValue(a.schema(), jv) {
+							argumentError(c, "Invalid argument: "+a.Name)
+							return
+						}
+						continue
+					}
+					break
+				}
+				v, present = c.GetPostForm(a.Name)
 			}
-			if method == GET {
-				val = c.Query(a.Name)
+			if !present || v == "" {
+				if a.Required {
+					argumentError(c, "Missing argument: "+a.Name)
+					return
+				}
+				continue
 			}
-			// TODO type check
-			if val == "" {
-				render(c, http.StatusNotFound, "error", gin.H{
-					"Title": "Missing argument",
-				})
-				c.Abort()
+			if typeCheck && !a.validFormValue(v) {
+				argumentError(c, "Invalid argument: "+a.Name)
 				return
 			}
 		}
@@ -357,6 +407,17 @@ func createValidateArgsMiddleware(method string, args []*EndpointArg) gin.Handle
 	}
 }
 
+func argumentError(c *gin.Context, msg string) {
+	if isAPIRequest(c) {
+		apiError(c, http.StatusBadRequest, msg)
+		return
+	}
+	render(c, http.StatusNotFound, "error", gin.H{
+		"Title": "Missing argument",
+	})
+	c.Abort()
+}
+
 func resolveDynamicPath(p string, v []string) string {
 	if len(v) == 0 {
 		if strings.Contains(p, "*") {
@@ -401,10 +462,7 @@ func createEngine(cfg *config.Config) *gin.Engine {
 	e.Use(SessionMiddleware(cfg))
 	e.Use(LocalizationMiddleware())
 	e.Use(ConfigMiddleware(cfg))
-	e.Use(CSRFMiddleware())
 	e.Use(ErrorLoggerMiddleware())
-	authorized := e.Group("/")
-	authorized.Use(authRequiredMiddleware)
 
 	baseURL = cfg.BaseURL
 	// TODO handle GET arguments as well
@@ -421,13 +479,11 @@ func createEngine(cfg *config.Config) *gin.Engine {
 	tplFuncMap["URLFor"] = URLFor
 	initDocs()
 	// ROUTES
+	initSnapshotOrigin(cfg)
 	staticFS(e, "/static", static.FS, storage.FS())
+	e.GET(isolatedPrefix+"/:key/:expires/:signature/*filepath", isolatedSnapshot)
 	for _, ep := range Endpoints {
-		if ep.AuthRequired {
-			registerEndpoint(authorized, ep)
-		} else {
-			registerEndpoint(&e.RouterGroup, ep)
-		}
+		registerEndpoint(&e.RouterGroup, ep)
 	}
 	e.NoRoute(notFoundView)
 	e.HTMLRender = createRenderer(templates.FS)
@@ -452,39 +508,52 @@ func openStaticFS(name string, staticfs fs.FS, snapshotfs fs.FS) (fs.File, bool,
 func staticFS(e *gin.Engine, prefix string, staticfs fs.FS, snapshotfs fs.FS) {
 	handler := func(c *gin.Context) {
 		name := strings.TrimPrefix(c.Param("filepath"), "/")
-		f, snapshotContent, err := openStaticFS(name, staticfs, snapshotfs)
-		if err != nil {
-			notFoundView(c)
-			return
-		}
-		defer f.Close()
-		info, err := f.Stat()
-		if err != nil || info.IsDir() {
-			notFoundView(c)
-			return
-		}
-		seeker, ok := f.(io.ReadSeeker)
-		if !ok {
-			notFoundView(c)
+		// snapshot keys are capabilities: anyone knowing the key of a
+		// snapshot can access it, the signature only binds the URL to the
+		// snapshot origin and limits its lifetime
+		if snapshotOrigin.BaseURL != "" && strings.HasPrefix(name, "data/snapshots/") {
+			key := strings.TrimSuffix(path.Base(name), ".gz")
+			c.Redirect(http.StatusFound, getIsolatedURL(key, name, time.Now()))
 			return
 		}
-		// Don't add gzip or content-type headers until after we've handled
-		// all of the error cases so that 404 pages get rendered correctly.
-		if snapshotContent {
-			if strings.HasPrefix(name, "data/snapshots/") {
-				c.Header("Content-Type", "text/html; charset=utf-8")
-			}
-			if !strings.HasPrefix(name, "data/streams/") {
-				c.Header("Content-Encoding", "gzip")
-			}
-		}
-		http.ServeContent(c.Writer, c.Request, name, info.ModTime(), seeker)
+		serveStaticFile(c, name, staticfs, snapshotfs)
 	}
 	pattern := path.Join(prefix, "/*filepath")
 	e.GET(pattern, handler)
 	e.HEAD(pattern, handler)
 }
 
+func serveStaticFile(c *gin.Context, name string, staticfs fs.FS, snapshotfs fs.FS) {
+	f, snapshotContent, err := openStaticFS(name, staticfs, snapshotfs)
+	if err != nil {
+		notFoundView(c)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		notFoundView(c)
+		return
+	}
+	seeker, ok := f.(io.ReadSeeker)
+	if !ok {
+		notFoundView(c)
+		return
+	}
+	// Don't add gzip or content-type headers until after we've handled
+	// all of the error cases so that 404 pages get rendered correctly.
+	if snapshotContent {
+		setSnapshotHeaders(c)
+		if strings.HasPrefix(name, "data/snapshots/") {
+			c.Header("Content-Type", "text/html; charset=utf-8")
+		}
+		if !strings.HasPrefix(name, "data/streams/") {
+			c.Header("Content-Encoding", "gzip")
+		}
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), seeker)
+}
+
 // Run starts the web application server.
 func Run(cfg *config.Config) {
 	gin.SetMode(gin.ReleaseMode)
@@ -498,6 +567,10 @@ func Run(cfg *config.Config) {
 }
 
 func notFoundView(c *gin.Context) {
+	if isAPIRequest(c) {
+		apiError(c, http.StatusNotFound, "Not found")
+		return
+	}
 	render(c, http.StatusNotFound, "error", gin.H{
 		"Title":   "Not found.",
 		"Message": "This page does not exist.",
@@ -523,6 +596,11 @@ func getPageno(c *gin.Context) uint {
 }
 
 func authRequiredMiddleware(c *gin.Context) {
+	// token scopes are already checked by the token middleware
+	if _, ok := c.Get("token"); ok {
+		c.Next()
+		return
+	}
 	session := sessions.Default(c)
 	user := session.Get(SID)
 	if user == nil {
@@ -586,20 +664,83 @@ func SessionMiddleware(cfg *config.Config) gin.HandlerFunc {
 		if uname != nil {
 			u := model.GetUser(uname.(string))
 			c.Set("user", u)
-		} else {
-			tok := c.PostForm("token")
-			if tok == "" {
-				tok = c.Query("token")
-			}
-			if tok != "" {
-				// can be nil in case of invalid token
-				c.Set("user", model.GetUserBySubmissionToken(tok))
+		}
+		c.Next()
+	}
+}
+
+// createTokenAuthMiddleware authenticates requests with API tokens.
+// Tokens are accepted from the Authorization header or from the "token"
+// argument of the endpoint and must have the scope of the endpoint.
+// Invalid tokens are ignored, the handlers and the authentication
+// middlewares decide how to respond to unauthenticated requests.
+func createTokenAuthMiddleware(e *Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !e.AuthRequired && e.Scope == "" {
+			c.Next()
+			return
+		}
+		tok, owner := requestToken(c, e)
+		t := model.GetToken(tok)
+		if t == nil || (owner != "" && owner != t.User.Username) {
+			c.Next()
+			return
+		}
+		if !t.HasScope(e.Scope) {
+			if isAPIRequest(c) {
+				apiError(c, http.StatusForbidden, "Insufficient token scope")
+				return
 			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "insufficient token scope",
+			})
+			return
+		}
+		if err := t.UpdateUsage(c.ClientIP()); err != nil {
+			log.Error().Err(err).Msg("Failed to update token usage")
 		}
+		c.Set("user", &t.User)
+		c.Set("token", t)
 		c.Next()
 	}
 }
 
+// requestToken returns the API token of the request and the username
+// it is prefixed with in case of Pinboard compatible tokens.
+func requestToken(c *gin.Context, e *Endpoint) (string, string) {
+	if tok, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(tok), ""
+	}
+	for _, a := range e.Args {
+		var tok string
+		switch {
+		case a.Name != "token" && a.Name != "auth_token":
+			continue
+		case e.argLocation(a) == argInQuery:
+			tok = c.Query(a.Name)
+		default:
+			tok = c.PostForm(a.Name)
+		}
+		// Pinboard compatible tokens are prefixed with the username
+		if a.Name == "auth_token" {
+			if owner, t, ok := strings.Cut(tok, ":"); ok {
+				return t, owner
+			}
+		}
+		return tok, ""
+	}
+	return "", ""
+}
+
+// tokenUser returns the user authenticated by an API token.
+func tokenUser(c *gin.Context) *model.User {
+	if _, ok := c.Get("token"); !ok {
+		return nil
+	}
+	u, _ := c.Get("user")
+	return u.(*model.User)
+}
+
 // ConfigMiddleware injects configuration into the request context.
 func ConfigMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -634,6 +775,7 @@ func LocalizationMiddleware() gin.HandlerFunc {
 }
 
 // CSRFMiddleware provides CSRF protection.
+// It must run after the token authentication of the endpoint.
 func CSRFMiddleware() gin.HandlerFunc {
 	protection := csrf.New()
 	exceptions := []string{
@@ -641,8 +783,14 @@ func CSRFMiddleware() gin.HandlerFunc {
 		".addResource",
 		".pageInfo",
 		".checkToken",
+		".wallabagToken",
 	}
 	return func(c *gin.Context) {
+		// requests authenticated with API tokens do not rely on cookies
+		if _, ok := c.Get("token"); ok {
+			c.Next()
+			return
+		}
 		h := c.HandlerName()
 		for _, e := range exceptions {
 			if strings.HasSuffix(h, e) {
@@ -0,0 +1,57 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package webapp
+
+import (
+	"net/http"
+
+	"github.com/asciimoo/omnom/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const webhookDeliveryLogSize = 50
+
+func webhooks(c *gin.Context) {
+	u, _ := c.Get("user")
+	uid := u.(*model.User).ID
+	ws, err := model.GetUserWebhooks(uid)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+	}
+	ds, err := model.GetWebhookDeliveries(uid, webhookDeliveryLogSize)
+	if err != nil {
+		setNotification(c, nError, err.Error(), false)
+	}
+	render(c, http.StatusOK, "webhooks", map[string]any{
+		"Webhooks":      ws,
+		"Deliveries":    ds,
+		"WebhookEvents": model.WebhookEvents,
+	})
+}
+
+func createWebhook(c *gin.Context) {
+	u, _ := c.Get("user")
+	events, err := model.ParseWebhookEvents(c.PostFormArray("events"))
+	if err == nil {
+		_, err = model.CreateWebhook(u.(*model.User).ID, c.PostForm("url"), events)
+	}
+	if err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Webhook created", true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/webhooks"))
+}
+
+func deleteWebhook(c *gin.Context) {
+	u, _ := c.Get("user")
+	if err := model.DeleteWebhook(u.(*model.User).ID, c.PostForm("id")); err != nil {
+		setNotification(c, nError, err.Error(), true)
+	} else {
+		setNotification(c, nInfo, "Webhook deleted", true)
+	}
+	c.Redirect(http.StatusFound, baseURL("/webhooks"))
+}
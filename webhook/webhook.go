@@ -0,0 +1,180 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+// Package webhook delivers events to the webhooks of the users.
+//
+// Events are queued in the database by the model package when bookmarks,
+// snapshots or feed items change. This package sends the queued deliveries
+// as JSON POST requests and retries the failed ones with exponential backoff.
+// Deliveries to loopback and private network addresses are refused unless
+// they are allowed in the configuration.
+//
+// Every request contains the following headers:
+//   - X-Omnom-Event: name of the event
+//   - X-Omnom-Delivery: ID of the delivery
+//   - X-Omnom-Signature-256: HMAC-SHA256 signature of the body, computed with
+//     the secret of the webhook in "sha256=<hex digest>" format
+//
+// Example usage:
+//
+//	// Apply the settings of the configuration
+//	webhook.Configure(cfg.Webhooks)
+//
+//	// Send the due deliveries
+//	err := webhook.Deliver()
+//
+//	// Run the delivery worker
+//	go webhook.DeliveryLoop()
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MaxAttempts is the number of attempts before a delivery fails.
+	MaxAttempts = 6
+	// SignatureHeader contains the HMAC signature of the request body.
+	SignatureHeader = "X-Omnom-Signature-256"
+	// EventHeader contains the name of the event.
+	EventHeader = "X-Omnom-Event"
+	// DeliveryHeader contains the ID of the delivery.
+	DeliveryHeader = "X-Omnom-Delivery"
+
+	batchSize         = 50
+	pollInterval      = time.Minute
+	retryInterval     = time.Minute
+	deliveryRetention = 30 * 24 * time.Hour
+	maxErrorLength    = 512
+	maxResponseSize   = 64 * 1024
+	requestTimeout    = 10 * time.Second
+)
+
+var client = newClient(false)
+
+// Configure applies the webhook settings of the configuration.
+func Configure(cfg config.Webhooks) {
+	client = newClient(cfg.AllowPrivateNetworks)
+}
+
+func newClient(allowPrivate bool) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		t.DialContext = utils.PublicDialer(requestTimeout).DialContext
+	}
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: t,
+	}
+}
+
+// Sign returns the signature of a payload in "sha256=<hex digest>" format.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver sends the pending deliveries which are due.
+func Deliver() error {
+	for {
+		ds, err := model.GetDueWebhookDeliveries(batchSize)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			deliver(d)
+		}
+		if len(ds) < batchSize {
+			return nil
+		}
+	}
+}
+
+// DeliveryLoop sends the queued deliveries and retries the failed ones.
+// Finished deliveries are removed from the delivery log after 30 days.
+func DeliveryLoop() {
+	ticker := time.NewTicker(pollInterval)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := model.PruneWebhookDeliveries(time.Now().Add(-deliveryRetention)); err != nil {
+				log.Error().Err(err).Msg("Failed to prune webhook deliveries")
+			}
+		case <-model.WebhookQueue():
+		}
+		if err := Deliver(); err != nil {
+			log.Error().Err(err).Msg("Failed to deliver webhooks")
+		}
+	}
+}
+
+func deliver(d *model.WebhookDelivery) {
+	d.Attempts++
+	code, err := send(d)
+	d.ResponseCode = code
+	if err == nil {
+		d.Status = model.DeliverySucceeded
+		d.Error = ""
+		d.NextAttemptAt = nil
+	} else {
+		d.Error = err.Error()
+		if len(d.Error) > maxErrorLength {
+			d.Error = d.Error[:maxErrorLength]
+		}
+		if d.Attempts >= MaxAttempts {
+			d.Status = model.DeliveryFailed
+			d.NextAttemptAt = nil
+		} else {
+			t := time.Now().Add(backoff(d.Attempts))
+			d.NextAttemptAt = &t
+		}
+		log.Debug().Err(err).Str("URL", d.Webhook.URL).Uint("attempts", d.Attempts).Msg("Webhook delivery failed")
+	}
+	if err := model.DB.Omit("Webhook").Save(d).Error; err != nil {
+		log.Error().Err(err).Msg("Failed to save webhook delivery")
+	}
+}
+
+// backoff returns the delay before the next attempt: 1m, 4m, 16m, ...
+func backoff(attempts uint) time.Duration {
+	return retryInterval << (2 * (attempts - 1))
+}
+
+func send(d *model.WebhookDelivery) (int, error) {
+	payload := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, d.Webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Omnom-Webhook")
+	req.Header.Set(EventHeader, string(d.Event))
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(d.Webhook.Secret, payload))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
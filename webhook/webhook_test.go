@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asciimoo/omnom/config"
+	"github.com/asciimoo/omnom/model"
+	"github.com/asciimoo/omnom/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliver(t *testing.T) {
+	err := model.Init(&config.Config{
+		DB: config.DB{
+			Type:       "sqlite",
+			Connection: ":memory:",
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Nil(t, model.CreateUser("webhook", "webhook@test.com")) {
+		return
+	}
+	u := model.GetUser("webhook")
+	// the test server listens on a loopback address
+	Configure(config.Webhooks{AllowPrivateNetworks: true})
+
+	var payload model.WebhookPayload
+	status := http.StatusInternalServerError
+	var w *model.Webhook
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, Sign(w.Secret, body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, string(model.EventBookmarkCreated), r.Header.Get(EventHeader))
+		assert.Nil(t, json.Unmarshal(body, &payload))
+		rw.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	w, err = model.CreateWebhook(u.ID, srv.URL, []model.WebhookEvent{model.EventBookmarkCreated})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, _, err = model.GetOrCreateBookmark(u, "https://example.com/", "Example", "a", "", "", "", "", "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	// existing bookmarks do not trigger events
+	_, _, err = model.GetOrCreateBookmark(u, "https://example.com/", "Example", "", "", "", "", "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, Deliver())
+	ds, err := model.GetWebhookDeliveries(u.ID, 10)
+	assert.Nil(t, err)
+	if !assert.Len(t, ds, 1) {
+		return
+	}
+	assert.Equal(t, model.DeliveryPending, ds[0].Status)
+	assert.Equal(t, uint(1), ds[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, ds[0].ResponseCode)
+
+	status = http.StatusOK
+	model.DB.Model(ds[0]).Update("next_attempt_at", ds[0].CreatedAt)
+	assert.Nil(t, Deliver())
+	ds, _ = model.GetWebhookDeliveries(u.ID, 10)
+	assert.Equal(t, model.DeliverySucceeded, ds[0].Status)
+	assert.Equal(t, uint(2), ds[0].Attempts)
+	assert.Equal(t, "https://example.com/", payload.Data.Bookmark.URL)
+	assert.Equal(t, []string{"a"}, payload.Data.Bookmark.Tags)
+
+	Configure(config.Webhooks{})
+	_, _, err = model.GetOrCreateBookmark(u, "https://example.com/private", "Example", "", "", "", "", "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, Deliver())
+	ds, _ = model.GetWebhookDeliveries(u.ID, 10)
+	assert.Equal(t, model.DeliveryPending, ds[0].Status)
+	assert.Equal(t, 0, ds[0].ResponseCode)
+	assert.Contains(t, ds[0].Error, utils.ErrNonPublicAddress.Error())
+}